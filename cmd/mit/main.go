@@ -15,6 +15,13 @@ var version = "dev"
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 
+	// Restore the default signal handling once shutdown begins, so that a second signal
+	// terminates the process immediately instead of waiting for connections to drain.
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+
 	command := cmd.InitCommand(cmd.BuildInfo{
 		DefaultServer: defaultServer,
 		Version:       version,
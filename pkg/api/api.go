@@ -6,7 +6,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"time"
 
 	"log/slog"
@@ -15,11 +18,21 @@ import (
 	"github.com/ksysoev/make-it-public/pkg/api/middleware"
 	"github.com/ksysoev/make-it-public/pkg/core"
 	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/make-it-public/pkg/metrics"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
+// Config holds the management API settings.
+// Cert and Key enable TLS, ClientCA additionally accepts client certificates signed by it.
+// APIKeys and Clients are the admin credentials and the scopes they grant, when neither API keys nor a client CA
+// are configured the API is not authenticated.
 type Config struct {
-	Listen string `mapstructure:"listen"`
+	Listen   string       `mapstructure:"listen"`
+	Cert     string       `mapstructure:"cert"`
+	Key      string       `mapstructure:"key"`
+	ClientCA string       `mapstructure:"client_ca"`
+	APIKeys  []APIKey     `mapstructure:"api_keys"`
+	Clients  []ClientCert `mapstructure:"clients"`
 }
 
 type API struct {
@@ -28,16 +41,39 @@ type API struct {
 }
 
 type Service interface {
-	GenerateToken(ctx context.Context, keyID string, ttl int, tokenType token.TokenType) (*token.Token, error)
+	GenerateToken(ctx context.Context, keyID string, ttl int, tokenType token.TokenType, policy token.Policy, meta token.Meta) (*token.Token, error)
+	ListTokens(ctx context.Context, filter core.TokenFilter) ([]token.Info, string, error)
+	GetToken(ctx context.Context, keyID string) (token.Info, error)
+	SetTokenTTL(ctx context.Context, keyID string, ttl int) (token.Info, error)
 	DeleteToken(ctx context.Context, tokenID string) error
+	ListTunnels(ctx context.Context) []core.Tunnel
+	DisconnectTunnel(ctx context.Context, keyID string) error
+	AttachDomain(ctx context.Context, keyID, domain string) error
+	DetachDomain(ctx context.Context, keyID, domain string) error
+	ListDomains(ctx context.Context, keyID string) ([]string, error)
 	CheckHealth(ctx context.Context) error
 }
 
 const (
-	HealthCheckEndpoint   = "GET /health"
-	GenerateTokenEndpoint = "POST /token"
-	RevokeTokenEndpoint   = "DELETE /token/{keyID}" //nolint:gosec // false positive, no hardcoded credentials
-	SwaggerEndpoint       = "/swagger/"
+	HealthCheckEndpoint      = "GET /health"
+	MetricsEndpoint          = "GET /metrics"
+	GenerateTokenEndpoint    = "POST /token"
+	RevokeTokenEndpoint      = "DELETE /token/{keyID}" //nolint:gosec // false positive, no hardcoded credentials
+	ListTokensEndpoint       = "GET /token"
+	GetTokenEndpoint         = "GET /token/{keyID}"
+	UpdateTokenEndpoint      = "PATCH /token/{keyID}"
+	ListDomainsEndpoint      = "GET /token/{keyID}/domains"
+	AttachDomainEndpoint     = "PUT /token/{keyID}/domains/{domain}"
+	DetachDomainEndpoint     = "DELETE /token/{keyID}/domains/{domain}"
+	ListTunnelsEndpoint      = "GET /tunnels"
+	DisconnectTunnelEndpoint = "DELETE /tunnels/{keyID}"
+	SwaggerEndpoint          = "/swagger/"
+
+	errMsgInvalidTokenType = "Invalid token type: must be 'web', 'tcp', 'udp' or a combination such as 'web,tcp'"
+
+	shutdownTimeout   = 5 * time.Second
+	defaultTokenLimit = 100
+	maxTokenLimit     = 1000
 )
 
 // New initializes and returns a new API instance configured with the provided Config and Service.
@@ -55,43 +91,98 @@ func New(cfg Config, svc Service) *API {
 // @description This is the API for managing MIT server resources.
 // @host localhost:8082
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description API key as "Bearer <key>", required when API keys are configured.
 
 // Run starts the API server and handles incoming HTTP requests.
 // It configures the HTTP routes, middleware, and server settings based on the API's configuration.
+// Token, tunnel and metrics endpoints require credentials granting the matching scope when authentication is configured.
 // Accepts ctx to gracefully shut down the server when context is canceled.
-// Returns error if the server fails to start or encounters issues during runtime.
+// Returns error if the authentication or TLS configuration is invalid, or if the server fails to start or
+// encounters issues during runtime.
 func (a *API) Run(ctx context.Context) error {
-	router := http.NewServeMux()
-	genToken := middleware.Metrics()(http.HandlerFunc(a.generateTokenHandler))
-	revokeToken := middleware.Metrics()(http.HandlerFunc(a.RevokeTokenHandler))
+	authn, err := newAuthenticator(a.config)
+	if err != nil {
+		return fmt.Errorf("invalid API authentication config: %w", err)
+	}
 
-	router.Handle(GenerateTokenEndpoint, genToken)
-	router.Handle(RevokeTokenEndpoint, revokeToken)
-	router.HandleFunc(HealthCheckEndpoint, a.healthCheckHandler)
-	router.HandleFunc(SwaggerEndpoint, httpSwagger.WrapHandler)
+	tlsCfg, err := tlsConfig(a.config)
+	if err != nil {
+		return fmt.Errorf("invalid API TLS config: %w", err)
+	}
+
+	if !authn.enabled {
+		slog.WarnContext(ctx, "Management API authentication is disabled, anyone who can reach it can manage tokens")
+	}
 
 	server := &http.Server{
 		Addr:              a.config.Listen,
 		ReadHeaderTimeout: 5 * time.Second,
 		WriteTimeout:      5 * time.Second,
-		Handler:           router,
+		Handler:           a.router(authn),
+		TLSConfig:         tlsCfg,
 	}
 
 	go func() {
 		<-ctx.Done()
 
-		_ = server.Close()
+		shutdown(server)
 	}()
 
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	if tlsCfg != nil {
+		err = server.ListenAndServeTLS(a.config.Cert, a.config.Key)
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if err != http.ErrServerClosed {
 		return err
 	}
 
 	return nil
 }
 
+// router returns the handler serving the API routes, with token, tunnel and metrics endpoints guarded by authn.
+func (a *API) router(authn *authenticator) http.Handler {
+	router := http.NewServeMux()
+	guard := func(scope string, h http.HandlerFunc) http.Handler {
+		return middleware.Metrics()(authn.require(scope)(h))
+	}
+
+	router.Handle(GenerateTokenEndpoint, guard(ScopeTokenCreate, a.generateTokenHandler))
+	router.Handle(RevokeTokenEndpoint, guard(ScopeTokenRevoke, a.RevokeTokenHandler))
+	router.Handle(ListTokensEndpoint, guard(ScopeTokenRead, a.listTokensHandler))
+	router.Handle(GetTokenEndpoint, guard(ScopeTokenRead, a.getTokenHandler))
+	router.Handle(UpdateTokenEndpoint, guard(ScopeTokenUpdate, a.updateTokenHandler))
+	router.Handle(ListDomainsEndpoint, guard(ScopeTokenRead, a.listDomainsHandler))
+	router.Handle(AttachDomainEndpoint, guard(ScopeTokenUpdate, a.attachDomainHandler))
+	router.Handle(DetachDomainEndpoint, guard(ScopeTokenUpdate, a.detachDomainHandler))
+	router.Handle(ListTunnelsEndpoint, guard(ScopeTunnelsRead, a.listTunnelsHandler))
+	router.Handle(DisconnectTunnelEndpoint, guard(ScopeTunnelsDisconnect, a.disconnectTunnelHandler))
+	router.HandleFunc(HealthCheckEndpoint, a.healthCheckHandler)
+	// Metrics are scraped every few seconds, they are not logged like the other requests.
+	router.Handle(MetricsEndpoint, authn.require(ScopeMetricsRead)(http.HandlerFunc(a.metricsHandler)))
+	router.HandleFunc(SwaggerEndpoint, httpSwagger.WrapHandler)
+
+	return router
+}
+
+// shutdown gracefully stops the server, giving in-flight requests up to shutdownTimeout to complete
+// before the remaining connections are closed.
+func shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		_ = server.Close()
+	}
+}
+
 // healthCheckHandler handles health check requests and validates the service's health status.
 // It queries the service's health and responds with "healthy" if all checks are successful.
+// Returns HTTP 503 while the server is draining connections before a shutdown.
 // Returns HTTP 500 if the health check fails or if writing the response encounters an error.
 // @Summary Health Check
 // @Description Returns the health status of the API.
@@ -99,9 +190,15 @@ func (a *API) Run(ctx context.Context) error {
 // @Produce text/plain
 // @Success 200 {string} string "healthy"
 // @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Service Unavailable"
 // @Router /health [get]
 func (a *API) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	if err := a.svc.CheckHealth(r.Context()); err != nil {
+		if errors.Is(err, core.ErrServerDraining) {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
 		slog.ErrorContext(r.Context(), "Health check failed", "error", err)
 
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -120,21 +217,50 @@ func (a *API) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// metricsHandler exposes server metrics in the Prometheus text exposition format.
+// It renders control connections, pending requests, edge responses, TCP and UDP port pool utilisation,
+// auth verification latency, proxied traffic counters and the Go runtime and process metrics.
+// It requires the metrics:read scope when authentication is configured.
+// @Summary Metrics
+// @Description Returns server metrics in the Prometheus text exposition format.
+// @Tags Metrics
+// @Produce text/plain
+// @Success 200 {string} string "Prometheus metrics"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Security BearerAuth
+// @Router /metrics [get]
+func (a *API) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	metrics.Handler().ServeHTTP(w, r)
+}
+
 // generateTokenHandler is an endpoint to create API token.
 // It optionally accepts a key ID, which is automatically generated if not provided.
 // It also optionally accepts a TTL for API token, which is set to a default value if not provided.
-// It accepts a token type (web or tcp), which defaults to web if not provided.
+// It accepts a token type (web, tcp or udp), which defaults to web if not provided.
+// A multi-purpose token usable for several tunnel types is requested with a list of types such as "web,tcp".
+// It optionally accepts a traffic quota limiting the bytes the tunnel may proxy per period.
+// It optionally accepts the custom subdomains clients may request for web tunnels, "*" allows any subdomain.
+// It optionally accepts an access restriction for web tunnels: basic auth credentials or a key required in a header.
+// It optionally accepts allow and deny lists of end-user IP addresses or CIDR prefixes.
+// It optionally accepts header rewrite rules for web tunnels: a Host override and headers to set or remove in
+// requests and responses.
+// It optionally accepts request rate limits for web tunnels overriding the limits configured on the server.
+// It optionally accepts a description and an owner stored with the token.
 // As a part of response, it returns the key ID, generated token, TTL in seconds, and token type.
 // @Summary Generate Token
-// @Description Generates an API token with an optional key ID, TTL, and type.
+// @Description Generates an API token with an optional key ID, TTL, type, traffic quota, custom subdomains, access restriction, IP filter, header rewrite rules, rate limits, description, and owner.
 // @Tags Token
 // @Accept json
 // @Produce json
 // @Param request body GenerateTokenRequest true "Generate Token Request"
 // @Success 201 {object} GenerateTokenResponse
 // @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
 // @Failure 409 {string} string "Duplicate token ID"
 // @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
 // @Router /token [post]
 func (a *API) generateTokenHandler(w http.ResponseWriter, r *http.Request) {
 	var req GenerateTokenRequest
@@ -150,20 +276,67 @@ func (a *API) generateTokenHandler(w http.ResponseWriter, r *http.Request) {
 		tokenTypeStr = "web"
 	}
 
-	// Map string to TokenType
-	var tokenType token.TokenType
-
-	switch tokenTypeStr {
-	case "web":
-		tokenType = token.TokenTypeWeb
-	case "tcp":
-		tokenType = token.TokenTypeTCP
-	default:
-		http.Error(w, "Invalid token type: must be 'web' or 'tcp'", http.StatusBadRequest)
+	tokenType, err := token.ParseTokenType(tokenTypeStr)
+	if err != nil {
+		http.Error(w, errMsgInvalidTokenType, http.StatusBadRequest)
 		return
 	}
 
-	t, err := a.svc.GenerateToken(r.Context(), req.KeyID, req.TTL, tokenType)
+	policy := token.Policy{Subdomains: req.Subdomains}
+
+	if req.Quota != nil {
+		quota, err := token.NewQuota(req.Quota.Bytes, time.Duration(req.Quota.Period)*time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policy.Quota = quota
+	}
+
+	if req.Access != nil {
+		access, err := token.NewAccess(req.Access.Username, req.Access.Password, req.Access.Header, req.Access.Key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policy.Access = access
+	}
+
+	if req.IPFilter != nil {
+		filter, err := token.NewIPFilter(req.IPFilter.Allow, req.IPFilter.Deny)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policy.IPFilter = filter
+	}
+
+	if req.Rewrite != nil {
+		rewrite, err := token.NewRewrite(token.Rewrite(*req.Rewrite))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policy.Rewrite = rewrite
+	}
+
+	if req.RateLimit != nil {
+		rateLimit, err := token.NewRateLimit(token.RateLimit(*req.RateLimit))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policy.RateLimit = rateLimit
+	}
+
+	meta := token.Meta{Description: req.Description, Owner: req.Owner}
+
+	t, err := a.svc.GenerateToken(r.Context(), req.KeyID, req.TTL, tokenType, policy, meta)
 
 	switch {
 	case errors.Is(err, token.ErrTokenInvalid):
@@ -178,6 +351,10 @@ func (a *API) generateTokenHandler(w http.ResponseWriter, r *http.Request) {
 	case errors.Is(err, token.ErrInvalidTokenType):
 		http.Error(w, token.ErrInvalidTokenType.Error(), http.StatusBadRequest)
 		return
+	case errors.Is(err, token.ErrInvalidSubdomain), errors.Is(err, token.ErrInvalidAccess), errors.Is(err, token.ErrInvalidRewrite),
+		errors.Is(err, token.ErrInvalidRateLimit):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	case errors.Is(err, core.ErrDuplicateTokenID):
 		http.Error(w, "Duplicate token ID", http.StatusConflict)
 		return
@@ -189,10 +366,35 @@ func (a *API) generateTokenHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := GenerateTokenResponse{
-		Token: t.Encode(),
-		KeyID: t.ID,
-		TTL:   int(t.TTL.Seconds()),
-		Type:  t.Type.String(),
+		Token:       t.Encode(),
+		KeyID:       t.ID,
+		TTL:         int(t.TTL.Seconds()),
+		Type:        t.Type.String(),
+		Description: t.Meta.Description,
+		Owner:       t.Meta.Owner,
+		Subdomains:  t.Policy.Subdomains,
+	}
+
+	if q := t.Policy.Quota; q != nil {
+		resp.Quota = &QuotaSchema{Bytes: q.Bytes, Period: int(q.Period.Seconds())}
+	}
+
+	if a := t.Policy.Access; a != nil {
+		resp.Access = &AccessSchema{Username: a.Username, Header: a.Header}
+	}
+
+	if f := t.Policy.IPFilter; f != nil {
+		resp.IPFilter = &IPFilterSchema{Allow: prefixStrings(f.Allow), Deny: prefixStrings(f.Deny)}
+	}
+
+	if rw := t.Policy.Rewrite; rw != nil {
+		rewrite := RewriteSchema(*rw)
+		resp.Rewrite = &rewrite
+	}
+
+	if rl := t.Policy.RateLimit; rl != nil {
+		rateLimit := RateLimitSchema(*rl)
+		resp.RateLimit = &rateLimit
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -209,14 +411,18 @@ func (a *API) generateTokenHandler(w http.ResponseWriter, r *http.Request) {
 // RevokeTokenHandler revokes an API token based on the provided key ID in the request path.
 // It checks the presence of the key ID and returns an HTTP error if missing.
 // Deletes the token and returns a no-content response on success or an internal server error if deletion fails.
+// Clients connected with the token are disconnected, on every node in cluster mode.
 // @Summary Revoke Token
-// @Description Revokes an API token using the provided Key ID.
+// @Description Revokes an API token using the provided Key ID and disconnects the clients connected with it.
 // @Tags Token
 // @Param keyID path string true "API Key ID"
 // @Success 204
 // @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
 // @Failure 404 {string} string "Token not found"
 // @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
 // @Router /token/{keyID} [delete]
 func (a *API) RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
 	keyID := r.PathValue("keyID")
@@ -241,3 +447,405 @@ func (a *API) RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// listTokensHandler responds with a page of stored tokens ordered by key ID, optionally filtered by type and owner.
+// Secrets are never returned. The next field of the response is passed as the after parameter to get the following page.
+// Returns HTTP 400 if the type or the limit is invalid.
+// @Summary List Tokens
+// @Description Lists the metadata of stored tokens, optionally filtered by type and owner, one page at a time.
+// @Tags Token
+// @Produce json
+// @Param type query string false "Tunnel type the tokens can be used for: web, tcp or udp"
+// @Param owner query string false "Token owner"
+// @Param after query string false "Return tokens with a key ID after this one"
+// @Param limit query int false "Maximum number of tokens to return, defaults to 100, at most 1000"
+// @Success 200 {object} TokenListResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
+// @Router /token [get]
+func (a *API) listTokensHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := core.TokenFilter{
+		Owner: query.Get("owner"),
+		After: query.Get("after"),
+		Limit: defaultTokenLimit,
+	}
+
+	if typ := query.Get("type"); typ != "" {
+		tokenType, err := token.ParseTokenType(typ)
+		if err != nil {
+			http.Error(w, errMsgInvalidTokenType, http.StatusBadRequest)
+			return
+		}
+
+		filter.Type = tokenType
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxTokenLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit: must be between 1 and %d", maxTokenLimit), http.StatusBadRequest)
+			return
+		}
+
+		filter.Limit = n
+	}
+
+	tokens, next, err := a.svc.ListTokens(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list tokens", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	resp := TokenListResponse{
+		Tokens: make([]TokenSchema, len(tokens)),
+		Next:   next,
+	}
+
+	for i, t := range tokens {
+		resp.Tokens[i] = newTokenSchema(t)
+	}
+
+	writeJSON(w, r, resp)
+}
+
+// getTokenHandler responds with the metadata of the token identified by the key ID in the request path.
+// The secret is never returned.
+// Returns HTTP 404 if the token does not exist.
+// @Summary Get Token
+// @Description Returns the metadata of a stored token using the provided Key ID.
+// @Tags Token
+// @Produce json
+// @Param keyID path string true "API Key ID"
+// @Success 200 {object} TokenSchema
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
+// @Router /token/{keyID} [get]
+func (a *API) getTokenHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("keyID")
+
+	if keyID == "" {
+		http.Error(w, "Key ID is required", http.StatusBadRequest)
+		return
+	}
+
+	info, err := a.svc.GetToken(r.Context(), keyID)
+
+	switch {
+	case errors.Is(err, core.ErrTokenNotFound):
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to get token", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	writeJSON(w, r, newTokenSchema(info))
+}
+
+// updateTokenHandler changes the expiration of the token identified by the key ID in the request path.
+// The TTL in the request body is counted in seconds from now, 0 makes the token never expire.
+// The token keeps its key ID and secret, so clients do not need a new token.
+// Returns HTTP 400 if the TTL is missing or negative and HTTP 404 if the token does not exist.
+// @Summary Update Token
+// @Description Changes the expiration of a token without reissuing it. A TTL of 0 makes the token never expire.
+// @Tags Token
+// @Accept json
+// @Produce json
+// @Param keyID path string true "API Key ID"
+// @Param request body UpdateTokenRequest true "Update Token Request"
+// @Success 200 {object} TokenSchema
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
+// @Router /token/{keyID} [patch]
+func (a *API) updateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("keyID")
+
+	if keyID == "" {
+		http.Error(w, "Key ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TTL == nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	info, err := a.svc.SetTokenTTL(r.Context(), keyID, *req.TTL)
+
+	switch {
+	case errors.Is(err, token.ErrInvalidTokenTTL):
+		http.Error(w, token.ErrInvalidTokenTTL.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, core.ErrTokenNotFound):
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to update token", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	writeJSON(w, r, newTokenSchema(info))
+}
+
+// listDomainsHandler responds with the custom domains attached to the token identified by the key ID in the request path.
+// Returns HTTP 404 if the token does not exist.
+// @Summary List Domains
+// @Description Lists the custom domains attached to a token, sorted by name.
+// @Tags Domain
+// @Produce json
+// @Param keyID path string true "API Key ID"
+// @Success 200 {object} DomainListResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
+// @Router /token/{keyID}/domains [get]
+func (a *API) listDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("keyID")
+
+	if keyID == "" {
+		http.Error(w, "Key ID is required", http.StatusBadRequest)
+		return
+	}
+
+	domains, err := a.svc.ListDomains(r.Context(), keyID)
+
+	switch {
+	case errors.Is(err, core.ErrTokenNotFound):
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to list domains", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	writeJSON(w, r, DomainListResponse{Domains: domains})
+}
+
+// attachDomainHandler attaches the custom domain in the request path to the web token identified by the key ID,
+// once the ownership of the domain is verified with a DNS TXT record or an HTTP challenge.
+// Returns HTTP 400 if the domain is invalid or the token does not serve web tunnels, HTTP 404 if the token does
+// not exist, HTTP 409 if the domain is attached to another token and HTTP 422 if the ownership cannot be verified.
+// @Summary Attach Domain
+// @Description Attaches a custom domain to a web token, so that requests for the domain are served by its tunnel. The ownership of the domain is proven with a TXT record "_mit-challenge.<domain>" or the resource "http://<domain>/.well-known/mit-challenge" holding "mit-verification=<keyID>".
+// @Tags Domain
+// @Param keyID path string true "API Key ID"
+// @Param domain path string true "Custom domain"
+// @Success 204
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Token not found"
+// @Failure 409 {string} string "Domain is attached to another token"
+// @Failure 422 {string} string "Domain ownership could not be verified"
+// @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
+// @Router /token/{keyID}/domains/{domain} [put]
+func (a *API) attachDomainHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("keyID")
+	domain := r.PathValue("domain")
+
+	if keyID == "" || domain == "" {
+		http.Error(w, "Key ID and domain are required", http.StatusBadRequest)
+		return
+	}
+
+	err := a.svc.AttachDomain(r.Context(), keyID, domain)
+
+	switch {
+	case errors.Is(err, token.ErrInvalidDomain):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, core.ErrTokenNotFound):
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	case errors.Is(err, core.ErrDomainTaken):
+		http.Error(w, "Domain is attached to another token", http.StatusConflict)
+		return
+	case errors.Is(err, core.ErrDomainNotVerified):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to attach domain", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// detachDomainHandler detaches the custom domain in the request path from the token identified by the key ID.
+// Returns HTTP 404 if the domain is not attached to the token.
+// @Summary Detach Domain
+// @Description Detaches a custom domain from a token, requests for the domain are no longer served.
+// @Tags Domain
+// @Param keyID path string true "API Key ID"
+// @Param domain path string true "Custom domain"
+// @Success 204
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Domain not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
+// @Router /token/{keyID}/domains/{domain} [delete]
+func (a *API) detachDomainHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("keyID")
+	domain := r.PathValue("domain")
+
+	if keyID == "" || domain == "" {
+		http.Error(w, "Key ID and domain are required", http.StatusBadRequest)
+		return
+	}
+
+	err := a.svc.DetachDomain(r.Context(), keyID, domain)
+
+	switch {
+	case errors.Is(err, core.ErrDomainNotFound):
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to detach domain", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTunnelsHandler responds with the control connections of the MIT clients connected to this server, oldest first.
+// Every control connection is listed separately, so a client connected several times appears several times.
+// @Summary List Tunnels
+// @Description Lists the live tunnels of this server with their key ID, type, protocol version, client address, connection time, public endpoint, and number of in-flight connections.
+// @Tags Tunnel
+// @Produce json
+// @Success 200 {object} TunnelListResponse
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Security BearerAuth
+// @Router /tunnels [get]
+func (a *API) listTunnelsHandler(w http.ResponseWriter, r *http.Request) {
+	tunnels := a.svc.ListTunnels(r.Context())
+
+	resp := TunnelListResponse{Tunnels: make([]TunnelSchema, len(tunnels))}
+
+	for i, t := range tunnels {
+		resp.Tunnels[i] = TunnelSchema{
+			KeyID:       t.KeyID,
+			Type:        t.Type.String(),
+			Protocol:    t.Protocol,
+			RemoteAddr:  t.RemoteAddr,
+			ConnectedAt: t.ConnectedAt,
+			Endpoint:    t.Endpoint,
+			InFlight:    t.InFlight,
+		}
+	}
+
+	writeJSON(w, r, resp)
+}
+
+// disconnectTunnelHandler closes the tunnels of the client identified by the key ID in the request path.
+// The token is not revoked, so the client may connect again.
+// Returns HTTP 404 if the client is not connected to this server.
+// @Summary Disconnect Tunnel
+// @Description Disconnects the client connected with the provided Key ID without revoking its token.
+// @Tags Tunnel
+// @Param keyID path string true "API Key ID"
+// @Success 204
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Tunnel not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Security BearerAuth
+// @Router /tunnels/{keyID} [delete]
+func (a *API) disconnectTunnelHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("keyID")
+
+	if keyID == "" {
+		http.Error(w, "Key ID is required", http.StatusBadRequest)
+		return
+	}
+
+	err := a.svc.DisconnectTunnel(r.Context(), keyID)
+
+	switch {
+	case errors.Is(err, core.ErrTunnelNotFound):
+		http.Error(w, "Tunnel not found", http.StatusNotFound)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Failed to disconnect tunnel", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes v as a JSON response with HTTP 200.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode response", "error", err)
+	}
+}
+
+// newTokenSchema converts the token metadata info to its API representation.
+func newTokenSchema(info token.Info) TokenSchema {
+	s := TokenSchema{
+		KeyID:       info.ID,
+		CreatedAt:   info.CreatedAt,
+		ExpiresAt:   info.ExpiresAt,
+		Description: info.Description,
+		Owner:       info.Owner,
+	}
+
+	if info.Type != "" {
+		s.Type = info.Type.String()
+	}
+
+	return s
+}
+
+// prefixStrings returns the string representations of prefixes.
+func prefixStrings(prefixes []netip.Prefix) []string {
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	s := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		s[i] = p.String()
+	}
+
+	return s
+}
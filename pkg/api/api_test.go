@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -14,6 +15,7 @@ import (
 	"github.com/ksysoev/make-it-public/pkg/core/token"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockResponseWriter struct {
@@ -53,6 +55,36 @@ func TestHealthCheckHandler_Error(t *testing.T) {
 	assert.Equal(t, rr.Body.String(), "Internal Server Error\n", "Body body does not match expected")
 }
 
+func TestHealthCheckHandler_Draining(t *testing.T) {
+	svc := NewMockService(t)
+	svc.EXPECT().CheckHealth(mock.Anything).Return(core.ErrServerDraining).Once()
+
+	api := New(Config{Listen: ":0"}, svc)
+	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(api.healthCheckHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "Expected status code 503")
+}
+
+func TestMetricsHandler(t *testing.T) {
+	svc := NewMockService(t)
+
+	api := New(Config{Listen: ":0"}, svc)
+	req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(api.metricsHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code, "Expected status code 200")
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rr.Body.String(), "# TYPE mit_pending_connection_requests gauge")
+	assert.Contains(t, rr.Body.String(), "# TYPE mit_auth_verify_duration_seconds histogram")
+}
+
 func TestGenerateTokenHandler(t *testing.T) {
 	auth := NewMockService(t)
 	api := New(Config{}, auth)
@@ -68,7 +100,7 @@ func TestGenerateTokenHandler(t *testing.T) {
 	})
 
 	t.Run("Success token generation", func(t *testing.T) {
-		auth.EXPECT().GenerateToken(mock.Anything, mock.Anything, 3600, mock.Anything).Return(&token.Token{
+		auth.EXPECT().GenerateToken(mock.Anything, mock.Anything, 3600, mock.Anything, token.Policy{}, token.Meta{}).Return(&token.Token{
 			ID:     "random-key-id",
 			Secret: "test-token",
 			TTL:    time.Hour,
@@ -95,7 +127,7 @@ func TestGenerateTokenHandler(t *testing.T) {
 	})
 
 	t.Run("Giving 0 TTL defaults to TTL of one hour", func(t *testing.T) {
-		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 0, mock.Anything).Return(&token.Token{
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 0, mock.Anything, token.Policy{}, token.Meta{}).Return(&token.Token{
 			ID:     "test-key-id",
 			Secret: "test-token",
 			TTL:    time.Hour,
@@ -122,8 +154,292 @@ func TestGenerateTokenHandler(t *testing.T) {
 		assert.Equal(t, 3600, response.TTL)
 	})
 
+	t.Run("Success token generation with quota", func(t *testing.T) {
+		quota := &token.Quota{Bytes: 5 << 30, Period: 24 * time.Hour}
+
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, mock.Anything, token.Policy{Quota: quota}, token.Meta{}).Return(&token.Token{
+			ID:     "test-key-id",
+			Secret: "test-token",
+			TTL:    time.Hour,
+			Policy: token.Policy{Quota: quota},
+		}, nil).Once()
+
+		requestBody := GenerateTokenRequest{
+			KeyID: "test-key-id",
+			TTL:   3600,
+			Quota: &QuotaSchema{Bytes: 5 << 30},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response GenerateTokenResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, &QuotaSchema{Bytes: 5 << 30, Period: 86400}, response.Quota)
+	})
+
+	t.Run("Invalid quota", func(t *testing.T) {
+		requestBody := GenerateTokenRequest{
+			KeyID: "test-key-id",
+			Quota: &QuotaSchema{Bytes: -1},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), token.ErrInvalidQuota.Error())
+	})
+
+	t.Run("Success token generation with subdomains", func(t *testing.T) {
+		policy := token.Policy{Subdomains: []string{"payments-demo"}}
+
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenTypeWeb, policy, token.Meta{}).Return(&token.Token{
+			ID:     "test-key-id",
+			Secret: "test-token",
+			TTL:    time.Hour,
+			Type:   token.TokenTypeWeb,
+			Policy: policy,
+		}, nil).Once()
+
+		requestBody := GenerateTokenRequest{
+			KeyID:      "test-key-id",
+			TTL:        3600,
+			Subdomains: []string{"payments-demo"},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response GenerateTokenResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"payments-demo"}, response.Subdomains)
+	})
+
+	t.Run("Invalid subdomains", func(t *testing.T) {
+		policy := token.Policy{Subdomains: []string{"Not_A_Label"}}
+
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenTypeWeb, policy, token.Meta{}).
+			Return(nil, token.ErrInvalidSubdomain).Once()
+
+		requestBody := GenerateTokenRequest{
+			KeyID:      "test-key-id",
+			TTL:        3600,
+			Subdomains: []string{"Not_A_Label"},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), token.ErrInvalidSubdomain.Error())
+	})
+
+	t.Run("Success token generation with access", func(t *testing.T) {
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenTypeWeb, mock.MatchedBy(func(p token.Policy) bool {
+			return p.Access != nil && p.Access.CheckBasic("admin", "secret")
+		}), token.Meta{}).Return(&token.Token{
+			ID:     "test-key-id",
+			Secret: "test-token",
+			TTL:    time.Hour,
+			Type:   token.TokenTypeWeb,
+			Policy: token.Policy{Access: &token.Access{Username: "admin", PasswordHash: "hash"}},
+		}, nil).Once()
+
+		requestBody := GenerateTokenRequest{
+			KeyID:  "test-key-id",
+			TTL:    3600,
+			Access: &AccessSchema{Username: "admin", Password: "secret"},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response GenerateTokenResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, &AccessSchema{Username: "admin"}, response.Access)
+	})
+
+	t.Run("Invalid access", func(t *testing.T) {
+		requestBody := GenerateTokenRequest{
+			KeyID:  "test-key-id",
+			Access: &AccessSchema{Username: "admin"},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), token.ErrInvalidAccess.Error())
+	})
+
+	t.Run("Success token generation with rewrite", func(t *testing.T) {
+		rewrite := &token.Rewrite{Host: "localhost:3000", RemoveResponseHeaders: []string{"Server"}}
+
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenTypeWeb, token.Policy{Rewrite: rewrite}, token.Meta{}).Return(&token.Token{
+			ID:     "test-key-id",
+			Secret: "test-token",
+			TTL:    time.Hour,
+			Type:   token.TokenTypeWeb,
+			Policy: token.Policy{Rewrite: rewrite},
+		}, nil).Once()
+
+		requestBody := GenerateTokenRequest{
+			KeyID:   "test-key-id",
+			TTL:     3600,
+			Rewrite: &RewriteSchema{Host: "localhost:3000", RemoveResponseHeaders: []string{"server"}},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response GenerateTokenResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, &RewriteSchema{Host: "localhost:3000", RemoveResponseHeaders: []string{"Server"}}, response.Rewrite)
+	})
+
+	t.Run("Invalid rewrite", func(t *testing.T) {
+		requestBody := GenerateTokenRequest{
+			KeyID:   "test-key-id",
+			Rewrite: &RewriteSchema{SetRequestHeaders: map[string]string{"Transfer-Encoding": "chunked"}},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), token.ErrInvalidRewrite.Error())
+	})
+
+	t.Run("Success token generation with rate limit", func(t *testing.T) {
+		rateLimit := &token.RateLimit{ClientRate: 2, ClientBurst: 2}
+
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenTypeWeb, token.Policy{RateLimit: rateLimit}, token.Meta{}).Return(&token.Token{
+			ID:     "test-key-id",
+			Secret: "test-token",
+			TTL:    time.Hour,
+			Type:   token.TokenTypeWeb,
+			Policy: token.Policy{RateLimit: rateLimit},
+		}, nil).Once()
+
+		requestBody := GenerateTokenRequest{
+			KeyID:     "test-key-id",
+			TTL:       3600,
+			RateLimit: &RateLimitSchema{ClientRate: 2},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response GenerateTokenResponse
+
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, &RateLimitSchema{ClientRate: 2, ClientBurst: 2}, response.RateLimit)
+	})
+
+	t.Run("Invalid rate limit", func(t *testing.T) {
+		requestBody := GenerateTokenRequest{
+			KeyID:     "test-key-id",
+			RateLimit: &RateLimitSchema{TunnelRate: -1},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), token.ErrInvalidRateLimit.Error())
+	})
+
+	t.Run("Success token generation with IP filter", func(t *testing.T) {
+		filter, err := token.NewIPFilter([]string{"10.0.0.0/8"}, []string{"10.0.0.1"})
+		require.NoError(t, err)
+
+		policy := token.Policy{IPFilter: filter}
+
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenTypeWeb, policy, token.Meta{}).Return(&token.Token{
+			ID:     "test-key-id",
+			Secret: "test-token",
+			TTL:    time.Hour,
+			Type:   token.TokenTypeWeb,
+			Policy: policy,
+		}, nil).Once()
+
+		requestBody := GenerateTokenRequest{
+			KeyID:    "test-key-id",
+			TTL:      3600,
+			IPFilter: &IPFilterSchema{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1"}},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response GenerateTokenResponse
+
+		err = json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, &IPFilterSchema{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.1/32"}}, response.IPFilter)
+	})
+
+	t.Run("Invalid IP filter", func(t *testing.T) {
+		requestBody := GenerateTokenRequest{
+			KeyID:    "test-key-id",
+			IPFilter: &IPFilterSchema{Allow: []string{"not-an-ip"}},
+		}
+		body, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+		rec := httptest.NewRecorder()
+
+		api.generateTokenHandler(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), token.ErrInvalidIPFilter.Error())
+	})
+
 	t.Run("Token Generation Error", func(t *testing.T) {
-		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, mock.Anything).Return(nil, errors.New("token generation error")).Once()
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, mock.Anything, token.Policy{}, token.Meta{}).Return(nil, errors.New("token generation error")).Once()
 
 		requestBody := GenerateTokenRequest{
 			KeyID: "test-key-id",
@@ -138,7 +454,7 @@ func TestGenerateTokenHandler(t *testing.T) {
 	})
 
 	t.Run("Duplicate Token ID Error", func(t *testing.T) {
-		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, mock.Anything).Return(nil, core.ErrDuplicateTokenID).Once()
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, mock.Anything, token.Policy{}, token.Meta{}).Return(nil, core.ErrDuplicateTokenID).Once()
 
 		requestBody := GenerateTokenRequest{
 			KeyID: "test-key-id",
@@ -154,7 +470,7 @@ func TestGenerateTokenHandler(t *testing.T) {
 	})
 
 	t.Run("JSON Encoding Error", func(_ *testing.T) {
-		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, mock.Anything).Return(&token.Token{
+		auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, mock.Anything, token.Policy{}, token.Meta{}).Return(&token.Token{
 			ID:     "test-key-id",
 			Secret: "test-token",
 			TTL:    3600,
@@ -172,28 +488,453 @@ func TestGenerateTokenHandler(t *testing.T) {
 	})
 }
 
-func TestAPIRun(t *testing.T) {
-	svc := NewMockService(t)
-	api := New(Config{Listen: ":58083"}, svc)
-	ctx, cancel := context.WithCancel(context.Background())
-	done := make(chan struct{})
+func TestGenerateTokenHandler_MultiPurpose(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
 
-	go func() {
-		err := api.Run(ctx)
-		assert.NoError(t, err, "API server should shut down gracefully")
-		close(done)
-	}()
+	auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenType("wt"), token.Policy{}, token.Meta{}).Return(&token.Token{
+		ID:     "test-key-id",
+		Secret: "test-token",
+		TTL:    time.Hour,
+		Type:   token.TokenType("wt"),
+	}, nil).Once()
 
-	cancel()
+	body, _ := json.Marshal(GenerateTokenRequest{KeyID: "test-key-id", TTL: 3600, Type: "web,tcp"})
+	req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
 
-	select {
-	case <-done:
-	case <-time.After(1 * time.Second):
-		assert.Fail(t, "API server did not shut down in time")
-	}
+	api.generateTokenHandler(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response GenerateTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "web,tcp", response.Type)
 }
 
-func TestRevokeTokenHandler(t *testing.T) {
+func TestGenerateTokenHandler_Meta(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	meta := token.Meta{Description: "staging", Owner: "team-a"}
+
+	auth.EXPECT().GenerateToken(mock.Anything, "test-key-id", 3600, token.TokenTypeWeb, token.Policy{}, meta).Return(&token.Token{
+		ID:     "test-key-id",
+		Secret: "test-token",
+		TTL:    time.Hour,
+		Type:   token.TokenTypeWeb,
+		Meta:   meta,
+	}, nil).Once()
+
+	body, _ := json.Marshal(GenerateTokenRequest{KeyID: "test-key-id", TTL: 3600, Description: "staging", Owner: "team-a"})
+	req := httptest.NewRequest(http.MethodPost, "/token", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+
+	api.generateTokenHandler(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response GenerateTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "staging", response.Description)
+	assert.Equal(t, "team-a", response.Owner)
+}
+
+func TestListTokensHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		mockBehavior func()
+		name         string
+		query        string
+		expectedBody string
+		expectedCode int
+	}{
+		{
+			name:  "Default Page",
+			query: "",
+			mockBehavior: func() {
+				auth.EXPECT().ListTokens(mock.Anything, core.TokenFilter{Limit: defaultTokenLimit}).Return([]token.Info{
+					{ID: "key1", Type: token.TokenTypeWeb, CreatedAt: createdAt, Meta: token.Meta{Owner: "team-a"}},
+					{ID: "key2"},
+				}, "", nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"tokens":[{"created_at":"2025-01-02T03:04:05Z","key_id":"key1","type":"web","owner":"team-a"},{"key_id":"key2"}]}` + "\n",
+		},
+		{
+			name:  "Filtered Page",
+			query: "?type=tcp&owner=team-a&after=key1&limit=1",
+			mockBehavior: func() {
+				filter := core.TokenFilter{Type: token.TokenTypeTCP, Owner: "team-a", After: "key1", Limit: 1}
+				auth.EXPECT().ListTokens(mock.Anything, filter).Return([]token.Info{{ID: "key2", Type: token.TokenTypeTCP}}, "key2", nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"next":"key2","tokens":[{"key_id":"key2","type":"tcp"}]}` + "\n",
+		},
+		{
+			name:         "Invalid Type",
+			query:        "?type=sctp",
+			mockBehavior: func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Invalid token type: must be 'web', 'tcp', 'udp' or a combination such as 'web,tcp'\n",
+		},
+		{
+			name:         "Invalid Limit",
+			query:        "?limit=0",
+			mockBehavior: func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Invalid limit: must be between 1 and 1000\n",
+		},
+		{
+			name:  "Internal Error",
+			query: "",
+			mockBehavior: func() {
+				auth.EXPECT().ListTokens(mock.Anything, mock.Anything).Return(nil, "", errors.New("failed to list tokens")).Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			req := httptest.NewRequest(http.MethodGet, "/token"+tt.query, http.NoBody)
+			rec := httptest.NewRecorder()
+
+			api.listTokensHandler(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestGetTokenHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	tests := []struct {
+		mockBehavior func()
+		name         string
+		keyID        string
+		expectedBody string
+		expectedCode int
+	}{
+		{
+			name:         "Missing KeyID",
+			keyID:        "",
+			mockBehavior: func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Key ID is required\n",
+		},
+		{
+			name:  "Token Found",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().GetToken(mock.Anything, "test-key-id").Return(token.Info{
+					ID:        "test-key-id",
+					Type:      token.TokenTypeWeb,
+					ExpiresAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+					Meta:      token.Meta{Description: "staging"},
+				}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"expires_at":"2025-01-02T03:04:05Z","key_id":"test-key-id","type":"web","description":"staging"}` + "\n",
+		},
+		{
+			name:  "Token Not Found",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().GetToken(mock.Anything, "test-key-id").Return(token.Info{}, core.ErrTokenNotFound).Once()
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Token not found\n",
+		},
+		{
+			name:  "Internal Error",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().GetToken(mock.Anything, "test-key-id").Return(token.Info{}, errors.New("failed to get token")).Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			req := httptest.NewRequest(http.MethodGet, "/token/"+tt.keyID, http.NoBody)
+
+			if tt.keyID != "" {
+				req.SetPathValue("keyID", tt.keyID)
+			}
+
+			rec := httptest.NewRecorder()
+
+			api.getTokenHandler(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestListTunnelsHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	tests := []struct {
+		name         string
+		expectedBody string
+		tunnels      []core.Tunnel
+	}{
+		{
+			name:         "No Tunnels",
+			tunnels:      []core.Tunnel{},
+			expectedBody: `{"tunnels":[]}` + "\n",
+		},
+		{
+			name: "Tunnels",
+			tunnels: []core.Tunnel{
+				{
+					KeyID:       "web-key",
+					Type:        token.TokenTypeWeb,
+					Protocol:    "V2",
+					RemoteAddr:  "192.0.2.1:52000",
+					ConnectedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+					Endpoint:    "https://web-key.example.com",
+					InFlight:    3,
+				},
+				{
+					KeyID:       "tcp-key",
+					Type:        token.TokenTypeTCP,
+					Protocol:    "V1",
+					RemoteAddr:  "192.0.2.2:52001",
+					ConnectedAt: time.Date(2025, 1, 2, 3, 5, 0, 0, time.UTC),
+					Endpoint:    "example.com:10001",
+				},
+			},
+			expectedBody: `{"tunnels":[` +
+				`{"connected_at":"2025-01-02T03:04:05Z","key_id":"web-key","type":"web","protocol":"V2","remote_addr":"192.0.2.1:52000","endpoint":"https://web-key.example.com","in_flight":3},` +
+				`{"connected_at":"2025-01-02T03:05:00Z","key_id":"tcp-key","type":"tcp","protocol":"V1","remote_addr":"192.0.2.2:52001","endpoint":"example.com:10001","in_flight":0}` +
+				`]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.EXPECT().ListTunnels(mock.Anything).Return(tt.tunnels).Once()
+
+			rec := httptest.NewRecorder()
+
+			api.listTunnelsHandler(rec, httptest.NewRequest(http.MethodGet, "/tunnels", http.NoBody))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestUpdateTokenHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	tests := []struct {
+		mockBehavior func()
+		name         string
+		keyID        string
+		body         string
+		expectedBody string
+		expectedCode int
+	}{
+		{
+			name:         "Missing KeyID",
+			keyID:        "",
+			body:         `{"ttl":3600}`,
+			mockBehavior: func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Key ID is required\n",
+		},
+		{
+			name:         "Missing TTL",
+			keyID:        "test-key-id",
+			body:         `{}`,
+			mockBehavior: func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Bad Request\n",
+		},
+		{
+			name:  "Expiration Extended",
+			keyID: "test-key-id",
+			body:  `{"ttl":3600}`,
+			mockBehavior: func() {
+				auth.EXPECT().SetTokenTTL(mock.Anything, "test-key-id", 3600).Return(token.Info{
+					ID:        "test-key-id",
+					ExpiresAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+				}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"expires_at":"2025-01-02T03:04:05Z","key_id":"test-key-id"}` + "\n",
+		},
+		{
+			name:  "Never Expires",
+			keyID: "test-key-id",
+			body:  `{"ttl":0}`,
+			mockBehavior: func() {
+				auth.EXPECT().SetTokenTTL(mock.Anything, "test-key-id", 0).Return(token.Info{ID: "test-key-id"}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"key_id":"test-key-id"}` + "\n",
+		},
+		{
+			name:  "Negative TTL",
+			keyID: "test-key-id",
+			body:  `{"ttl":-1}`,
+			mockBehavior: func() {
+				auth.EXPECT().SetTokenTTL(mock.Anything, "test-key-id", -1).Return(token.Info{}, token.ErrInvalidTokenTTL).Once()
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: token.ErrInvalidTokenTTL.Error() + "\n",
+		},
+		{
+			name:  "Token Not Found",
+			keyID: "test-key-id",
+			body:  `{"ttl":3600}`,
+			mockBehavior: func() {
+				auth.EXPECT().SetTokenTTL(mock.Anything, "test-key-id", 3600).Return(token.Info{}, core.ErrTokenNotFound).Once()
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Token not found\n",
+		},
+		{
+			name:  "Internal Error",
+			keyID: "test-key-id",
+			body:  `{"ttl":3600}`,
+			mockBehavior: func() {
+				auth.EXPECT().SetTokenTTL(mock.Anything, "test-key-id", 3600).Return(token.Info{}, errors.New("failed")).Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			req := httptest.NewRequest(http.MethodPatch, "/token/"+tt.keyID, bytes.NewBufferString(tt.body))
+
+			if tt.keyID != "" {
+				req.SetPathValue("keyID", tt.keyID)
+			}
+
+			rec := httptest.NewRecorder()
+
+			api.updateTokenHandler(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestAPIRun(t *testing.T) {
+	svc := NewMockService(t)
+	api := New(Config{Listen: ":58083"}, svc)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		err := api.Run(ctx)
+		assert.NoError(t, err, "API server should shut down gracefully")
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "API server did not shut down in time")
+	}
+}
+
+func TestDisconnectTunnelHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	tests := []struct {
+		mockBehavior func()
+		name         string
+		keyID        string
+		expectedBody string
+		expectedCode int
+	}{
+		{
+			name:         "Missing KeyID",
+			keyID:        "",
+			mockBehavior: func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Key ID is required\n",
+		},
+		{
+			name:  "Disconnected",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().DisconnectTunnel(mock.Anything, "test-key-id").Return(nil).Once()
+			},
+			expectedCode: http.StatusNoContent,
+			expectedBody: "",
+		},
+		{
+			name:  "Tunnel Not Found",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().DisconnectTunnel(mock.Anything, "test-key-id").Return(core.ErrTunnelNotFound).Once()
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Tunnel not found\n",
+		},
+		{
+			name:  "Internal Error",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().DisconnectTunnel(mock.Anything, "test-key-id").Return(errors.New("failed to disconnect")).Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			req := httptest.NewRequest(http.MethodDelete, "/tunnels/"+tt.keyID, http.NoBody)
+
+			if tt.keyID != "" {
+				req.SetPathValue("keyID", tt.keyID)
+			}
+
+			rec := httptest.NewRecorder()
+
+			api.disconnectTunnelHandler(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestRevokeTokenHandler(t *testing.T) {
 	auth := NewMockService(t)
 	api := New(Config{}, auth)
 
@@ -259,3 +1000,189 @@ func TestRevokeTokenHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestListDomainsHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	tests := []struct {
+		mockBehavior func()
+		name         string
+		keyID        string
+		expectedBody string
+		expectedCode int
+	}{
+		{
+			name:         "Missing KeyID",
+			keyID:        "",
+			mockBehavior: func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Key ID is required\n",
+		},
+		{
+			name:  "Listed",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().ListDomains(mock.Anything, "test-key-id").Return([]string{"app.example.com"}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"domains\":[\"app.example.com\"]}\n",
+		},
+		{
+			name:  "Token Not Found",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().ListDomains(mock.Anything, "test-key-id").Return(nil, core.ErrTokenNotFound).Once()
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Token not found\n",
+		},
+		{
+			name:  "Internal Error",
+			keyID: "test-key-id",
+			mockBehavior: func() {
+				auth.EXPECT().ListDomains(mock.Anything, "test-key-id").Return(nil, errors.New("failed to list")).Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockBehavior()
+
+			req := httptest.NewRequest(http.MethodGet, "/token/"+tt.keyID+"/domains", http.NoBody)
+
+			if tt.keyID != "" {
+				req.SetPathValue("keyID", tt.keyID)
+			}
+
+			rec := httptest.NewRecorder()
+
+			api.listDomainsHandler(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestAttachDomainHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	tests := []struct {
+		err          error
+		name         string
+		domain       string
+		expectedBody string
+		expectedCode int
+	}{
+		{name: "Missing Domain", expectedCode: http.StatusBadRequest, expectedBody: "Key ID and domain are required\n"},
+		{name: "Attached", domain: "app.example.com", expectedCode: http.StatusNoContent},
+		{
+			name:         "Invalid Domain",
+			domain:       "localhost",
+			err:          token.ErrInvalidDomain,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: token.ErrInvalidDomain.Error() + "\n",
+		},
+		{
+			name:         "Token Not Found",
+			domain:       "app.example.com",
+			err:          core.ErrTokenNotFound,
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Token not found\n",
+		},
+		{
+			name:         "Domain Taken",
+			domain:       "app.example.com",
+			err:          core.ErrDomainTaken,
+			expectedCode: http.StatusConflict,
+			expectedBody: "Domain is attached to another token\n",
+		},
+		{
+			name:         "Not Verified",
+			domain:       "app.example.com",
+			err:          fmt.Errorf("%w: no TXT record", core.ErrDomainNotVerified),
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: core.ErrDomainNotVerified.Error() + ": no TXT record\n",
+		},
+		{
+			name:         "Internal Error",
+			domain:       "app.example.com",
+			err:          errors.New("failed to attach"),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.domain != "" {
+				auth.EXPECT().AttachDomain(mock.Anything, "test-key-id", tt.domain).Return(tt.err).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/token/test-key-id/domains/"+tt.domain, http.NoBody)
+			req.SetPathValue("keyID", "test-key-id")
+			req.SetPathValue("domain", tt.domain)
+
+			rec := httptest.NewRecorder()
+
+			api.attachDomainHandler(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestDetachDomainHandler(t *testing.T) {
+	auth := NewMockService(t)
+	api := New(Config{}, auth)
+
+	tests := []struct {
+		err          error
+		name         string
+		domain       string
+		expectedBody string
+		expectedCode int
+	}{
+		{name: "Missing Domain", expectedCode: http.StatusBadRequest, expectedBody: "Key ID and domain are required\n"},
+		{name: "Detached", domain: "app.example.com", expectedCode: http.StatusNoContent},
+		{
+			name:         "Domain Not Found",
+			domain:       "app.example.com",
+			err:          core.ErrDomainNotFound,
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Domain not found\n",
+		},
+		{
+			name:         "Internal Error",
+			domain:       "app.example.com",
+			err:          errors.New("failed to detach"),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal Server Error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.domain != "" {
+				auth.EXPECT().DetachDomain(mock.Anything, "test-key-id", tt.domain).Return(tt.err).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/token/test-key-id/domains/"+tt.domain, http.NoBody)
+			req.SetPathValue("keyID", "test-key-id")
+			req.SetPathValue("domain", tt.domain)
+
+			rec := httptest.NewRecorder()
+
+			api.detachDomainHandler(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
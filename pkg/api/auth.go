@@ -0,0 +1,226 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Scopes grant access to groups of management API endpoints.
+const (
+	ScopeTokenCreate       = "token:create"
+	ScopeTokenRead         = "token:read"
+	ScopeTokenUpdate       = "token:update"
+	ScopeTokenRevoke       = "token:revoke"
+	ScopeTunnelsRead       = "tunnels:read"
+	ScopeTunnelsDisconnect = "tunnels:disconnect"
+	ScopeMetricsRead       = "metrics:read"
+)
+
+var knownScopes = map[string]bool{
+	ScopeTokenCreate:       true,
+	ScopeTokenRead:         true,
+	ScopeTokenUpdate:       true,
+	ScopeTokenRevoke:       true,
+	ScopeTunnelsRead:       true,
+	ScopeTunnelsDisconnect: true,
+	ScopeMetricsRead:       true,
+}
+
+// APIKey is an admin credential sent as a bearer token in the Authorization header.
+// Hash is the hex encoded SHA-256 digest of the key, the key itself is never stored in the configuration.
+// Name identifies the key in logs.
+type APIKey struct {
+	Name   string   `mapstructure:"name"`
+	Hash   string   `mapstructure:"hash"`
+	Scopes []string `mapstructure:"scopes"`
+}
+
+// ClientCert grants scopes to the client certificates with the given subject common name.
+// Certificates are only accepted if they are signed by the client CA configured for the API.
+type ClientCert struct {
+	CommonName string   `mapstructure:"common_name"`
+	Scopes     []string `mapstructure:"scopes"`
+}
+
+// apiKey is a validated API key.
+type apiKey struct {
+	scopes map[string]bool
+	name   string
+	hash   []byte
+}
+
+// authenticator checks the credentials of management API requests and the scopes they grant.
+// When no API keys and no client CA are configured, authentication is disabled and every request is allowed.
+type authenticator struct {
+	clients map[string]map[string]bool
+	keys    []apiKey
+	enabled bool
+}
+
+// newAuthenticator creates an authenticator from the API keys and client certificates of cfg.
+// Returns an error if a key hash is not a hex encoded SHA-256 digest, a scope is unknown or client certificates
+// are configured without a client CA to verify them.
+func newAuthenticator(cfg Config) (*authenticator, error) {
+	a := &authenticator{
+		clients: make(map[string]map[string]bool, len(cfg.Clients)),
+		keys:    make([]apiKey, 0, len(cfg.APIKeys)),
+		enabled: len(cfg.APIKeys) > 0 || cfg.ClientCA != "",
+	}
+
+	for _, k := range cfg.APIKeys {
+		hash, err := hex.DecodeString(strings.TrimSpace(k.Hash))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("API key %q: hash must be a hex encoded SHA-256 digest", k.Name)
+		}
+
+		scopes, err := parseScopes(k.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("API key %q: %w", k.Name, err)
+		}
+
+		a.keys = append(a.keys, apiKey{name: k.Name, hash: hash, scopes: scopes})
+	}
+
+	if len(cfg.Clients) > 0 && cfg.ClientCA == "" {
+		return nil, fmt.Errorf("client certificates require a client CA")
+	}
+
+	for _, c := range cfg.Clients {
+		if c.CommonName == "" {
+			return nil, fmt.Errorf("client certificate common name is required")
+		}
+
+		scopes, err := parseScopes(c.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate %q: %w", c.CommonName, err)
+		}
+
+		a.clients[c.CommonName] = scopes
+	}
+
+	return a, nil
+}
+
+// parseScopes returns the set of scopes.
+// Returns an error if a scope is unknown.
+func parseScopes(scopes []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(scopes))
+
+	for _, s := range scopes {
+		if !knownScopes[s] {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+
+		set[s] = true
+	}
+
+	return set, nil
+}
+
+// require returns a middleware that only lets through requests authenticated with credentials granting scope.
+// It responds with HTTP 401 to requests without valid credentials and HTTP 403 to requests lacking the scope.
+func (a *authenticator) require(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !a.enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			name, scopes, ok := a.authenticate(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			if !scopes[scope] {
+				slog.WarnContext(r.Context(), "mng api request denied", slog.String("principal", name), slog.String("scope", scope))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authenticate identifies the caller by its verified client certificate or its bearer API key.
+// Returns the name of the caller and its scopes, or false if the request carries no valid credentials.
+func (a *authenticator) authenticate(r *http.Request) (name string, scopes map[string]bool, ok bool) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if scopes, ok := a.clients[cn]; ok {
+			return "cert:" + cn, scopes, true
+		}
+	}
+
+	key, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || key == "" {
+		return "", nil, false
+	}
+
+	sum := sha256.Sum256([]byte(key))
+
+	// Every key is compared so that the response time does not depend on which key matched.
+	var match *apiKey
+
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], a.keys[i].hash) == 1 {
+			match = &a.keys[i]
+		}
+	}
+
+	if match == nil {
+		return "", nil, false
+	}
+
+	return "key:" + match.name, match.scopes, true
+}
+
+// tlsConfig returns the TLS configuration of the API server.
+// If a client CA is configured, clients may present certificates signed by it, which are verified and
+// authenticate the caller; clients without certificates can still use API keys.
+// Returns nil if TLS is not configured, or an error if the configuration is incomplete or the CA cannot be loaded.
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	if cfg.Cert == "" && cfg.Key == "" {
+		if cfg.ClientCA != "" {
+			return nil, fmt.Errorf("client CA requires cert and key to be set")
+		}
+
+		return nil, nil
+	}
+
+	if cfg.Cert == "" || cfg.Key == "" {
+		return nil, fmt.Errorf("both cert and key are required for TLS")
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.ClientCA != "" {
+		pem, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", cfg.ClientCA)
+		}
+
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsCfg, nil
+}
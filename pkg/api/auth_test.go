@@ -0,0 +1,275 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	tests := []struct {
+		name        string
+		wantErr     string
+		cfg         Config
+		wantEnabled bool
+	}{
+		{name: "disabled", cfg: Config{}},
+		{
+			name:        "api keys",
+			cfg:         Config{APIKeys: []APIKey{{Name: "portal", Hash: hashKey("secret"), Scopes: []string{ScopeTokenCreate}}}},
+			wantEnabled: true,
+		},
+		{name: "client CA", cfg: Config{ClientCA: "ca.pem"}, wantEnabled: true},
+		{
+			name:    "invalid hash",
+			cfg:     Config{APIKeys: []APIKey{{Name: "portal", Hash: "secret"}}},
+			wantErr: "SHA-256 digest",
+		},
+		{
+			name:    "unknown scope",
+			cfg:     Config{APIKeys: []APIKey{{Name: "portal", Hash: hashKey("secret"), Scopes: []string{"token:*"}}}},
+			wantErr: "unknown scope",
+		},
+		{
+			name:    "client without common name",
+			cfg:     Config{ClientCA: "ca.pem", Clients: []ClientCert{{Scopes: []string{ScopeTokenRead}}}},
+			wantErr: "common name is required",
+		},
+		{
+			name:    "clients without client CA",
+			cfg:     Config{Clients: []ClientCert{{CommonName: "portal", Scopes: []string{ScopeTokenRead}}}},
+			wantErr: "require a client CA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAuthenticator(tt.cfg)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEnabled, a.enabled)
+		})
+	}
+}
+
+func TestRouter_APIKeys(t *testing.T) {
+	svc := NewMockService(t)
+	api := New(Config{}, svc)
+
+	authn, err := newAuthenticator(Config{APIKeys: []APIKey{
+		{Name: "portal", Hash: hashKey("portal-key"), Scopes: []string{ScopeTokenRead, ScopeTokenCreate}},
+		{Name: "auditor", Hash: hashKey("auditor-key"), Scopes: []string{ScopeTokenRead}},
+		{Name: "oncall", Hash: hashKey("oncall-key"), Scopes: []string{ScopeTunnelsRead}},
+		{Name: "prometheus", Hash: hashKey("prometheus-key"), Scopes: []string{ScopeMetricsRead}},
+	}})
+	require.NoError(t, err)
+
+	handler := api.router(authn)
+
+	svc.EXPECT().GetToken(mock.Anything, "key1").Return(token.Info{ID: "key1"}, nil)
+	svc.EXPECT().CheckHealth(mock.Anything).Return(nil)
+	svc.EXPECT().ListTunnels(mock.Anything).Return(nil)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		auth         string
+		expectedCode int
+	}{
+		{name: "no credentials", method: http.MethodGet, path: "/token/key1", expectedCode: http.StatusUnauthorized},
+		{name: "unknown key", method: http.MethodGet, path: "/token/key1", auth: "Bearer wrong", expectedCode: http.StatusUnauthorized},
+		{name: "not a bearer token", method: http.MethodGet, path: "/token/key1", auth: "Basic portal-key", expectedCode: http.StatusUnauthorized},
+		{name: "scope granted", method: http.MethodGet, path: "/token/key1", auth: "Bearer auditor-key", expectedCode: http.StatusOK},
+		{name: "scope missing", method: http.MethodDelete, path: "/token/key1", auth: "Bearer portal-key", expectedCode: http.StatusForbidden},
+		{name: "tunnels scope missing", method: http.MethodGet, path: "/tunnels", auth: "Bearer auditor-key", expectedCode: http.StatusForbidden},
+		{name: "tunnels scope granted", method: http.MethodGet, path: "/tunnels", auth: "Bearer oncall-key", expectedCode: http.StatusOK},
+		{name: "health is public", method: http.MethodGet, path: "/health", expectedCode: http.StatusOK},
+		{name: "metrics require credentials", method: http.MethodGet, path: "/metrics", expectedCode: http.StatusUnauthorized},
+		{name: "metrics scope missing", method: http.MethodGet, path: "/metrics", auth: "Bearer oncall-key", expectedCode: http.StatusForbidden},
+		{name: "metrics scope granted", method: http.MethodGet, path: "/metrics", auth: "Bearer prometheus-key", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+
+			if tt.expectedCode == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRouter_Disabled(t *testing.T) {
+	svc := NewMockService(t)
+	api := New(Config{}, svc)
+
+	authn, err := newAuthenticator(Config{})
+	require.NoError(t, err)
+
+	svc.EXPECT().DeleteToken(mock.Anything, "key1").Return(core.ErrTokenNotFound)
+
+	rec := httptest.NewRecorder()
+	api.router(authn).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/token/key1", http.NoBody))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRouter_ClientCert(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newTestCA(t, dir)
+	serverCert := newTestCert(t, caCert, caKey, "localhost")
+	portalCert := newTestCert(t, caCert, caKey, "portal")
+	otherCert := newTestCert(t, caCert, caKey, "other")
+
+	cfg := Config{
+		Cert:     "unused",
+		Key:      "unused",
+		ClientCA: filepath.Join(dir, "ca.pem"),
+		Clients:  []ClientCert{{CommonName: "portal", Scopes: []string{ScopeTokenRead}}},
+	}
+
+	tlsCfg, err := tlsConfig(cfg)
+	require.NoError(t, err)
+
+	authn, err := newAuthenticator(cfg)
+	require.NoError(t, err)
+
+	svc := NewMockService(t)
+	svc.EXPECT().GetToken(mock.Anything, "key1").Return(token.Info{ID: "key1"}, nil)
+
+	srv := httptest.NewUnstartedServer(New(cfg, svc).router(authn))
+	srv.TLS = tlsCfg
+	srv.TLS.Certificates = []tls.Certificate{serverCert}
+	srv.StartTLS()
+
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	get := func(clientCert *tls.Certificate) int {
+		tlsClient := &tls.Config{RootCAs: roots, ServerName: "localhost", MinVersion: tls.VersionTLS12}
+		if clientCert != nil {
+			tlsClient.Certificates = []tls.Certificate{*clientCert}
+		}
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsClient}}
+
+		resp, err := client.Get(srv.URL + "/token/key1")
+		require.NoError(t, err)
+
+		_ = resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, get(&portalCert))
+	assert.Equal(t, http.StatusUnauthorized, get(&otherCert))
+	assert.Equal(t, http.StatusUnauthorized, get(nil))
+}
+
+func TestTLSConfig(t *testing.T) {
+	tlsCfg, err := tlsConfig(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, tlsCfg)
+
+	_, err = tlsConfig(Config{ClientCA: "ca.pem"})
+	assert.ErrorContains(t, err, "requires cert and key")
+
+	_, err = tlsConfig(Config{Cert: "cert.pem"})
+	assert.ErrorContains(t, err, "both cert and key")
+
+	_, err = tlsConfig(Config{Cert: "cert.pem", Key: "key.pem", ClientCA: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read client CA")
+}
+
+// newTestCA creates a self-signed CA and writes its certificate to ca.pem in dir.
+func newTestCA(t *testing.T, dir string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), pemData, 0o600))
+
+	return cert, key
+}
+
+// newTestCert creates a certificate for commonName signed by the CA, usable by both servers and clients.
+func newTestCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, commonName string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
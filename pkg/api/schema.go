@@ -1,14 +1,124 @@
 package api
 
+import "time"
+
 type GenerateTokenRequest struct {
-	KeyID string `json:"key_id"`
-	Type  string `json:"type"`
-	TTL   int    `json:"ttl"`
+	Quota       *QuotaSchema     `json:"quota,omitempty"`
+	Access      *AccessSchema    `json:"access,omitempty"`
+	IPFilter    *IPFilterSchema  `json:"ip_filter,omitempty"`
+	Rewrite     *RewriteSchema   `json:"rewrite,omitempty"`
+	RateLimit   *RateLimitSchema `json:"rate_limit,omitempty"`
+	KeyID       string           `json:"key_id"`
+	Type        string           `json:"type"`
+	Description string           `json:"description,omitempty"`
+	Owner       string           `json:"owner,omitempty"`
+	Subdomains  []string         `json:"subdomains,omitempty"`
+	TTL         int              `json:"ttl"`
 }
 
 type GenerateTokenResponse struct {
-	Token string `json:"token"`
-	KeyID string `json:"key_id"`
-	Type  string `json:"type"`
-	TTL   int    `json:"ttl"`
+	Quota       *QuotaSchema     `json:"quota,omitempty"`
+	Access      *AccessSchema    `json:"access,omitempty"`
+	IPFilter    *IPFilterSchema  `json:"ip_filter,omitempty"`
+	Rewrite     *RewriteSchema   `json:"rewrite,omitempty"`
+	RateLimit   *RateLimitSchema `json:"rate_limit,omitempty"`
+	Token       string           `json:"token"`
+	KeyID       string           `json:"key_id"`
+	Type        string           `json:"type"`
+	Description string           `json:"description,omitempty"`
+	Owner       string           `json:"owner,omitempty"`
+	Subdomains  []string         `json:"subdomains,omitempty"`
+	TTL         int              `json:"ttl"`
+}
+
+// UpdateTokenRequest changes the expiration of a token. TTL is in seconds from now, 0 makes the token never expire.
+type UpdateTokenRequest struct {
+	TTL *int `json:"ttl"`
+}
+
+// TokenSchema is the metadata of a stored token, the secret is never returned.
+// ExpiresAt is omitted for tokens that never expire, Type is omitted when the auth backend does not record it.
+type TokenSchema struct {
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+	KeyID       string    `json:"key_id"`
+	Type        string    `json:"type,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+}
+
+// TokenListResponse is a page of tokens ordered by key ID.
+// Next is the value of the after parameter to get the following page, it is omitted on the last page.
+type TokenListResponse struct {
+	Next   string        `json:"next,omitempty"`
+	Tokens []TokenSchema `json:"tokens"`
+}
+
+// DomainListResponse lists the custom domains attached to a token, sorted by name.
+type DomainListResponse struct {
+	Domains []string `json:"domains"`
+}
+
+// TunnelSchema describes a control connection of a MIT client connected to the server.
+// Protocol is the protocol version of the connection, V1 or V2.
+// Endpoint is the public URL of web tunnels or the host:port of TCP tunnels.
+// InFlight is the number of end-user connections currently proxied through the tunnel.
+type TunnelSchema struct {
+	ConnectedAt time.Time `json:"connected_at"`
+	KeyID       string    `json:"key_id"`
+	Type        string    `json:"type"`
+	Protocol    string    `json:"protocol"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Endpoint    string    `json:"endpoint"`
+	InFlight    int64     `json:"in_flight"`
+}
+
+// TunnelListResponse lists the tunnels connected to the server, oldest first.
+type TunnelListResponse struct {
+	Tunnels []TunnelSchema `json:"tunnels"`
+}
+
+// QuotaSchema describes a traffic quota: the number of bytes a tunnel may proxy per period.
+// Period is in seconds and defaults to one day.
+type QuotaSchema struct {
+	Bytes  int64 `json:"bytes"`
+	Period int   `json:"period,omitempty"`
+}
+
+// AccessSchema restricts who may reach a web tunnel: either HTTP basic auth with Username and Password,
+// or a Key sent in Header, which defaults to the Authorization header with the key as a bearer token.
+// Password and Key are never returned in responses.
+type AccessSchema struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"` // #nosec G117 -- This is a request field name, not an exposed password
+	Header   string `json:"header,omitempty"`
+	Key      string `json:"key,omitempty"`
+}
+
+// IPFilterSchema restricts the end-user IP addresses that may reach a tunnel with lists of CIDR prefixes or
+// single IP addresses. Addresses in Deny are always rejected, when Allow is not empty only addresses in Allow are accepted.
+type IPFilterSchema struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// RewriteSchema holds the header rewrite rules of a web tunnel. Host replaces the Host header of forwarded requests.
+// Headers in the set maps are set, replacing any value the message already has, and headers in the remove lists
+// are removed from requests forwarded to the tunnel or responses sent back to end users.
+type RewriteSchema struct {
+	SetRequestHeaders     map[string]string `json:"set_request_headers,omitempty"`
+	SetResponseHeaders    map[string]string `json:"set_response_headers,omitempty"`
+	Host                  string            `json:"host,omitempty"`
+	RemoveRequestHeaders  []string          `json:"remove_request_headers,omitempty"`
+	RemoveResponseHeaders []string          `json:"remove_response_headers,omitempty"`
+}
+
+// RateLimitSchema holds the request rate limits of a web tunnel overriding the limits configured on the server.
+// TunnelRate limits all the requests of the tunnel and ClientRate the requests of each end-user IP address,
+// in requests per second. Bursts are the requests accepted at once and default to the rates rounded up.
+type RateLimitSchema struct {
+	TunnelRate  float64 `json:"tunnel_rate,omitempty"`
+	ClientRate  float64 `json:"client_rate,omitempty"`
+	TunnelBurst int     `json:"tunnel_burst,omitempty"`
+	ClientBurst int     `json:"client_burst,omitempty"`
 }
@@ -7,6 +7,7 @@ package api
 import (
 	context "context"
 
+	core "github.com/ksysoev/make-it-public/pkg/core"
 	token "github.com/ksysoev/make-it-public/pkg/core/token"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -24,6 +25,54 @@ func (_m *MockService) EXPECT() *MockService_Expecter {
 	return &MockService_Expecter{mock: &_m.Mock}
 }
 
+// AttachDomain provides a mock function with given fields: ctx, keyID, domain
+func (_m *MockService) AttachDomain(ctx context.Context, keyID string, domain string) error {
+	ret := _m.Called(ctx, keyID, domain)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AttachDomain")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, keyID, domain)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockService_AttachDomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AttachDomain'
+type MockService_AttachDomain_Call struct {
+	*mock.Call
+}
+
+// AttachDomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - domain string
+func (_e *MockService_Expecter) AttachDomain(ctx interface{}, keyID interface{}, domain interface{}) *MockService_AttachDomain_Call {
+	return &MockService_AttachDomain_Call{Call: _e.mock.On("AttachDomain", ctx, keyID, domain)}
+}
+
+func (_c *MockService_AttachDomain_Call) Run(run func(ctx context.Context, keyID string, domain string)) *MockService_AttachDomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockService_AttachDomain_Call) Return(_a0 error) *MockService_AttachDomain_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockService_AttachDomain_Call) RunAndReturn(run func(context.Context, string, string) error) *MockService_AttachDomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // CheckHealth provides a mock function with given fields: ctx
 func (_m *MockService) CheckHealth(ctx context.Context) error {
 	ret := _m.Called(ctx)
@@ -117,9 +166,104 @@ func (_c *MockService_DeleteToken_Call) RunAndReturn(run func(context.Context, s
 	return _c
 }
 
-// GenerateToken provides a mock function with given fields: ctx, keyID, ttl, tokenType
-func (_m *MockService) GenerateToken(ctx context.Context, keyID string, ttl int, tokenType token.TokenType) (*token.Token, error) {
-	ret := _m.Called(ctx, keyID, ttl, tokenType)
+// DetachDomain provides a mock function with given fields: ctx, keyID, domain
+func (_m *MockService) DetachDomain(ctx context.Context, keyID string, domain string) error {
+	ret := _m.Called(ctx, keyID, domain)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DetachDomain")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, keyID, domain)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockService_DetachDomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DetachDomain'
+type MockService_DetachDomain_Call struct {
+	*mock.Call
+}
+
+// DetachDomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - domain string
+func (_e *MockService_Expecter) DetachDomain(ctx interface{}, keyID interface{}, domain interface{}) *MockService_DetachDomain_Call {
+	return &MockService_DetachDomain_Call{Call: _e.mock.On("DetachDomain", ctx, keyID, domain)}
+}
+
+func (_c *MockService_DetachDomain_Call) Run(run func(ctx context.Context, keyID string, domain string)) *MockService_DetachDomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockService_DetachDomain_Call) Return(_a0 error) *MockService_DetachDomain_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockService_DetachDomain_Call) RunAndReturn(run func(context.Context, string, string) error) *MockService_DetachDomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DisconnectTunnel provides a mock function with given fields: ctx, keyID
+func (_m *MockService) DisconnectTunnel(ctx context.Context, keyID string) error {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisconnectTunnel")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockService_DisconnectTunnel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DisconnectTunnel'
+type MockService_DisconnectTunnel_Call struct {
+	*mock.Call
+}
+
+// DisconnectTunnel is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockService_Expecter) DisconnectTunnel(ctx interface{}, keyID interface{}) *MockService_DisconnectTunnel_Call {
+	return &MockService_DisconnectTunnel_Call{Call: _e.mock.On("DisconnectTunnel", ctx, keyID)}
+}
+
+func (_c *MockService_DisconnectTunnel_Call) Run(run func(ctx context.Context, keyID string)) *MockService_DisconnectTunnel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockService_DisconnectTunnel_Call) Return(_a0 error) *MockService_DisconnectTunnel_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockService_DisconnectTunnel_Call) RunAndReturn(run func(context.Context, string) error) *MockService_DisconnectTunnel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GenerateToken provides a mock function with given fields: ctx, keyID, ttl, tokenType, policy, meta
+func (_m *MockService) GenerateToken(ctx context.Context, keyID string, ttl int, tokenType token.TokenType, policy token.Policy, meta token.Meta) (*token.Token, error) {
+	ret := _m.Called(ctx, keyID, ttl, tokenType, policy, meta)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GenerateToken")
@@ -127,19 +271,19 @@ func (_m *MockService) GenerateToken(ctx context.Context, keyID string, ttl int,
 
 	var r0 *token.Token
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, int, token.TokenType) (*token.Token, error)); ok {
-		return rf(ctx, keyID, ttl, tokenType)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, token.TokenType, token.Policy, token.Meta) (*token.Token, error)); ok {
+		return rf(ctx, keyID, ttl, tokenType, policy, meta)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, int, token.TokenType) *token.Token); ok {
-		r0 = rf(ctx, keyID, ttl, tokenType)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, token.TokenType, token.Policy, token.Meta) *token.Token); ok {
+		r0 = rf(ctx, keyID, ttl, tokenType, policy, meta)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*token.Token)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, int, token.TokenType) error); ok {
-		r1 = rf(ctx, keyID, ttl, tokenType)
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, token.TokenType, token.Policy, token.Meta) error); ok {
+		r1 = rf(ctx, keyID, ttl, tokenType, policy, meta)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -157,13 +301,15 @@ type MockService_GenerateToken_Call struct {
 //   - keyID string
 //   - ttl int
 //   - tokenType token.TokenType
-func (_e *MockService_Expecter) GenerateToken(ctx interface{}, keyID interface{}, ttl interface{}, tokenType interface{}) *MockService_GenerateToken_Call {
-	return &MockService_GenerateToken_Call{Call: _e.mock.On("GenerateToken", ctx, keyID, ttl, tokenType)}
+//   - policy token.Policy
+//   - meta token.Meta
+func (_e *MockService_Expecter) GenerateToken(ctx interface{}, keyID interface{}, ttl interface{}, tokenType interface{}, policy interface{}, meta interface{}) *MockService_GenerateToken_Call {
+	return &MockService_GenerateToken_Call{Call: _e.mock.On("GenerateToken", ctx, keyID, ttl, tokenType, policy, meta)}
 }
 
-func (_c *MockService_GenerateToken_Call) Run(run func(ctx context.Context, keyID string, ttl int, tokenType token.TokenType)) *MockService_GenerateToken_Call {
+func (_c *MockService_GenerateToken_Call) Run(run func(ctx context.Context, keyID string, ttl int, tokenType token.TokenType, policy token.Policy, meta token.Meta)) *MockService_GenerateToken_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string), args[2].(int), args[3].(token.TokenType))
+		run(args[0].(context.Context), args[1].(string), args[2].(int), args[3].(token.TokenType), args[4].(token.Policy), args[5].(token.Meta))
 	})
 	return _c
 }
@@ -173,7 +319,295 @@ func (_c *MockService_GenerateToken_Call) Return(_a0 *token.Token, _a1 error) *M
 	return _c
 }
 
-func (_c *MockService_GenerateToken_Call) RunAndReturn(run func(context.Context, string, int, token.TokenType) (*token.Token, error)) *MockService_GenerateToken_Call {
+func (_c *MockService_GenerateToken_Call) RunAndReturn(run func(context.Context, string, int, token.TokenType, token.Policy, token.Meta) (*token.Token, error)) *MockService_GenerateToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetToken provides a mock function with given fields: ctx, keyID
+func (_m *MockService) GetToken(ctx context.Context, keyID string) (token.Info, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetToken")
+	}
+
+	var r0 token.Info
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (token.Info, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) token.Info); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(token.Info)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockService_GetToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetToken'
+type MockService_GetToken_Call struct {
+	*mock.Call
+}
+
+// GetToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockService_Expecter) GetToken(ctx interface{}, keyID interface{}) *MockService_GetToken_Call {
+	return &MockService_GetToken_Call{Call: _e.mock.On("GetToken", ctx, keyID)}
+}
+
+func (_c *MockService_GetToken_Call) Run(run func(ctx context.Context, keyID string)) *MockService_GetToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockService_GetToken_Call) Return(_a0 token.Info, _a1 error) *MockService_GetToken_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockService_GetToken_Call) RunAndReturn(run func(context.Context, string) (token.Info, error)) *MockService_GetToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListDomains provides a mock function with given fields: ctx, keyID
+func (_m *MockService) ListDomains(ctx context.Context, keyID string) ([]string, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDomains")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]string, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockService_ListDomains_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListDomains'
+type MockService_ListDomains_Call struct {
+	*mock.Call
+}
+
+// ListDomains is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockService_Expecter) ListDomains(ctx interface{}, keyID interface{}) *MockService_ListDomains_Call {
+	return &MockService_ListDomains_Call{Call: _e.mock.On("ListDomains", ctx, keyID)}
+}
+
+func (_c *MockService_ListDomains_Call) Run(run func(ctx context.Context, keyID string)) *MockService_ListDomains_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockService_ListDomains_Call) Return(_a0 []string, _a1 error) *MockService_ListDomains_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockService_ListDomains_Call) RunAndReturn(run func(context.Context, string) ([]string, error)) *MockService_ListDomains_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListTokens provides a mock function with given fields: ctx, filter
+func (_m *MockService) ListTokens(ctx context.Context, filter core.TokenFilter) ([]token.Info, string, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTokens")
+	}
+
+	var r0 []token.Info
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, core.TokenFilter) ([]token.Info, string, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, core.TokenFilter) []token.Info); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]token.Info)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, core.TokenFilter) string); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, core.TokenFilter) error); ok {
+		r2 = rf(ctx, filter)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// MockService_ListTokens_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListTokens'
+type MockService_ListTokens_Call struct {
+	*mock.Call
+}
+
+// ListTokens is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter core.TokenFilter
+func (_e *MockService_Expecter) ListTokens(ctx interface{}, filter interface{}) *MockService_ListTokens_Call {
+	return &MockService_ListTokens_Call{Call: _e.mock.On("ListTokens", ctx, filter)}
+}
+
+func (_c *MockService_ListTokens_Call) Run(run func(ctx context.Context, filter core.TokenFilter)) *MockService_ListTokens_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(core.TokenFilter))
+	})
+	return _c
+}
+
+func (_c *MockService_ListTokens_Call) Return(_a0 []token.Info, _a1 string, _a2 error) *MockService_ListTokens_Call {
+	_c.Call.Return(_a0, _a1, _a2)
+	return _c
+}
+
+func (_c *MockService_ListTokens_Call) RunAndReturn(run func(context.Context, core.TokenFilter) ([]token.Info, string, error)) *MockService_ListTokens_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListTunnels provides a mock function with given fields: ctx
+func (_m *MockService) ListTunnels(ctx context.Context) []core.Tunnel {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTunnels")
+	}
+
+	var r0 []core.Tunnel
+	if rf, ok := ret.Get(0).(func(context.Context) []core.Tunnel); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]core.Tunnel)
+		}
+	}
+
+	return r0
+}
+
+// MockService_ListTunnels_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListTunnels'
+type MockService_ListTunnels_Call struct {
+	*mock.Call
+}
+
+// ListTunnels is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockService_Expecter) ListTunnels(ctx interface{}) *MockService_ListTunnels_Call {
+	return &MockService_ListTunnels_Call{Call: _e.mock.On("ListTunnels", ctx)}
+}
+
+func (_c *MockService_ListTunnels_Call) Run(run func(ctx context.Context)) *MockService_ListTunnels_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockService_ListTunnels_Call) Return(_a0 []core.Tunnel) *MockService_ListTunnels_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockService_ListTunnels_Call) RunAndReturn(run func(context.Context) []core.Tunnel) *MockService_ListTunnels_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetTokenTTL provides a mock function with given fields: ctx, keyID, ttl
+func (_m *MockService) SetTokenTTL(ctx context.Context, keyID string, ttl int) (token.Info, error) {
+	ret := _m.Called(ctx, keyID, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetTokenTTL")
+	}
+
+	var r0 token.Info
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) (token.Info, error)); ok {
+		return rf(ctx, keyID, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) token.Info); ok {
+		r0 = rf(ctx, keyID, ttl)
+	} else {
+		r0 = ret.Get(0).(token.Info)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, keyID, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockService_SetTokenTTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetTokenTTL'
+type MockService_SetTokenTTL_Call struct {
+	*mock.Call
+}
+
+// SetTokenTTL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - ttl int
+func (_e *MockService_Expecter) SetTokenTTL(ctx interface{}, keyID interface{}, ttl interface{}) *MockService_SetTokenTTL_Call {
+	return &MockService_SetTokenTTL_Call{Call: _e.mock.On("SetTokenTTL", ctx, keyID, ttl)}
+}
+
+func (_c *MockService_SetTokenTTL_Call) Run(run func(ctx context.Context, keyID string, ttl int)) *MockService_SetTokenTTL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *MockService_SetTokenTTL_Call) Return(_a0 token.Info, _a1 error) *MockService_SetTokenTTL_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockService_SetTokenTTL_Call) RunAndReturn(run func(context.Context, string, int) (token.Info, error)) *MockService_SetTokenTTL_Call {
 	_c.Call.Return(run)
 	return _c
 }
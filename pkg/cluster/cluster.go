@@ -0,0 +1,364 @@
+// Package cluster lets several server replicas behind one load balancer serve the same tunnels.
+// Every node announces the keyIDs of the MIT clients connected to it in a shared registry, and
+// end-user connections that reach a node without the client are forwarded to the owning node
+// over an internal link authenticated with a shared secret. The link is plain TCP and is meant for
+// a trusted private network between the nodes.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/conn"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+const defaultNodeTTL = 15 * time.Second
+
+// Config configures cluster mode. Cluster mode is enabled when Listen is set.
+// NodeID defaults to the hostname and Advertise, the address other nodes use to reach the internal link,
+// defaults to Listen with the hostname as host when Listen has none.
+// Secret authenticates the internal link and must be the same on every node. The link is not encrypted,
+// so Listen must only be reachable from the other nodes over a trusted network.
+// Nodes that stop refreshing their registration for NodeTTL are considered gone, it defaults to 15 seconds.
+type Config struct {
+	NodeID    string        `mapstructure:"node_id"`
+	Listen    string        `mapstructure:"listen"`
+	Advertise string        `mapstructure:"advertise"`
+	Secret    string        `mapstructure:"secret"` // #nosec G117 -- This is a config field name, not an exposed password
+	RedisAddr string        `mapstructure:"redis_addr"`
+	Password  string        `mapstructure:"redis_password"` // #nosec G117 -- This is a config field name, not an exposed password
+	KeyPrefix string        `mapstructure:"key_prefix"`
+	NodeTTL   time.Duration `mapstructure:"node_ttl"`
+}
+
+// ConnService is the subset of core.Service required by a cluster node.
+type ConnService interface {
+	HandleForwardedConnection(ctx context.Context, keyID string, tokenType token.TokenType, conn net.Conn, ready func() error, clientIP string) error
+	DisconnectTunnel(ctx context.Context, keyID string) error
+	SetCluster(cluster core.Cluster)
+}
+
+// Registry keeps track of the nodes of the cluster and of the keyIDs each node holds.
+type Registry interface {
+	Heartbeat(ctx context.Context, nodeID, addr string, ttl time.Duration) error
+	Leave(ctx context.Context, nodeID string, keyIDs []string) error
+	AddKeys(ctx context.Context, nodeID string, keyIDs []string) error
+	RemoveKey(ctx context.Context, nodeID, keyID string) error
+	Lookup(ctx context.Context, keyID string) (map[string]string, error)
+}
+
+// Node is a member of the cluster. It implements core.Cluster.
+type Node struct {
+	connService ConnService
+	registry    Registry
+	keys        map[string]int
+	id          string
+	listen      string
+	addr        string
+	secret      []byte
+	ttl         time.Duration
+	mu          sync.Mutex
+}
+
+// New validates cfg, creates a Node backed by a Redis registry and injects it as the cluster into connService.
+// Returns an error if the secret or the Redis address is missing, or the addresses are invalid.
+func New(cfg *Config, connService ConnService) (*Node, error) {
+	if cfg.RedisAddr == "" {
+		return nil, fmt.Errorf("redis address is required for cluster mode")
+	}
+
+	return newNode(cfg, connService, NewRedisRegistry(cfg))
+}
+
+// newNode creates a Node using registry and injects it as the cluster into connService.
+// Returns an error if the configuration is invalid.
+func newNode(cfg *Config, connService ConnService, registry Registry) (*Node, error) {
+	if cfg.Listen == "" {
+		return nil, fmt.Errorf("listen address is required for cluster mode")
+	}
+
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("secret is required for cluster mode")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil && (cfg.NodeID == "" || cfg.Advertise == "") {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	n := &Node{
+		connService: connService,
+		registry:    registry,
+		keys:        make(map[string]int),
+		id:          cfg.NodeID,
+		listen:      cfg.Listen,
+		addr:        cfg.Advertise,
+		secret:      []byte(cfg.Secret),
+		ttl:         cfg.NodeTTL,
+	}
+
+	if n.id == "" {
+		n.id = hostname
+	}
+
+	if n.ttl <= 0 {
+		n.ttl = defaultNodeTTL
+	}
+
+	if n.addr == "" {
+		host, port, err := net.SplitHostPort(cfg.Listen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster listen address: %w", err)
+		}
+
+		if host == "" {
+			host = hostname
+		}
+
+		n.addr = net.JoinHostPort(host, port)
+	}
+
+	connService.SetCluster(n)
+
+	return n, nil
+}
+
+// ID returns the identifier of the node in the cluster.
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Run joins the cluster and serves the internal link until ctx is cancelled.
+// The node refreshes its registration every third of the node TTL and leaves the cluster on exit.
+// Returns an error if the internal link cannot listen or the node cannot join the cluster.
+func (n *Node) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	l, err := net.Listen("tcp", n.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	if err := n.heartbeat(ctx); err != nil {
+		_ = l.Close()
+		return fmt.Errorf("failed to join cluster: %w", err)
+	}
+
+	slog.InfoContext(ctx, "joined cluster", slog.String("node", n.id), slog.String("addr", n.addr))
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		n.refreshLoop(ctx)
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		if err := l.Close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close cluster listener", slog.Any("error", err))
+		}
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			cancel()
+			wg.Wait()
+
+			leaveErr := n.leave(ctx)
+
+			if errors.Is(err, net.ErrClosed) {
+				return leaveErr
+			}
+
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			n.handleLink(ctx, c)
+		}()
+	}
+}
+
+// Register announces that this node holds a control connection for keyID.
+// The registry is only updated for the first control connection of keyID on this node.
+func (n *Node) Register(ctx context.Context, keyID string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.keys[keyID]++
+
+	if n.keys[keyID] > 1 {
+		return nil
+	}
+
+	if err := n.registry.AddKeys(ctx, n.id, []string{keyID}); err != nil {
+		return fmt.Errorf("failed to register key: %w", err)
+	}
+
+	return nil
+}
+
+// Unregister withdraws a control connection for keyID.
+// The registry is only updated once the last control connection of keyID on this node is gone.
+func (n *Node) Unregister(ctx context.Context, keyID string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.keys[keyID]--
+
+	if n.keys[keyID] > 0 {
+		return nil
+	}
+
+	delete(n.keys, keyID)
+
+	if err := n.registry.RemoveKey(ctx, n.id, keyID); err != nil {
+		return fmt.Errorf("failed to unregister key: %w", err)
+	}
+
+	return nil
+}
+
+// Dial opens a link to another node that holds a control connection for keyID.
+// Nodes are tried in random order until one of them connects to the MIT client.
+// Returns core.ErrKeyIDNotFound if no other node holds keyID, core.ErrQuotaExceeded if the traffic quota of the
+// tunnel is used up, or an error if none of the nodes could be reached.
+func (n *Node) Dial(ctx context.Context, keyID string, tokenType token.TokenType, clientIP string) (conn.WithWriteCloser, error) {
+	nodes, err := n.registry.Lookup(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up key: %w", err)
+	}
+
+	delete(nodes, n.id)
+
+	addrs := make([]string, 0, len(nodes))
+	for _, addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+
+	lastErr := fmt.Errorf("keyID %s is not connected to other nodes: %w", keyID, core.ErrKeyIDNotFound)
+
+	for _, addr := range addrs {
+		c, err := n.dialLink(ctx, addr, keyID, tokenType, clientIP)
+
+		switch {
+		case err == nil:
+			return c, nil
+		case errors.Is(err, core.ErrQuotaExceeded):
+			return nil, err
+		case errors.Is(err, core.ErrKeyIDNotFound):
+			// The client has left the node since the registry was read, the next node may still hold it.
+		default:
+			slog.DebugContext(ctx, "failed to dial cluster node", slog.String("addr", addr), slog.Any("error", err))
+
+			lastErr = err
+		}
+	}
+
+	return nil, lastErr
+}
+
+// Disconnect asks every other node that holds a control connection for keyID to close it.
+// Nodes that no longer hold keyID are skipped.
+// Returns an error if the registry cannot be read or some of the nodes could not be reached.
+func (n *Node) Disconnect(ctx context.Context, keyID string) error {
+	nodes, err := n.registry.Lookup(ctx, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to look up key: %w", err)
+	}
+
+	delete(nodes, n.id)
+
+	var errs []error
+
+	for nodeID, addr := range nodes {
+		err := n.disconnectLink(ctx, addr, keyID)
+		if err != nil && !errors.Is(err, core.ErrKeyIDNotFound) {
+			errs = append(errs, fmt.Errorf("failed to disconnect key on node %s: %w", nodeID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// heartbeat refreshes the registration of the node and of all keyIDs it holds,
+// so that the registry recovers if its data was lost.
+func (n *Node) heartbeat(ctx context.Context) error {
+	if err := n.registry.Heartbeat(ctx, n.id, n.addr, n.ttl); err != nil {
+		return fmt.Errorf("failed to refresh node: %w", err)
+	}
+
+	keyIDs := n.keyIDs()
+	if len(keyIDs) == 0 {
+		return nil
+	}
+
+	if err := n.registry.AddKeys(ctx, n.id, keyIDs); err != nil {
+		return fmt.Errorf("failed to refresh keys: %w", err)
+	}
+
+	return nil
+}
+
+// refreshLoop calls heartbeat every third of the node TTL until ctx is cancelled.
+func (n *Node) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(n.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.heartbeat(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to refresh cluster registration", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// leave removes the node and the keyIDs it holds from the registry.
+func (n *Node) leave(ctx context.Context) error {
+	if err := n.registry.Leave(context.WithoutCancel(ctx), n.id, n.keyIDs()); err != nil {
+		return fmt.Errorf("failed to leave cluster: %w", err)
+	}
+
+	slog.InfoContext(ctx, "left cluster", slog.String("node", n.id))
+
+	return nil
+}
+
+// keyIDs returns the keyIDs this node holds control connections for.
+func (n *Node) keyIDs() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	keyIDs := make([]string, 0, len(n.keys))
+	for keyID := range n.keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+
+	return keyIDs
+}
@@ -0,0 +1,289 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/conn/meta"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr string
+		cfg     Config
+	}{
+		{name: "missing redis", cfg: Config{Listen: ":8083", Secret: "s"}, wantErr: "redis address is required"},
+		{name: "missing listen", cfg: Config{RedisAddr: "localhost:6379", Secret: "s"}, wantErr: "listen address is required"},
+		{name: "missing secret", cfg: Config{RedisAddr: "localhost:6379", Listen: ":8083"}, wantErr: "secret is required"},
+		{name: "invalid listen", cfg: Config{RedisAddr: "localhost:6379", Listen: "8083", Secret: "s"}, wantErr: "invalid cluster listen address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&tt.cfg, NewMockConnService(t))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetCluster(mock.Anything).Return()
+
+	node, err := New(&Config{RedisAddr: "localhost:6379", Listen: ":8083", Secret: "s"}, svc)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, node.ID())
+	assert.Equal(t, net.JoinHostPort(node.ID(), "8083"), node.addr)
+	assert.Equal(t, defaultNodeTTL, node.ttl)
+}
+
+func TestNode_RegisterUnregister(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetCluster(mock.Anything).Return()
+
+	registry := NewMockRegistry(t)
+
+	node, err := newNode(&Config{NodeID: "node-a", Listen: "127.0.0.1:0", Secret: "s"}, svc, registry)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// The registry is only updated for the first and the last control connection of a key.
+	registry.EXPECT().AddKeys(ctx, "node-a", []string{"key"}).Return(nil).Once()
+	registry.EXPECT().RemoveKey(ctx, "node-a", "key").Return(nil).Once()
+
+	require.NoError(t, node.Register(ctx, "key"))
+	require.NoError(t, node.Register(ctx, "key"))
+	assert.Equal(t, []string{"key"}, node.keyIDs())
+
+	require.NoError(t, node.Unregister(ctx, "key"))
+	require.NoError(t, node.Unregister(ctx, "key"))
+	assert.Empty(t, node.keyIDs())
+}
+
+func TestNode_Dial_NotConnected(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetCluster(mock.Anything).Return()
+
+	registry := NewMockRegistry(t)
+	registry.EXPECT().Lookup(mock.Anything, "key").Return(map[string]string{"node-a": "127.0.0.1:1"}, nil)
+
+	node, err := newNode(&Config{NodeID: "node-a", Listen: "127.0.0.1:0", Secret: "s"}, svc, registry)
+	require.NoError(t, err)
+
+	// The node itself is never dialed.
+	_, err = node.Dial(context.Background(), "key", token.TokenTypeWeb, "10.0.0.1")
+	assert.ErrorIs(t, err, core.ErrKeyIDNotFound)
+}
+
+func TestNode_Dial_LookupError(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetCluster(mock.Anything).Return()
+
+	registry := NewMockRegistry(t)
+	registry.EXPECT().Lookup(mock.Anything, "key").Return(nil, assert.AnError)
+
+	node, err := newNode(&Config{NodeID: "node-a", Listen: "127.0.0.1:0", Secret: "s"}, svc, registry)
+	require.NoError(t, err)
+
+	_, err = node.Dial(context.Background(), "key", token.TokenTypeWeb, "10.0.0.1")
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+// startNode runs a node with the given link address and connection service until the test ends.
+func startNode(t *testing.T, id, secret string, svc *MockConnService) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	svc.EXPECT().SetCluster(mock.Anything).Return()
+
+	registry := NewMockRegistry(t)
+	registry.EXPECT().Heartbeat(mock.Anything, id, addr, defaultNodeTTL).Return(nil)
+	registry.EXPECT().Leave(mock.Anything, id, []string{}).Return(nil)
+
+	node, err := newNode(&Config{NodeID: id, Listen: addr, Secret: secret}, svc, registry)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- node.Run(ctx) }()
+
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	require.Eventually(t, func() bool {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+
+		_ = c.Close()
+
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	return addr
+}
+
+// newDialer creates a node that only dials other nodes.
+func newDialer(t *testing.T, secret string, nodes map[string]string) *Node {
+	t.Helper()
+
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetCluster(mock.Anything).Return()
+
+	registry := NewMockRegistry(t)
+	registry.EXPECT().Lookup(mock.Anything, "key").Return(nodes, nil)
+
+	node, err := newNode(&Config{NodeID: "node-a", Listen: "127.0.0.1:0", Secret: secret}, svc, registry)
+	require.NoError(t, err)
+
+	return node
+}
+
+func TestNode_Dial_ForwardsToOwner(t *testing.T) {
+	owner := NewMockConnService(t)
+	owner.EXPECT().HandleForwardedConnection(mock.Anything, "key", token.TokenTypeTCP, mock.Anything, mock.Anything, "10.0.0.1").
+		RunAndReturn(func(_ context.Context, _ string, _ token.TokenType, c net.Conn, ready func() error, _ string) error {
+			if err := ready(); err != nil {
+				return err
+			}
+
+			buf := make([]byte, 4)
+			if _, err := io.ReadFull(c, buf); err != nil {
+				return err
+			}
+
+			_, err := c.Write([]byte("pong"))
+
+			return err
+		})
+
+	addr := startNode(t, "node-b", "secret", owner)
+	node := newDialer(t, "secret", map[string]string{"node-b": addr})
+
+	link, err := node.Dial(context.Background(), "key", token.TokenTypeTCP, "10.0.0.1")
+	require.NoError(t, err)
+
+	defer link.Close()
+
+	_, err = link.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(link, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf))
+}
+
+func TestNode_Dial_OwnerErrors(t *testing.T) {
+	tests := []struct {
+		handleErr error
+		wantErr   error
+		name      string
+	}{
+		{name: "key not found", handleErr: core.ErrKeyIDNotFound, wantErr: core.ErrKeyIDNotFound},
+		{name: "quota exceeded", handleErr: core.ErrQuotaExceeded, wantErr: core.ErrQuotaExceeded},
+		{name: "failed to connect", handleErr: assert.AnError, wantErr: core.ErrFailedToConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := NewMockConnService(t)
+			owner.EXPECT().HandleForwardedConnection(mock.Anything, "key", token.TokenTypeWeb, mock.Anything, mock.Anything, "10.0.0.1").
+				Return(tt.handleErr)
+
+			addr := startNode(t, "node-b", "secret", owner)
+			node := newDialer(t, "secret", map[string]string{"node-b": addr})
+
+			_, err := node.Dial(context.Background(), "key", token.TokenTypeWeb, "10.0.0.1")
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNode_Dial_WrongSecret(t *testing.T) {
+	addr := startNode(t, "node-b", "secret", NewMockConnService(t))
+	node := newDialer(t, "other-secret", map[string]string{"node-b": addr})
+
+	_, err := node.Dial(context.Background(), "key", token.TokenTypeWeb, "10.0.0.1")
+	assert.ErrorIs(t, err, errUnauthorized)
+}
+
+func TestNode_Disconnect(t *testing.T) {
+	holder := NewMockConnService(t)
+	holder.EXPECT().DisconnectTunnel(mock.Anything, "key").Return(nil).Once()
+
+	gone := NewMockConnService(t)
+	gone.EXPECT().DisconnectTunnel(mock.Anything, "key").Return(core.ErrTunnelNotFound).Once()
+
+	nodes := map[string]string{
+		"node-a": "127.0.0.1:1",
+		"node-b": startNode(t, "node-b", "secret", holder),
+		"node-c": startNode(t, "node-c", "secret", gone),
+	}
+
+	// Nodes that no longer hold the key are skipped, the node itself is never dialed.
+	require.NoError(t, newDialer(t, "secret", nodes).Disconnect(context.Background(), "key"))
+}
+
+func TestNode_Disconnect_Errors(t *testing.T) {
+	failing := NewMockConnService(t)
+	failing.EXPECT().DisconnectTunnel(mock.Anything, "key").Return(assert.AnError).Once()
+
+	addr := startNode(t, "node-b", "secret", failing)
+
+	err := newDialer(t, "secret", map[string]string{"node-b": addr}).Disconnect(context.Background(), "key")
+	assert.ErrorIs(t, err, core.ErrFailedToConnect)
+
+	err = newDialer(t, "other-secret", map[string]string{"node-b": addr}).Disconnect(context.Background(), "key")
+	assert.ErrorIs(t, err, errUnauthorized)
+}
+
+func TestNode_HandleLink_RejectsReplayedRequest(t *testing.T) {
+	addr := startNode(t, "node-b", "secret", NewMockConnService(t))
+
+	// A request signed for the challenge of one link must not authorize another link.
+	first, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	defer first.Close()
+
+	var challenge linkChallenge
+
+	require.NoError(t, meta.ReadData(first, &challenge))
+
+	req := linkRequest{NodeID: "node-a", KeyID: "key", Type: token.TokenTypeWeb, ClientIP: "10.0.0.1", Nonce: challenge.Nonce}
+	req.Signature = req.sign([]byte("secret"))
+
+	replay, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	defer replay.Close()
+
+	require.NoError(t, meta.ReadData(replay, &challenge))
+	require.NoError(t, meta.WriteData(replay, &req))
+
+	var resp linkResponse
+
+	require.NoError(t, meta.ReadData(replay, &resp))
+	assert.Equal(t, errCodeAuth, resp.Error)
+}
@@ -0,0 +1,172 @@
+// Code generated by mockery. DO NOT EDIT.
+
+//go:build !compile
+
+package cluster
+
+import (
+	context "context"
+	net "net"
+
+	core "github.com/ksysoev/make-it-public/pkg/core"
+	token "github.com/ksysoev/make-it-public/pkg/core/token"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockConnService is an autogenerated mock type for the ConnService type
+type MockConnService struct {
+	mock.Mock
+}
+
+type MockConnService_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockConnService) EXPECT() *MockConnService_Expecter {
+	return &MockConnService_Expecter{mock: &_m.Mock}
+}
+
+// DisconnectTunnel provides a mock function with given fields: ctx, keyID
+func (_m *MockConnService) DisconnectTunnel(ctx context.Context, keyID string) error {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisconnectTunnel")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockConnService_DisconnectTunnel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DisconnectTunnel'
+type MockConnService_DisconnectTunnel_Call struct {
+	*mock.Call
+}
+
+// DisconnectTunnel is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockConnService_Expecter) DisconnectTunnel(ctx interface{}, keyID interface{}) *MockConnService_DisconnectTunnel_Call {
+	return &MockConnService_DisconnectTunnel_Call{Call: _e.mock.On("DisconnectTunnel", ctx, keyID)}
+}
+
+func (_c *MockConnService_DisconnectTunnel_Call) Run(run func(ctx context.Context, keyID string)) *MockConnService_DisconnectTunnel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_DisconnectTunnel_Call) Return(_a0 error) *MockConnService_DisconnectTunnel_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockConnService_DisconnectTunnel_Call) RunAndReturn(run func(context.Context, string) error) *MockConnService_DisconnectTunnel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HandleForwardedConnection provides a mock function with given fields: ctx, keyID, tokenType, conn, ready, clientIP
+func (_m *MockConnService) HandleForwardedConnection(ctx context.Context, keyID string, tokenType token.TokenType, conn net.Conn, ready func() error, clientIP string) error {
+	ret := _m.Called(ctx, keyID, tokenType, conn, ready, clientIP)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HandleForwardedConnection")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, token.TokenType, net.Conn, func() error, string) error); ok {
+		r0 = rf(ctx, keyID, tokenType, conn, ready, clientIP)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockConnService_HandleForwardedConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HandleForwardedConnection'
+type MockConnService_HandleForwardedConnection_Call struct {
+	*mock.Call
+}
+
+// HandleForwardedConnection is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - tokenType token.TokenType
+//   - conn net.Conn
+//   - ready func() error
+//   - clientIP string
+func (_e *MockConnService_Expecter) HandleForwardedConnection(ctx interface{}, keyID interface{}, tokenType interface{}, conn interface{}, ready interface{}, clientIP interface{}) *MockConnService_HandleForwardedConnection_Call {
+	return &MockConnService_HandleForwardedConnection_Call{Call: _e.mock.On("HandleForwardedConnection", ctx, keyID, tokenType, conn, ready, clientIP)}
+}
+
+func (_c *MockConnService_HandleForwardedConnection_Call) Run(run func(ctx context.Context, keyID string, tokenType token.TokenType, conn net.Conn, ready func() error, clientIP string)) *MockConnService_HandleForwardedConnection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(token.TokenType), args[3].(net.Conn), args[4].(func() error), args[5].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_HandleForwardedConnection_Call) Return(_a0 error) *MockConnService_HandleForwardedConnection_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockConnService_HandleForwardedConnection_Call) RunAndReturn(run func(context.Context, string, token.TokenType, net.Conn, func() error, string) error) *MockConnService_HandleForwardedConnection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetCluster provides a mock function with given fields: cluster
+func (_m *MockConnService) SetCluster(cluster core.Cluster) {
+	_m.Called(cluster)
+}
+
+// MockConnService_SetCluster_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetCluster'
+type MockConnService_SetCluster_Call struct {
+	*mock.Call
+}
+
+// SetCluster is a helper method to define mock.On call
+//   - cluster core.Cluster
+func (_e *MockConnService_Expecter) SetCluster(cluster interface{}) *MockConnService_SetCluster_Call {
+	return &MockConnService_SetCluster_Call{Call: _e.mock.On("SetCluster", cluster)}
+}
+
+func (_c *MockConnService_SetCluster_Call) Run(run func(cluster core.Cluster)) *MockConnService_SetCluster_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(core.Cluster))
+	})
+	return _c
+}
+
+func (_c *MockConnService_SetCluster_Call) Return() *MockConnService_SetCluster_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockConnService_SetCluster_Call) RunAndReturn(run func(core.Cluster)) *MockConnService_SetCluster_Call {
+	_c.Run(run)
+	return _c
+}
+
+// NewMockConnService creates a new instance of MockConnService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockConnService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockConnService {
+	mock := &MockConnService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,296 @@
+package cluster
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/conn"
+	"github.com/ksysoev/make-it-public/pkg/core/conn/meta"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+const (
+	linkTimeout   = 10 * time.Second
+	nonceSize     = 16
+	errCodeAuth   = "unauthorized"
+	errCodeKey    = "key_not_found"
+	errCodeQuota  = "quota_exceeded"
+	errCodeFailed = "failed_to_connect"
+
+	// actionDisconnect is the action of links that ask the owning node to close the control connections
+	// of a keyID instead of connecting to its MIT client.
+	actionDisconnect = "disconnect"
+)
+
+var errUnauthorized = errors.New("cluster link is not authorized")
+
+// linkChallenge is the first message of a link, sent by the owning node as soon as it accepts the connection.
+// Nonce is a random value issued for this link only, the forwarding node has to sign it in its request
+// so that a captured request cannot be replayed on another link.
+type linkChallenge struct {
+	Nonce string `json:"nonce"`
+}
+
+// linkRequest opens a link for an end-user connection, it is sent by the forwarding node in reply to the challenge.
+// Action is empty for end-user connections, or actionDisconnect to close the control connections of KeyID.
+// Signature is the HMAC-SHA256 of the other fields with the shared cluster secret.
+type linkRequest struct {
+	NodeID    string          `json:"node_id"`
+	KeyID     string          `json:"key_id"`
+	Type      token.TokenType `json:"type"`
+	ClientIP  string          `json:"client_ip"`
+	Action    string          `json:"action,omitempty"`
+	Nonce     string          `json:"nonce"`
+	Signature string          `json:"signature"`
+}
+
+// linkResponse is sent by the owning node once it is connected to the MIT client, or with an error code
+// if it cannot serve the connection. Raw connection data follows a successful response.
+type linkResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// sign returns the signature of req with secret.
+func (req *linkRequest) sign(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+
+	mac.Write([]byte(strings.Join([]string{
+		req.NodeID,
+		req.KeyID,
+		string(req.Type),
+		req.ClientIP,
+		req.Action,
+		req.Nonce,
+	}, "\n")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks that req is signed with secret and answers the challenge with nonce.
+// Returns errUnauthorized if it is not.
+func (req *linkRequest) verify(secret []byte, nonce string) error {
+	if !hmac.Equal([]byte(req.Signature), []byte(req.sign(secret))) {
+		return fmt.Errorf("invalid signature: %w", errUnauthorized)
+	}
+
+	if !hmac.Equal([]byte(req.Nonce), []byte(nonce)) {
+		return fmt.Errorf("request does not answer the link challenge: %w", errUnauthorized)
+	}
+
+	return nil
+}
+
+// newNonce returns a random hex-encoded challenge for a new link.
+func newNonce() (string, error) {
+	b := make([]byte, nonceSize)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// dialLink connects to the internal link of the node at addr and requests a connection to the MIT client of keyID.
+// Returns the link once the node is connected to the client, or the error reported by the node.
+func (n *Node) dialLink(ctx context.Context, addr, keyID string, tokenType token.TokenType, clientIP string) (conn.WithWriteCloser, error) {
+	return n.openLink(ctx, addr, linkRequest{KeyID: keyID, Type: tokenType, ClientIP: clientIP})
+}
+
+// disconnectLink asks the node at addr to close its control connections for keyID.
+// Returns core.ErrKeyIDNotFound if the node holds none, or the error reported by the node.
+func (n *Node) disconnectLink(ctx context.Context, addr, keyID string) error {
+	link, err := n.openLink(ctx, addr, linkRequest{KeyID: keyID, Action: actionDisconnect})
+	if err != nil {
+		return err
+	}
+
+	return link.Close()
+}
+
+// openLink connects to the internal link of the node at addr and sends req signed on behalf of this node.
+// Returns the link once the node has accepted the request, or the error reported by the node.
+func (n *Node) openLink(ctx context.Context, addr string, req linkRequest) (conn.WithWriteCloser, error) {
+	dialer := net.Dialer{Timeout: linkTimeout}
+
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to node: %w", err)
+	}
+
+	link, ok := c.(*net.TCPConn)
+	if !ok {
+		_ = c.Close()
+		return nil, fmt.Errorf("unexpected connection type %T", c)
+	}
+
+	deadline := time.Now().Add(linkTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	resp, err := n.handshake(link, deadline, req)
+	if err != nil {
+		_ = link.Close()
+		return nil, err
+	}
+
+	switch resp.Error {
+	case "":
+		return link, nil
+	case errCodeKey:
+		err = core.ErrKeyIDNotFound
+	case errCodeQuota:
+		err = core.ErrQuotaExceeded
+	case errCodeAuth:
+		err = errUnauthorized
+	default:
+		err = core.ErrFailedToConnect
+	}
+
+	_ = link.Close()
+
+	return nil, fmt.Errorf("node %s rejected connection: %w", addr, err)
+}
+
+// handshake reads the challenge of the owning node, sends req signed on behalf of this node over link
+// and waits for the response until deadline.
+func (n *Node) handshake(link net.Conn, deadline time.Time, req linkRequest) (*linkResponse, error) {
+	if err := link.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set link deadline: %w", err)
+	}
+
+	var challenge linkChallenge
+
+	if err := meta.ReadData(link, &challenge); err != nil {
+		return nil, fmt.Errorf("failed to read link challenge: %w", err)
+	}
+
+	req.NodeID = n.id
+	req.Nonce = challenge.Nonce
+	req.Signature = req.sign(n.secret)
+
+	if err := meta.WriteData(link, &req); err != nil {
+		return nil, fmt.Errorf("failed to send link request: %w", err)
+	}
+
+	var resp linkResponse
+
+	if err := meta.ReadData(link, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read link response: %w", err)
+	}
+
+	if err := link.SetDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("failed to clear link deadline: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// handleLink serves a link opened by another node: it challenges the other node with a fresh nonce,
+// authenticates the request and hands the connection to the local service, which must not forward it again.
+// Disconnect requests close the local control connections of the keyID instead.
+func (n *Node) handleLink(ctx context.Context, c net.Conn) {
+	defer func() { _ = c.Close() }()
+
+	if err := c.SetDeadline(time.Now().Add(linkTimeout)); err != nil {
+		slog.DebugContext(ctx, "failed to set link deadline", slog.Any("error", err))
+		return
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create link challenge", slog.Any("error", err))
+		return
+	}
+
+	if err := meta.WriteData(c, &linkChallenge{Nonce: nonce}); err != nil {
+		slog.DebugContext(ctx, "failed to send link challenge", slog.Any("remote", c.RemoteAddr()), slog.Any("error", err))
+		return
+	}
+
+	var req linkRequest
+
+	if err := meta.ReadData(c, &req); err != nil {
+		slog.DebugContext(ctx, "failed to read link request", slog.Any("remote", c.RemoteAddr()), slog.Any("error", err))
+		return
+	}
+
+	if err := req.verify(n.secret, nonce); err != nil {
+		slog.WarnContext(ctx, "rejected cluster link", slog.Any("remote", c.RemoteAddr()), slog.Any("error", err))
+
+		_ = meta.WriteData(c, &linkResponse{Error: errCodeAuth})
+
+		return
+	}
+
+	if req.Action == actionDisconnect {
+		n.handleDisconnect(ctx, c, &req)
+		return
+	}
+
+	if err := c.SetDeadline(time.Time{}); err != nil {
+		slog.DebugContext(ctx, "failed to clear link deadline", slog.Any("error", err))
+		return
+	}
+
+	acked := false
+	ready := func() error {
+		acked = true
+
+		return meta.WriteData(c, &linkResponse{})
+	}
+
+	err = n.connService.HandleForwardedConnection(ctx, req.KeyID, req.Type, c, ready, req.ClientIP)
+	if err == nil {
+		return
+	}
+
+	slog.DebugContext(ctx, "failed to handle forwarded connection",
+		slog.String("node", req.NodeID),
+		slog.String("keyID", req.KeyID),
+		slog.Any("error", err))
+
+	if acked {
+		return
+	}
+
+	code := errCodeFailed
+
+	switch {
+	case errors.Is(err, core.ErrKeyIDNotFound):
+		code = errCodeKey
+	case errors.Is(err, core.ErrQuotaExceeded):
+		code = errCodeQuota
+	}
+
+	_ = meta.WriteData(c, &linkResponse{Error: code})
+}
+
+// handleDisconnect closes the local control connections of the keyID of req, on behalf of the node that
+// revoked its token, and reports the result over c.
+func (n *Node) handleDisconnect(ctx context.Context, c net.Conn, req *linkRequest) {
+	resp := linkResponse{}
+
+	if err := n.connService.DisconnectTunnel(ctx, req.KeyID); err != nil {
+		resp.Error = errCodeFailed
+
+		if errors.Is(err, core.ErrTunnelNotFound) {
+			resp.Error = errCodeKey
+		}
+	}
+
+	if err := meta.WriteData(c, &resp); err != nil {
+		slog.DebugContext(ctx, "failed to send disconnect response", slog.String("node", req.NodeID), slog.Any("error", err))
+	}
+}
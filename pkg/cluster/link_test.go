@@ -0,0 +1,47 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkRequest_Verify(t *testing.T) {
+	secret := []byte("secret")
+	nonce := "nonce"
+
+	newRequest := func() *linkRequest {
+		req := &linkRequest{
+			NodeID:   "node-a",
+			KeyID:    "key",
+			Type:     token.TokenTypeWeb,
+			ClientIP: "10.0.0.1",
+			Nonce:    nonce,
+		}
+		req.Signature = req.sign(secret)
+
+		return req
+	}
+
+	assert.NoError(t, newRequest().verify(secret, nonce))
+	assert.ErrorIs(t, newRequest().verify([]byte("other"), nonce), errUnauthorized)
+	assert.ErrorIs(t, newRequest().verify(secret, "other-nonce"), errUnauthorized, "replayed requests must be rejected")
+
+	tampered := newRequest()
+	tampered.KeyID = "other-key"
+
+	assert.ErrorIs(t, tampered.verify(secret, nonce), errUnauthorized)
+}
+
+func TestNewNonce(t *testing.T) {
+	first, err := newNonce()
+	require.NoError(t, err)
+
+	second, err := newNonce()
+	require.NoError(t, err)
+
+	assert.Len(t, first, 2*nonceSize)
+	assert.NotEqual(t, first, second)
+}
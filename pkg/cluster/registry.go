@@ -0,0 +1,138 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	nodePrefix   = "CLUSTER_NODE::"
+	tunnelPrefix = "CLUSTER_TUNNEL::"
+	// tunnelTTL bounds how long keyIDs of nodes that crashed without leaving the cluster are kept.
+	// Live nodes refresh their keyIDs on every heartbeat.
+	tunnelTTL = 24 * time.Hour
+)
+
+type Redis interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	HKeys(ctx context.Context, key string) *redis.StringSliceCmd
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	Close() error
+}
+
+// RedisRegistry is a Registry stored in Redis.
+// Every node has a key holding its link address that expires unless it is refreshed, and every keyID
+// has a hash with the IDs of the nodes holding it. Nodes whose address key has expired are ignored on lookup.
+type RedisRegistry struct {
+	db        Redis
+	keyPrefix string
+}
+
+// NewRedisRegistry creates a RedisRegistry connected to the Redis server from cfg.
+func NewRedisRegistry(cfg *Config) *RedisRegistry {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.Password,
+	})
+
+	return &RedisRegistry{
+		db:        rdb,
+		keyPrefix: cfg.KeyPrefix,
+	}
+}
+
+// Heartbeat stores the link address of nodeID for ttl.
+func (r *RedisRegistry) Heartbeat(ctx context.Context, nodeID, addr string, ttl time.Duration) error {
+	if err := r.db.Set(ctx, r.keyPrefix+nodePrefix+nodeID, addr, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store node: %w", err)
+	}
+
+	return nil
+}
+
+// Leave removes nodeID and the given keyIDs it holds from the registry.
+func (r *RedisRegistry) Leave(ctx context.Context, nodeID string, keyIDs []string) error {
+	_, err := r.db.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, r.keyPrefix+nodePrefix+nodeID)
+
+		for _, keyID := range keyIDs {
+			p.HDel(ctx, r.keyPrefix+tunnelPrefix+keyID, nodeID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove node: %w", err)
+	}
+
+	return nil
+}
+
+// AddKeys records that nodeID holds control connections for keyIDs.
+func (r *RedisRegistry) AddKeys(ctx context.Context, nodeID string, keyIDs []string) error {
+	_, err := r.db.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, keyID := range keyIDs {
+			key := r.keyPrefix + tunnelPrefix + keyID
+
+			p.HSet(ctx, key, nodeID, time.Now().Unix())
+			p.Expire(ctx, key, tunnelTTL)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store keys: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveKey records that nodeID no longer holds control connections for keyID.
+func (r *RedisRegistry) RemoveKey(ctx context.Context, nodeID, keyID string) error {
+	if err := r.db.HDel(ctx, r.keyPrefix+tunnelPrefix+keyID, nodeID).Err(); err != nil {
+		return fmt.Errorf("failed to remove key: %w", err)
+	}
+
+	return nil
+}
+
+// Lookup returns the link addresses of the live nodes holding keyID, indexed by node ID.
+func (r *RedisRegistry) Lookup(ctx context.Context, keyID string) (map[string]string, error) {
+	nodeIDs, err := r.db.HKeys(ctx, r.keyPrefix+tunnelPrefix+keyID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes of key: %w", err)
+	}
+
+	nodes := make(map[string]string, len(nodeIDs))
+	if len(nodeIDs) == 0 {
+		return nodes, nil
+	}
+
+	keys := make([]string, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		keys[i] = r.keyPrefix + nodePrefix + nodeID
+	}
+
+	addrs, err := r.db.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node addresses: %w", err)
+	}
+
+	for i, addr := range addrs {
+		if s, ok := addr.(string); ok && s != "" {
+			nodes[nodeIDs[i]] = s
+		}
+	}
+
+	return nodes, nil
+}
+
+// Close closes the connection to Redis.
+func (r *RedisRegistry) Close() error {
+	return r.db.Close()
+}
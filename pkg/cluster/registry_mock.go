@@ -0,0 +1,291 @@
+// Code generated by mockery. DO NOT EDIT.
+
+//go:build !compile
+
+package cluster
+
+import (
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockRegistry is an autogenerated mock type for the Registry type
+type MockRegistry struct {
+	mock.Mock
+}
+
+type MockRegistry_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockRegistry) EXPECT() *MockRegistry_Expecter {
+	return &MockRegistry_Expecter{mock: &_m.Mock}
+}
+
+// AddKeys provides a mock function with given fields: ctx, nodeID, keyIDs
+func (_m *MockRegistry) AddKeys(ctx context.Context, nodeID string, keyIDs []string) error {
+	ret := _m.Called(ctx, nodeID, keyIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddKeys")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, nodeID, keyIDs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRegistry_AddKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AddKeys'
+type MockRegistry_AddKeys_Call struct {
+	*mock.Call
+}
+
+// AddKeys is a helper method to define mock.On call
+//   - ctx context.Context
+//   - nodeID string
+//   - keyIDs []string
+func (_e *MockRegistry_Expecter) AddKeys(ctx interface{}, nodeID interface{}, keyIDs interface{}) *MockRegistry_AddKeys_Call {
+	return &MockRegistry_AddKeys_Call{Call: _e.mock.On("AddKeys", ctx, nodeID, keyIDs)}
+}
+
+func (_c *MockRegistry_AddKeys_Call) Run(run func(ctx context.Context, nodeID string, keyIDs []string)) *MockRegistry_AddKeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].([]string))
+	})
+	return _c
+}
+
+func (_c *MockRegistry_AddKeys_Call) Return(_a0 error) *MockRegistry_AddKeys_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRegistry_AddKeys_Call) RunAndReturn(run func(context.Context, string, []string) error) *MockRegistry_AddKeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Heartbeat provides a mock function with given fields: ctx, nodeID, addr, ttl
+func (_m *MockRegistry) Heartbeat(ctx context.Context, nodeID string, addr string, ttl time.Duration) error {
+	ret := _m.Called(ctx, nodeID, addr, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Heartbeat")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Duration) error); ok {
+		r0 = rf(ctx, nodeID, addr, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRegistry_Heartbeat_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Heartbeat'
+type MockRegistry_Heartbeat_Call struct {
+	*mock.Call
+}
+
+// Heartbeat is a helper method to define mock.On call
+//   - ctx context.Context
+//   - nodeID string
+//   - addr string
+//   - ttl time.Duration
+func (_e *MockRegistry_Expecter) Heartbeat(ctx interface{}, nodeID interface{}, addr interface{}, ttl interface{}) *MockRegistry_Heartbeat_Call {
+	return &MockRegistry_Heartbeat_Call{Call: _e.mock.On("Heartbeat", ctx, nodeID, addr, ttl)}
+}
+
+func (_c *MockRegistry_Heartbeat_Call) Run(run func(ctx context.Context, nodeID string, addr string, ttl time.Duration)) *MockRegistry_Heartbeat_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *MockRegistry_Heartbeat_Call) Return(_a0 error) *MockRegistry_Heartbeat_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRegistry_Heartbeat_Call) RunAndReturn(run func(context.Context, string, string, time.Duration) error) *MockRegistry_Heartbeat_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Leave provides a mock function with given fields: ctx, nodeID, keyIDs
+func (_m *MockRegistry) Leave(ctx context.Context, nodeID string, keyIDs []string) error {
+	ret := _m.Called(ctx, nodeID, keyIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Leave")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, nodeID, keyIDs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRegistry_Leave_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Leave'
+type MockRegistry_Leave_Call struct {
+	*mock.Call
+}
+
+// Leave is a helper method to define mock.On call
+//   - ctx context.Context
+//   - nodeID string
+//   - keyIDs []string
+func (_e *MockRegistry_Expecter) Leave(ctx interface{}, nodeID interface{}, keyIDs interface{}) *MockRegistry_Leave_Call {
+	return &MockRegistry_Leave_Call{Call: _e.mock.On("Leave", ctx, nodeID, keyIDs)}
+}
+
+func (_c *MockRegistry_Leave_Call) Run(run func(ctx context.Context, nodeID string, keyIDs []string)) *MockRegistry_Leave_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].([]string))
+	})
+	return _c
+}
+
+func (_c *MockRegistry_Leave_Call) Return(_a0 error) *MockRegistry_Leave_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRegistry_Leave_Call) RunAndReturn(run func(context.Context, string, []string) error) *MockRegistry_Leave_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Lookup provides a mock function with given fields: ctx, keyID
+func (_m *MockRegistry) Lookup(ctx context.Context, keyID string) (map[string]string, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Lookup")
+	}
+
+	var r0 map[string]string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (map[string]string, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]string); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRegistry_Lookup_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Lookup'
+type MockRegistry_Lookup_Call struct {
+	*mock.Call
+}
+
+// Lookup is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockRegistry_Expecter) Lookup(ctx interface{}, keyID interface{}) *MockRegistry_Lookup_Call {
+	return &MockRegistry_Lookup_Call{Call: _e.mock.On("Lookup", ctx, keyID)}
+}
+
+func (_c *MockRegistry_Lookup_Call) Run(run func(ctx context.Context, keyID string)) *MockRegistry_Lookup_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockRegistry_Lookup_Call) Return(_a0 map[string]string, _a1 error) *MockRegistry_Lookup_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRegistry_Lookup_Call) RunAndReturn(run func(context.Context, string) (map[string]string, error)) *MockRegistry_Lookup_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RemoveKey provides a mock function with given fields: ctx, nodeID, keyID
+func (_m *MockRegistry) RemoveKey(ctx context.Context, nodeID string, keyID string) error {
+	ret := _m.Called(ctx, nodeID, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, nodeID, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRegistry_RemoveKey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RemoveKey'
+type MockRegistry_RemoveKey_Call struct {
+	*mock.Call
+}
+
+// RemoveKey is a helper method to define mock.On call
+//   - ctx context.Context
+//   - nodeID string
+//   - keyID string
+func (_e *MockRegistry_Expecter) RemoveKey(ctx interface{}, nodeID interface{}, keyID interface{}) *MockRegistry_RemoveKey_Call {
+	return &MockRegistry_RemoveKey_Call{Call: _e.mock.On("RemoveKey", ctx, nodeID, keyID)}
+}
+
+func (_c *MockRegistry_RemoveKey_Call) Run(run func(ctx context.Context, nodeID string, keyID string)) *MockRegistry_RemoveKey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockRegistry_RemoveKey_Call) Return(_a0 error) *MockRegistry_RemoveKey_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRegistry_RemoveKey_Call) RunAndReturn(run func(context.Context, string, string) error) *MockRegistry_RemoveKey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockRegistry creates a new instance of MockRegistry. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockRegistry(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockRegistry {
+	mock := &MockRegistry{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
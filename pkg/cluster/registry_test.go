@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRegistry_Heartbeat(t *testing.T) {
+	db, m := redismock.NewClientMock()
+	r := &RedisRegistry{db: db, keyPrefix: "prefix::"}
+
+	m.ExpectSet("prefix::CLUSTER_NODE::node-a", "10.0.0.1:8083", 15*time.Second).SetVal("OK")
+	m.ExpectSet("prefix::CLUSTER_NODE::node-a", "10.0.0.1:8083", 15*time.Second).SetErr(assert.AnError)
+
+	require.NoError(t, r.Heartbeat(context.Background(), "node-a", "10.0.0.1:8083", 15*time.Second))
+	require.ErrorIs(t, r.Heartbeat(context.Background(), "node-a", "10.0.0.1:8083", 15*time.Second), assert.AnError)
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestRedisRegistry_Keys(t *testing.T) {
+	db, m := redismock.NewClientMock()
+	r := &RedisRegistry{db: db, keyPrefix: "prefix::"}
+
+	m.MatchExpectationsInOrder(true)
+	m.Regexp().ExpectHSet("prefix::CLUSTER_TUNNEL::key1", "node-a", `\d+`).SetVal(1)
+	m.ExpectExpire("prefix::CLUSTER_TUNNEL::key1", tunnelTTL).SetVal(true)
+	m.Regexp().ExpectHSet("prefix::CLUSTER_TUNNEL::key2", "node-a", `\d+`).SetVal(1)
+	m.ExpectExpire("prefix::CLUSTER_TUNNEL::key2", tunnelTTL).SetVal(true)
+	m.ExpectHDel("prefix::CLUSTER_TUNNEL::key1", "node-a").SetVal(1)
+	m.ExpectDel("prefix::CLUSTER_NODE::node-a").SetVal(1)
+	m.ExpectHDel("prefix::CLUSTER_TUNNEL::key2", "node-a").SetVal(1)
+
+	require.NoError(t, r.AddKeys(context.Background(), "node-a", []string{"key1", "key2"}))
+	require.NoError(t, r.RemoveKey(context.Background(), "node-a", "key1"))
+	require.NoError(t, r.Leave(context.Background(), "node-a", []string{"key2"}))
+	assert.NoError(t, m.ExpectationsWereMet())
+}
+
+func TestRedisRegistry_Lookup(t *testing.T) {
+	db, m := redismock.NewClientMock()
+	r := &RedisRegistry{db: db, keyPrefix: "prefix::"}
+
+	m.ExpectHKeys("prefix::CLUSTER_TUNNEL::key").SetVal([]string{"node-a", "node-b"})
+	m.ExpectMGet("prefix::CLUSTER_NODE::node-a", "prefix::CLUSTER_NODE::node-b").SetVal([]interface{}{"10.0.0.1:8083", nil})
+
+	nodes, err := r.Lookup(context.Background(), "key")
+	require.NoError(t, err)
+
+	// node-b has not refreshed its registration and is ignored.
+	assert.Equal(t, map[string]string{"node-a": "10.0.0.1:8083"}, nodes)
+
+	m.ExpectHKeys("prefix::CLUSTER_TUNNEL::unknown").SetVal([]string{})
+
+	nodes, err = r.Lookup(context.Background(), "unknown")
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	m.ExpectHKeys("prefix::CLUSTER_TUNNEL::key").SetErr(assert.AnError)
+
+	_, err = r.Lookup(context.Background(), "key")
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, m.ExpectationsWereMet())
+}
@@ -2,12 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/core/token"
 	"github.com/ksysoev/make-it-public/pkg/display"
 	"github.com/ksysoev/make-it-public/pkg/dummy"
+	"github.com/ksysoev/make-it-public/pkg/inspector"
 	"github.com/ksysoev/make-it-public/pkg/revclient"
 
 	"golang.org/x/sync/errgroup"
@@ -31,18 +36,72 @@ func RunClientCommand(ctx context.Context, args *args) error {
 		return fmt.Errorf("invalid token: %w", err)
 	}
 
+	if err := selectTunnelType(tkn, args.TunnelType); err != nil {
+		disp.ShowError("Invalid tunnel type", err,
+			"Use --type only with a multi-purpose token to pick the tunnel to open, for example:\n"+
+				"  mit --token <token> --type tcp --expose localhost:5432")
+
+		return fmt.Errorf("invalid tunnel type: %w", err)
+	}
+
 	exposeAddr := args.Expose
 	eg, ctx := errgroup.WithContext(ctx)
 
-	// Reject --dummy and --echo-ws for TCP tokens — these flags start HTTP-specific services
-	if tkn.Type == token.TokenTypeTCP && (args.LocalServer || args.EchoWS) {
+	// Reject --dummy and --echo-ws for TCP and UDP tokens — these flags start HTTP-specific services
+	if tkn.Type != token.TokenTypeWeb && (args.LocalServer || args.EchoWS) {
 		disp.ShowError("Invalid configuration", nil,
 			"--dummy and --echo-ws are only supported with web tokens.\n"+
-				"  Use --expose to forward a TCP service.")
+				"  Use --expose to forward a TCP or UDP service.")
 
 		return fmt.Errorf("--dummy and --echo-ws are only supported with web tokens")
 	}
 
+	if args.Subdomain != "" {
+		if tkn.Type != token.TokenTypeWeb {
+			disp.ShowError("Invalid configuration", nil, "--subdomain is only supported with web tokens.")
+
+			return fmt.Errorf("--subdomain is only supported with web tokens")
+		}
+
+		if err := token.ValidateSubdomain(args.Subdomain); err != nil {
+			disp.ShowError("Invalid subdomain", err,
+				"Use a DNS label of lowercase letters, digits and hyphens, for example:\n"+
+					"  mit --token <token> --subdomain payments-demo")
+
+			return fmt.Errorf("invalid subdomain: %w", err)
+		}
+	}
+
+	routes, err := parseRoutes(args.Routes)
+	if err != nil {
+		disp.ShowError("Invalid route", err,
+			"Describe each route as <prefix>=<host:port>[,strip], for example:\n"+
+				"  mit --token <token> --expose localhost:3000 --route /api=localhost:8080")
+
+		return fmt.Errorf("invalid route: %w", err)
+	}
+
+	if len(routes) > 0 && tkn.Type != token.TokenTypeWeb {
+		disp.ShowError("Invalid configuration", nil, "--route is only supported with web tokens.")
+
+		return fmt.Errorf("--route is only supported with web tokens")
+	}
+
+	extra, err := parseTunnels(args.Tunnels)
+	if err != nil {
+		disp.ShowError("Invalid tunnel", err,
+			"Describe each additional tunnel as token=<token>,expose=<host:port>[,subdomain=<label>][,type=<web|tcp|udp>], for example:\n"+
+				"  mit --token <token> --expose localhost:3000 --tunnel token=<token>,expose=localhost:5432")
+
+		return fmt.Errorf("invalid tunnel: %w", err)
+	}
+
+	if args.Inspect && tkn.Type != token.TokenTypeWeb {
+		disp.ShowError("Invalid configuration", nil, "--inspect is only supported with web tokens.")
+
+		return fmt.Errorf("--inspect is only supported with web tokens")
+	}
+
 	// Validate mutual exclusivity of --dummy and --echo-ws
 	if args.LocalServer && args.EchoWS {
 		disp.ShowError("Invalid configuration", nil,
@@ -85,7 +144,7 @@ func RunClientCommand(ctx context.Context, args *args) error {
 	}
 
 	// Validate that we have something to expose
-	if exposeAddr == "" {
+	if exposeAddr == "" && len(routes) == 0 {
 		disp.ShowError("No service to expose", nil,
 			"Specify a local service with --expose or use --dummy/--echo-ws for testing:\n"+
 				"  mit --token <token> --expose localhost:8080\n"+
@@ -95,46 +154,286 @@ func RunClientCommand(ctx context.Context, args *args) error {
 		return fmt.Errorf("no service to expose: use --expose, --dummy, or --echo-ws flag")
 	}
 
-	cfg := revclient.Config{
-		ServerAddr: args.Server,
-		DestAddr:   exposeAddr,
-		NoTLS:      args.NoTLS,
-		Insecure:   args.Insecure,
-		EnableV2:   !args.DisableV2, // V2 enabled by default, use --disable-v2 for old servers
+	tunnels := append([]tunnel{{token: tkn, expose: exposeAddr, subdomain: args.Subdomain, routes: routes}}, extra...)
+
+	var inspectOpts []revclient.Option
+
+	if args.Inspect {
+		insp := inspector.New(inspector.Config{Listen: args.InspectAddr})
+
+		eg.Go(func() error { return insp.Run(ctx) })
+
+		if insp.Addr() == "" {
+			err := eg.Wait()
+			disp.ShowError("Failed to start request inspector", err, "Use --inspect-addr to choose another listen address")
+
+			return fmt.Errorf("failed to start request inspector: %w", err)
+		}
+
+		slog.InfoContext(ctx, "request inspector started", slog.String("url", "http://"+insp.Addr()))
+
+		inspectOpts = append(inspectOpts, revclient.WithTapper(insp))
 	}
 
-	// Start spinner while connecting
+	// Start spinner while connecting. It is replaced on every reconnection attempt,
+	// and the client callbacks may run concurrently, so access is guarded by a mutex.
+	// The same mutex guards the public URLs of the tunnels shown in the banner.
+	var mu sync.Mutex
+
 	spinner := disp.ShowConnecting(args.Server)
-	if spinner != nil {
-		defer spinner.Stop()
-	}
+	connected := make([]display.Tunnel, len(tunnels))
+
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if spinner != nil {
+			spinner.Stop()
+		}
+	}()
+
+	for i, t := range tunnels {
+		cfg := revclient.Config{
+			ServerAddr: args.Server,
+			DestAddr:   t.expose,
+			Subdomain:  t.subdomain,
+			Routes:     t.routes,
+			MaxRetries: args.MaxRetries,
+			NoTLS:      args.NoTLS,
+			Insecure:   args.Insecure,
+			EnableV2:   !args.DisableV2, // V2 enabled by default, use --disable-v2 for old servers
+		}
+
+		var opts []revclient.Option
+		if t.token.Type == token.TokenTypeWeb {
+			opts = append(opts, inspectOpts...)
+		}
+
+		// Create client with callbacks for display
+		opts = append(opts,
+			revclient.WithOnConnected(func(url string) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				connected[i] = display.Tunnel{PublicURL: url, LocalAddr: t.forwarding(), TokenType: string(t.token.Type)}
+
+				// The banner is shown once every tunnel is connected, and again whenever one of them reconnects
+				for _, c := range connected {
+					if c.PublicURL == "" {
+						return
+					}
+				}
 
-	// Create client with callbacks for display
-	revcli := revclient.NewClientServer(cfg, tkn,
-		revclient.WithOnConnected(func(url string) {
-			// Stop spinner and show success banner
-			if spinner != nil {
-				spinner.Success("Connected!")
+				// Stop spinner and show success banner
+				if spinner != nil {
+					spinner.Success("Connected!")
+					spinner = nil
+				}
+
+				disp.ShowTunnels(connected)
+			}),
+			revclient.WithOnReconnecting(func(attempt int, delay time.Duration, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if spinner != nil {
+					spinner.Stop()
+				}
+
+				spinner = disp.ShowReconnecting(args.Server, attempt, delay, err)
+			}),
+			revclient.WithOnRequest(func(clientIP string) {
+				// Show request separator for each incoming connection
+				if len(tunnels) > 1 {
+					clientIP += " -> " + t.expose
+				}
+
+				disp.ShowRequestSeparator(clientIP)
+			}),
+		)
+
+		revcli := revclient.NewClientServer(cfg, t.token, opts...)
+
+		eg.Go(func() error {
+			err := revcli.Run(ctx)
+			if err != nil && len(tunnels) > 1 {
+				return fmt.Errorf("tunnel to %s: %w", t.expose, err)
 			}
 
-			disp.ShowConnected(url, exposeAddr, string(tkn.Type))
-		}),
-		revclient.WithOnRequest(func(clientIP string) {
-			// Show request separator for each incoming connection
-			disp.ShowRequestSeparator(clientIP)
-		}),
-	)
+			return err
+		})
+	}
 
-	slog.InfoContext(ctx, "mit client started", "server", args.Server)
-	eg.Go(func() error { return revcli.Run(ctx) })
+	slog.InfoContext(ctx, "mit client started", "server", args.Server, "tunnels", len(tunnels))
 
 	err = eg.Wait()
 	if err != nil {
 		// Stop spinner if still running (connection failed)
+		mu.Lock()
+
 		if spinner != nil {
 			spinner.Fail("Connection failed")
+			spinner = nil
+		}
+
+		mu.Unlock()
+
+		if errors.Is(err, revclient.ErrAuthFailed) {
+			hint := "The server rejected the token, it may be expired or revoked.\n" +
+				"  Get a new token from your administrator"
+
+			if args.Subdomain != "" || hasSubdomain(extra) {
+				hint = "The server rejected the token, it may be expired or revoked, or the requested subdomain\n" +
+					"  may not be allowed for the token or already reserved by another token"
+			}
+
+			disp.ShowError("Authentication failed", err, hint)
 		}
 	}
 
 	return err
 }
+
+// tunnel holds the settings of a single tunnel run by the client.
+type tunnel struct {
+	token     *token.Token
+	expose    string
+	subdomain string
+	routes    []revclient.Route
+}
+
+// forwarding describes where the tunnel forwards connections to, listing the routes of web tunnels that have any.
+func (t tunnel) forwarding() string {
+	if len(t.routes) == 0 {
+		return t.expose
+	}
+
+	parts := make([]string, 0, len(t.routes)+1)
+	root := false
+
+	for _, r := range t.routes {
+		parts = append(parts, r.String())
+		root = root || r.Prefix == "/"
+	}
+
+	if t.expose != "" && !root {
+		parts = append(parts, "/ -> "+t.expose)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// parseRoutes parses the routes given with --route, see revclient.ParseRoute for the format.
+// Returns an error if any of the routes is malformed or two routes have the same prefix.
+func parseRoutes(specs []string) ([]revclient.Route, error) {
+	routes := make([]revclient.Route, 0, len(specs))
+	seen := make(map[string]bool, len(specs))
+
+	for _, spec := range specs {
+		r, err := revclient.ParseRoute(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[r.Prefix] {
+			return nil, fmt.Errorf("duplicate route prefix %q", r.Prefix)
+		}
+
+		seen[r.Prefix] = true
+		routes = append(routes, r)
+	}
+
+	return routes, nil
+}
+
+// parseTunnels parses the additional tunnels given with --tunnel.
+// Each spec is a comma-separated list of token=<token>, expose=<host:port> and the optional subdomain=<label>
+// and type=<web|tcp|udp>, the latter selecting the tunnel type of a multi-purpose token.
+// Returns an error if a spec is malformed, its token is invalid or its subdomain or type cannot be used.
+func parseTunnels(specs []string) ([]tunnel, error) {
+	tunnels := make([]tunnel, 0, len(specs))
+
+	for _, spec := range specs {
+		var rawToken, tunnelType string
+
+		t := tunnel{}
+
+		for _, field := range strings.Split(spec, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid field %q in tunnel %q, expected key=value", field, spec)
+			}
+
+			switch key {
+			case "token":
+				rawToken = value
+			case "expose":
+				t.expose = value
+			case "subdomain":
+				t.subdomain = value
+			case "type":
+				tunnelType = value
+			default:
+				return nil, fmt.Errorf("unknown field %q in tunnel %q", key, spec)
+			}
+		}
+
+		if rawToken == "" || t.expose == "" {
+			return nil, fmt.Errorf("tunnel %q must set both token and expose", spec)
+		}
+
+		tkn, err := token.Decode(rawToken)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token for %s: %w", t.expose, err)
+		}
+
+		if err := selectTunnelType(tkn, tunnelType); err != nil {
+			return nil, fmt.Errorf("invalid type for %s: %w", t.expose, err)
+		}
+
+		t.token = tkn
+
+		if t.subdomain != "" {
+			if tkn.Type != token.TokenTypeWeb {
+				return nil, fmt.Errorf("subdomain for %s is only supported with web tokens", t.expose)
+			}
+
+			if err := token.ValidateSubdomain(t.subdomain); err != nil {
+				return nil, fmt.Errorf("invalid subdomain for %s: %w", t.expose, err)
+			}
+		}
+
+		tunnels = append(tunnels, t)
+	}
+
+	return tunnels, nil
+}
+
+// selectTunnelType narrows tkn to the tunnel type with the given name, "web", "tcp" or "udp".
+// An empty name selects the default type of the token.
+// Returns an error if the name is unknown or the token does not allow the type.
+func selectTunnelType(tkn *token.Token, name string) error {
+	var tunnelType token.TokenType
+
+	if name != "" {
+		parsed, err := token.ParseTokenType(name)
+		if err != nil {
+			return fmt.Errorf("unknown tunnel type %q: %w", name, err)
+		}
+
+		tunnelType = parsed
+	}
+
+	return tkn.SelectType(tunnelType)
+}
+
+// hasSubdomain reports whether any of the tunnels requests a custom subdomain.
+func hasSubdomain(tunnels []tunnel) bool {
+	for _, t := range tunnels {
+		if t.subdomain != "" {
+			return true
+		}
+	}
+
+	return false
+}
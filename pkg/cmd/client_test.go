@@ -4,14 +4,17 @@ import (
 	"context"
 	"testing"
 
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/make-it-public/pkg/revclient"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRunClientCommand(t *testing.T) {
-	testToken := "dGVzdDp0ZXN0"                // #nosec G101 -- base64("test:test"), old-format web token for tests
-	tcpToken := "dGVzdGtleS10OnRlc3RzZWNyZXQ=" // #nosec G101 -- base64("testkey-t:testsecret"), TCP token for tests
-	webToken := "dGVzdGtleS13OnRlc3RzZWNyZXQ=" // #nosec G101 -- base64("testkey-w:testsecret"), web token for tests
+	testToken := "dGVzdDp0ZXN0"                  // #nosec G101 -- base64("test:test"), old-format web token for tests
+	tcpToken := "dGVzdGtleS10OnRlc3RzZWNyZXQ="   // #nosec G101 -- base64("testkey-t:testsecret"), TCP token for tests
+	webToken := "dGVzdGtleS13OnRlc3RzZWNyZXQ="   // #nosec G101 -- base64("testkey-w:testsecret"), web token for tests
+	multiToken := "dGVzdGtleS13dDp0ZXN0c2VjcmV0" // #nosec G101 -- base64("testkey-wt:testsecret"), web and TCP token for tests
 
 	tests := []struct {
 		name    string
@@ -77,6 +80,7 @@ func TestRunClientCommand(t *testing.T) {
 				NoTLS:       false,
 				Insecure:    false,
 				LogLevel:    "info",
+				MaxRetries:  1,
 				Status:      200,
 				Body:        "test",
 				Headers:     []string{"X-Custom-Header:value"},
@@ -86,12 +90,13 @@ func TestRunClientCommand(t *testing.T) {
 		{
 			name: "websocket echo server",
 			args: args{
-				Token:    testToken,
-				Server:   "test-server:8080",
-				EchoWS:   true,
-				NoTLS:    false,
-				Insecure: false,
-				LogLevel: "info",
+				Token:      testToken,
+				Server:     "test-server:8080",
+				EchoWS:     true,
+				NoTLS:      false,
+				Insecure:   false,
+				LogLevel:   "info",
+				MaxRetries: 1,
 			},
 			wantErr: "lookup test-server",
 		},
@@ -132,6 +137,83 @@ func TestRunClientCommand(t *testing.T) {
 			},
 			wantErr: "--dummy and --echo-ws are only supported with web tokens",
 		},
+		{
+			name: "TCP token with --inspect flag is rejected",
+			args: args{
+				Token:    tcpToken,
+				Server:   "test-server:8080",
+				Expose:   "test-dest",
+				Inspect:  true,
+				LogLevel: "info",
+			},
+			wantErr: "--inspect is only supported with web tokens",
+		},
+		{
+			name: "TCP type with web token is rejected",
+			args: args{
+				Token:      webToken,
+				Server:     "test-server:8080",
+				Expose:     "test-dest",
+				TunnelType: "tcp",
+				LogLevel:   "info",
+			},
+			wantErr: "invalid tunnel type",
+		},
+		{
+			name: "multi-purpose token with TCP type rejects --dummy",
+			args: args{
+				Token:       multiToken,
+				Server:      "test-server:8080",
+				TunnelType:  "tcp",
+				LocalServer: true,
+				LogLevel:    "info",
+			},
+			wantErr: "--dummy and --echo-ws are only supported with web tokens",
+		},
+		{
+			name: "malformed --tunnel is rejected",
+			args: args{
+				Token:    webToken,
+				Server:   "test-server:8080",
+				Expose:   "test-dest",
+				Tunnels:  []string{"expose=localhost:5432"},
+				LogLevel: "info",
+			},
+			wantErr: "must set both token and expose",
+		},
+		{
+			name: "malformed --route is rejected",
+			args: args{
+				Token:    webToken,
+				Server:   "test-server:8080",
+				Expose:   "test-dest",
+				Routes:   []string{"api=localhost:8080"},
+				LogLevel: "info",
+			},
+			wantErr: "invalid route",
+		},
+		{
+			name: "TCP token with --route is rejected",
+			args: args{
+				Token:    tcpToken,
+				Server:   "test-server:8080",
+				Expose:   "test-dest",
+				Routes:   []string{"/api=localhost:8080"},
+				LogLevel: "info",
+			},
+			wantErr: "--route is only supported with web tokens",
+		},
+		{
+			name: "routes without --expose are allowed",
+			args: args{
+				Token:      webToken,
+				Server:     "test-server:8080",
+				Routes:     []string{"/api=localhost:8080", "/=localhost:3000"},
+				LogLevel:   "info",
+				MaxRetries: 1,
+			},
+			wantErr: "lookup test-server",
+		},
 		{
 			name: "web token with --dummy flag is allowed past TCP check",
 			args: args{
@@ -141,6 +223,7 @@ func TestRunClientCommand(t *testing.T) {
 				NoTLS:       false,
 				Insecure:    false,
 				LogLevel:    "info",
+				MaxRetries:  1,
 				Status:      200,
 			},
 			// Fails at DNS lookup, not at the TCP token check — confirms web tokens pass validation
@@ -164,3 +247,84 @@ func TestRunClientCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTunnels(t *testing.T) {
+	webToken := "dGVzdGtleS13OnRlc3RzZWNyZXQ="   // #nosec G101 -- base64("testkey-w:testsecret"), web token for tests
+	tcpToken := "dGVzdGtleS10OnRlc3RzZWNyZXQ="   // #nosec G101 -- base64("testkey-t:testsecret"), TCP token for tests
+	multiToken := "dGVzdGtleS13dDp0ZXN0c2VjcmV0" // #nosec G101 -- base64("testkey-wt:testsecret"), web and TCP token for tests
+
+	t.Run("multi-purpose token", func(t *testing.T) {
+		tunnels, err := parseTunnels([]string{
+			"token=" + multiToken + ",expose=localhost:3000",
+			"token=" + multiToken + ",expose=localhost:5432,type=tcp",
+		})
+		require.NoError(t, err)
+		require.Len(t, tunnels, 2)
+
+		assert.Equal(t, token.TokenTypeWeb, tunnels[0].token.Type)
+		assert.Equal(t, token.TokenTypeTCP, tunnels[1].token.Type)
+	})
+
+	t.Run("valid tunnels", func(t *testing.T) {
+		tunnels, err := parseTunnels([]string{
+			"token=" + webToken + ",expose=localhost:3000,subdomain=frontend",
+			"token=" + tcpToken + ", expose=localhost:5432",
+		})
+		require.NoError(t, err)
+		require.Len(t, tunnels, 2)
+
+		assert.Equal(t, "testkey", tunnels[0].token.ID)
+		assert.Equal(t, "localhost:3000", tunnels[0].expose)
+		assert.Equal(t, "frontend", tunnels[0].subdomain)
+		assert.Equal(t, token.TokenTypeTCP, tunnels[1].token.Type)
+		assert.Equal(t, "localhost:5432", tunnels[1].expose)
+		assert.True(t, hasSubdomain(tunnels))
+	})
+
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{name: "missing value", spec: "token", wantErr: "expected key=value"},
+		{name: "unknown field", spec: "token=" + webToken + ",port=1", wantErr: "unknown field"},
+		{name: "missing expose", spec: "token=" + webToken, wantErr: "must set both token and expose"},
+		{name: "invalid token", spec: "token=invalid,expose=localhost:1", wantErr: "invalid token for localhost:1"},
+		{name: "subdomain with TCP token", spec: "token=" + tcpToken + ",expose=localhost:1,subdomain=db", wantErr: "only supported with web tokens"},
+		{name: "invalid subdomain", spec: "token=" + webToken + ",expose=localhost:1,subdomain=Bad_Label", wantErr: "invalid subdomain"},
+		{name: "type not allowed by token", spec: "token=" + tcpToken + ",expose=localhost:1,type=web", wantErr: "invalid type for localhost:1"},
+		{name: "type not allowed by multi-purpose token", spec: "token=" + multiToken + ",expose=localhost:1,type=udp", wantErr: "invalid type for localhost:1"},
+		{name: "unknown type", spec: "token=" + multiToken + ",expose=localhost:1,type=sctp", wantErr: "unknown tunnel type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTunnels([]string{tt.spec})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := parseRoutes([]string{"/api=localhost:8080,strip", "/=localhost:3000"})
+	require.NoError(t, err)
+	assert.Equal(t, []revclient.Route{
+		{Prefix: "/api", Target: "localhost:8080", StripPrefix: true},
+		{Prefix: "/", Target: "localhost:3000"},
+	}, routes)
+
+	_, err = parseRoutes([]string{"/api=localhost:8080", "/api=localhost:8081"})
+	assert.ErrorContains(t, err, "duplicate route prefix")
+}
+
+func TestTunnel_Forwarding(t *testing.T) {
+	assert.Equal(t, "localhost:3000", tunnel{expose: "localhost:3000"}.forwarding())
+
+	routes := []revclient.Route{{Prefix: "/api", Target: "localhost:8080", StripPrefix: true}}
+	assert.Equal(t, "/api -> localhost:8080 (strip), / -> localhost:3000",
+		tunnel{expose: "localhost:3000", routes: routes}.forwarding())
+
+	routes = append(routes, revclient.Route{Prefix: "/", Target: "localhost:4000"})
+	assert.Equal(t, "/api -> localhost:8080 (strip), / -> localhost:4000",
+		tunnel{expose: "localhost:3000", routes: routes}.forwarding())
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"gopkg.in/yaml.v3"
+)
+
+// clientConfig is the client configuration file holding named tunnels and stored credentials.
+// Server and Token are the defaults for tunnels that do not set their own.
+type clientConfig struct {
+	Tunnels map[string]tunnelConfig `yaml:"tunnels,omitempty"`
+	Server  string                  `yaml:"server,omitempty"`
+	Token   string                  `yaml:"token,omitempty"`
+}
+
+// tunnelConfig is a named tunnel definition in the client configuration file.
+// Routes are given in the format of --route.
+type tunnelConfig struct {
+	Server    string   `yaml:"server,omitempty"`
+	Token     string   `yaml:"token,omitempty"`
+	Expose    string   `yaml:"expose,omitempty"`
+	Subdomain string   `yaml:"subdomain,omitempty"`
+	Type      string   `yaml:"type,omitempty"`
+	Routes    []string `yaml:"routes,omitempty"`
+	NoTLS     bool     `yaml:"no_tls,omitempty"`
+	Insecure  bool     `yaml:"insecure,omitempty"`
+	DisableV2 bool     `yaml:"disable_v2,omitempty"`
+}
+
+// defaultClientConfigPath returns the path of the client configuration file in the user configuration directory,
+// for example ~/.config/mit/config.yaml on Linux.
+func defaultClientConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user config directory: %w", err)
+	}
+
+	return filepath.Join(dir, "mit", "config.yaml"), nil
+}
+
+// loadClientConfig reads the client configuration file at path.
+// A missing file is not an error, an empty configuration is returned instead.
+func loadClientConfig(path string) (*clientConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &clientConfig{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read client config: %w", err)
+	}
+
+	var cfg clientConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse client config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// saveClientConfig writes cfg to path, readable only by the current user as it holds tokens.
+// The file is replaced atomically so that a failed write never leaves a truncated configuration behind.
+func saveClientConfig(path string, cfg *clientConfig) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to encode client config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create client config directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create client config: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	// CreateTemp already uses 0600, the explicit chmod documents the requirement.
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to set client config permissions: %w", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write client config: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write client config: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write client config: %w", err)
+	}
+
+	return nil
+}
+
+// clientConfigPath returns the configuration path given with --config, or the default one.
+func clientConfigPath(arg *args) (string, error) {
+	if arg.ClientConfigPath != "" {
+		return arg.ClientConfigPath, nil
+	}
+
+	return defaultClientConfigPath()
+}
+
+// RunLogin reads a token from in and stores it in the client configuration file.
+// Without a name the token becomes the default token, otherwise it is stored for the named tunnel,
+// which is created if it does not exist yet. The token is read from in rather than taken as a flag
+// so that it does not end up in the shell history.
+// Returns an error if the token is invalid or the configuration cannot be saved.
+func RunLogin(_ context.Context, arg *args, name string, in io.Reader, out io.Writer) error {
+	path, err := clientConfigPath(arg)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadClientConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if arg.Interactive {
+		_, _ = fmt.Fprint(out, "Paste your token: ")
+	}
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read token: %w", err)
+	}
+
+	rawToken := strings.TrimSpace(line)
+	if rawToken == "" {
+		return fmt.Errorf("no token provided")
+	}
+
+	if _, err := token.Decode(rawToken); err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+
+	if name == "" {
+		cfg.Token = rawToken
+	} else {
+		if cfg.Tunnels == nil {
+			cfg.Tunnels = make(map[string]tunnelConfig)
+		}
+
+		tc := cfg.Tunnels[name]
+		tc.Token = rawToken
+		cfg.Tunnels[name] = tc
+	}
+
+	if err := saveClientConfig(path, cfg); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(out, "Token saved to %s\n", path)
+
+	return nil
+}
+
+// RunStart runs the tunnel with the given name from the client configuration file.
+// Settings missing from the tunnel definition fall back to the defaults of the configuration file and then to the
+// command-line defaults. changed reports whether a flag was set on the command line, such flags take precedence
+// over the configuration file.
+// Returns an error if the tunnel is not defined or the client fails.
+func RunStart(ctx context.Context, arg *args, name string, changed func(flag string) bool) error {
+	path, err := clientConfigPath(arg)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadClientConfig(path)
+	if err != nil {
+		return err
+	}
+
+	tc, ok := cfg.Tunnels[name]
+	if !ok {
+		return fmt.Errorf("tunnel %q is not defined in %s", name, path)
+	}
+
+	applyTunnelConfig(arg, cfg, tc, changed)
+
+	return RunClientCommand(ctx, arg)
+}
+
+// applyTunnelConfig sets the client arguments from the tunnel definition tc and the defaults of cfg, leaving
+// the arguments of the flags for which changed reports true as they are.
+func applyTunnelConfig(arg *args, cfg *clientConfig, tc tunnelConfig, changed func(flag string) bool) {
+	if !changed("server") {
+		switch {
+		case tc.Server != "":
+			arg.Server = tc.Server
+		case cfg.Server != "":
+			arg.Server = cfg.Server
+		}
+	}
+
+	if !changed("token") {
+		arg.Token = tc.Token
+		if arg.Token == "" {
+			arg.Token = cfg.Token
+		}
+	}
+
+	setUnlessChanged(changed, "expose", &arg.Expose, tc.Expose)
+	setUnlessChanged(changed, "subdomain", &arg.Subdomain, tc.Subdomain)
+	setUnlessChanged(changed, "route", &arg.Routes, tc.Routes)
+	setUnlessChanged(changed, "type", &arg.TunnelType, tc.Type)
+	setUnlessChanged(changed, "no-tls", &arg.NoTLS, tc.NoTLS)
+	setUnlessChanged(changed, "insecure", &arg.Insecure, tc.Insecure)
+	setUnlessChanged(changed, "disable-v2", &arg.DisableV2, tc.DisableV2)
+}
+
+// setUnlessChanged sets *arg to v unless changed reports that flag was set on the command line.
+func setUnlessChanged[T any](changed func(flag string) bool, flag string, arg *T, v T) {
+	if !changed(flag) {
+		*arg = v
+	}
+}
+
+// useStoredToken sets the token to the default token of the client configuration file, if there is one.
+func useStoredToken(arg *args) error {
+	path, err := clientConfigPath(arg)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadClientConfig(path)
+	if err != nil {
+		return err
+	}
+
+	arg.Token = cfg.Token
+
+	return nil
+}
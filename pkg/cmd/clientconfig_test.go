@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unchanged reports that no flag was set on the command line.
+func unchanged(string) bool { return false }
+
+func TestRunLogin(t *testing.T) {
+	webToken := "dGVzdGtleS13OnRlc3RzZWNyZXQ=" // #nosec G101 -- base64("testkey-w:testsecret"), web token for tests
+
+	path := filepath.Join(t.TempDir(), "mit", "config.yaml")
+	arg := &args{ClientConfigPath: path}
+
+	var out bytes.Buffer
+
+	require.NoError(t, RunLogin(context.Background(), arg, "", strings.NewReader(webToken+"\n"), &out))
+	require.NoError(t, RunLogin(context.Background(), arg, "api", strings.NewReader(webToken), &out))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.Contains(t, out.String(), "Token saved to "+path)
+
+	cfg, err := loadClientConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, webToken, cfg.Token)
+	assert.Equal(t, webToken, cfg.Tunnels["api"].Token)
+
+	err = RunLogin(context.Background(), arg, "", strings.NewReader("\n"), &out)
+	assert.ErrorContains(t, err, "no token provided")
+
+	err = RunLogin(context.Background(), arg, "", strings.NewReader("invalid-token\n"), &out)
+	assert.ErrorContains(t, err, "invalid token")
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		cfg, err := loadClientConfig(filepath.Join(t.TempDir(), "config.yaml"))
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Tunnels)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("tunnels: ["), 0o600))
+
+		_, err := loadClientConfig(path)
+		assert.ErrorContains(t, err, "failed to parse client config")
+	})
+}
+
+func TestApplyTunnelConfig(t *testing.T) {
+	const data = `
+server: mit.example.com:8081
+token: default-token
+tunnels:
+  web:
+    expose: localhost:3000
+    subdomain: frontend
+    routes: ["/api=localhost:8080,strip"]
+  db:
+    server: tcp.example.com:8081
+    token: db-token
+    expose: localhost:5432
+    type: tcp
+    no_tls: true
+    insecure: true
+    disable_v2: true
+`
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	cfg, err := loadClientConfig(path)
+	require.NoError(t, err)
+
+	web := &args{Server: "default:8081"}
+	applyTunnelConfig(web, cfg, cfg.Tunnels["web"], unchanged)
+
+	assert.Equal(t, &args{
+		Server:    "mit.example.com:8081",
+		Token:     "default-token",
+		Expose:    "localhost:3000",
+		Subdomain: "frontend",
+		Routes:    []string{"/api=localhost:8080,strip"},
+	}, web)
+
+	db := &args{Server: "default:8081"}
+	applyTunnelConfig(db, cfg, cfg.Tunnels["db"], unchanged)
+
+	assert.Equal(t, &args{
+		Server:     "tcp.example.com:8081",
+		Token:      "db-token",
+		Expose:     "localhost:5432",
+		TunnelType: "tcp",
+		NoTLS:      true,
+		Insecure:   true,
+		DisableV2:  true,
+	}, db)
+}
+
+func TestApplyTunnelConfig_FlagPrecedence(t *testing.T) {
+	cfg := &clientConfig{
+		Server: "mit.example.com:8081",
+		Token:  "default-token",
+	}
+	tc := tunnelConfig{
+		Token:     "db-token",
+		Expose:    "localhost:5432",
+		Subdomain: "db",
+		Type:      "tcp",
+		Routes:    []string{"/api=localhost:8080"},
+		NoTLS:     true,
+		Insecure:  true,
+		DisableV2: true,
+	}
+
+	arg := &args{}
+	cmd := initStartCommand(arg, "default:8081")
+
+	require.NoError(t, cmd.ParseFlags([]string{
+		"--server", "local:8081", "--token", "cli-token", "--expose", "localhost:6543", "--subdomain", "cli",
+		"--route", "/v2=localhost:9090", "--type", "web", "--no-tls=false", "--insecure=false",
+	}))
+
+	applyTunnelConfig(arg, cfg, tc, cmd.Flags().Changed)
+
+	assert.Equal(t, &args{
+		Server:     "local:8081",
+		Token:      "cli-token",
+		Expose:     "localhost:6543",
+		Subdomain:  "cli",
+		Routes:     []string{"/v2=localhost:9090"},
+		TunnelType: "web",
+		DisableV2:  true,
+	}, arg)
+}
+
+func TestRunStart_UnknownTunnel(t *testing.T) {
+	arg := &args{ClientConfigPath: filepath.Join(t.TempDir(), "config.yaml")}
+
+	err := RunStart(context.Background(), arg, "missing", unchanged)
+	assert.ErrorContains(t, err, `tunnel "missing" is not defined`)
+}
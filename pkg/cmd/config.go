@@ -4,21 +4,33 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/api"
+	"github.com/ksysoev/make-it-public/pkg/cluster"
 	"github.com/ksysoev/make-it-public/pkg/edge"
 	"github.com/ksysoev/make-it-public/pkg/repo/auth"
 	"github.com/ksysoev/make-it-public/pkg/revproxy"
 	"github.com/ksysoev/make-it-public/pkg/tcpedge"
+	"github.com/ksysoev/make-it-public/pkg/udpedge"
 	"github.com/spf13/viper"
 )
 
 type appConfig struct {
-	Auth     auth.Config     `mapstructure:"auth"`
 	RevProxy revproxy.Config `mapstructure:"reverse_proxy"`
+	Auth     auth.Config     `mapstructure:"auth"`
+	Cluster  cluster.Config  `mapstructure:"cluster"`
 	API      api.Config      `mapstructure:"api"`
 	TCP      tcpedge.Config  `mapstructure:"tcp"`
+	UDP      udpedge.Config  `mapstructure:"udp"`
 	HTTP     edge.Config     `mapstructure:"http"`
+	Shutdown shutdownConfig  `mapstructure:"shutdown"`
+}
+
+// shutdownConfig controls how the server drains its connections before exiting.
+// DrainTimeout is the maximum time to wait for in-flight connections, defaults to 30 seconds.
+type shutdownConfig struct {
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
 }
 
 // loadConfig loads the application configuration from the specified file path and environment variables.
@@ -4,9 +4,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/api"
 	"github.com/ksysoev/make-it-public/pkg/edge"
+	"github.com/ksysoev/make-it-public/pkg/repo/auth"
 	"github.com/ksysoev/make-it-public/pkg/revproxy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -40,6 +42,31 @@ api:
 				API:      api.Config{Listen: ":8082"},
 			},
 		},
+		{
+			name:        "static auth backend",
+			envVars:     nil,
+			expectError: false,
+			configData: validConfig + `
+auth:
+  backend: static
+  tokens:
+    - id: "team"
+      secret: "secret"
+      quota_bytes: 1024
+      quota_period: 1h
+`,
+			expectConfig: &appConfig{
+				HTTP:     edge.Config{Listen: ":8080"},
+				RevProxy: revproxy.Config{Listen: ":8081"},
+				API:      api.Config{Listen: ":8082"},
+				Auth: auth.Config{
+					Backend: auth.BackendStatic,
+					Tokens: []auth.StaticToken{
+						{ID: "team", Secret: "secret", QuotaBytes: 1024, QuotaPeriod: time.Hour},
+					},
+				},
+			},
+		},
 		{
 			name:        "missing config file",
 			envVars:     nil,
@@ -64,6 +91,32 @@ api:
 				API:      api.Config{Listen: ":8082"},
 			},
 		},
+		{
+			name: "http rate limit",
+			envVars: map[string]string{
+				"HTTP_RATE_LIMIT_CLIENT_RATE": "2.5",
+			},
+			expectError: false,
+			configData: `
+http:
+  listen: ":8080"
+  rate_limit:
+    tunnel_rate: 100
+    tunnel_burst: 200
+reverse_proxy:
+  listen: ":8081"
+api:
+  listen: ":8082"
+`,
+			expectConfig: &appConfig{
+				HTTP: edge.Config{
+					Listen:    ":8080",
+					RateLimit: edge.RateLimitConfig{TunnelRate: 100, TunnelBurst: 200, ClientRate: 2.5},
+				},
+				RevProxy: revproxy.Config{Listen: ":8081"},
+				API:      api.Config{Listen: ":8082"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -4,6 +4,8 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/make-it-public/pkg/inspector"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -13,23 +15,31 @@ type BuildInfo struct {
 	Version       string
 }
 type args struct {
-	Body        string `mapstructure:"body"`
-	Expose      string `mapstructure:"expose"`
-	Token       string `mapstructure:"token"`
-	ConfigPath  string `mapstructure:"config"`
-	LogLevel    string `mapstructure:"log_level"`
-	Version     string
-	Server      string   `mapstructure:"server"`
-	JSON        string   `mapstructure:"json"`
-	Headers     []string `mapstructure:"headers"`
-	Status      int      `mapstructure:"status"`
-	NoTLS       bool     `mapstructure:"no_tls"`
-	Interactive bool     `mapstructure:"interactive"`
-	LocalServer bool     `mapstructure:"local"`
-	TextFormat  bool     `mapstructure:"log_text"`
-	Insecure    bool     `mapstructure:"insecure"`
-	DisableV2   bool     `mapstructure:"disable_v2"`
-	EchoWS      bool     `mapstructure:"echo_ws"`
+	Body             string `mapstructure:"body"`
+	Expose           string `mapstructure:"expose"`
+	Subdomain        string `mapstructure:"subdomain"`
+	TunnelType       string `mapstructure:"type"`
+	InspectAddr      string `mapstructure:"inspect_addr"`
+	Token            string `mapstructure:"token"`
+	ConfigPath       string `mapstructure:"config"`
+	ClientConfigPath string
+	LogLevel         string `mapstructure:"log_level"`
+	Version          string
+	Server           string   `mapstructure:"server"`
+	JSON             string   `mapstructure:"json"`
+	Headers          []string `mapstructure:"headers"`
+	Tunnels          []string `mapstructure:"tunnels"`
+	Routes           []string `mapstructure:"routes"`
+	Status           int      `mapstructure:"status"`
+	MaxRetries       int      `mapstructure:"max_retries"`
+	NoTLS            bool     `mapstructure:"no_tls"`
+	Interactive      bool     `mapstructure:"interactive"`
+	LocalServer      bool     `mapstructure:"local"`
+	TextFormat       bool     `mapstructure:"log_text"`
+	Insecure         bool     `mapstructure:"insecure"`
+	DisableV2        bool     `mapstructure:"disable_v2"`
+	EchoWS           bool     `mapstructure:"echo_ws"`
+	Inspect          bool     `mapstructure:"inspect"`
 }
 
 // InitCommand initializes the root command of the CLI application with its subcommands and flags.
@@ -47,32 +57,39 @@ func InitCommand(build BuildInfo) cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, _ []string) error {
+			if arg.Token == "" {
+				if err := useStoredToken(&arg); err != nil {
+					return err
+				}
+			}
+
 			return RunClientCommand(cmd.Context(), &arg)
 		},
 	}
 
 	isInteractive := os.Stdout != nil && (os.Stdout.Fd() == 1 || os.Stdout.Fd() == 2) && os.Getenv("TERM") != ""
 
-	cmd.Flags().StringVar(&arg.Server, "server", build.DefaultServer, "server address")
-	cmd.Flags().StringVar(&arg.Expose, "expose", "", "expose service")
-	cmd.Flags().StringVar(&arg.Token, "token", "", "token")
-	cmd.Flags().BoolVar(&arg.NoTLS, "no-tls", false, "disable TLS")
-	cmd.Flags().BoolVar(&arg.Insecure, "insecure", false, "skip TLS verification")
-	cmd.Flags().BoolVar(&arg.DisableV2, "disable-v2", false, "disable V2 protocol (fallback to V1 for old servers)")
+	addTunnelFlags(&cmd, &arg, build.DefaultServer)
+	cmd.Flags().StringArrayVar(&arg.Tunnels, "tunnel", []string{}, "additional tunnel to run, can be repeated (format: 'token=<token>,expose=<host:port>[,subdomain=<label>][,type=<web|tcp|udp>]')")
+	cmd.Flags().IntVar(&arg.MaxRetries, "max-retries", 0, "maximum number of consecutive reconnection attempts, 0 to reconnect forever")
 	cmd.Flags().BoolVar(&arg.LocalServer, "dummy", false, "run local dummy web server that will print incoming requests(experimental feature)")
 	cmd.Flags().BoolVar(&arg.EchoWS, "echo-ws", false, "run local WebSocket echo server that echoes incoming messages")
 	cmd.Flags().StringVar(&arg.Body, "body", "", "response to send back to the client by the dummy server")
 	cmd.Flags().StringVar(&arg.JSON, "json", "", "JSON response to send back to the client by the dummy server")
 	cmd.Flags().IntVar(&arg.Status, "status", 200, "HTTP status code to return by the dummy server")
 	cmd.Flags().StringArrayVar(&arg.Headers, "headers", []string{}, "custom HTTP headers to return by the dummy server (format: 'Name:Value')")
+	cmd.Flags().BoolVar(&arg.Inspect, "inspect", false, "capture HTTP requests to the exposed service and serve a local web UI to browse and replay them")
+	cmd.Flags().StringVar(&arg.InspectAddr, "inspect-addr", inspector.DefaultListen, "listen address of the request inspector web UI")
 	cmd.Flags().BoolVar(&arg.Interactive, "interactive", isInteractive, "run in interactive mode")
 
 	cmd.PersistentFlags().StringVar(&arg.LogLevel, "log-level", "info", "log level (debug, info, warn, error)")
 	cmd.PersistentFlags().BoolVar(&arg.TextFormat, "log-text", true, "log in text format, otherwise JSON")
 
 	cmd.AddCommand(initServerCommand(&arg))
+	cmd.AddCommand(initLoginCommand(&arg))
+	cmd.AddCommand(initStartCommand(&arg, build.DefaultServer))
 
-	for _, name := range []string{"server", "expose", "token", "log_level", "log_text"} {
+	for _, name := range []string{"server", "expose", "token", "subdomain", "max_retries", "inspect", "inspect_addr", "log_level", "log_text"} {
 		if err := viper.BindEnv(name); err != nil {
 			slog.Error("failed to bind env var", "name", name, "error", err)
 		}
@@ -87,6 +104,66 @@ func InitCommand(build BuildInfo) cobra.Command {
 	return cmd
 }
 
+// addTunnelFlags adds to cmd the flags describing the tunnel to run, which the start command accepts as well to
+// override the settings of the named tunnel.
+func addTunnelFlags(cmd *cobra.Command, arg *args, defaultServer string) {
+	cmd.Flags().StringVar(&arg.Server, "server", defaultServer, "server address")
+	cmd.Flags().StringVar(&arg.Expose, "expose", "", "expose service")
+	cmd.Flags().StringVar(&arg.Token, "token", "", "token")
+	cmd.Flags().StringVar(&arg.Subdomain, "subdomain", "", "custom subdomain to request for web tunnels, the token must allow it")
+	cmd.Flags().StringArrayVar(&arg.Routes, "route", []string{}, "forward requests of the web tunnel by path prefix, can be repeated, --expose serves the other paths (format: '<prefix>=<host:port>[,strip]')")
+	cmd.Flags().StringVar(&arg.TunnelType, "type", "", "tunnel type to open with a multi-purpose token: web, tcp or udp, defaults to the first type of the token")
+	cmd.Flags().BoolVar(&arg.NoTLS, "no-tls", false, "disable TLS")
+	cmd.Flags().BoolVar(&arg.Insecure, "insecure", false, "skip TLS verification")
+	cmd.Flags().BoolVar(&arg.DisableV2, "disable-v2", false, "disable V2 protocol (fallback to V1 for old servers)")
+}
+
+// initLoginCommand initializes the "login" command that stores a token in the client configuration file.
+// Accepts arg of type *args to share the client settings with the root command.
+// Returns a pointer to the initialized cobra.Command.
+func initLoginCommand(arg *args) *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "login [tunnel]",
+		Short: "Store a token in the client configuration",
+		Long: "Read a token from standard input and store it in the client configuration file, readable only by the current user.\n" +
+			"Without a tunnel name the token becomes the default token used when --token is not set.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, posArgs []string) error {
+			name := ""
+			if len(posArgs) > 0 {
+				name = posArgs[0]
+			}
+
+			return RunLogin(cmd.Context(), arg, name, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&arg.ClientConfigPath, "config", "", "client config path (default: mit/config.yaml in the user config directory)")
+
+	return &cmd
+}
+
+// initStartCommand initializes the "start" command that runs a named tunnel from the client configuration file.
+// Accepts arg of type *args to share the client settings with the root command, and defaultServer as the default
+// of the --server flag. The tunnel flags passed to the command take precedence over the configuration file.
+// Returns a pointer to the initialized cobra.Command.
+func initStartCommand(arg *args, defaultServer string) *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "start <tunnel>",
+		Short: "Start a tunnel from the client configuration",
+		Long:  "Start a named tunnel defined in the client configuration file.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, posArgs []string) error {
+			return RunStart(cmd.Context(), arg, posArgs[0], cmd.Flags().Changed)
+		},
+	}
+
+	addTunnelFlags(&cmd, arg, defaultServer)
+	cmd.Flags().StringVar(&arg.ClientConfigPath, "config", "", "client config path (default: mit/config.yaml in the user config directory)")
+
+	return &cmd
+}
+
 // initServerCommand initializes the "server" command for the CLI application, adding necessary flags and subcommands.
 // It configures the command with options for specifying the configuration file, log level, and log format.
 // Accepts arg of type *args to set up custom behavior and flag bindings.
@@ -156,26 +233,57 @@ func initTokenCommand(arg *args) *cobra.Command {
 		Long:  "Token management commands for the server.",
 	}
 
-	var (
-		keyID     string
-		keyTTL    int
-		tokenType string
-	)
+	var flags generateTokenFlags
 
 	cmdGenerateToken := &cobra.Command{
 		Use:   "generate",
 		Short: "Generate a new token",
 		Long:  "Generate a new token for authentication.",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return RunGenerateToken(cmd.Context(), arg, keyID, keyTTL, tokenType)
+			return RunGenerateToken(cmd.Context(), arg, &flags)
+		},
+	}
+
+	cmdGenerateToken.Flags().StringVar(&flags.keyID, "key-id", "", "Key ID for the token")
+	cmdGenerateToken.Flags().IntVar(&flags.keyTTL, "ttl", 1, "Token time to live in hours")
+	cmdGenerateToken.Flags().StringVar(&flags.tokenType, "type", "web", "Token type: 'web' for HTTP tunnels, 'tcp' for TCP tunnels, 'udp' for UDP tunnels or a combination such as 'web,tcp'")
+	cmdGenerateToken.Flags().Int64Var(&flags.quotaBytes, "quota-bytes", 0, "Traffic quota in bytes per quota period, 0 means unlimited")
+	cmdGenerateToken.Flags().DurationVar(&flags.quotaPeriod, "quota-period", token.DefaultQuotaPeriod, "Period after which the traffic quota resets")
+	cmdGenerateToken.Flags().StringSliceVar(&flags.subdomains, "subdomain", nil, "Custom subdomain clients may request for web tunnels, can be repeated, '*' allows any subdomain")
+	cmdGenerateToken.Flags().StringVar(&flags.basicAuth, "basic-auth", "", "Require HTTP basic auth on the web tunnel (format: 'user:password')")
+	cmdGenerateToken.Flags().StringVar(&flags.accessKey, "access-key", "", "Require the key on the web tunnel, as a bearer token unless --access-header is set")
+	cmdGenerateToken.Flags().StringSliceVar(&flags.allowIPs, "allow-ip", nil, "Only accept end users from this IP address or CIDR prefix, can be repeated")
+	cmdGenerateToken.Flags().StringSliceVar(&flags.denyIPs, "deny-ip", nil, "Reject end users from this IP address or CIDR prefix, can be repeated")
+	cmdGenerateToken.Flags().StringVar(&flags.accessHeader, "access-header", "", "Header carrying the access key, defaults to 'Authorization: Bearer <key>'")
+	cmdGenerateToken.Flags().StringVar(&flags.hostHeader, "host-header", "", "Replace the Host header of the requests forwarded to the web tunnel (format: 'host[:port]')")
+	cmdGenerateToken.Flags().StringArrayVar(&flags.setReqHdrs, "set-request-header", nil, "Set a header on the requests forwarded to the web tunnel, can be repeated (format: 'Name:Value')")
+	cmdGenerateToken.Flags().StringSliceVar(&flags.delReqHdrs, "remove-request-header", nil, "Remove a header from the requests forwarded to the web tunnel, can be repeated")
+	cmdGenerateToken.Flags().StringArrayVar(&flags.setRespHdrs, "set-response-header", nil, "Set a header on the responses of the web tunnel, can be repeated (format: 'Name:Value')")
+	cmdGenerateToken.Flags().StringSliceVar(&flags.delRespHdrs, "remove-response-header", nil, "Remove a header from the responses of the web tunnel, can be repeated")
+	cmdGenerateToken.Flags().Float64Var(&flags.tunnelRate, "rate-limit", 0, "Requests per second accepted by the web tunnel, overrides the server limit, 0 keeps it")
+	cmdGenerateToken.Flags().IntVar(&flags.tunnelBurst, "rate-burst", 0, "Requests accepted at once by the web tunnel, defaults to --rate-limit rounded up")
+	cmdGenerateToken.Flags().Float64Var(&flags.clientRate, "client-rate-limit", 0, "Requests per second accepted from each end-user IP address, overrides the server limit, 0 keeps it")
+	cmdGenerateToken.Flags().IntVar(&flags.clientBurst, "client-rate-burst", 0, "Requests accepted at once from each end-user IP address, defaults to --client-rate-limit rounded up")
+	cmdGenerateToken.Flags().StringVar(&flags.description, "description", "", "Description stored with the token")
+	cmdGenerateToken.Flags().StringVar(&flags.owner, "owner", "", "Person or team the token is issued to")
+
+	var extendFlags extendTokenFlags
+
+	cmdExtendToken := &cobra.Command{
+		Use:   "extend",
+		Short: "Change the expiration of a token",
+		Long:  "Change the expiration of an existing token, keeping its key ID and secret.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunExtendToken(cmd.Context(), arg, &extendFlags)
 		},
 	}
 
-	cmdGenerateToken.Flags().StringVar(&keyID, "key-id", "", "Key ID for the token")
-	cmdGenerateToken.Flags().IntVar(&keyTTL, "ttl", 1, "Token time to live in hours")
-	cmdGenerateToken.Flags().StringVar(&tokenType, "type", "web", "Token type: 'web' for HTTP tunnels or 'tcp' for TCP tunnels")
+	cmdExtendToken.Flags().StringVar(&extendFlags.keyID, "key-id", "", "Key ID of the token")
+	cmdExtendToken.Flags().IntVar(&extendFlags.keyTTL, "ttl", 1, "New token time to live in hours, counted from now")
+	cmdExtendToken.Flags().BoolVar(&extendFlags.noExpiry, "no-expiry", false, "Make the token never expire")
+	cmdExtendToken.MarkFlagsMutuallyExclusive("ttl", "no-expiry")
 
-	cmd.AddCommand(cmdGenerateToken)
+	cmd.AddCommand(cmdGenerateToken, cmdExtendToken)
 
 	return &cmd
 }
@@ -14,8 +14,11 @@ func TestInitCommand(t *testing.T) {
 	assert.Contains(t, cmd.Short, "Make It Public")
 	assert.Contains(t, cmd.Long, "Make It Public Reverse Connect Proxy is a tool for exposing local services to the internet.")
 
-	require.Len(t, cmd.Commands(), 1)
-	assert.Equal(t, "server", cmd.Commands()[0].Use)
+	// Commands are sorted by name
+	require.Len(t, cmd.Commands(), 3)
+	assert.Equal(t, "login [tunnel]", cmd.Commands()[0].Use)
+	assert.Equal(t, "server", cmd.Commands()[1].Use)
+	assert.Equal(t, "start <tunnel>", cmd.Commands()[2].Use)
 }
 
 func TestInitRunCommand(t *testing.T) {
@@ -39,8 +42,8 @@ func TestInitTokenCommand(t *testing.T) {
 	assert.Contains(t, cmd.Short, "Token management")
 	assert.Contains(t, cmd.Long, "commands for the server")
 
-	require.Len(t, cmd.Commands(), 1)
-	generateCmd := cmd.Commands()[0]
+	require.Len(t, cmd.Commands(), 2)
+	generateCmd := cmd.Commands()[1]
 	assert.Equal(t, "generate", generateCmd.Use)
 	assert.Contains(t, generateCmd.Short, "Generate a new token")
 
@@ -54,4 +57,10 @@ func TestInitTokenCommand(t *testing.T) {
 	require.NotNil(t, ttlFlag)
 	assert.Equal(t, "1", ttlFlag.DefValue)
 	assert.Contains(t, ttlFlag.Usage, "Token time to live in hours")
+
+	extendCmd := cmd.Commands()[0]
+	assert.Equal(t, "extend", extendCmd.Use)
+	assert.NotNil(t, extendCmd.Flags().Lookup("key-id"))
+	assert.NotNil(t, extendCmd.Flags().Lookup("ttl"))
+	assert.NotNil(t, extendCmd.Flags().Lookup("no-expiry"))
 }
@@ -1,22 +1,31 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/api"
+	"github.com/ksysoev/make-it-public/pkg/cluster"
 	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/domainverify"
 	"github.com/ksysoev/make-it-public/pkg/edge"
 	"github.com/ksysoev/make-it-public/pkg/repo/auth"
 	"github.com/ksysoev/make-it-public/pkg/repo/connmng"
 	"github.com/ksysoev/make-it-public/pkg/revproxy"
 	"github.com/ksysoev/make-it-public/pkg/tcpedge"
+	"github.com/ksysoev/make-it-public/pkg/udpedge"
 	"golang.org/x/sync/errgroup"
 )
 
+const defaultDrainTimeout = 30 * time.Second
+
 // RunServerCommand initializes and starts both reverse proxy and HTTP servers for handling revclient connections.
 // It takes ctx of type context.Context for managing the server lifecycle and args of type *args to load configuration.
+// When ctx is cancelled the server is drained: public listeners are closed, connected clients are notified
+// that the server is going away and in-flight connections are given up to the configured drain timeout to finish.
 // It returns an error if the configuration fails to load, servers cannot start, or any runtime error occurs.
 func RunServerCommand(ctx context.Context, args *args) error {
 	if err := initLogger(args); err != nil {
@@ -28,13 +37,19 @@ func RunServerCommand(ctx context.Context, args *args) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	authRepo := auth.New(&cfg.Auth)
+	authRepo, err := auth.NewBackend(&cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to create auth repository: %w", err)
+	}
 
-	// Create two separate connection managers for web and TCP connections
+	// Create separate connection managers for web, TCP and UDP connections
 	webConnManager := connmng.New()
 	tcpConnManager := connmng.New()
+	udpConnManager := connmng.New()
+
+	connService := core.New(webConnManager, tcpConnManager, udpConnManager, authRepo)
+	connService.SetDomainVerifier(domainverify.New())
 
-	connService := core.New(webConnManager, tcpConnManager, authRepo)
 	apiServ := api.New(cfg.API, connService)
 
 	revServ, err := revproxy.New(&cfg.RevProxy, connService)
@@ -58,6 +73,36 @@ func RunServerCommand(ctx context.Context, args *args) error {
 		}
 	}
 
+	udpEnabled := cfg.UDP.PortRange.Min > 0 && cfg.UDP.PortRange.Max > 0
+
+	var udpServ *udpedge.UDPServer
+
+	if udpEnabled {
+		udpServ, err = udpedge.New(cfg.UDP, connService)
+		if err != nil {
+			return fmt.Errorf("failed to create UDP edge server: %w", err)
+		}
+	}
+
+	clusterEnabled := cfg.Cluster.Listen != ""
+
+	var clusterNode *cluster.Node
+
+	if clusterEnabled {
+		// The cluster registry shares the Redis server of the auth repository unless it is configured separately.
+		clusterCfg := cfg.Cluster
+		if clusterCfg.RedisAddr == "" {
+			clusterCfg.RedisAddr = cfg.Auth.RedisAddr
+			clusterCfg.Password = cfg.Auth.Password
+			clusterCfg.KeyPrefix = cmp.Or(clusterCfg.KeyPrefix, cfg.Auth.KeyPrefix)
+		}
+
+		clusterNode, err = cluster.New(&clusterCfg, connService)
+		if err != nil {
+			return fmt.Errorf("failed to create cluster node: %w", err)
+		}
+	}
+
 	logAttrs := []any{
 		"http", cfg.HTTP.Listen,
 		"rev", cfg.RevProxy.Listen,
@@ -70,17 +115,76 @@ func RunServerCommand(ctx context.Context, args *args) error {
 		logAttrs = append(logAttrs, "tcp", "disabled")
 	}
 
+	if udpEnabled {
+		logAttrs = append(logAttrs, "udp_port_range", fmt.Sprintf("%d-%d", cfg.UDP.PortRange.Min, cfg.UDP.PortRange.Max))
+	} else {
+		logAttrs = append(logAttrs, "udp", "disabled")
+	}
+
+	if clusterEnabled {
+		logAttrs = append(logAttrs, "cluster", cfg.Cluster.Listen, "node", clusterNode.ID())
+	}
+
 	slog.InfoContext(ctx, "server started", logAttrs...)
 
-	eg, ctx := errgroup.WithContext(ctx)
+	// Servers run on their own context, so that on shutdown connections can be drained before they are stopped.
+	runCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	defer stop()
+
+	eg, runCtx := errgroup.WithContext(runCtx)
+
+	// The public HTTP edge stops accepting new connections as soon as draining begins.
+	publicCtx, stopPublic := context.WithCancel(runCtx)
+	defer stopPublic()
 
-	eg.Go(func() error { return revServ.Run(ctx) })
-	eg.Go(func() error { return httpServ.Run(ctx) })
-	eg.Go(func() error { return apiServ.Run(ctx) })
+	eg.Go(func() error { return revServ.Run(runCtx) })
+	eg.Go(func() error { return httpServ.Run(publicCtx) })
+	eg.Go(func() error { return apiServ.Run(runCtx) })
 
 	if tcpEnabled {
-		eg.Go(func() error { return tcpServ.Run(ctx) })
+		eg.Go(func() error { return tcpServ.Run(runCtx) })
 	}
 
+	if udpEnabled {
+		eg.Go(func() error { return udpServ.Run(runCtx) })
+	}
+
+	if clusterEnabled {
+		eg.Go(func() error { return clusterNode.Run(runCtx) })
+	}
+
+	eg.Go(func() error {
+		defer stop()
+
+		select {
+		case <-runCtx.Done():
+			return nil
+		case <-ctx.Done():
+		}
+
+		stopPublic()
+
+		if tcpEnabled {
+			tcpServ.StopAccepting()
+		}
+
+		if udpEnabled {
+			udpServ.StopAccepting()
+		}
+
+		drainTimeout := cmp.Or(cfg.Shutdown.DrainTimeout, defaultDrainTimeout)
+
+		drainCtx, cancel := context.WithTimeout(runCtx, drainTimeout)
+		defer cancel()
+
+		slog.InfoContext(ctx, "server is shutting down", slog.Duration("drain_timeout", drainTimeout))
+
+		if err := connService.Drain(drainCtx); err != nil {
+			slog.WarnContext(ctx, "drain timed out, closing remaining connections", slog.Any("error", err))
+		}
+
+		return nil
+	})
+
 	return eg.Wait()
 }
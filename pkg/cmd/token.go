@@ -3,6 +3,9 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/core"
@@ -14,46 +17,169 @@ const (
 	secondsInHour = 3600
 )
 
+// generateTokenFlags holds the flags of the token generate command.
+type generateTokenFlags struct {
+	keyID        string
+	tokenType    string
+	basicAuth    string
+	accessKey    string
+	accessHeader string
+	description  string
+	owner        string
+	hostHeader   string
+	subdomains   []string
+	allowIPs     []string
+	denyIPs      []string
+	setReqHdrs   []string
+	delReqHdrs   []string
+	setRespHdrs  []string
+	delRespHdrs  []string
+	quotaBytes   int64
+	quotaPeriod  time.Duration
+	tunnelRate   float64
+	clientRate   float64
+	keyTTL       int
+	tunnelBurst  int
+	clientBurst  int
+}
+
+// extendTokenFlags holds the flags of the token extend command.
+type extendTokenFlags struct {
+	keyID    string
+	keyTTL   int
+	noExpiry bool
+}
+
+// policy builds the token policy from the flags.
+// Returns an error if the quota, the access restriction, the IP filter, the header rewrite rules or the rate limits
+// are invalid.
+func (f *generateTokenFlags) policy() (token.Policy, error) {
+	quota, err := token.NewQuota(f.quotaBytes, f.quotaPeriod)
+	if err != nil {
+		return token.Policy{}, fmt.Errorf("invalid quota: %w", err)
+	}
+
+	var username, password string
+
+	if f.basicAuth != "" {
+		var ok bool
+
+		if username, password, ok = strings.Cut(f.basicAuth, ":"); !ok {
+			return token.Policy{}, fmt.Errorf("invalid basic auth: format must be 'user:password'")
+		}
+	}
+
+	access, err := token.NewAccess(username, password, f.accessHeader, f.accessKey)
+	if err != nil {
+		return token.Policy{}, fmt.Errorf("invalid access: %w", err)
+	}
+
+	filter, err := token.NewIPFilter(f.allowIPs, f.denyIPs)
+	if err != nil {
+		return token.Policy{}, fmt.Errorf("invalid IP filter: %w", err)
+	}
+
+	rewrite, err := f.rewrite()
+	if err != nil {
+		return token.Policy{}, fmt.Errorf("invalid rewrite rules: %w", err)
+	}
+
+	rateLimit, err := token.NewRateLimit(token.RateLimit{
+		TunnelRate:  f.tunnelRate,
+		TunnelBurst: f.tunnelBurst,
+		ClientRate:  f.clientRate,
+		ClientBurst: f.clientBurst,
+	})
+	if err != nil {
+		return token.Policy{}, err
+	}
+
+	return token.Policy{
+		Quota:      quota,
+		Access:     access,
+		IPFilter:   filter,
+		Rewrite:    rewrite,
+		RateLimit:  rateLimit,
+		Subdomains: f.subdomains,
+	}, nil
+}
+
+// rewrite builds the header rewrite rules from the flags, headers to set are given as 'Name:Value'.
+// Returns nil if no rule is set, or an error if a rule is invalid.
+func (f *generateTokenFlags) rewrite() (*token.Rewrite, error) {
+	setReq, err := parseHeaderValues(f.setReqHdrs)
+	if err != nil {
+		return nil, err
+	}
+
+	setResp, err := parseHeaderValues(f.setRespHdrs)
+	if err != nil {
+		return nil, err
+	}
+
+	return token.NewRewrite(token.Rewrite{
+		Host:                  f.hostHeader,
+		SetRequestHeaders:     setReq,
+		RemoveRequestHeaders:  f.delReqHdrs,
+		SetResponseHeaders:    setResp,
+		RemoveResponseHeaders: f.delRespHdrs,
+	})
+}
+
+// parseHeaderValues parses headers given as 'Name:Value', surrounding whitespace of the value is trimmed.
+// Returns an error if a header has no colon.
+func parseHeaderValues(headers []string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]string, len(headers))
+
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q: format must be 'Name:Value'", h)
+		}
+
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return values, nil
+}
+
 // RunGenerateToken generates a new authentication token with a specified key ID, TTL, and type.
 // It initializes necessary services such as the logger and configuration loader,
 // validates inputs, and creates the token, printing the details upon success.
 // ctx is the context for managing request deadlines and cancellations.
 // args are the application configuration parameters.
-// keyID is the unique identifier for the token being generated.
-// keyTTL specifies the token's time to live in hours; it must be greater than 0.
-// tokenTypeStr specifies the token type: "web" or "tcp".
+// flags holds the key ID, the TTL in hours, which must be greater than 0, the token type ("web", "tcp", "udp" or a combination),
+// the optional traffic quota, custom subdomains, access restriction, IP filter and header rewrite rules of the token,
+// and the optional description and owner stored with it.
 // Returns an error if any step in initialization, configuration loading, or token generation fails.
-func RunGenerateToken(ctx context.Context, args *args, keyID string, keyTTL int, tokenTypeStr string) error {
-	if keyTTL < 1 {
+func RunGenerateToken(ctx context.Context, args *args, flags *generateTokenFlags) error {
+	if flags.keyTTL < 1 {
 		return fmt.Errorf("key TTL must be greater than 0")
 	}
 
-	// Validate and map token type
-	var tokenType token.TokenType
-
-	switch tokenTypeStr {
-	case "web":
-		tokenType = token.TokenTypeWeb
-	case "tcp":
-		tokenType = token.TokenTypeTCP
-	default:
-		return fmt.Errorf("invalid token type: must be 'web' or 'tcp'")
+	policy, err := flags.policy()
+	if err != nil {
+		return err
 	}
 
-	if err := initLogger(args); err != nil {
-		return fmt.Errorf("failed to init logger: %w", err)
+	tokenType, err := token.ParseTokenType(flags.tokenType)
+	if err != nil {
+		return fmt.Errorf("invalid token type: must be 'web', 'tcp', 'udp' or a combination such as 'web,tcp'")
 	}
 
-	cfg, err := loadConfig(args)
+	svc, err := newTokenService(args)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
-	authRepo := auth.New(&cfg.Auth)
-	// Pass nil for connection managers since token generation doesn't need them
-	svc := core.New(nil, nil, authRepo)
-
-	tok, err := svc.GenerateToken(ctx, keyID, keyTTL*secondsInHour, tokenType)
+	tok, err := svc.GenerateToken(ctx, flags.keyID, flags.keyTTL*secondsInHour, tokenType, policy, token.Meta{
+		Description: flags.description,
+		Owner:       flags.owner,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -63,5 +189,134 @@ func RunGenerateToken(ctx context.Context, args *args, keyID string, keyTTL int,
 	fmt.Println("Type:", tok.Type.String())
 	fmt.Println("Valid until:", time.Now().Add(tok.TTL).Format(time.RFC3339))
 
+	if tok.Meta.Owner != "" {
+		fmt.Println("Owner:", tok.Meta.Owner)
+	}
+
+	if tok.Meta.Description != "" {
+		fmt.Println("Description:", tok.Meta.Description)
+	}
+
+	if q := tok.Policy.Quota; q != nil {
+		fmt.Printf("Quota: %d bytes per %s\n", q.Bytes, q.Period)
+	}
+
+	if len(tok.Policy.Subdomains) > 0 {
+		fmt.Println("Subdomains:", strings.Join(tok.Policy.Subdomains, ", "))
+	}
+
+	if a := tok.Policy.Access; a != nil {
+		if a.IsBasic() {
+			fmt.Println("Access: basic auth as", a.Username)
+		} else {
+			fmt.Println("Access: key in", a.Header, "header")
+		}
+	}
+
+	if f := tok.Policy.IPFilter; f != nil && len(f.Allow) > 0 {
+		fmt.Println("Allowed IPs:", f.Allow)
+	}
+
+	if f := tok.Policy.IPFilter; f != nil && len(f.Deny) > 0 {
+		fmt.Println("Denied IPs:", f.Deny)
+	}
+
+	if rw := tok.Policy.Rewrite; rw != nil {
+		printRewrite(rw)
+	}
+
+	if rl := tok.Policy.RateLimit; rl != nil && rl.TunnelRate > 0 {
+		fmt.Printf("Tunnel rate limit: %g requests/s, burst %d\n", rl.TunnelRate, rl.TunnelBurst)
+	}
+
+	if rl := tok.Policy.RateLimit; rl != nil && rl.ClientRate > 0 {
+		fmt.Printf("Client rate limit: %g requests/s, burst %d\n", rl.ClientRate, rl.ClientBurst)
+	}
+
+	return nil
+}
+
+// printRewrite prints the header rewrite rules of a generated token.
+func printRewrite(rw *token.Rewrite) {
+	if rw.Host != "" {
+		fmt.Println("Host header:", rw.Host)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(rw.SetRequestHeaders)) {
+		fmt.Printf("Request header: %s: %s\n", name, rw.SetRequestHeaders[name])
+	}
+
+	if len(rw.RemoveRequestHeaders) > 0 {
+		fmt.Println("Removed request headers:", strings.Join(rw.RemoveRequestHeaders, ", "))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(rw.SetResponseHeaders)) {
+		fmt.Printf("Response header: %s: %s\n", name, rw.SetResponseHeaders[name])
+	}
+
+	if len(rw.RemoveResponseHeaders) > 0 {
+		fmt.Println("Removed response headers:", strings.Join(rw.RemoveResponseHeaders, ", "))
+	}
+}
+
+// RunExtendToken changes the expiration of an existing token while keeping its key ID and secret,
+// so that clients using the token keep working without reconfiguration.
+// flags holds the key ID, the new TTL in hours counted from now, which must be greater than 0,
+// or noExpiry to make the token never expire.
+// Returns an error if the flags are invalid, the token does not exist or the update fails.
+func RunExtendToken(ctx context.Context, args *args, flags *extendTokenFlags) error {
+	if flags.keyID == "" {
+		return fmt.Errorf("key ID is required")
+	}
+
+	ttl := 0
+
+	if !flags.noExpiry {
+		if flags.keyTTL < 1 {
+			return fmt.Errorf("key TTL must be greater than 0")
+		}
+
+		ttl = flags.keyTTL * secondsInHour
+	}
+
+	svc, err := newTokenService(args)
+	if err != nil {
+		return err
+	}
+
+	info, err := svc.SetTokenTTL(ctx, flags.keyID, ttl)
+	if err != nil {
+		return fmt.Errorf("failed to extend token: %w", err)
+	}
+
+	fmt.Println("Key ID:", info.ID)
+
+	if info.ExpiresAt.IsZero() {
+		fmt.Println("Valid until: never expires")
+	} else {
+		fmt.Println("Valid until:", info.ExpiresAt.Local().Format(time.RFC3339))
+	}
+
 	return nil
 }
+
+// newTokenService initializes the logger and creates the core service backed by the configured auth repository.
+// Returns an error if the logger, the configuration or the auth repository cannot be initialized.
+func newTokenService(args *args) (*core.Service, error) {
+	if err := initLogger(args); err != nil {
+		return nil, fmt.Errorf("failed to init logger: %w", err)
+	}
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	authRepo, err := auth.NewBackend(&cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auth repository: %w", err)
+	}
+
+	// Pass nil for connection managers since token management doesn't need them
+	return core.New(nil, nil, nil, authRepo), nil
+}
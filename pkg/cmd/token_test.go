@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/make-it-public/pkg/repo/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTokenFlags_Policy(t *testing.T) {
+	t.Run("no restrictions", func(t *testing.T) {
+		policy, err := (&generateTokenFlags{}).policy()
+		require.NoError(t, err)
+		assert.True(t, policy.IsZero())
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		policy, err := (&generateTokenFlags{basicAuth: "admin:pa:ss"}).policy()
+		require.NoError(t, err)
+		require.NotNil(t, policy.Access)
+		assert.True(t, policy.Access.CheckBasic("admin", "pa:ss"))
+	})
+
+	t.Run("access key", func(t *testing.T) {
+		policy, err := (&generateTokenFlags{accessKey: "secret", accessHeader: "X-Api-Key"}).policy()
+		require.NoError(t, err)
+		require.NotNil(t, policy.Access)
+		assert.Equal(t, "X-Api-Key", policy.Access.Header)
+		assert.True(t, policy.Access.CheckKey("secret"))
+	})
+
+	t.Run("invalid basic auth", func(t *testing.T) {
+		_, err := (&generateTokenFlags{basicAuth: "admin"}).policy()
+		assert.ErrorContains(t, err, "user:password")
+	})
+
+	t.Run("basic auth and access key", func(t *testing.T) {
+		_, err := (&generateTokenFlags{basicAuth: "admin:secret", accessKey: "secret"}).policy()
+		assert.ErrorIs(t, err, token.ErrInvalidAccess)
+	})
+
+	t.Run("ip filter", func(t *testing.T) {
+		policy, err := (&generateTokenFlags{allowIPs: []string{"10.0.0.0/8"}}).policy()
+		require.NoError(t, err)
+		require.NotNil(t, policy.IPFilter)
+		assert.True(t, policy.IPFilter.Allows("10.0.0.1"))
+		assert.False(t, policy.IPFilter.Allows("192.168.0.1"))
+
+		_, err = (&generateTokenFlags{denyIPs: []string{"bad"}}).policy()
+		assert.ErrorIs(t, err, token.ErrInvalidIPFilter)
+	})
+
+	t.Run("rewrite", func(t *testing.T) {
+		policy, err := (&generateTokenFlags{
+			hostHeader:  "localhost:3000",
+			setReqHdrs:  []string{"x-env: dev"},
+			delRespHdrs: []string{"server"},
+		}).policy()
+		require.NoError(t, err)
+		assert.Equal(t, &token.Rewrite{
+			Host:                  "localhost:3000",
+			SetRequestHeaders:     map[string]string{"X-Env": "dev"},
+			RemoveResponseHeaders: []string{"Server"},
+		}, policy.Rewrite)
+	})
+
+	t.Run("invalid rewrite", func(t *testing.T) {
+		_, err := (&generateTokenFlags{setRespHdrs: []string{"X-Env"}}).policy()
+		assert.ErrorContains(t, err, "Name:Value")
+
+		_, err = (&generateTokenFlags{delReqHdrs: []string{"Content-Length"}}).policy()
+		assert.ErrorIs(t, err, token.ErrInvalidRewrite)
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		policy, err := (&generateTokenFlags{clientRate: 1.5, tunnelRate: 100, tunnelBurst: 200}).policy()
+		require.NoError(t, err)
+		assert.Equal(t, &token.RateLimit{TunnelRate: 100, TunnelBurst: 200, ClientRate: 1.5, ClientBurst: 2}, policy.RateLimit)
+
+		_, err = (&generateTokenFlags{clientBurst: 5}).policy()
+		assert.ErrorIs(t, err, token.ErrInvalidRateLimit)
+	})
+
+	t.Run("invalid quota", func(t *testing.T) {
+		_, err := (&generateTokenFlags{quotaBytes: -1}).policy()
+		assert.ErrorIs(t, err, token.ErrInvalidQuota)
+	})
+}
+
+func TestRunExtendToken(t *testing.T) {
+	dir := t.TempDir()
+	tokensPath := filepath.Join(dir, "tokens.json")
+	configPath := filepath.Join(dir, "config.yaml")
+
+	require.NoError(t, os.WriteFile(configPath, []byte("auth:\n  backend: file\n  path: "+tokensPath+"\n"), 0o600))
+
+	arg := &args{ConfigPath: configPath, LogLevel: "error"}
+	ctx := context.Background()
+
+	require.NoError(t, RunGenerateToken(ctx, arg, &generateTokenFlags{keyID: "key1", keyTTL: 1, tokenType: "web"}))
+
+	require.NoError(t, RunExtendToken(ctx, arg, &extendTokenFlags{keyID: "key1", noExpiry: true}))
+
+	repo, err := auth.NewFileRepo(&auth.Config{Path: tokensPath})
+	require.NoError(t, err)
+
+	info, err := repo.GetTokenInfo(ctx, "key1")
+	require.NoError(t, err)
+	assert.True(t, info.ExpiresAt.IsZero())
+
+	require.NoError(t, RunExtendToken(ctx, arg, &extendTokenFlags{keyID: "key1", keyTTL: 48}))
+
+	// Reopen the file, the modification time may not change between two writes in quick succession.
+	repo, err = auth.NewFileRepo(&auth.Config{Path: tokensPath})
+	require.NoError(t, err)
+
+	info, err = repo.GetTokenInfo(ctx, "key1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), info.ExpiresAt, time.Minute)
+
+	assert.ErrorContains(t, RunExtendToken(ctx, arg, &extendTokenFlags{keyID: "key1"}), "greater than 0")
+	assert.ErrorContains(t, RunExtendToken(ctx, arg, &extendTokenFlags{keyTTL: 1}), "key ID is required")
+	assert.ErrorIs(t, RunExtendToken(ctx, arg, &extendTokenFlags{keyID: "unknown", keyTTL: 1}), core.ErrTokenNotFound)
+}
@@ -6,6 +6,7 @@ package core
 
 import (
 	context "context"
+	time "time"
 
 	token "github.com/ksysoev/make-it-public/pkg/core/token"
 	mock "github.com/stretchr/testify/mock"
@@ -24,6 +25,103 @@ func (_m *MockAuthRepo) EXPECT() *MockAuthRepo_Expecter {
 	return &MockAuthRepo_Expecter{mock: &_m.Mock}
 }
 
+// AddTrafficUsage provides a mock function with given fields: ctx, keyID, usage, period
+func (_m *MockAuthRepo) AddTrafficUsage(ctx context.Context, keyID string, usage TrafficUsage, period time.Duration) error {
+	ret := _m.Called(ctx, keyID, usage, period)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddTrafficUsage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, TrafficUsage, time.Duration) error); ok {
+		r0 = rf(ctx, keyID, usage, period)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockAuthRepo_AddTrafficUsage_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AddTrafficUsage'
+type MockAuthRepo_AddTrafficUsage_Call struct {
+	*mock.Call
+}
+
+// AddTrafficUsage is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - usage TrafficUsage
+//   - period time.Duration
+func (_e *MockAuthRepo_Expecter) AddTrafficUsage(ctx interface{}, keyID interface{}, usage interface{}, period interface{}) *MockAuthRepo_AddTrafficUsage_Call {
+	return &MockAuthRepo_AddTrafficUsage_Call{Call: _e.mock.On("AddTrafficUsage", ctx, keyID, usage, period)}
+}
+
+func (_c *MockAuthRepo_AddTrafficUsage_Call) Run(run func(ctx context.Context, keyID string, usage TrafficUsage, period time.Duration)) *MockAuthRepo_AddTrafficUsage_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(TrafficUsage), args[3].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_AddTrafficUsage_Call) Return(_a0 error) *MockAuthRepo_AddTrafficUsage_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockAuthRepo_AddTrafficUsage_Call) RunAndReturn(run func(context.Context, string, TrafficUsage, time.Duration) error) *MockAuthRepo_AddTrafficUsage_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// AttachDomain provides a mock function with given fields: ctx, domain, keyID
+func (_m *MockAuthRepo) AttachDomain(ctx context.Context, domain string, keyID string) error {
+	ret := _m.Called(ctx, domain, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AttachDomain")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, domain, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockAuthRepo_AttachDomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AttachDomain'
+type MockAuthRepo_AttachDomain_Call struct {
+	*mock.Call
+}
+
+// AttachDomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - domain string
+//   - keyID string
+func (_e *MockAuthRepo_Expecter) AttachDomain(ctx interface{}, domain interface{}, keyID interface{}) *MockAuthRepo_AttachDomain_Call {
+	return &MockAuthRepo_AttachDomain_Call{Call: _e.mock.On("AttachDomain", ctx, domain, keyID)}
+}
+
+func (_c *MockAuthRepo_AttachDomain_Call) Run(run func(ctx context.Context, domain string, keyID string)) *MockAuthRepo_AttachDomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_AttachDomain_Call) Return(_a0 error) *MockAuthRepo_AttachDomain_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockAuthRepo_AttachDomain_Call) RunAndReturn(run func(context.Context, string, string) error) *MockAuthRepo_AttachDomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // CheckHealth provides a mock function with given fields: ctx
 func (_m *MockAuthRepo) CheckHealth(ctx context.Context) error {
 	ret := _m.Called(ctx)
@@ -117,6 +215,226 @@ func (_c *MockAuthRepo_DeleteToken_Call) RunAndReturn(run func(context.Context,
 	return _c
 }
 
+// DetachDomain provides a mock function with given fields: ctx, domain, keyID
+func (_m *MockAuthRepo) DetachDomain(ctx context.Context, domain string, keyID string) error {
+	ret := _m.Called(ctx, domain, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DetachDomain")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, domain, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockAuthRepo_DetachDomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DetachDomain'
+type MockAuthRepo_DetachDomain_Call struct {
+	*mock.Call
+}
+
+// DetachDomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - domain string
+//   - keyID string
+func (_e *MockAuthRepo_Expecter) DetachDomain(ctx interface{}, domain interface{}, keyID interface{}) *MockAuthRepo_DetachDomain_Call {
+	return &MockAuthRepo_DetachDomain_Call{Call: _e.mock.On("DetachDomain", ctx, domain, keyID)}
+}
+
+func (_c *MockAuthRepo_DetachDomain_Call) Run(run func(ctx context.Context, domain string, keyID string)) *MockAuthRepo_DetachDomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_DetachDomain_Call) Return(_a0 error) *MockAuthRepo_DetachDomain_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockAuthRepo_DetachDomain_Call) RunAndReturn(run func(context.Context, string, string) error) *MockAuthRepo_DetachDomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetTokenInfo provides a mock function with given fields: ctx, keyID
+func (_m *MockAuthRepo) GetTokenInfo(ctx context.Context, keyID string) (token.Info, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTokenInfo")
+	}
+
+	var r0 token.Info
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (token.Info, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) token.Info); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(token.Info)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_GetTokenInfo_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetTokenInfo'
+type MockAuthRepo_GetTokenInfo_Call struct {
+	*mock.Call
+}
+
+// GetTokenInfo is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockAuthRepo_Expecter) GetTokenInfo(ctx interface{}, keyID interface{}) *MockAuthRepo_GetTokenInfo_Call {
+	return &MockAuthRepo_GetTokenInfo_Call{Call: _e.mock.On("GetTokenInfo", ctx, keyID)}
+}
+
+func (_c *MockAuthRepo_GetTokenInfo_Call) Run(run func(ctx context.Context, keyID string)) *MockAuthRepo_GetTokenInfo_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_GetTokenInfo_Call) Return(_a0 token.Info, _a1 error) *MockAuthRepo_GetTokenInfo_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_GetTokenInfo_Call) RunAndReturn(run func(context.Context, string) (token.Info, error)) *MockAuthRepo_GetTokenInfo_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetTokenPolicy provides a mock function with given fields: ctx, keyID
+func (_m *MockAuthRepo) GetTokenPolicy(ctx context.Context, keyID string) (token.Policy, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTokenPolicy")
+	}
+
+	var r0 token.Policy
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (token.Policy, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) token.Policy); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(token.Policy)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_GetTokenPolicy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetTokenPolicy'
+type MockAuthRepo_GetTokenPolicy_Call struct {
+	*mock.Call
+}
+
+// GetTokenPolicy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockAuthRepo_Expecter) GetTokenPolicy(ctx interface{}, keyID interface{}) *MockAuthRepo_GetTokenPolicy_Call {
+	return &MockAuthRepo_GetTokenPolicy_Call{Call: _e.mock.On("GetTokenPolicy", ctx, keyID)}
+}
+
+func (_c *MockAuthRepo_GetTokenPolicy_Call) Run(run func(ctx context.Context, keyID string)) *MockAuthRepo_GetTokenPolicy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_GetTokenPolicy_Call) Return(_a0 token.Policy, _a1 error) *MockAuthRepo_GetTokenPolicy_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_GetTokenPolicy_Call) RunAndReturn(run func(context.Context, string) (token.Policy, error)) *MockAuthRepo_GetTokenPolicy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetTrafficUsage provides a mock function with given fields: ctx, keyID, period
+func (_m *MockAuthRepo) GetTrafficUsage(ctx context.Context, keyID string, period time.Duration) (TrafficUsage, error) {
+	ret := _m.Called(ctx, keyID, period)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTrafficUsage")
+	}
+
+	var r0 TrafficUsage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) (TrafficUsage, error)); ok {
+		return rf(ctx, keyID, period)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) TrafficUsage); ok {
+		r0 = rf(ctx, keyID, period)
+	} else {
+		r0 = ret.Get(0).(TrafficUsage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration) error); ok {
+		r1 = rf(ctx, keyID, period)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_GetTrafficUsage_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetTrafficUsage'
+type MockAuthRepo_GetTrafficUsage_Call struct {
+	*mock.Call
+}
+
+// GetTrafficUsage is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - period time.Duration
+func (_e *MockAuthRepo_Expecter) GetTrafficUsage(ctx interface{}, keyID interface{}, period interface{}) *MockAuthRepo_GetTrafficUsage_Call {
+	return &MockAuthRepo_GetTrafficUsage_Call{Call: _e.mock.On("GetTrafficUsage", ctx, keyID, period)}
+}
+
+func (_c *MockAuthRepo_GetTrafficUsage_Call) Run(run func(ctx context.Context, keyID string, period time.Duration)) *MockAuthRepo_GetTrafficUsage_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_GetTrafficUsage_Call) Return(_a0 TrafficUsage, _a1 error) *MockAuthRepo_GetTrafficUsage_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_GetTrafficUsage_Call) RunAndReturn(run func(context.Context, string, time.Duration) (TrafficUsage, error)) *MockAuthRepo_GetTrafficUsage_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // IsKeyExists provides a mock function with given fields: ctx, keyID
 func (_m *MockAuthRepo) IsKeyExists(ctx context.Context, keyID string) (bool, error) {
 	ret := _m.Called(ctx, keyID)
@@ -174,6 +492,285 @@ func (_c *MockAuthRepo_IsKeyExists_Call) RunAndReturn(run func(context.Context,
 	return _c
 }
 
+// ListDomains provides a mock function with given fields: ctx, keyID
+func (_m *MockAuthRepo) ListDomains(ctx context.Context, keyID string) ([]string, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDomains")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]string, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_ListDomains_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListDomains'
+type MockAuthRepo_ListDomains_Call struct {
+	*mock.Call
+}
+
+// ListDomains is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockAuthRepo_Expecter) ListDomains(ctx interface{}, keyID interface{}) *MockAuthRepo_ListDomains_Call {
+	return &MockAuthRepo_ListDomains_Call{Call: _e.mock.On("ListDomains", ctx, keyID)}
+}
+
+func (_c *MockAuthRepo_ListDomains_Call) Run(run func(ctx context.Context, keyID string)) *MockAuthRepo_ListDomains_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_ListDomains_Call) Return(_a0 []string, _a1 error) *MockAuthRepo_ListDomains_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_ListDomains_Call) RunAndReturn(run func(context.Context, string) ([]string, error)) *MockAuthRepo_ListDomains_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListTokens provides a mock function with given fields: ctx
+func (_m *MockAuthRepo) ListTokens(ctx context.Context) ([]token.Info, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTokens")
+	}
+
+	var r0 []token.Info
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]token.Info, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []token.Info); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]token.Info)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_ListTokens_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListTokens'
+type MockAuthRepo_ListTokens_Call struct {
+	*mock.Call
+}
+
+// ListTokens is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockAuthRepo_Expecter) ListTokens(ctx interface{}) *MockAuthRepo_ListTokens_Call {
+	return &MockAuthRepo_ListTokens_Call{Call: _e.mock.On("ListTokens", ctx)}
+}
+
+func (_c *MockAuthRepo_ListTokens_Call) Run(run func(ctx context.Context)) *MockAuthRepo_ListTokens_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_ListTokens_Call) Return(_a0 []token.Info, _a1 error) *MockAuthRepo_ListTokens_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_ListTokens_Call) RunAndReturn(run func(context.Context) ([]token.Info, error)) *MockAuthRepo_ListTokens_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReserveSubdomain provides a mock function with given fields: ctx, label, keyID
+func (_m *MockAuthRepo) ReserveSubdomain(ctx context.Context, label string, keyID string) error {
+	ret := _m.Called(ctx, label, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReserveSubdomain")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, label, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockAuthRepo_ReserveSubdomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReserveSubdomain'
+type MockAuthRepo_ReserveSubdomain_Call struct {
+	*mock.Call
+}
+
+// ReserveSubdomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - label string
+//   - keyID string
+func (_e *MockAuthRepo_Expecter) ReserveSubdomain(ctx interface{}, label interface{}, keyID interface{}) *MockAuthRepo_ReserveSubdomain_Call {
+	return &MockAuthRepo_ReserveSubdomain_Call{Call: _e.mock.On("ReserveSubdomain", ctx, label, keyID)}
+}
+
+func (_c *MockAuthRepo_ReserveSubdomain_Call) Run(run func(ctx context.Context, label string, keyID string)) *MockAuthRepo_ReserveSubdomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_ReserveSubdomain_Call) Return(_a0 error) *MockAuthRepo_ReserveSubdomain_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockAuthRepo_ReserveSubdomain_Call) RunAndReturn(run func(context.Context, string, string) error) *MockAuthRepo_ReserveSubdomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResolveDomain provides a mock function with given fields: ctx, domain
+func (_m *MockAuthRepo) ResolveDomain(ctx context.Context, domain string) (string, error) {
+	ret := _m.Called(ctx, domain)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResolveDomain")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, domain)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, domain)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, domain)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_ResolveDomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResolveDomain'
+type MockAuthRepo_ResolveDomain_Call struct {
+	*mock.Call
+}
+
+// ResolveDomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - domain string
+func (_e *MockAuthRepo_Expecter) ResolveDomain(ctx interface{}, domain interface{}) *MockAuthRepo_ResolveDomain_Call {
+	return &MockAuthRepo_ResolveDomain_Call{Call: _e.mock.On("ResolveDomain", ctx, domain)}
+}
+
+func (_c *MockAuthRepo_ResolveDomain_Call) Run(run func(ctx context.Context, domain string)) *MockAuthRepo_ResolveDomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_ResolveDomain_Call) Return(_a0 string, _a1 error) *MockAuthRepo_ResolveDomain_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_ResolveDomain_Call) RunAndReturn(run func(context.Context, string) (string, error)) *MockAuthRepo_ResolveDomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResolveSubdomain provides a mock function with given fields: ctx, label
+func (_m *MockAuthRepo) ResolveSubdomain(ctx context.Context, label string) (string, error) {
+	ret := _m.Called(ctx, label)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResolveSubdomain")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, label)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, label)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, label)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_ResolveSubdomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResolveSubdomain'
+type MockAuthRepo_ResolveSubdomain_Call struct {
+	*mock.Call
+}
+
+// ResolveSubdomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - label string
+func (_e *MockAuthRepo_Expecter) ResolveSubdomain(ctx interface{}, label interface{}) *MockAuthRepo_ResolveSubdomain_Call {
+	return &MockAuthRepo_ResolveSubdomain_Call{Call: _e.mock.On("ResolveSubdomain", ctx, label)}
+}
+
+func (_c *MockAuthRepo_ResolveSubdomain_Call) Run(run func(ctx context.Context, label string)) *MockAuthRepo_ResolveSubdomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_ResolveSubdomain_Call) Return(_a0 string, _a1 error) *MockAuthRepo_ResolveSubdomain_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_ResolveSubdomain_Call) RunAndReturn(run func(context.Context, string) (string, error)) *MockAuthRepo_ResolveSubdomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SaveToken provides a mock function with given fields: ctx, t
 func (_m *MockAuthRepo) SaveToken(ctx context.Context, t *token.Token) error {
 	ret := _m.Called(ctx, t)
@@ -221,6 +818,64 @@ func (_c *MockAuthRepo_SaveToken_Call) RunAndReturn(run func(context.Context, *t
 	return _c
 }
 
+// SetTokenTTL provides a mock function with given fields: ctx, keyID, ttl
+func (_m *MockAuthRepo) SetTokenTTL(ctx context.Context, keyID string, ttl time.Duration) (token.Info, error) {
+	ret := _m.Called(ctx, keyID, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetTokenTTL")
+	}
+
+	var r0 token.Info
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) (token.Info, error)); ok {
+		return rf(ctx, keyID, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Duration) token.Info); ok {
+		r0 = rf(ctx, keyID, ttl)
+	} else {
+		r0 = ret.Get(0).(token.Info)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Duration) error); ok {
+		r1 = rf(ctx, keyID, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockAuthRepo_SetTokenTTL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetTokenTTL'
+type MockAuthRepo_SetTokenTTL_Call struct {
+	*mock.Call
+}
+
+// SetTokenTTL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - ttl time.Duration
+func (_e *MockAuthRepo_Expecter) SetTokenTTL(ctx interface{}, keyID interface{}, ttl interface{}) *MockAuthRepo_SetTokenTTL_Call {
+	return &MockAuthRepo_SetTokenTTL_Call{Call: _e.mock.On("SetTokenTTL", ctx, keyID, ttl)}
+}
+
+func (_c *MockAuthRepo_SetTokenTTL_Call) Run(run func(ctx context.Context, keyID string, ttl time.Duration)) *MockAuthRepo_SetTokenTTL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(time.Duration))
+	})
+	return _c
+}
+
+func (_c *MockAuthRepo_SetTokenTTL_Call) Return(_a0 token.Info, _a1 error) *MockAuthRepo_SetTokenTTL_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockAuthRepo_SetTokenTTL_Call) RunAndReturn(run func(context.Context, string, time.Duration) (token.Info, error)) *MockAuthRepo_SetTokenTTL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Verify provides a mock function with given fields: ctx, keyID, secret
 func (_m *MockAuthRepo) Verify(ctx context.Context, keyID string, secret string) (*token.Token, error) {
 	ret := _m.Called(ctx, keyID, secret)
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/ksysoev/make-it-public/pkg/core/conn"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"golang.org/x/sync/errgroup"
+)
+
+// Cluster routes end-user connections between the server nodes of a cluster, so that a connection
+// reaching any node can be served by the node the MIT client is connected to.
+// Register and Unregister announce the control connections held by this node, they are called once
+// per control connection. Dial opens a stream to another node that holds a control connection for keyID
+// and returns it once that node has connected to the MIT client. Dial returns ErrKeyIDNotFound
+// if no other node holds keyID. Disconnect asks the other nodes that hold control connections for keyID
+// to close them.
+type Cluster interface {
+	Register(ctx context.Context, keyID string) error
+	Unregister(ctx context.Context, keyID string) error
+	Dial(ctx context.Context, keyID string, tokenType token.TokenType, clientIP string) (conn.WithWriteCloser, error)
+	Disconnect(ctx context.Context, keyID string) error
+}
+
+// SetCluster sets the cluster used to reach MIT clients connected to other server nodes.
+// Without a cluster the service only serves clients connected to this node.
+func (s *Service) SetCluster(cluster Cluster) {
+	s.cluster = cluster
+}
+
+// HandleForwardedConnection serves an end-user connection that another node of the cluster forwarded
+// to this node, because this node holds the control connection of keyID.
+// ready is called once the reverse connection to the MIT client is established, before any data is proxied.
+// Forwarded connections are never forwarded again, so the error is ErrKeyIDNotFound if the client is not connected here.
+// Returns an error if tokenType is not supported or the connection cannot be served.
+func (s *Service) HandleForwardedConnection(
+	ctx context.Context,
+	keyID string,
+	tokenType token.TokenType,
+	cliConn net.Conn,
+	ready func() error,
+	clientIP string,
+) error {
+	switch tokenType {
+	case token.TokenTypeWeb:
+		return s.handleHTTPConnection(ctx, keyID, cliConn, func(net.Conn) error { return ready() }, clientIP, false)
+	case token.TokenTypeTCP, token.TokenTypeUDP:
+		return s.handleRawConnection(ctx, tokenType, keyID, cliConn, ready, clientIP, false)
+	default:
+		return fmt.Errorf("unsupported token type %q for forwarded connection", tokenType)
+	}
+}
+
+// registerInCluster announces that this node holds a control connection for keyID.
+// It returns a function that withdraws the announcement. Failures are logged, as the tunnel
+// still works for connections that reach this node.
+func (s *Service) registerInCluster(ctx context.Context, keyID string) func() {
+	if err := s.cluster.Register(ctx, keyID); err != nil {
+		slog.WarnContext(ctx, "failed to register tunnel in cluster", slog.String("keyID", keyID), slog.Any("error", err))
+	}
+
+	return func() {
+		if err := s.cluster.Unregister(context.WithoutCancel(ctx), keyID); err != nil {
+			slog.WarnContext(ctx, "failed to unregister tunnel from cluster", slog.String("keyID", keyID), slog.Any("error", err))
+		}
+	}
+}
+
+// dialCluster opens a stream to the node of the cluster that holds a control connection for keyID.
+// Returns ErrKeyIDNotFound if no other node holds keyID, or the error reported by the owning node.
+func (s *Service) dialCluster(ctx context.Context, keyID string, tokenType token.TokenType, clientIP string) (conn.WithWriteCloser, error) {
+	peerConn, err := s.cluster.Dial(ctx, keyID, tokenType, clientIP)
+
+	switch {
+	case err == nil:
+		return peerConn, nil
+	case errors.Is(err, ErrKeyIDNotFound), errors.Is(err, ErrQuotaExceeded):
+		return nil, err
+	default:
+		slog.DebugContext(ctx, "failed to forward connection to cluster node", slog.String("keyID", keyID), slog.Any("error", err))
+		return nil, fmt.Errorf("failed to forward connection: %w", ErrFailedToConnect)
+	}
+}
+
+// proxyToPeer writes the initial request data to a stream opened by dialCluster and pipes data
+// between the end-user connection and the stream until either side is done.
+// Traffic is accounted by the node that serves the tunnel, not by this node.
+// Returns ErrFailedToConnect if the peer did not respond, or an error if copying data fails.
+func proxyToPeer(ctx context.Context, cliConn net.Conn, peerConn conn.WithWriteCloser, write func(net.Conn) error) error {
+	defer func() { _ = peerConn.Close() }()
+
+	if err := write(peerConn); err != nil {
+		slog.DebugContext(ctx, "failed to write initial request to cluster node", slog.Any("error", err))
+		return fmt.Errorf("failed to write initial request: %w", ErrFailedToConnect)
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	connNopCloser := conn.NewContextConnNopCloser(egCtx, cliConn)
+	respBytesWritten := int64(0)
+
+	eg.Go(pipeToDest(egCtx, connNopCloser, peerConn))
+	eg.Go(pipeToSource(egCtx, peerConn, connNopCloser, &respBytesWritten))
+
+	guard := closeOnContextDone(egCtx, ctx, peerConn)
+	defer guard.Wait()
+
+	err := eg.Wait()
+
+	if respBytesWritten <= 0 {
+		return fmt.Errorf("no data received from cluster node: %w", ErrFailedToConnect)
+	}
+
+	if err != nil && !errors.Is(err, ErrConnClosed) {
+		return fmt.Errorf("failed to copy data: %w", err)
+	}
+
+	return nil
+}
+
+// noopCluster is the default cluster of a single-node deployment. It holds no other nodes.
+type noopCluster struct{}
+
+func (noopCluster) Register(_ context.Context, _ string) error { return nil }
+
+func (noopCluster) Unregister(_ context.Context, _ string) error { return nil }
+
+func (noopCluster) Disconnect(_ context.Context, _ string) error { return nil }
+
+func (noopCluster) Dial(_ context.Context, keyID string, _ token.TokenType, _ string) (conn.WithWriteCloser, error) {
+	return nil, fmt.Errorf("keyID %s is not connected to other nodes: %w", keyID, ErrKeyIDNotFound)
+}
@@ -0,0 +1,242 @@
+// Code generated by mockery. DO NOT EDIT.
+
+//go:build !compile
+
+package core
+
+import (
+	context "context"
+
+	conn "github.com/ksysoev/make-it-public/pkg/core/conn"
+	token "github.com/ksysoev/make-it-public/pkg/core/token"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockCluster is an autogenerated mock type for the Cluster type
+type MockCluster struct {
+	mock.Mock
+}
+
+type MockCluster_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockCluster) EXPECT() *MockCluster_Expecter {
+	return &MockCluster_Expecter{mock: &_m.Mock}
+}
+
+// Dial provides a mock function with given fields: ctx, keyID, tokenType, clientIP
+func (_m *MockCluster) Dial(ctx context.Context, keyID string, tokenType token.TokenType, clientIP string) (conn.WithWriteCloser, error) {
+	ret := _m.Called(ctx, keyID, tokenType, clientIP)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Dial")
+	}
+
+	var r0 conn.WithWriteCloser
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, token.TokenType, string) (conn.WithWriteCloser, error)); ok {
+		return rf(ctx, keyID, tokenType, clientIP)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, token.TokenType, string) conn.WithWriteCloser); ok {
+		r0 = rf(ctx, keyID, tokenType, clientIP)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(conn.WithWriteCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, token.TokenType, string) error); ok {
+		r1 = rf(ctx, keyID, tokenType, clientIP)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCluster_Dial_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Dial'
+type MockCluster_Dial_Call struct {
+	*mock.Call
+}
+
+// Dial is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - tokenType token.TokenType
+//   - clientIP string
+func (_e *MockCluster_Expecter) Dial(ctx interface{}, keyID interface{}, tokenType interface{}, clientIP interface{}) *MockCluster_Dial_Call {
+	return &MockCluster_Dial_Call{Call: _e.mock.On("Dial", ctx, keyID, tokenType, clientIP)}
+}
+
+func (_c *MockCluster_Dial_Call) Run(run func(ctx context.Context, keyID string, tokenType token.TokenType, clientIP string)) *MockCluster_Dial_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(token.TokenType), args[3].(string))
+	})
+	return _c
+}
+
+func (_c *MockCluster_Dial_Call) Return(_a0 conn.WithWriteCloser, _a1 error) *MockCluster_Dial_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCluster_Dial_Call) RunAndReturn(run func(context.Context, string, token.TokenType, string) (conn.WithWriteCloser, error)) *MockCluster_Dial_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Disconnect provides a mock function with given fields: ctx, keyID
+func (_m *MockCluster) Disconnect(ctx context.Context, keyID string) error {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Disconnect")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCluster_Disconnect_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Disconnect'
+type MockCluster_Disconnect_Call struct {
+	*mock.Call
+}
+
+// Disconnect is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockCluster_Expecter) Disconnect(ctx interface{}, keyID interface{}) *MockCluster_Disconnect_Call {
+	return &MockCluster_Disconnect_Call{Call: _e.mock.On("Disconnect", ctx, keyID)}
+}
+
+func (_c *MockCluster_Disconnect_Call) Run(run func(ctx context.Context, keyID string)) *MockCluster_Disconnect_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockCluster_Disconnect_Call) Return(_a0 error) *MockCluster_Disconnect_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCluster_Disconnect_Call) RunAndReturn(run func(context.Context, string) error) *MockCluster_Disconnect_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Register provides a mock function with given fields: ctx, keyID
+func (_m *MockCluster) Register(ctx context.Context, keyID string) error {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Register")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCluster_Register_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Register'
+type MockCluster_Register_Call struct {
+	*mock.Call
+}
+
+// Register is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockCluster_Expecter) Register(ctx interface{}, keyID interface{}) *MockCluster_Register_Call {
+	return &MockCluster_Register_Call{Call: _e.mock.On("Register", ctx, keyID)}
+}
+
+func (_c *MockCluster_Register_Call) Run(run func(ctx context.Context, keyID string)) *MockCluster_Register_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockCluster_Register_Call) Return(_a0 error) *MockCluster_Register_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCluster_Register_Call) RunAndReturn(run func(context.Context, string) error) *MockCluster_Register_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Unregister provides a mock function with given fields: ctx, keyID
+func (_m *MockCluster) Unregister(ctx context.Context, keyID string) error {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Unregister")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCluster_Unregister_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Unregister'
+type MockCluster_Unregister_Call struct {
+	*mock.Call
+}
+
+// Unregister is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockCluster_Expecter) Unregister(ctx interface{}, keyID interface{}) *MockCluster_Unregister_Call {
+	return &MockCluster_Unregister_Call{Call: _e.mock.On("Unregister", ctx, keyID)}
+}
+
+func (_c *MockCluster_Unregister_Call) Run(run func(ctx context.Context, keyID string)) *MockCluster_Unregister_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockCluster_Unregister_Call) Return(_a0 error) *MockCluster_Unregister_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCluster_Unregister_Call) RunAndReturn(run func(context.Context, string) error) *MockCluster_Unregister_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockCluster creates a new instance of MockCluster. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockCluster(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockCluster {
+	mock := &MockCluster{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/conn"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHTTPConnection_ForwardedToCluster(t *testing.T) {
+	connManager := NewMockConnManager(t)
+	authRepo := NewMockAuthRepo(t)
+	cluster := NewMockCluster(t)
+
+	peerServer, peerClient := net.Pipe()
+	cliServer, cliClient := net.Pipe()
+
+	defer cliClient.Close()
+
+	connManager.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, ErrKeyIDNotFound)
+	cluster.EXPECT().Dial(mock.Anything, "test-user", token.TokenTypeWeb, "127.0.0.1").
+		Return(&yamuxStreamWrapper{Conn: peerServer}, nil)
+
+	service := New(connManager, connManager, nil, authRepo)
+	service.SetCluster(cluster)
+
+	// The owning node reads the request and responds.
+	go func() {
+		defer peerClient.Close()
+
+		buf := make([]byte, len("request"))
+		if _, err := io.ReadFull(peerClient, buf); err != nil {
+			return
+		}
+
+		_, _ = peerClient.Write([]byte("response"))
+	}()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- service.HandleHTTPConnection(context.Background(), "test-user", cliServer, func(c net.Conn) error {
+			_, err := c.Write([]byte("request"))
+			return err
+		}, "127.0.0.1")
+	}()
+
+	buf := make([]byte, len("response"))
+	_, err := io.ReadFull(cliClient, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "response", string(buf))
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("HandleHTTPConnection did not return after the peer closed the stream")
+	}
+}
+
+func TestHandleHTTPConnection_ClusterErrors(t *testing.T) {
+	tests := []struct {
+		dialErr   error
+		wantErr   error
+		name      string
+		keyExists bool
+	}{
+		{name: "not connected anywhere", dialErr: ErrKeyIDNotFound, keyExists: true, wantErr: ErrFailedToConnect},
+		{name: "unknown key", dialErr: ErrKeyIDNotFound, keyExists: false, wantErr: ErrKeyIDNotFound},
+		{name: "quota exceeded on peer", dialErr: ErrQuotaExceeded, wantErr: ErrQuotaExceeded},
+		{name: "peer unreachable", dialErr: assert.AnError, wantErr: ErrFailedToConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connManager := NewMockConnManager(t)
+			authRepo := NewMockAuthRepo(t)
+			cluster := NewMockCluster(t)
+
+			connManager.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, ErrKeyIDNotFound)
+			cluster.EXPECT().Dial(mock.Anything, "test-user", token.TokenTypeWeb, "127.0.0.1").Return(nil, tt.dialErr)
+
+			if tt.dialErr == ErrKeyIDNotFound {
+				authRepo.EXPECT().IsKeyExists(mock.Anything, "test-user").Return(tt.keyExists, nil)
+			}
+
+			service := New(connManager, connManager, nil, authRepo)
+			service.SetCluster(cluster)
+
+			clientConn := conn.NewMockWithWriteCloser(t)
+			clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080})
+
+			err := service.HandleHTTPConnection(context.Background(), "test-user", clientConn, func(net.Conn) error { return nil }, "127.0.0.1")
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHandleForwardedConnection_NotForwardedAgain(t *testing.T) {
+	webConnMng := NewMockConnManager(t)
+	tcpConnMng := NewMockConnManager(t)
+	authRepo := NewMockAuthRepo(t)
+	cluster := NewMockCluster(t)
+
+	authRepo.EXPECT().IsKeyExists(mock.Anything, "test-user").Return(true, nil)
+	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, ErrKeyIDNotFound)
+
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
+	service.SetCluster(cluster)
+
+	clientConn := conn.NewMockWithWriteCloser(t)
+	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
+
+	ready := func() error {
+		t.Error("ready must not be called when the client is not connected")
+		return nil
+	}
+
+	err := service.HandleForwardedConnection(context.Background(), "test-user", token.TokenTypeTCP, clientConn, ready, "127.0.0.1")
+	assert.ErrorIs(t, err, ErrFailedToConnect)
+}
+
+func TestHandleForwardedConnection_UnsupportedType(t *testing.T) {
+	service := New(NewMockConnManager(t), NewMockConnManager(t), nil, NewMockAuthRepo(t))
+
+	err := service.HandleForwardedConnection(context.Background(), "test-user", token.TokenType("x"), nil, nil, "127.0.0.1")
+	assert.ErrorContains(t, err, "unsupported token type")
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"github.com/ksysoev/make-it-public/pkg/core/conn"
 	"github.com/ksysoev/make-it-public/pkg/core/conn/meta"
 	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/make-it-public/pkg/metrics"
 	"github.com/ksysoev/revdial/proto"
 	"golang.org/x/sync/errgroup"
 )
@@ -27,6 +29,10 @@ var (
 	ErrFailedToConnect = errors.New("failed to connect")
 	ErrKeyIDNotFound   = errors.New("keyID not found")
 	ErrConnClosed      = errors.New("connection closed")
+
+	// errAuthUnavailable marks failures of the authentication repository while a MIT client connects,
+	// as opposed to a rejection of its token or of the subdomain it requests.
+	errAuthUnavailable = errors.New("authentication is unavailable")
 )
 
 func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error {
@@ -39,13 +45,27 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 
 	var connTokenType token.TokenType
 
+	var connSubdomain string
+
 	// Use NewServerV2 to support both V1 and V2 protocols
 	// V2 provides yamux multiplexing for better performance
 	baseOpts := []proto.ServerOption{
-		proto.WithUserPassAuth(func(keyID, secret string) bool {
+		proto.WithUserPassAuth(func(username, secret string) bool {
+			keyID, subdomain := token.SplitSubdomain(username)
+
+			start := time.Now()
 			t, err := s.auth.Verify(ctx, keyID, secret)
+
+			metrics.AuthVerifyDuration.Observe(time.Since(start).Seconds())
+
 			if err != nil {
 				slog.ErrorContext(ctx, "failed to verify user", slog.Any("error", err))
+
+				// Malformed key IDs are rejected, storage failures must not make the client give up on its token.
+				if !errors.Is(err, token.ErrInvalidTypeSuffix) {
+					abortAuth(ctx, revConn)
+				}
+
 				return false
 			}
 
@@ -53,7 +73,40 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 				return false
 			}
 
+			// Clients choose the tunnel type at connect time, it must be one of the types the token allows.
+			allowed, err := s.isTypeAllowed(ctx, t)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to get token info", slog.String("keyID", t.ID), slog.Any("error", err))
+				abortAuth(ctx, revConn)
+
+				return false
+			}
+
+			if !allowed {
+				slog.WarnContext(ctx, "tunnel type not allowed for token", slog.String("keyID", t.ID), slog.String("type", t.Type.String()))
+				return false
+			}
+
+			// Clients request custom subdomains at connect time, a subdomain that cannot be used rejects the connection.
+			if subdomain != "" {
+				if t.Type != token.TokenTypeWeb {
+					slog.WarnContext(ctx, "subdomain requested for non-web token", slog.String("keyID", t.ID))
+					return false
+				}
+
+				if err := s.reserveSubdomain(ctx, t.ID, subdomain); err != nil {
+					slog.WarnContext(ctx, "failed to reserve subdomain", slog.String("keyID", t.ID), slog.Any("error", err))
+
+					if errors.Is(err, errAuthUnavailable) {
+						abortAuth(ctx, revConn)
+					}
+
+					return false
+				}
+			}
+
 			connKeyID = t.ID
+			connSubdomain = subdomain
 			connTokenType = t.Type
 
 			return true
@@ -86,17 +139,24 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 	case proto.StateRegistered:
 		srvConn := conn.NewServerConn(ctx, servConn)
 
-		// Route to the correct connection manager based on token type.
-		connMng := s.webConnMng
-		if connTokenType == token.TokenTypeTCP {
-			connMng = s.tcpConnMng
+		// A draining server does not take new clients, they are asked to connect elsewhere.
+		if s.isDraining() {
+			if err := srvConn.SendServerGoingAwayEvent(); err != nil {
+				slog.DebugContext(ctx, "failed to send server going away event", slog.Any("error", err))
+			}
+
+			return nil
 		}
 
+		// Route to the correct connection manager based on token type.
+		connMng := s.connManager(connTokenType)
+
 		// Generate the public endpoint for the client to advertise.
-		// TCP tokens get a dynamically allocated port; web tokens get a subdomain URL.
+		// TCP and UDP tokens get a dynamically allocated port; web tokens get a subdomain URL.
 		var endpoint string
 
-		if connTokenType == token.TokenTypeTCP {
+		switch connTokenType {
+		case token.TokenTypeTCP:
 			ep, err := s.tcpEndpointAllocator.Allocate(srvConn.Context(), connKeyID)
 			if err != nil {
 				return fmt.Errorf("failed to allocate TCP endpoint: %w", err)
@@ -105,8 +165,17 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 			defer s.tcpEndpointAllocator.Release(connKeyID)
 
 			endpoint = ep
-		} else {
-			ep, err := s.endpointGenerator(connKeyID)
+		case token.TokenTypeUDP:
+			ep, err := s.udpEndpointAllocator.Allocate(srvConn.Context(), connKeyID)
+			if err != nil {
+				return fmt.Errorf("failed to allocate UDP endpoint: %w", err)
+			}
+
+			defer s.udpEndpointAllocator.Release(connKeyID)
+
+			endpoint = ep
+		default:
+			ep, err := s.endpointGenerator(cmp.Or(connSubdomain, connKeyID))
 			if err != nil {
 				return fmt.Errorf("failed to generate endpoint: %w", err)
 			}
@@ -118,19 +187,35 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 			return fmt.Errorf("failed to send url to connect updated event: %w", err)
 		}
 
+		defer s.tunnels.add(Tunnel{
+			ID:          srvConn.ID(),
+			KeyID:       connKeyID,
+			Type:        connTokenType,
+			Protocol:    protocolVersion,
+			RemoteAddr:  revConn.RemoteAddr().String(),
+			ConnectedAt: time.Now(),
+			Endpoint:    endpoint,
+		}, srvConn)()
+
 		connMng.AddConnection(connKeyID, srvConn)
 
 		defer connMng.RemoveConnection(connKeyID, srvConn.ID())
+		defer s.registerInCluster(ctx, connKeyID)()
+
+		activeConns := metrics.ControlConnections.WithLabelValues(connTokenType.String())
+		activeConns.Inc()
 
-		protocolVersion := "V1"
+		defer activeConns.Dec()
+
+		logProtocol := protocolVersion
 		if servConn.IsV2() {
-			protocolVersion = "V2 (yamux multiplexed)"
+			logProtocol = "V2 (yamux multiplexed)"
 		}
 
 		slog.InfoContext(ctx, "control conn established",
 			slog.String("keyID", connKeyID),
 			slog.String("tokenType", string(connTokenType)),
-			slog.String("protocol", protocolVersion))
+			slog.String("protocol", logProtocol))
 
 		// For V2 connections, start accepting yamux streams in the background.
 		// The client opens new streams (instead of new TCP connections) for each data connection.
@@ -138,10 +223,21 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 			go s.acceptV2Streams(srvConn.Context(), servConn, connKeyID, connMng)
 		}
 
+		draining := s.draining
+
 		for {
 			select {
 			case <-srvConn.Context().Done():
 				return nil
+			case <-draining:
+				// The connection stays open so that in-flight requests can complete.
+				draining = nil
+
+				if err := srvConn.SendServerGoingAwayEvent(); err != nil {
+					slog.DebugContext(ctx, "failed to send server going away event", slog.Any("error", err))
+				}
+
+				continue
 			case <-time.After(200 * time.Millisecond):
 			}
 
@@ -158,10 +254,7 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 		}
 
 		// Route to the correct connection manager based on token type
-		connMng := s.webConnMng
-		if connTokenType == token.TokenTypeTCP {
-			connMng = s.tcpConnMng
-		}
+		connMng := s.connManager(connTokenType)
 
 		connMng.ResolveRequest(servConn.ID(), notifier)
 		slog.InfoContext(ctx, "rev conn established", slog.String("keyID", connKeyID), slog.String("tokenType", string(connTokenType)))
@@ -175,14 +268,72 @@ func (s *Service) HandleReverseConn(ctx context.Context, revConn net.Conn) error
 	}
 }
 
+// isTypeAllowed reports whether the verified token t may open a tunnel of the type the client connected for.
+// Tokens that do not record their type, like tokens issued before types were stored, allow any tunnel type.
+// Tokens deleted since they were verified allow none.
+// Returns an error if the token cannot be read from the authentication repository.
+func (s *Service) isTypeAllowed(ctx context.Context, t *token.Token) (bool, error) {
+	info, err := s.auth.GetTokenInfo(ctx, t.ID)
+
+	switch {
+	case errors.Is(err, ErrTokenNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return info.Type == "" || info.Type.Allows(t.Type), nil
+}
+
+// abortAuth closes revConn without answering the authentication of the MIT client, when its credentials cannot be
+// checked because the authentication repository fails. The client sees a network failure and connects again later,
+// whereas a rejection would make it give up on its token. The authentication callback must return false afterwards.
+func abortAuth(ctx context.Context, revConn net.Conn) {
+	if err := revConn.Close(); err != nil {
+		slog.DebugContext(ctx, "failed to close connection", slog.Any("error", err))
+	}
+}
+
+// HandleHTTPConnection handles an incoming HTTP connection from an end-user.
+// It requests a reverse tunnel connection from the MIT client identified by keyID, writes the initial
+// request data with write and then bidirectionally pipes data between the end-user connection and the tunnel.
+// If the client is not connected to this node, the connection is forwarded to the node of the cluster that holds it.
+// Returns ErrKeyIDNotFound if the tunnel does not exist, ErrQuotaExceeded if its traffic quota is used up,
+// or ErrFailedToConnect if the MIT client cannot be reached.
 func (s *Service) HandleHTTPConnection(ctx context.Context, keyID string, cliConn net.Conn, write func(net.Conn) error, clientIP string) error {
+	return s.handleHTTPConnection(ctx, keyID, cliConn, write, clientIP, true)
+}
+
+// handleHTTPConnection implements HandleHTTPConnection, forward controls whether
+// connections for clients that are not connected to this node are forwarded to other cluster nodes.
+func (s *Service) handleHTTPConnection(
+	ctx context.Context,
+	keyID string,
+	cliConn net.Conn,
+	write func(net.Conn) error,
+	clientIP string,
+	forward bool,
+) error {
 	slog.DebugContext(ctx, "new HTTP connection", slog.Any("remote", cliConn.RemoteAddr()))
 	defer slog.DebugContext(ctx, "closing HTTP connection", slog.Any("remote", cliConn.RemoteAddr()))
 
+	defer s.conns.add()()
+
 	// HTTP connections always use the web connection manager
 	req, err := s.webConnMng.RequestConnection(ctx, keyID)
 
 	switch {
+	case errors.Is(err, ErrKeyIDNotFound) && forward:
+		peerConn, err := s.dialCluster(ctx, keyID, token.TokenTypeWeb, clientIP)
+		if err == nil {
+			return proxyToPeer(ctx, cliConn, peerConn, write)
+		}
+
+		if !errors.Is(err, ErrKeyIDNotFound) {
+			return err
+		}
+
+		fallthrough
 	case errors.Is(err, ErrKeyIDNotFound):
 		ok, err := s.auth.IsKeyExists(ctx, keyID)
 		if err != nil {
@@ -198,12 +349,24 @@ func (s *Service) HandleHTTPConnection(ctx context.Context, keyID string, cliCon
 		return fmt.Errorf("failed to request connection: %w", ErrFailedToConnect)
 	}
 
+	// The policy of the tunnel is only loaded once it is known to be connected to this node, connections for
+	// unknown keyIDs never reach the auth repository and forwarded ones are metered by the node serving them.
+	meter, err := s.newTrafficMeter(ctx, keyID)
+	if err != nil {
+		s.webConnMng.CancelRequest(req.ID())
+		return err
+	}
+
+	defer s.releaseTrafficMeter(ctx, meter)
+
 	revConn, err := req.WaitConn(ctx)
 	if err != nil {
 		s.webConnMng.CancelRequest(req.ID())
 		return fmt.Errorf("connection request failed: %w", ErrFailedToConnect)
 	}
 
+	defer s.tunnels.track(req.ConnID())()
+
 	slog.DebugContext(ctx, "connection received", slog.Any("remote", cliConn.RemoteAddr()))
 
 	if err := meta.WriteData(revConn, &meta.ClientConnMeta{IP: clientIP}); err != nil {
@@ -213,9 +376,13 @@ func (s *Service) HandleHTTPConnection(ctx context.Context, keyID string, cliCon
 	}
 
 	// Write initial request data
-	if err := write(revConn); err != nil {
+	if err := write(&meteredConn{Conn: revConn, meter: meter}); err != nil {
 		slog.DebugContext(ctx, "failed to write initial request", slog.Any("error", err))
 
+		if errors.Is(err, ErrQuotaExceeded) {
+			return fmt.Errorf("failed to write initial request: %w", ErrQuotaExceeded)
+		}
+
 		return fmt.Errorf("failed to write initial request: %w", ErrFailedToConnect)
 	}
 
@@ -223,14 +390,25 @@ func (s *Service) HandleHTTPConnection(ctx context.Context, keyID string, cliCon
 	connNopCloser := conn.NewContextConnNopCloser(ctx, cliConn)
 	respBytesWritten := int64(0)
 
-	eg.Go(pipeToDest(ctx, connNopCloser, revConn))
-	eg.Go(pipeToSource(ctx, revConn, connNopCloser, &respBytesWritten))
+	eg.Go(pipeToDest(ctx, meter.reader(connNopCloser), revConn))
+	eg.Go(pipeToSource(ctx, revConn, meter.writer(connNopCloser), &respBytesWritten))
 
 	guard := closeOnContextDone(ctx, req.ParentContext(), revConn)
 	defer guard.Wait()
 
 	err = eg.Wait()
 
+	if errors.Is(err, ErrQuotaExceeded) {
+		if respBytesWritten <= 0 {
+			return fmt.Errorf("failed to proxy request: %w", ErrQuotaExceeded)
+		}
+
+		// The response has already started, so the connection is cut instead of reporting an error page.
+		slog.InfoContext(ctx, "traffic quota exceeded, connection closed", slog.String("keyID", keyID))
+
+		return nil
+	}
+
 	if respBytesWritten <= 0 {
 		slog.DebugContext(ctx, "no data written to reverse connection", slog.Any("error", err))
 		return fmt.Errorf("no data written to reverse connection: %w", ErrFailedToConnect)
@@ -418,6 +596,8 @@ func pipeToDest(ctx context.Context, src io.Reader, dst conn.WithWriteCloser) fu
 		n, err := io.Copy(dst, src)
 		slog.DebugContext(ctx, "data copied to reverse connection", slog.Any("error", err), slog.Int64("bytes_written", n))
 
+		metrics.PipedBytes.WithLabelValues("inbound").Add(float64(n))
+
 		switch {
 		case errors.Is(err, net.ErrClosed), errors.Is(err, syscall.ECONNRESET):
 			return ErrConnClosed
@@ -445,6 +625,8 @@ func pipeToSource(ctx context.Context, src conn.WithWriteCloser, dst io.Writer,
 		*written, err = io.Copy(dst, src)
 		slog.DebugContext(ctx, "data copied from reverse connection", slog.Int64("bytes_written", *written), slog.Any("error", err))
 
+		metrics.PipedBytes.WithLabelValues("outbound").Add(float64(*written))
+
 		switch {
 		case errors.Is(err, net.ErrClosed), errors.Is(err, syscall.ECONNRESET):
 			return ErrConnClosed
@@ -488,13 +670,58 @@ func closeOnContextDone(reqCtx, parentCtx context.Context, c conn.WithWriteClose
 // It requests a reverse tunnel connection from the MIT client identified by keyID,
 // writes connection metadata, and then bidirectionally pipes data between the
 // end-user connection and the reverse tunnel.
+// Returns ErrQuotaExceeded if the traffic quota of the tunnel is already used up;
+// a connection that exhausts the quota while piping is closed.
 func (s *Service) HandleTCPConnection(ctx context.Context, keyID string, cliConn net.Conn, clientIP string) error {
-	slog.DebugContext(ctx, "new TCP connection", slog.Any("remote", cliConn.RemoteAddr()))
-	defer slog.DebugContext(ctx, "closing TCP connection", slog.Any("remote", cliConn.RemoteAddr()))
+	return s.handleRawConnection(ctx, token.TokenTypeTCP, keyID, cliConn, func() error { return nil }, clientIP, true)
+}
+
+// HandleUDPConnection handles a UDP session of an end-user, identified by its source address.
+// cliConn carries the datagrams of the session framed with the dgram package, they are piped through
+// a reverse tunnel connection to the MIT client identified by keyID like the data of a TCP connection.
+// Returns ErrQuotaExceeded if the traffic quota of the tunnel is already used up;
+// a session that exhausts the quota while piping is closed.
+func (s *Service) HandleUDPConnection(ctx context.Context, keyID string, cliConn net.Conn, clientIP string) error {
+	return s.handleRawConnection(ctx, token.TokenTypeUDP, keyID, cliConn, func() error { return nil }, clientIP, true)
+}
+
+// handleRawConnection implements HandleTCPConnection and HandleUDPConnection for tunnels of tokenType.
+// ready is called once the reverse connection is established and forward controls whether connections for
+// clients that are not connected to this node are forwarded to other cluster nodes.
+func (s *Service) handleRawConnection(
+	ctx context.Context,
+	tokenType token.TokenType,
+	keyID string,
+	cliConn net.Conn,
+	ready func() error,
+	clientIP string,
+	forward bool,
+) error {
+	slog.DebugContext(ctx, "new raw connection", slog.String("type", tokenType.String()), slog.Any("remote", cliConn.RemoteAddr()))
+	defer slog.DebugContext(ctx, "closing raw connection", slog.String("type", tokenType.String()), slog.Any("remote", cliConn.RemoteAddr()))
+
+	defer s.conns.add()()
 
-	req, err := s.tcpConnMng.RequestConnection(ctx, keyID)
+	connMng := s.connManager(tokenType)
+
+	req, err := connMng.RequestConnection(ctx, keyID)
 
 	switch {
+	case errors.Is(err, ErrKeyIDNotFound) && forward:
+		peerConn, err := s.dialCluster(ctx, keyID, tokenType, clientIP)
+		if err == nil {
+			if err := proxyToPeer(ctx, cliConn, peerConn, func(net.Conn) error { return nil }); err != nil {
+				slog.DebugContext(ctx, "forwarded raw data pipe closed", slog.String("type", tokenType.String()), slog.Any("error", err))
+			}
+
+			return nil
+		}
+
+		if !errors.Is(err, ErrKeyIDNotFound) {
+			return err
+		}
+
+		fallthrough
 	case errors.Is(err, ErrKeyIDNotFound):
 		ok, authErr := s.auth.IsKeyExists(ctx, keyID)
 		if authErr != nil {
@@ -507,37 +734,57 @@ func (s *Service) HandleTCPConnection(ctx context.Context, keyID string, cliConn
 
 		return fmt.Errorf("no connections available for keyID %s: %w", keyID, ErrFailedToConnect)
 	case err != nil:
-		return fmt.Errorf("failed to request TCP connection: %w", ErrFailedToConnect)
+		return fmt.Errorf("failed to request %s connection: %w", tokenType, ErrFailedToConnect)
+	}
+
+	// As with HTTP connections, the policy is only loaded for tunnels connected to this node.
+	meter, err := s.newTrafficMeter(ctx, keyID)
+	if err != nil {
+		connMng.CancelRequest(req.ID())
+		return err
 	}
 
+	defer s.releaseTrafficMeter(ctx, meter)
+
 	revConn, err := req.WaitConn(ctx)
 	if err != nil {
-		s.tcpConnMng.CancelRequest(req.ID())
-		return fmt.Errorf("TCP connection request failed: %w", ErrFailedToConnect)
+		connMng.CancelRequest(req.ID())
+		return fmt.Errorf("%s connection request failed: %w", tokenType, ErrFailedToConnect)
 	}
 
-	slog.DebugContext(ctx, "TCP reverse connection received", slog.Any("remote", cliConn.RemoteAddr()))
+	defer s.tunnels.track(req.ConnID())()
+
+	slog.DebugContext(ctx, "raw reverse connection received", slog.String("type", tokenType.String()), slog.Any("remote", cliConn.RemoteAddr()))
 
 	if err := meta.WriteData(revConn, &meta.ClientConnMeta{IP: clientIP}); err != nil {
-		slog.DebugContext(ctx, "failed to write TCP client connection meta", slog.Any("error", err))
+		slog.DebugContext(ctx, "failed to write raw client connection meta", slog.Any("error", err))
+
+		_ = revConn.Close()
+
+		return fmt.Errorf("failed to write %s client connection meta: %w", tokenType, ErrFailedToConnect)
+	}
 
+	if err := ready(); err != nil {
 		_ = revConn.Close()
 
-		return fmt.Errorf("failed to write TCP client connection meta: %w", ErrFailedToConnect)
+		return fmt.Errorf("failed to signal %s connection readiness: %w", tokenType, ErrFailedToConnect)
 	}
 
 	eg, egCtx := errgroup.WithContext(ctx)
 	connNopCloser := conn.NewContextConnNopCloser(egCtx, cliConn)
 	respBytesWritten := int64(0)
 
-	eg.Go(pipeToDest(egCtx, connNopCloser, revConn))
-	eg.Go(pipeToSource(egCtx, revConn, connNopCloser, &respBytesWritten))
+	eg.Go(pipeToDest(egCtx, meter.reader(connNopCloser), revConn))
+	eg.Go(pipeToSource(egCtx, revConn, meter.writer(connNopCloser), &respBytesWritten))
 
 	guard := closeOnContextDone(egCtx, req.ParentContext(), revConn)
 	defer guard.Wait()
 
-	if err := eg.Wait(); err != nil && !errors.Is(err, ErrConnClosed) {
-		slog.DebugContext(ctx, "TCP data pipe closed", slog.Any("error", err))
+	switch err := eg.Wait(); {
+	case errors.Is(err, ErrQuotaExceeded):
+		slog.InfoContext(ctx, "traffic quota exceeded, connection closed", slog.String("keyID", keyID), slog.String("type", tokenType.String()))
+	case err != nil && !errors.Is(err, ErrConnClosed):
+		slog.DebugContext(ctx, "raw data pipe closed", slog.String("type", tokenType.String()), slog.Any("error", err))
 	}
 
 	return nil
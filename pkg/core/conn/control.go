@@ -64,16 +64,21 @@ func (r *ControlConn) Close() error {
 	return r.conn.Close()
 }
 
-// RequestConnection initiates a new connection request by issuing a connect command to the server.
-// It ensures the server is in a registered state before proceeding.
-// Returns a pointer to request containing the connection request details and an error if the server is not connected or if the command fails to send.
-func (r *ControlConn) RequestConnection() (Request, error) {
-	req := newRequest(r.Context())
-	if err := r.conn.SendConnectCommand(req.ID()); err != nil {
-		return nil, fmt.Errorf("failed to send connect command: %w", err)
+// NewRequest creates a connection request issued on the control connection.
+// The client is asked to connect for it with SendConnectCommand, which must only be called once the request
+// is registered, as the client may connect back before the command returns.
+func (r *ControlConn) NewRequest() Request {
+	return newRequest(r.Context(), r.ID())
+}
+
+// SendConnectCommand asks the client to open a connection for the request with the given id.
+// Returns an error if the server is not connected or if the command fails to send.
+func (r *ControlConn) SendConnectCommand(id uuid.UUID) error {
+	if err := r.conn.SendConnectCommand(id); err != nil {
+		return fmt.Errorf("failed to send connect command: %w", err)
 	}
 
-	return req, nil
+	return nil
 }
 
 // Ping sends a ping command to the server to verify the connection's responsiveness.
@@ -89,3 +94,10 @@ func (r *ControlConn) Ping() error {
 func (r *ControlConn) SendURLToConnectUpdatedEvent(url string) error {
 	return r.conn.SendCustomEvent("urlToConnectUpdated", url)
 }
+
+// SendServerGoingAwayEvent notifies the client that the server is shutting down.
+// The client is expected to finish its in-flight requests and reconnect, possibly to another server.
+// It returns an error if the event fails to send.
+func (r *ControlConn) SendServerGoingAwayEvent() error {
+	return r.conn.SendCustomEvent("serverGoingAway", nil)
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/ksysoev/revdial/proto"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -52,7 +51,21 @@ func TestServConn_Close(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestServConn_RequestConnection(t *testing.T) {
+func TestServConn_NewRequest(t *testing.T) {
+	connID := uuid.New()
+
+	mockConn := NewMockserverConn(t)
+	mockConn.EXPECT().ID().Return(connID)
+
+	sc := NewServerConn(context.Background(), mockConn)
+	req := sc.NewRequest()
+
+	assert.NotEqual(t, uuid.Nil, req.ID())
+	assert.Equal(t, connID, req.ConnID())
+	assert.Equal(t, sc.Context(), req.ParentContext())
+}
+
+func TestServConn_SendConnectCommand(t *testing.T) {
 	tests := []struct {
 		mockSendResponse error
 		expectedError    error
@@ -66,17 +79,16 @@ func TestServConn_RequestConnection(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockConn := NewMockserverConn(t)
+			reqID := uuid.New()
 
-			mockConn.EXPECT().SendConnectCommand(mock.Anything).Return(tt.mockSendResponse)
+			mockConn.EXPECT().SendConnectCommand(reqID).Return(tt.mockSendResponse)
 
 			sc := NewServerConn(context.Background(), mockConn)
-			req, err := sc.RequestConnection()
+			err := sc.SendConnectCommand(reqID)
 
 			if tt.expectedError != nil {
-				assert.Nil(t, req)
 				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
-				assert.NotNil(t, req)
 				assert.NoError(t, err)
 			}
 		})
@@ -171,3 +183,12 @@ func TestControlConn_Ping(t *testing.T) {
 		})
 	}
 }
+
+func TestControlConn_SendServerGoingAwayEvent(t *testing.T) {
+	mockConn := NewMockserverConn(t)
+	mockConn.EXPECT().SendCustomEvent("serverGoingAway", nil).Return(nil)
+
+	cc := NewServerConn(context.Background(), mockConn)
+
+	assert.NoError(t, cc.SendServerGoingAwayEvent())
+}
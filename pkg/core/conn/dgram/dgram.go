@@ -0,0 +1,56 @@
+// Package dgram frames UDP datagrams over the stream connections of the tunnel.
+// Each datagram is sent as its length encoded as uint16 followed by its payload, so that datagram boundaries
+// survive the stream.
+package dgram
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxSize is the largest datagram payload that can be framed.
+const MaxSize = 65535
+
+var ErrTooLarge = errors.New("datagram exceeds maximum size")
+
+// WriteFrame writes the datagram p to w as a single frame.
+// Returns ErrTooLarge if p is larger than MaxSize, or an error if writing fails.
+func WriteFrame(w io.Writer, p []byte) error {
+	if len(p) > MaxSize {
+		return fmt.Errorf("datagram of %d bytes: %w", len(p), ErrTooLarge)
+	}
+
+	frame := make([]byte, 2+len(p))
+	binary.BigEndian.PutUint16(frame, uint16(len(p)))
+	copy(frame[2:], p)
+
+	// The frame is written at once so that concurrent writers never interleave headers and payloads.
+	if _, err := w.Write(frame); err != nil {
+		return fmt.Errorf("failed to write datagram: %w", err)
+	}
+
+	return nil
+}
+
+// ReadFrame reads a single frame from r into buf and returns the size of the datagram.
+// Returns io.EOF if r ends before a frame starts, io.ErrShortBuffer if buf cannot hold the datagram,
+// or an error if reading fails.
+func ReadFrame(r io.Reader, buf []byte) (int, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+
+	size := int(binary.BigEndian.Uint16(header[:]))
+	if size > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+
+	if _, err := io.ReadFull(r, buf[:size]); err != nil {
+		return 0, fmt.Errorf("failed to read datagram: %w", err)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,51 @@
+package dgram
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAndReadFrame(t *testing.T) {
+	var stream bytes.Buffer
+
+	datagrams := [][]byte{[]byte("ping"), {}, bytes.Repeat([]byte{0xAB}, MaxSize)}
+
+	for _, d := range datagrams {
+		require.NoError(t, WriteFrame(&stream, d))
+	}
+
+	buf := make([]byte, MaxSize)
+
+	for _, want := range datagrams {
+		n, err := ReadFrame(&stream, buf)
+		require.NoError(t, err)
+		assert.Equal(t, want, buf[:n])
+	}
+
+	_, err := ReadFrame(&stream, buf)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestWriteFrame_TooLarge(t *testing.T) {
+	var stream bytes.Buffer
+
+	err := WriteFrame(&stream, make([]byte, MaxSize+1))
+	require.ErrorIs(t, err, ErrTooLarge)
+	assert.Zero(t, stream.Len())
+}
+
+func TestReadFrame_Errors(t *testing.T) {
+	var stream bytes.Buffer
+
+	require.NoError(t, WriteFrame(&stream, []byte("datagram")))
+
+	_, err := ReadFrame(bytes.NewReader(stream.Bytes()), make([]byte, 4))
+	assert.ErrorIs(t, err, io.ErrShortBuffer)
+
+	_, err = ReadFrame(bytes.NewReader(stream.Bytes()[:5]), make([]byte, MaxSize))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
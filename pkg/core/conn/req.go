@@ -11,6 +11,7 @@ import (
 // It provides methods to retrieve a unique identifier, access associated contexts, wait for a response, and handle cancellation.
 type Request interface {
 	ID() uuid.UUID
+	ConnID() uuid.UUID
 	ParentContext() context.Context
 	WaitConn(ctx context.Context) (WithWriteCloser, error)
 	SendConn(ctx context.Context, conn WithWriteCloser)
@@ -19,19 +20,22 @@ type Request interface {
 
 // request represents a connection request with a unique identifier, channel for delivering the connection, and context for cancellation.
 type request struct {
-	ctx context.Context
-	ch  chan WithWriteCloser
-	id  uuid.UUID
+	ctx    context.Context
+	ch     chan WithWriteCloser
+	id     uuid.UUID
+	connID uuid.UUID
 }
 
 // newRequest creates a new request instance with a unique identifier, channel for delivering connections, and a context.
 // It ensures the request is initialized with a provided parent context to manage cancellation or timeouts.
+// connID is the identifier of the control connection the request is issued on.
 // Returns a pointer to the created request.
-func newRequest(ctx context.Context) *request {
+func newRequest(ctx context.Context, connID uuid.UUID) *request {
 	return &request{
-		id:  uuid.New(),
-		ch:  make(chan WithWriteCloser),
-		ctx: ctx,
+		id:     uuid.New(),
+		connID: connID,
+		ch:     make(chan WithWriteCloser),
+		ctx:    ctx,
 	}
 }
 
@@ -40,6 +44,11 @@ func (r *request) ID() uuid.UUID {
 	return r.id
 }
 
+// ConnID retrieves the unique identifier (UUID) of the control connection the request is issued on.
+func (r *request) ConnID() uuid.UUID {
+	return r.connID
+}
+
 // ParentContext retrieves the parent context associated with the connection request.
 // It allows callers to observe cancellation or manage lifetimes using the parent's context.
 func (r *request) ParentContext() context.Context {
@@ -16,16 +16,25 @@ func TestConnReq_ID(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	connReq := newRequest(ctx)
+	connReq := newRequest(ctx, uuid.New())
 
 	assert.NotZero(t, connReq.ID())
 	assert.IsType(t, uuid.UUID{}, connReq.ID())
 }
 
+func TestConnReq_ConnID(t *testing.T) {
+	t.Parallel()
+
+	connID := uuid.New()
+	connReq := newRequest(t.Context(), connID)
+
+	assert.Equal(t, connID, connReq.ConnID())
+}
+
 func TestConnReq_ParentContext(t *testing.T) {
 	t.Parallel()
 
-	connReq := newRequest(t.Context())
+	connReq := newRequest(t.Context(), uuid.New())
 
 	assert.Equal(t, t.Context(), connReq.ParentContext())
 }
@@ -51,7 +60,7 @@ func TestConnReq_WaitConn(t *testing.T) {
 			parentCtx, parentCancel := context.WithCancel(ctx)
 			defer parentCancel()
 
-			connReq := newRequest(parentCtx)
+			connReq := newRequest(parentCtx, uuid.New())
 
 			if tt.parentDone {
 				parentCancel()
@@ -106,7 +115,7 @@ func TestConnReq_SendConn(t *testing.T) {
 			parentCtx, parentCancel := context.WithCancel(ctx)
 			defer parentCancel()
 
-			connReq := newRequest(parentCtx)
+			connReq := newRequest(parentCtx, uuid.New())
 
 			if tt.parentDone {
 				parentCancel()
@@ -151,7 +160,7 @@ func TestConnReq_Cancel(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	connReq := newRequest(ctx)
+	connReq := newRequest(ctx, uuid.New())
 
 	go func() {
 		connReq.Cancel()
@@ -170,7 +179,7 @@ func TestNewConnReq(t *testing.T) {
 
 	ctx := context.Background()
 
-	connReq := newRequest(ctx)
+	connReq := newRequest(ctx, uuid.New())
 
 	require.NotNil(t, connReq)
 	assert.Equal(t, ctx, connReq.ctx)
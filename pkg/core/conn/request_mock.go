@@ -56,6 +56,53 @@ func (_c *MockRequest_Cancel_Call) RunAndReturn(run func()) *MockRequest_Cancel_
 	return _c
 }
 
+// ConnID provides a mock function with no fields
+func (_m *MockRequest) ConnID() uuid.UUID {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConnID")
+	}
+
+	var r0 uuid.UUID
+	if rf, ok := ret.Get(0).(func() uuid.UUID); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(uuid.UUID)
+		}
+	}
+
+	return r0
+}
+
+// MockRequest_ConnID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ConnID'
+type MockRequest_ConnID_Call struct {
+	*mock.Call
+}
+
+// ConnID is a helper method to define mock.On call
+func (_e *MockRequest_Expecter) ConnID() *MockRequest_ConnID_Call {
+	return &MockRequest_ConnID_Call{Call: _e.mock.On("ConnID")}
+}
+
+func (_c *MockRequest_ConnID_Call) Run(run func()) *MockRequest_ConnID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockRequest_ConnID_Call) Return(_a0 uuid.UUID) *MockRequest_ConnID_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRequest_ConnID_Call) RunAndReturn(run func() uuid.UUID) *MockRequest_ConnID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ID provides a mock function with no fields
 func (_m *MockRequest) ID() uuid.UUID {
 	ret := _m.Called()
@@ -7,11 +7,9 @@ package core
 import (
 	context "context"
 
+	uuid "github.com/google/uuid"
 	conn "github.com/ksysoev/make-it-public/pkg/core/conn"
-
 	mock "github.com/stretchr/testify/mock"
-
-	uuid "github.com/google/uuid"
 )
 
 // MockConnManager is an autogenerated mock type for the ConnManager type
@@ -14,6 +14,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/ksysoev/make-it-public/pkg/core/conn"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
 	"github.com/ksysoev/revdial/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -26,7 +27,7 @@ func TestHandleHTTPConnection_ConnectionRequestFailure(t *testing.T) {
 
 	connManager.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, errors.New("connection failed"))
 
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080})
@@ -38,18 +39,118 @@ func TestHandleHTTPConnection_ConnectionRequestFailure(t *testing.T) {
 	require.ErrorIs(t, err, ErrFailedToConnect)
 }
 
+func TestService_IsTypeAllowed(t *testing.T) {
+	tests := []struct {
+		err      error
+		name     string
+		stored   token.TokenType
+		tunnel   token.TokenType
+		expected bool
+	}{
+		{name: "same type", stored: token.TokenTypeWeb, tunnel: token.TokenTypeWeb, expected: true},
+		{name: "other type", stored: token.TokenTypeWeb, tunnel: token.TokenTypeTCP, expected: false},
+		{name: "multi-purpose web", stored: token.TokenType("wt"), tunnel: token.TokenTypeWeb, expected: true},
+		{name: "multi-purpose tcp", stored: token.TokenType("wt"), tunnel: token.TokenTypeTCP, expected: true},
+		{name: "type not recorded", stored: "", tunnel: token.TokenTypeTCP, expected: true},
+		{name: "token deleted", err: ErrTokenNotFound, tunnel: token.TokenTypeWeb, expected: false},
+		{name: "repository error", err: assert.AnError, tunnel: token.TokenTypeWeb, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authRepo := NewMockAuthRepo(t)
+			authRepo.EXPECT().GetTokenInfo(mock.Anything, "key1").Return(token.Info{ID: "key1", Type: tt.stored}, tt.err)
+
+			service := New(nil, nil, nil, authRepo)
+
+			allowed, err := service.isTypeAllowed(t.Context(), &token.Token{ID: "key1", Type: tt.tunnel})
+			if errors.Is(tt.err, assert.AnError) {
+				assert.ErrorIs(t, err, assert.AnError)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expected, allowed)
+		})
+	}
+}
+
+func TestService_HandleReverseConn_AuthFailures(t *testing.T) {
+	tests := []struct {
+		setup    func(authRepo *MockAuthRepo)
+		name     string
+		rejected bool
+	}{
+		{
+			name: "invalid credentials",
+			setup: func(authRepo *MockAuthRepo) {
+				authRepo.EXPECT().Verify(mock.Anything, "key1-w", "secret").Return(nil, nil)
+			},
+			rejected: true,
+		},
+		{
+			name: "verification error",
+			setup: func(authRepo *MockAuthRepo) {
+				authRepo.EXPECT().Verify(mock.Anything, "key1-w", "secret").Return(nil, assert.AnError)
+			},
+		},
+		{
+			name: "token info error",
+			setup: func(authRepo *MockAuthRepo) {
+				authRepo.EXPECT().Verify(mock.Anything, "key1-w", "secret").Return(&token.Token{ID: "key1", Type: token.TokenTypeWeb}, nil)
+				authRepo.EXPECT().GetTokenInfo(mock.Anything, "key1").Return(token.Info{}, assert.AnError)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authRepo := NewMockAuthRepo(t)
+			tt.setup(authRepo)
+
+			service := New(nil, nil, nil, authRepo)
+			srvConn, cliConn := net.Pipe()
+
+			done := make(chan error, 1)
+
+			go func() { done <- service.HandleReverseConn(context.Background(), srvConn) }()
+
+			opt, err := proto.WithUserPass("key1-w", "secret")
+			require.NoError(t, err)
+
+			client := proto.NewClient(cliConn, opt)
+			defer client.Close()
+
+			err = client.Register(context.Background(), uuid.New())
+			require.Error(t, err)
+
+			// Only a rejection is reported to the client as an authentication failure, which it does not retry.
+			if tt.rejected {
+				assert.ErrorContains(t, err, "failed to authenticate")
+			} else {
+				assert.NotContains(t, err.Error(), "failed to authenticate")
+			}
+
+			assert.NoError(t, <-done)
+		})
+	}
+}
+
 func TestHandleHTTPConnection_WriteError(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
 
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, nil)
+
 	revConn := conn.NewMockWithWriteCloser(t)
 	revConn.EXPECT().Write(mock.Anything).Return(0, assert.AnError)
 
 	mockReq := conn.NewMockRequest(t)
 	connManager.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
 	mockReq.EXPECT().WaitConn(mock.Anything).Return(revConn, nil)
+	mockReq.EXPECT().ConnID().Return(uuid.New())
 
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080})
@@ -69,6 +170,8 @@ func TestHandleHTTPConnection_ContextCancellation(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
 
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, nil)
+
 	reqID := uuid.New()
 	mockReq := conn.NewMockRequest(t)
 	mockReq.EXPECT().ID().Return(reqID)
@@ -77,7 +180,7 @@ func TestHandleHTTPConnection_ContextCancellation(t *testing.T) {
 	connManager.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
 	connManager.EXPECT().CancelRequest(reqID).Return()
 
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080})
@@ -89,6 +192,52 @@ func TestHandleHTTPConnection_ContextCancellation(t *testing.T) {
 	require.ErrorIs(t, err, ErrFailedToConnect)
 }
 
+func TestHandleHTTPConnection_QuotaExceeded(t *testing.T) {
+	connManager := NewMockConnManager(t)
+	authRepo := NewMockAuthRepo(t)
+
+	quota := &token.Quota{Bytes: 100, Period: time.Hour}
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{Quota: quota}, nil)
+	authRepo.EXPECT().GetTrafficUsage(mock.Anything, "test-user", time.Hour).Return(TrafficUsage{Inbound: 60, Outbound: 40}, nil)
+
+	reqID := uuid.New()
+	mockReq := conn.NewMockRequest(t)
+	mockReq.EXPECT().ID().Return(reqID)
+
+	connManager.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
+	connManager.EXPECT().CancelRequest(reqID).Return()
+
+	service := New(connManager, connManager, nil, authRepo)
+	clientConn := conn.NewMockWithWriteCloser(t)
+
+	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080})
+
+	err := service.HandleHTTPConnection(context.Background(), "test-user", clientConn, func(net.Conn) error { return nil }, "127.0.0.1")
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestHandleHTTPConnection_PolicyError(t *testing.T) {
+	connManager := NewMockConnManager(t)
+	authRepo := NewMockAuthRepo(t)
+
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, assert.AnError)
+
+	reqID := uuid.New()
+	mockReq := conn.NewMockRequest(t)
+	mockReq.EXPECT().ID().Return(reqID)
+
+	connManager.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
+	connManager.EXPECT().CancelRequest(reqID).Return()
+
+	service := New(connManager, connManager, nil, authRepo)
+	clientConn := conn.NewMockWithWriteCloser(t)
+
+	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080})
+
+	err := service.HandleHTTPConnection(context.Background(), "test-user", clientConn, func(net.Conn) error { return nil }, "127.0.0.1")
+	require.ErrorIs(t, err, assert.AnError)
+}
+
 func TestTimeoutContext(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -601,7 +750,7 @@ func buildBindMessage(id uuid.UUID) []byte {
 func TestHandleV2Stream_Success(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 
 	streamServer, streamClient := net.Pipe()
 	defer streamClient.Close()
@@ -635,7 +784,7 @@ func TestHandleV2Stream_Success(t *testing.T) {
 func TestHandleV2Stream_InvalidVersion(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 
 	streamServer, streamClient := net.Pipe()
 	defer streamClient.Close()
@@ -656,7 +805,7 @@ func TestHandleV2Stream_InvalidVersion(t *testing.T) {
 func TestHandleV2Stream_InvalidCommand(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 
 	streamServer, streamClient := net.Pipe()
 	defer streamClient.Close()
@@ -677,7 +826,7 @@ func TestHandleV2Stream_InvalidCommand(t *testing.T) {
 func TestHandleV2Stream_TruncatedUUID(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 
 	streamServer, streamClient := net.Pipe()
 
@@ -699,7 +848,7 @@ func TestHandleV2Stream_TruncatedUUID(t *testing.T) {
 func TestHandleV2Stream_WriteResponseFails(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 
 	streamServer, streamClient := net.Pipe()
 
@@ -723,7 +872,7 @@ func TestHandleV2Stream_WriteResponseFails(t *testing.T) {
 func TestHandleV2Stream_EmptyStream(t *testing.T) {
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 
 	streamServer, streamClient := net.Pipe()
 
@@ -749,7 +898,7 @@ func TestHandleTCPConnection_ConnectionRequestFailure(t *testing.T) {
 
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, errors.New("connection failed"))
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
 
@@ -760,6 +909,30 @@ func TestHandleTCPConnection_ConnectionRequestFailure(t *testing.T) {
 	require.ErrorIs(t, err, ErrFailedToConnect)
 }
 
+func TestHandleTCPConnection_QuotaExceeded(t *testing.T) {
+	webConnMng := NewMockConnManager(t)
+	tcpConnMng := NewMockConnManager(t)
+	authRepo := NewMockAuthRepo(t)
+
+	quota := &token.Quota{Bytes: 100, Period: time.Hour}
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{Quota: quota}, nil)
+	authRepo.EXPECT().GetTrafficUsage(mock.Anything, "test-user", time.Hour).Return(TrafficUsage{Outbound: 150}, nil)
+
+	reqID := uuid.New()
+	mockReq := conn.NewMockRequest(t)
+	mockReq.EXPECT().ID().Return(reqID)
+
+	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
+	tcpConnMng.EXPECT().CancelRequest(reqID).Return()
+
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
+	clientConn := conn.NewMockWithWriteCloser(t)
+	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
+
+	err := service.HandleTCPConnection(context.Background(), "test-user", clientConn, "127.0.0.1")
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
 func TestHandleTCPConnection_KeyNotFound_NoActiveConn(t *testing.T) {
 	webConnMng := NewMockConnManager(t)
 	tcpConnMng := NewMockConnManager(t)
@@ -768,7 +941,7 @@ func TestHandleTCPConnection_KeyNotFound_NoActiveConn(t *testing.T) {
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, ErrKeyIDNotFound)
 	authRepo.EXPECT().IsKeyExists(mock.Anything, "test-user").Return(true, nil)
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
 
@@ -787,7 +960,7 @@ func TestHandleTCPConnection_KeyNotFound_KeyDoesNotExist(t *testing.T) {
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, ErrKeyIDNotFound)
 	authRepo.EXPECT().IsKeyExists(mock.Anything, "test-user").Return(false, nil)
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
 
@@ -806,7 +979,7 @@ func TestHandleTCPConnection_KeyNotFound_AuthError(t *testing.T) {
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, ErrKeyIDNotFound)
 	authRepo.EXPECT().IsKeyExists(mock.Anything, "test-user").Return(false, errors.New("db error"))
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
 
@@ -823,6 +996,8 @@ func TestHandleTCPConnection_WaitConnCancellation(t *testing.T) {
 	tcpConnMng := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
 
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, nil)
+
 	reqID := uuid.New()
 	mockReq := conn.NewMockRequest(t)
 	mockReq.EXPECT().ID().Return(reqID)
@@ -831,7 +1006,7 @@ func TestHandleTCPConnection_WaitConnCancellation(t *testing.T) {
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
 	tcpConnMng.EXPECT().CancelRequest(reqID).Return()
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
 
@@ -847,6 +1022,8 @@ func TestHandleTCPConnection_MetaWriteFailure(t *testing.T) {
 	tcpConnMng := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
 
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, nil)
+
 	revConn := conn.NewMockWithWriteCloser(t)
 	// meta.WriteData writes a gob-encoded struct; the first Write call goes to the revConn.
 	revConn.EXPECT().Write(mock.Anything).Return(0, errors.New("write failed"))
@@ -855,10 +1032,11 @@ func TestHandleTCPConnection_MetaWriteFailure(t *testing.T) {
 
 	mockReq := conn.NewMockRequest(t)
 	mockReq.EXPECT().WaitConn(mock.Anything).Return(revConn, nil)
+	mockReq.EXPECT().ConnID().Return(uuid.New())
 
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 	clientConn := conn.NewMockWithWriteCloser(t)
 	clientConn.EXPECT().RemoteAddr().Return(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
 
@@ -874,6 +1052,9 @@ func TestHandleTCPConnection_SuccessfulBidirectionalPipe(t *testing.T) {
 	tcpConnMng := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
 
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, nil)
+	authRepo.EXPECT().AddTrafficUsage(mock.Anything, "test-user", mock.Anything, time.Duration(0)).Return(nil).Maybe()
+
 	// Use real net.Pipe() connections so we can test actual I/O.
 	// yamuxStreamWrapper (in the same package) adds CloseWrite() to net.Conn.
 	revServer, revClient := net.Pipe()
@@ -886,11 +1067,12 @@ func TestHandleTCPConnection_SuccessfulBidirectionalPipe(t *testing.T) {
 
 	mockReq := conn.NewMockRequest(t)
 	mockReq.EXPECT().WaitConn(mock.Anything).Return(revWrapped, nil)
+	mockReq.EXPECT().ConnID().Return(uuid.New())
 	mockReq.EXPECT().ParentContext().Return(context.Background())
 
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -925,11 +1107,34 @@ func TestHandleTCPConnection_SuccessfulBidirectionalPipe(t *testing.T) {
 	}
 }
 
+func TestHandleUDPConnection_UsesUDPConnManager(t *testing.T) {
+	webConnMng := NewMockConnManager(t)
+	tcpConnMng := NewMockConnManager(t)
+	udpConnMng := NewMockConnManager(t)
+	authRepo := NewMockAuthRepo(t)
+
+	// Only the UDP connection manager is asked for a reverse connection.
+	udpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(nil, errors.New("connection failed"))
+
+	service := New(webConnMng, tcpConnMng, udpConnMng, authRepo)
+	clientConn := conn.NewMockWithWriteCloser(t)
+	clientConn.EXPECT().RemoteAddr().Return(&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9000})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := service.HandleUDPConnection(ctx, "test-user", clientConn, "127.0.0.1")
+	require.ErrorIs(t, err, ErrFailedToConnect)
+}
+
 func TestHandleTCPConnection_ContextCancellation(t *testing.T) {
 	webConnMng := NewMockConnManager(t)
 	tcpConnMng := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
 
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, nil)
+	authRepo.EXPECT().AddTrafficUsage(mock.Anything, "test-user", mock.Anything, time.Duration(0)).Return(nil).Maybe()
+
 	revServer, revClient := net.Pipe()
 	defer revClient.Close()
 
@@ -940,11 +1145,12 @@ func TestHandleTCPConnection_ContextCancellation(t *testing.T) {
 
 	mockReq := conn.NewMockRequest(t)
 	mockReq.EXPECT().WaitConn(mock.Anything).Return(revWrapped, nil)
+	mockReq.EXPECT().ConnID().Return(uuid.New())
 	mockReq.EXPECT().ParentContext().Return(context.Background())
 
 	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
 
-	service := New(webConnMng, tcpConnMng, authRepo)
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -985,7 +1191,7 @@ func TestAcceptV2Streams_ContextCancellation(t *testing.T) {
 	// We use a mock ServerV2 to avoid race conditions in the revdial library.
 	connManager := NewMockConnManager(t)
 	authRepo := NewMockAuthRepo(t)
-	service := New(connManager, connManager, authRepo)
+	service := New(connManager, connManager, nil, authRepo)
 
 	// Create a real ServerV2 that will block on AcceptStream
 	serverPipe, clientPipe := net.Pipe()
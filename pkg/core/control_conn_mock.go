@@ -7,11 +7,9 @@ package core
 import (
 	context "context"
 
+	uuid "github.com/google/uuid"
 	conn "github.com/ksysoev/make-it-public/pkg/core/conn"
-
 	mock "github.com/stretchr/testify/mock"
-
-	uuid "github.com/google/uuid"
 )
 
 // MockControlConn is an autogenerated mock type for the ControlConn type
@@ -166,19 +164,15 @@ func (_c *MockControlConn_ID_Call) RunAndReturn(run func() uuid.UUID) *MockContr
 	return _c
 }
 
-// RequestConnection provides a mock function with no fields
-func (_m *MockControlConn) RequestConnection() (conn.Request, error) {
+// NewRequest provides a mock function with no fields
+func (_m *MockControlConn) NewRequest() conn.Request {
 	ret := _m.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for RequestConnection")
+		panic("no return value specified for NewRequest")
 	}
 
 	var r0 conn.Request
-	var r1 error
-	if rf, ok := ret.Get(0).(func() (conn.Request, error)); ok {
-		return rf()
-	}
 	if rf, ok := ret.Get(0).(func() conn.Request); ok {
 		r0 = rf()
 	} else {
@@ -187,38 +181,78 @@ func (_m *MockControlConn) RequestConnection() (conn.Request, error) {
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	return r0
+}
+
+// MockControlConn_NewRequest_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'NewRequest'
+type MockControlConn_NewRequest_Call struct {
+	*mock.Call
+}
+
+// NewRequest is a helper method to define mock.On call
+func (_e *MockControlConn_Expecter) NewRequest() *MockControlConn_NewRequest_Call {
+	return &MockControlConn_NewRequest_Call{Call: _e.mock.On("NewRequest")}
+}
+
+func (_c *MockControlConn_NewRequest_Call) Run(run func()) *MockControlConn_NewRequest_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockControlConn_NewRequest_Call) Return(_a0 conn.Request) *MockControlConn_NewRequest_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockControlConn_NewRequest_Call) RunAndReturn(run func() conn.Request) *MockControlConn_NewRequest_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SendConnectCommand provides a mock function with given fields: id
+func (_m *MockControlConn) SendConnectCommand(id uuid.UUID) error {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendConnectCommand")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) error); ok {
+		r0 = rf(id)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// MockControlConn_RequestConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RequestConnection'
-type MockControlConn_RequestConnection_Call struct {
+// MockControlConn_SendConnectCommand_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SendConnectCommand'
+type MockControlConn_SendConnectCommand_Call struct {
 	*mock.Call
 }
 
-// RequestConnection is a helper method to define mock.On call
-func (_e *MockControlConn_Expecter) RequestConnection() *MockControlConn_RequestConnection_Call {
-	return &MockControlConn_RequestConnection_Call{Call: _e.mock.On("RequestConnection")}
+// SendConnectCommand is a helper method to define mock.On call
+//   - id uuid.UUID
+func (_e *MockControlConn_Expecter) SendConnectCommand(id interface{}) *MockControlConn_SendConnectCommand_Call {
+	return &MockControlConn_SendConnectCommand_Call{Call: _e.mock.On("SendConnectCommand", id)}
 }
 
-func (_c *MockControlConn_RequestConnection_Call) Run(run func()) *MockControlConn_RequestConnection_Call {
+func (_c *MockControlConn_SendConnectCommand_Call) Run(run func(id uuid.UUID)) *MockControlConn_SendConnectCommand_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run()
+		run(args[0].(uuid.UUID))
 	})
 	return _c
 }
 
-func (_c *MockControlConn_RequestConnection_Call) Return(_a0 conn.Request, _a1 error) *MockControlConn_RequestConnection_Call {
-	_c.Call.Return(_a0, _a1)
+func (_c *MockControlConn_SendConnectCommand_Call) Return(_a0 error) *MockControlConn_SendConnectCommand_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockControlConn_RequestConnection_Call) RunAndReturn(run func() (conn.Request, error)) *MockControlConn_RequestConnection_Call {
+func (_c *MockControlConn_SendConnectCommand_Call) RunAndReturn(run func(uuid.UUID) error) *MockControlConn_SendConnectCommand_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+var (
+	ErrDomainTaken       = errors.New("domain is attached to another token")
+	ErrDomainNotFound    = errors.New("domain is not attached to the token")
+	ErrDomainNotVerified = errors.New("domain ownership could not be verified")
+)
+
+// DomainVerifier checks that whoever attaches a custom domain to a token controls the domain.
+// Verify returns an error if the ownership of domain by the owner of the token keyID cannot be proven.
+type DomainVerifier interface {
+	Verify(ctx context.Context, domain, keyID string) error
+}
+
+// SetDomainVerifier sets the verifier of the ownership of custom domains attached to tokens.
+// Without a verifier no custom domain can be attached.
+func (s *Service) SetDomainVerifier(verifier DomainVerifier) {
+	s.domainVerifier = verifier
+}
+
+// AttachDomain attaches the custom domain to the web token keyID once its ownership is verified,
+// so that requests for the domain are served by the tunnel of the token.
+// The domain is case-insensitive and may be given with a trailing dot.
+// Attaching a domain already attached to the token is a no-op.
+// Returns token.ErrInvalidDomain if domain is not a valid hostname or the token does not serve web tunnels,
+// ErrTokenNotFound if the token does not exist, ErrDomainNotVerified if the ownership check fails,
+// ErrDomainTaken if the domain is attached to another token, or an error if the authentication repository fails.
+func (s *Service) AttachDomain(ctx context.Context, keyID, domain string) error {
+	domain = token.NormalizeDomain(domain)
+
+	if err := token.ValidateDomain(domain); err != nil {
+		return err
+	}
+
+	info, err := s.auth.GetTokenInfo(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	// Tokens stored without a type are web tokens.
+	if !cmp.Or(info.Type, token.TokenTypeWeb).Allows(token.TokenTypeWeb) {
+		return fmt.Errorf("custom domains are only supported for web tokens: %w", token.ErrInvalidDomain)
+	}
+
+	owner, err := s.auth.ResolveDomain(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to resolve domain: %w", err)
+	}
+
+	if owner == keyID {
+		return nil
+	}
+
+	if err := s.domainVerifier.Verify(ctx, domain, keyID); err != nil {
+		return fmt.Errorf("%w: %w", ErrDomainNotVerified, err)
+	}
+
+	if err := s.auth.AttachDomain(ctx, domain, keyID); err != nil {
+		return fmt.Errorf("failed to attach domain %s: %w", domain, err)
+	}
+
+	return nil
+}
+
+// DetachDomain detaches the custom domain from the token keyID, requests for the domain are no longer served.
+// Returns ErrDomainNotFound if the domain is not attached to the token,
+// or an error if the authentication repository fails.
+func (s *Service) DetachDomain(ctx context.Context, keyID, domain string) error {
+	if err := s.auth.DetachDomain(ctx, token.NormalizeDomain(domain), keyID); err != nil {
+		return fmt.Errorf("failed to detach domain: %w", err)
+	}
+
+	return nil
+}
+
+// ListDomains returns the custom domains attached to the token keyID, sorted by name.
+// Returns ErrTokenNotFound if the token does not exist, or an error if the authentication repository fails.
+func (s *Service) ListDomains(ctx context.Context, keyID string) ([]string, error) {
+	if _, err := s.auth.GetTokenInfo(ctx, keyID); err != nil {
+		return nil, err
+	}
+
+	domains, err := s.auth.ListDomains(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// ResolveDomain returns the keyID of the tunnel serving the custom domain host,
+// or an empty string if no token has the domain attached.
+// Returns an error if the domain registry cannot be read from the authentication repository.
+func (s *Service) ResolveDomain(ctx context.Context, host string) (string, error) {
+	keyID, err := s.auth.ResolveDomain(ctx, token.NormalizeDomain(host))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve domain: %w", err)
+	}
+
+	return keyID, nil
+}
+
+// noopDomainVerifier is the default verifier used when none has been wired in.
+// It rejects every domain so that unverified domains are never attached.
+type noopDomainVerifier struct{}
+
+func (noopDomainVerifier) Verify(_ context.Context, domain, _ string) error {
+	return fmt.Errorf("domain verifier is not configured (domain=%s)", domain)
+}
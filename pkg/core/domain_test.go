@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_AttachDomain(t *testing.T) {
+	ctx := context.Background()
+	webInfo := token.Info{ID: "key1", Type: token.TokenTypeWeb}
+
+	tests := []struct {
+		setup   func(auth *MockAuthRepo, verifier *MockDomainVerifier)
+		wantErr error
+		name    string
+		domain  string
+	}{
+		{
+			name:   "verified domain is attached",
+			domain: "App.Example.com.",
+			setup: func(auth *MockAuthRepo, verifier *MockDomainVerifier) {
+				auth.EXPECT().GetTokenInfo(ctx, "key1").Return(webInfo, nil)
+				auth.EXPECT().ResolveDomain(ctx, "app.example.com").Return("", nil)
+				verifier.EXPECT().Verify(ctx, "app.example.com", "key1").Return(nil)
+				auth.EXPECT().AttachDomain(ctx, "app.example.com", "key1").Return(nil)
+			},
+		},
+		{
+			name:   "domain already attached to the token is not verified again",
+			domain: "app.example.com",
+			setup: func(auth *MockAuthRepo, _ *MockDomainVerifier) {
+				auth.EXPECT().GetTokenInfo(ctx, "key1").Return(webInfo, nil)
+				auth.EXPECT().ResolveDomain(ctx, "app.example.com").Return("key1", nil)
+			},
+		},
+		{
+			name:    "invalid domain",
+			domain:  "localhost",
+			setup:   func(*MockAuthRepo, *MockDomainVerifier) {},
+			wantErr: token.ErrInvalidDomain,
+		},
+		{
+			name:   "token not found",
+			domain: "app.example.com",
+			setup: func(auth *MockAuthRepo, _ *MockDomainVerifier) {
+				auth.EXPECT().GetTokenInfo(ctx, "key1").Return(token.Info{}, ErrTokenNotFound)
+			},
+			wantErr: ErrTokenNotFound,
+		},
+		{
+			name:   "TCP token",
+			domain: "app.example.com",
+			setup: func(auth *MockAuthRepo, _ *MockDomainVerifier) {
+				auth.EXPECT().GetTokenInfo(ctx, "key1").Return(token.Info{ID: "key1", Type: token.TokenTypeTCP}, nil)
+			},
+			wantErr: token.ErrInvalidDomain,
+		},
+		{
+			name:   "ownership not verified",
+			domain: "app.example.com",
+			setup: func(auth *MockAuthRepo, verifier *MockDomainVerifier) {
+				auth.EXPECT().GetTokenInfo(ctx, "key1").Return(webInfo, nil)
+				auth.EXPECT().ResolveDomain(ctx, "app.example.com").Return("", nil)
+				verifier.EXPECT().Verify(ctx, "app.example.com", "key1").Return(assert.AnError)
+			},
+			wantErr: ErrDomainNotVerified,
+		},
+		{
+			name:   "domain attached to another token",
+			domain: "app.example.com",
+			setup: func(auth *MockAuthRepo, verifier *MockDomainVerifier) {
+				auth.EXPECT().GetTokenInfo(ctx, "key1").Return(webInfo, nil)
+				auth.EXPECT().ResolveDomain(ctx, "app.example.com").Return("key2", nil)
+				verifier.EXPECT().Verify(ctx, "app.example.com", "key1").Return(nil)
+				auth.EXPECT().AttachDomain(ctx, "app.example.com", "key1").Return(ErrDomainTaken)
+			},
+			wantErr: ErrDomainTaken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewMockAuthRepo(t)
+			verifier := NewMockDomainVerifier(t)
+			tt.setup(auth, verifier)
+
+			svc := New(nil, nil, nil, auth)
+			svc.SetDomainVerifier(verifier)
+
+			err := svc.AttachDomain(ctx, "key1", tt.domain)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestService_AttachDomain_NoVerifier(t *testing.T) {
+	auth := NewMockAuthRepo(t)
+	auth.EXPECT().GetTokenInfo(context.Background(), "key1").Return(token.Info{ID: "key1"}, nil)
+	auth.EXPECT().ResolveDomain(context.Background(), "app.example.com").Return("", nil)
+
+	svc := New(nil, nil, nil, auth)
+
+	err := svc.AttachDomain(context.Background(), "key1", "app.example.com")
+	assert.ErrorIs(t, err, ErrDomainNotVerified)
+}
+
+func TestService_DetachDomain(t *testing.T) {
+	auth := NewMockAuthRepo(t)
+	auth.EXPECT().DetachDomain(context.Background(), "app.example.com", "key1").Return(nil).Once()
+	auth.EXPECT().DetachDomain(context.Background(), "other.example.com", "key1").Return(ErrDomainNotFound).Once()
+
+	svc := New(nil, nil, nil, auth)
+
+	require.NoError(t, svc.DetachDomain(context.Background(), "key1", "APP.example.com"))
+	assert.ErrorIs(t, svc.DetachDomain(context.Background(), "key1", "other.example.com"), ErrDomainNotFound)
+}
+
+func TestService_ListDomains(t *testing.T) {
+	auth := NewMockAuthRepo(t)
+	auth.EXPECT().GetTokenInfo(context.Background(), "key1").Return(token.Info{ID: "key1"}, nil)
+	auth.EXPECT().ListDomains(context.Background(), "key1").Return([]string{"a.example.com", "b.example.com"}, nil)
+	auth.EXPECT().GetTokenInfo(context.Background(), "missing").Return(token.Info{}, ErrTokenNotFound)
+
+	svc := New(nil, nil, nil, auth)
+
+	domains, err := svc.ListDomains(context.Background(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, domains)
+
+	_, err = svc.ListDomains(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+}
+
+func TestService_ResolveDomain(t *testing.T) {
+	auth := NewMockAuthRepo(t)
+	auth.EXPECT().ResolveDomain(context.Background(), "app.example.com").Return("key1", nil).Once()
+	auth.EXPECT().ResolveDomain(context.Background(), "broken.example.com").Return("", assert.AnError).Once()
+
+	svc := New(nil, nil, nil, auth)
+
+	keyID, err := svc.ResolveDomain(context.Background(), "App.Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "key1", keyID)
+
+	_, err = svc.ResolveDomain(context.Background(), "broken.example.com")
+	assert.ErrorIs(t, err, assert.AnError)
+}
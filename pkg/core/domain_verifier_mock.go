@@ -0,0 +1,86 @@
+// Code generated by mockery. DO NOT EDIT.
+
+//go:build !compile
+
+package core
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockDomainVerifier is an autogenerated mock type for the DomainVerifier type
+type MockDomainVerifier struct {
+	mock.Mock
+}
+
+type MockDomainVerifier_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDomainVerifier) EXPECT() *MockDomainVerifier_Expecter {
+	return &MockDomainVerifier_Expecter{mock: &_m.Mock}
+}
+
+// Verify provides a mock function with given fields: ctx, domain, keyID
+func (_m *MockDomainVerifier) Verify(ctx context.Context, domain string, keyID string) error {
+	ret := _m.Called(ctx, domain, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Verify")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, domain, keyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockDomainVerifier_Verify_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Verify'
+type MockDomainVerifier_Verify_Call struct {
+	*mock.Call
+}
+
+// Verify is a helper method to define mock.On call
+//   - ctx context.Context
+//   - domain string
+//   - keyID string
+func (_e *MockDomainVerifier_Expecter) Verify(ctx interface{}, domain interface{}, keyID interface{}) *MockDomainVerifier_Verify_Call {
+	return &MockDomainVerifier_Verify_Call{Call: _e.mock.On("Verify", ctx, domain, keyID)}
+}
+
+func (_c *MockDomainVerifier_Verify_Call) Run(run func(ctx context.Context, domain string, keyID string)) *MockDomainVerifier_Verify_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockDomainVerifier_Verify_Call) Return(_a0 error) *MockDomainVerifier_Verify_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockDomainVerifier_Verify_Call) RunAndReturn(run func(context.Context, string, string) error) *MockDomainVerifier_Verify_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockDomainVerifier creates a new instance of MockDomainVerifier. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDomainVerifier(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDomainVerifier {
+	mock := &MockDomainVerifier{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+var ErrServerDraining = errors.New("server is draining")
+
+// activeConns counts end-user connections that are currently piped through the tunnels
+// and lets callers wait until all of them are closed.
+type activeConns struct {
+	idle  chan struct{}
+	mu    sync.Mutex
+	count int
+}
+
+// add registers a new active connection.
+// It returns a function that must be called once the connection is closed.
+func (a *activeConns) add() func() {
+	a.mu.Lock()
+	a.count++
+	a.mu.Unlock()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			a.count--
+
+			if a.count == 0 && a.idle != nil {
+				close(a.idle)
+				a.idle = nil
+			}
+		})
+	}
+}
+
+// wait blocks until there are no active connections left or ctx is done.
+// It returns an error if ctx is done before all connections are closed.
+func (a *activeConns) wait(ctx context.Context) error {
+	a.mu.Lock()
+
+	if a.count == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+
+	if a.idle == nil {
+		a.idle = make(chan struct{})
+	}
+
+	idle := a.idle
+
+	a.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d connections are still active: %w", a.len(), ctx.Err())
+	}
+}
+
+// len returns the number of currently active connections.
+func (a *activeConns) len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.count
+}
+
+// Drain switches the service into drain mode ahead of a shutdown.
+// It notifies every connected client that the server is going away, so that it can reconnect elsewhere,
+// refuses new client registrations and waits for the active end-user connections to finish.
+// Connections that are already bound keep being served while the service is draining.
+// Returns an error if ctx is done before all active connections are closed.
+func (s *Service) Drain(ctx context.Context) error {
+	s.drainOnce.Do(func() { close(s.draining) })
+
+	slog.InfoContext(ctx, "draining connections", slog.Int("active", s.conns.len()))
+
+	if err := s.conns.wait(ctx); err != nil {
+		return fmt.Errorf("failed to drain connections: %w", err)
+	}
+
+	return nil
+}
+
+// isDraining reports whether Drain has been called on the service.
+func (s *Service) isDraining() bool {
+	select {
+	case <-s.draining:
+		return true
+	default:
+		return false
+	}
+}
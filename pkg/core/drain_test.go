@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveConns_Wait(t *testing.T) {
+	var conns activeConns
+
+	require.NoError(t, conns.wait(context.Background()), "wait should return immediately without active connections")
+
+	done := conns.add()
+	doneOther := conns.add()
+
+	assert.Equal(t, 2, conns.len())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, conns.wait(ctx), context.DeadlineExceeded)
+
+	waitErr := make(chan error, 1)
+
+	go func() { waitErr <- conns.wait(context.Background()) }()
+
+	done()
+	done() // calling done twice must not release another connection
+
+	assert.Equal(t, 1, conns.len())
+
+	doneOther()
+
+	select {
+	case err := <-waitErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("wait did not return after all connections were closed")
+	}
+}
+
+func TestService_Drain(t *testing.T) {
+	svc := New(NewMockConnManager(t), NewMockConnManager(t), nil, NewMockAuthRepo(t))
+
+	done := svc.conns.add()
+
+	drainErr := make(chan error, 1)
+
+	go func() { drainErr <- svc.Drain(context.Background()) }()
+
+	assert.Eventually(t, svc.isDraining, time.Second, 5*time.Millisecond)
+	assert.ErrorIs(t, svc.CheckHealth(context.Background()), ErrServerDraining)
+
+	done()
+
+	select {
+	case err := <-drainErr:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return after all connections were closed")
+	}
+
+	// Draining an already drained service is a no-op.
+	assert.NoError(t, svc.Drain(context.Background()))
+}
+
+func TestService_Drain_Timeout(t *testing.T) {
+	svc := New(NewMockConnManager(t), NewMockConnManager(t), nil, NewMockAuthRepo(t))
+
+	defer svc.conns.add()()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := svc.Drain(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -3,6 +3,8 @@ package core
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ksysoev/make-it-public/pkg/core/conn"
@@ -13,7 +15,8 @@ type ControlConn interface {
 	ID() uuid.UUID
 	Context() context.Context
 	Close() error
-	RequestConnection() (conn.Request, error)
+	NewRequest() conn.Request
+	SendConnectCommand(id uuid.UUID) error
 }
 
 type AuthRepo interface {
@@ -22,6 +25,18 @@ type AuthRepo interface {
 	DeleteToken(ctx context.Context, tokenID string) error
 	IsKeyExists(ctx context.Context, keyID string) (bool, error)
 	CheckHealth(ctx context.Context) error
+	GetTokenPolicy(ctx context.Context, keyID string) (token.Policy, error)
+	AddTrafficUsage(ctx context.Context, keyID string, usage TrafficUsage, period time.Duration) error
+	GetTrafficUsage(ctx context.Context, keyID string, period time.Duration) (TrafficUsage, error)
+	ReserveSubdomain(ctx context.Context, label, keyID string) error
+	ResolveSubdomain(ctx context.Context, label string) (string, error)
+	AttachDomain(ctx context.Context, domain, keyID string) error
+	DetachDomain(ctx context.Context, domain, keyID string) error
+	ResolveDomain(ctx context.Context, domain string) (string, error)
+	ListDomains(ctx context.Context, keyID string) ([]string, error)
+	ListTokens(ctx context.Context) ([]token.Info, error)
+	GetTokenInfo(ctx context.Context, keyID string) (token.Info, error)
+	SetTokenTTL(ctx context.Context, keyID string, ttl time.Duration) (token.Info, error)
 }
 
 type ConnManager interface {
@@ -41,28 +56,52 @@ type TCPEndpointAllocator interface {
 	Release(keyID string)
 }
 
+// UDPEndpointAllocator dynamically allocates and releases UDP ports for
+// individual MIT clients that authenticate with a UDP token.
+// Allocate binds a UDP port and returns the public endpoint (host:port).
+// Release unbinds the port and frees it back to the pool.
+type UDPEndpointAllocator interface {
+	Allocate(ctx context.Context, keyID string) (string, error)
+	Release(keyID string)
+}
+
 type Service struct {
 	endpointGenerator    func(string) (string, error)
 	tcpEndpointAllocator TCPEndpointAllocator
+	udpEndpointAllocator UDPEndpointAllocator
+	domainVerifier       DomainVerifier
+	cluster              Cluster
 	webConnMng           ConnManager
 	tcpConnMng           ConnManager
+	udpConnMng           ConnManager
 	auth                 AuthRepo
+	draining             chan struct{}
+	traffic              trafficMeters
+	conns                activeConns
+	tunnels              tunnelRegistry
+	drainOnce            sync.Once
 }
 
 // New initializes and returns a new Service instance with the provided ConnManagers and AuthRepo.
 // It assigns a default endpoint generator function that returns an error if invoked.
 // webConnMng manages web/HTTP connection-related operations.
 // tcpConnMng manages TCP connection-related operations.
+// udpConnMng manages UDP connection-related operations.
 // auth handles authentication-related operations.
-func New(webConnMng, tcpConnMng ConnManager, auth AuthRepo) *Service {
+func New(webConnMng, tcpConnMng, udpConnMng ConnManager, auth AuthRepo) *Service {
 	return &Service{
 		webConnMng: webConnMng,
 		tcpConnMng: tcpConnMng,
+		udpConnMng: udpConnMng,
 		auth:       auth,
 		endpointGenerator: func(_ string) (string, error) {
 			return "", fmt.Errorf("endpoint generator is not set")
 		},
 		tcpEndpointAllocator: noopTCPEndpointAllocator{},
+		udpEndpointAllocator: noopUDPEndpointAllocator{},
+		domainVerifier:       noopDomainVerifier{},
+		cluster:              noopCluster{},
+		draining:             make(chan struct{}),
 	}
 }
 
@@ -80,7 +119,32 @@ func (s *Service) SetTCPEndpointAllocator(allocator TCPEndpointAllocator) {
 	s.tcpEndpointAllocator = allocator
 }
 
+// SetUDPEndpointAllocator sets the allocator used to bind per-keyID UDP ports.
+// It is called by the UDP edge server during initialisation.
+func (s *Service) SetUDPEndpointAllocator(allocator UDPEndpointAllocator) {
+	s.udpEndpointAllocator = allocator
+}
+
+// connManager returns the connection manager of the tunnels of tokenType.
+func (s *Service) connManager(tokenType token.TokenType) ConnManager {
+	switch tokenType {
+	case token.TokenTypeTCP:
+		return s.tcpConnMng
+	case token.TokenTypeUDP:
+		return s.udpConnMng
+	default:
+		return s.webConnMng
+	}
+}
+
+// CheckHealth verifies that the service is able to accept new clients.
+// It returns ErrServerDraining once the service is draining, so that load balancers stop routing to it,
+// or an error if the auth repository is unhealthy.
 func (s *Service) CheckHealth(ctx context.Context) error {
+	if s.isDraining() {
+		return ErrServerDraining
+	}
+
 	return s.auth.CheckHealth(ctx)
 }
 
@@ -94,3 +158,14 @@ func (noopTCPEndpointAllocator) Allocate(_ context.Context, keyID string) (strin
 }
 
 func (noopTCPEndpointAllocator) Release(_ string) {}
+
+// noopUDPEndpointAllocator is the default allocator used when no UDP edge
+// server has been wired in.  It returns an error on every Allocate call so that
+// UDP tokens are rejected cleanly.
+type noopUDPEndpointAllocator struct{}
+
+func (noopUDPEndpointAllocator) Allocate(_ context.Context, keyID string) (string, error) {
+	return "", fmt.Errorf("UDP endpoint allocator is not configured (keyID=%s)", keyID)
+}
+
+func (noopUDPEndpointAllocator) Release(_ string) {}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/core/token"
 )
@@ -15,21 +19,57 @@ const (
 var (
 	ErrDuplicateTokenID = fmt.Errorf("duplicate token ID")
 	ErrTokenNotFound    = fmt.Errorf("token not found")
+	ErrSubdomainTaken   = fmt.Errorf("subdomain is reserved by another token")
 )
 
-// GenerateToken generates a new token with the given keyID, time-to-live (TTL), and token type.
+// GenerateToken generates a new token with the given keyID, time-to-live (TTL), token type, policy and metadata.
 // It attempts to save the token to the authentication repository, retrying on duplicate token ID errors.
 // Accepts ctx which is the context for the request, keyID as the identifier for the token, ttl as the duration in seconds,
-// and tokenType as the type of token (web or tcp).
+// tokenType as the type of token (web, tcp, or a combination of them for a multi-purpose token), policy with the restrictions enforced for tunnels opened with the token,
+// and meta with the description and owner stored alongside the token.
 // Returns the generated token and an error if generation or saving fails, or if all retry attempts are exhausted.
-func (s *Service) GenerateToken(ctx context.Context, keyID string, ttl int, tokenType token.TokenType) (*token.Token, error) {
+// Returns ErrDuplicateTokenID if keyID is the ID of another token or a subdomain reserved by another token.
+// Returns token.ErrInvalidSubdomain if the policy allows invalid subdomains or subdomains for a non-web token,
+// token.ErrInvalidAccess if the policy restricts access to a non-web token,
+// token.ErrInvalidRewrite if the policy rewrites the headers of a non-web token,
+// or token.ErrInvalidRateLimit if the policy limits the request rate of a non-web token.
+func (s *Service) GenerateToken(ctx context.Context, keyID string, ttl int, tokenType token.TokenType, policy token.Policy, meta token.Meta) (*token.Token, error) {
+	if policy.Access != nil && !tokenType.Allows(token.TokenTypeWeb) {
+		return nil, fmt.Errorf("access restrictions are only supported for web tokens: %w", token.ErrInvalidAccess)
+	}
+
+	if policy.Rewrite != nil && !tokenType.Allows(token.TokenTypeWeb) {
+		return nil, fmt.Errorf("header rewrite rules are only supported for web tokens: %w", token.ErrInvalidRewrite)
+	}
+
+	if policy.RateLimit != nil && !tokenType.Allows(token.TokenTypeWeb) {
+		return nil, fmt.Errorf("request rate limits are only supported for web tokens: %w", token.ErrInvalidRateLimit)
+	}
+
+	if len(policy.Subdomains) > 0 {
+		if !tokenType.Allows(token.TokenTypeWeb) {
+			return nil, fmt.Errorf("custom subdomains are only supported for web tokens: %w", token.ErrInvalidSubdomain)
+		}
+
+		if err := token.ValidateSubdomains(policy.Subdomains); err != nil {
+			return nil, err
+		}
+	}
+
 	for i := 0; i < attemptsToGenerateToken; i++ {
 		t, err := token.GenerateToken(keyID, ttl, tokenType)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate token: %w", err)
 		}
 
-		err = s.auth.SaveToken(ctx, t)
+		t.Policy = policy
+		t.Meta = meta
+
+		// Reserved subdomains resolve to their owner, a token with the same ID would never receive its traffic.
+		err = s.checkSubdomainFree(ctx, t.ID)
+		if err == nil {
+			err = s.auth.SaveToken(ctx, t)
+		}
 
 		switch {
 		case err == nil:
@@ -49,9 +89,173 @@ func (s *Service) GenerateToken(ctx context.Context, keyID string, ttl int, toke
 	return nil, fmt.Errorf("failed to generate token after %d attempts", attemptsToGenerateToken)
 }
 
+// TokenFilter selects the tokens returned by ListTokens, empty fields match any token.
+// Type matches the tokens usable for that tunnel type, including multi-purpose tokens.
+// Tokens are ordered by ID: After skips the tokens up to and including that ID, and Limit caps the number of tokens
+// returned, 0 means no limit.
+type TokenFilter struct {
+	Type  token.TokenType
+	Owner string
+	After string
+	Limit int
+}
+
+// ListTokens returns the metadata of the stored tokens matching filter, ordered by ID.
+// next is the ID to pass as filter.After to get the following page, it is empty on the last page.
+// Returns an error if the tokens cannot be read from the authentication repository.
+func (s *Service) ListTokens(ctx context.Context, filter TokenFilter) (tokens []token.Info, next string, err error) {
+	all, err := s.auth.ListTokens(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	slices.SortFunc(all, func(a, b token.Info) int { return strings.Compare(a.ID, b.ID) })
+
+	tokens = make([]token.Info, 0, len(all))
+
+	for _, t := range all {
+		switch {
+		case filter.After != "" && t.ID <= filter.After:
+			continue
+		case filter.Type != "" && !t.Type.Allows(filter.Type):
+			continue
+		case filter.Owner != "" && t.Owner != filter.Owner:
+			continue
+		}
+
+		if filter.Limit > 0 && len(tokens) == filter.Limit {
+			return tokens, tokens[len(tokens)-1].ID, nil
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens, "", nil
+}
+
+// GetToken returns the metadata of the token identified by keyID.
+// Returns ErrTokenNotFound if the token does not exist, or an error if it cannot be read from the authentication repository.
+func (s *Service) GetToken(ctx context.Context, keyID string) (token.Info, error) {
+	return s.auth.GetTokenInfo(ctx, keyID)
+}
+
+// SetTokenTTL changes the expiration of the token identified by keyID to ttl seconds from now, keeping its ID and
+// secret so that clients do not need a new token. A ttl of 0 makes the token never expire.
+// Returns the updated metadata of the token, token.ErrInvalidTokenTTL if ttl is negative, ErrTokenNotFound if the
+// token does not exist, or an error if the authentication repository fails to update it.
+func (s *Service) SetTokenTTL(ctx context.Context, keyID string, ttl int) (token.Info, error) {
+	if ttl < 0 {
+		return token.Info{}, token.ErrInvalidTokenTTL
+	}
+
+	info, err := s.auth.SetTokenTTL(ctx, keyID, time.Duration(ttl)*time.Second)
+	if err != nil {
+		return token.Info{}, fmt.Errorf("failed to update token TTL: %w", err)
+	}
+
+	return info, nil
+}
+
 // DeleteToken removes the token identified by tokenID from the system.
-// It performs a deletion operation in the underlying authentication repository.
-// Returns an error if the token does not exist or the deletion process fails.
+// It performs a deletion operation in the underlying authentication repository and then disconnects
+// the MIT clients connected with the token to this server and to the other nodes of the cluster,
+// so that a revoked token stops serving traffic at once.
+// Returns an error if the token does not exist or the deletion process fails. Failing to reach other nodes
+// is only logged, as the token is already revoked.
 func (s *Service) DeleteToken(ctx context.Context, tokenID string) error {
-	return s.auth.DeleteToken(ctx, tokenID)
+	if err := s.auth.DeleteToken(ctx, tokenID); err != nil {
+		return err
+	}
+
+	s.tunnels.disconnect(ctx, tokenID)
+
+	if err := s.cluster.Disconnect(ctx, tokenID); err != nil {
+		slog.WarnContext(ctx, "failed to disconnect tunnel from cluster", slog.String("keyID", tokenID), slog.Any("error", err))
+	}
+
+	return nil
+}
+
+// ResolveKeyID returns the keyID of the tunnel served on the subdomain label.
+// Labels reserved as custom subdomains resolve to the keyID of the token that reserved them,
+// any other label is the keyID itself.
+// Returns an error if the reservation cannot be read from the authentication repository.
+func (s *Service) ResolveKeyID(ctx context.Context, label string) (string, error) {
+	keyID, err := s.auth.ResolveSubdomain(ctx, label)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve subdomain: %w", err)
+	}
+
+	if keyID == "" {
+		return label, nil
+	}
+
+	return keyID, nil
+}
+
+// GetPolicy returns the policy of the token of the tunnel keyID, restricting how its tunnels can be reached.
+// Returns an error if the token policy cannot be read from the authentication repository.
+func (s *Service) GetPolicy(ctx context.Context, keyID string) (token.Policy, error) {
+	policy, err := s.auth.GetTokenPolicy(ctx, keyID)
+	if err != nil {
+		return token.Policy{}, fmt.Errorf("failed to get token policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+// GetIPFilter returns the filter of end-user IP addresses of the tunnel keyID, or nil if any address may reach it.
+// Returns an error if the token policy cannot be read from the authentication repository.
+func (s *Service) GetIPFilter(ctx context.Context, keyID string) (*token.IPFilter, error) {
+	policy, err := s.auth.GetTokenPolicy(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token policy: %w", err)
+	}
+
+	return policy.IPFilter, nil
+}
+
+// checkSubdomainFree checks that keyID is not a subdomain reserved by another token.
+// Returns ErrDuplicateTokenID if it is, or an error if the reservation cannot be read from the authentication repository.
+func (s *Service) checkSubdomainFree(ctx context.Context, keyID string) error {
+	owner, err := s.auth.ResolveSubdomain(ctx, keyID)
+
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to resolve subdomain: %w", err)
+	case owner != "" && owner != keyID:
+		return fmt.Errorf("key ID %s is reserved as a subdomain: %w", keyID, ErrDuplicateTokenID)
+	}
+
+	return nil
+}
+
+// reserveSubdomain checks that the token keyID may request the subdomain label and reserves the label for it.
+// Returns ErrSubdomainTaken if the label is reserved by another token or is the ID of another token, which the
+// authentication repository checks together with the reservation, or an error if the token is not allowed
+// to use the label. Failures of the authentication repository wrap errAuthUnavailable.
+func (s *Service) reserveSubdomain(ctx context.Context, keyID, label string) error {
+	if err := token.ValidateSubdomain(label); err != nil {
+		return err
+	}
+
+	policy, err := s.auth.GetTokenPolicy(ctx, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to get token policy: %w: %w", errAuthUnavailable, err)
+	}
+
+	if !policy.AllowsSubdomain(label) {
+		return fmt.Errorf("token %s is not allowed to use subdomain %s", keyID, label)
+	}
+
+	err = s.auth.ReserveSubdomain(ctx, label, keyID)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrSubdomainTaken), errors.Is(err, ErrTokenNotFound):
+		return fmt.Errorf("failed to reserve subdomain %s: %w", label, err)
+	default:
+		return fmt.Errorf("failed to reserve subdomain %s: %w: %w", label, errAuthUnavailable, err)
+	}
 }
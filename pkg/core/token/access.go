@@ -0,0 +1,221 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// DefaultAccessHeader is the header carrying the access key when none is configured.
+// In this header the key is sent as a bearer token: "Authorization: Bearer <key>".
+const DefaultAccessHeader = "Authorization"
+
+const (
+	// credentialPrefix marks credentials hashed with scrypt as "sc:<salt>:<key>", both base64 encoded.
+	// Credentials without it are SHA-256 digests stored by earlier versions.
+	credentialPrefix   = "sc:"
+	credentialSaltSize = 16
+	// maxVerifiedCredentials bounds the cache of the credentials checked successfully.
+	maxVerifiedCredentials = 1024
+)
+
+var (
+	ErrInvalidAccess = fmt.Errorf("access requires either a username and password or a key")
+
+	headerNameRe = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+	// derivations bounds the concurrent key derivations, each of which uses 32 MiB of memory,
+	// so that a flood of requests with wrong credentials cannot exhaust the memory of the server.
+	derivations = make(chan struct{}, max(runtime.GOMAXPROCS(0), 2))
+)
+
+// Access restricts who may reach a web tunnel. It either requires HTTP basic auth with Username and the
+// password hashed in PasswordHash, or a key hashed in KeyHash sent in Header.
+// Credentials are hashed with scrypt and a random salt, so they are only known to the one who generated the token.
+type Access struct {
+	Username     string `json:"username,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	Header       string `json:"header,omitempty"`
+	KeyHash      string `json:"key_hash,omitempty"`
+}
+
+// NewAccess creates an access restriction requiring HTTP basic auth with username and password, or key sent
+// in header. header defaults to DefaultAccessHeader and is only used with a key.
+// Returns nil and no error if no credentials are given, meaning the tunnel is public.
+// Returns ErrInvalidAccess if both kinds of credentials are given, the basic auth credentials are incomplete
+// or the header name is invalid, or an error if the credentials cannot be hashed.
+func NewAccess(username, password, header, key string) (*Access, error) {
+	basic := username != "" || password != ""
+
+	switch {
+	case !basic && key == "" && header == "":
+		return nil, nil
+	case basic && (key != "" || header != ""):
+		return nil, fmt.Errorf("basic auth and key cannot be combined: %w", ErrInvalidAccess)
+	case basic:
+		if username == "" || password == "" || strings.Contains(username, ":") {
+			return nil, fmt.Errorf("basic auth requires a username without colons and a password: %w", ErrInvalidAccess)
+		}
+
+		hash, err := hashCredential(password)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Access{Username: username, PasswordHash: hash}, nil
+	}
+
+	if key == "" {
+		return nil, fmt.Errorf("key is required: %w", ErrInvalidAccess)
+	}
+
+	if header == "" {
+		header = DefaultAccessHeader
+	}
+
+	if !headerNameRe.MatchString(header) {
+		return nil, fmt.Errorf("invalid header name %q: %w", header, ErrInvalidAccess)
+	}
+
+	hash, err := hashCredential(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Access{Header: http.CanonicalHeaderKey(header), KeyHash: hash}, nil
+}
+
+// IsBasic reports whether the access restriction requires HTTP basic auth.
+func (a *Access) IsBasic() bool {
+	return a.Username != ""
+}
+
+// CheckBasic reports whether username and password match the basic auth credentials.
+func (a *Access) CheckBasic(username, password string) bool {
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(a.Username)) == 1
+
+	return a.IsBasic() && checkCredential(password, a.PasswordHash) && userOK
+}
+
+// CheckKey reports whether key matches the access key.
+func (a *Access) CheckKey(key string) bool {
+	return a.KeyHash != "" && checkCredential(key, a.KeyHash)
+}
+
+// hashCredential returns credential hashed with scrypt and a random salt.
+// Returns an error if the salt cannot be generated or the key derivation fails.
+func hashCredential(credential string) (string, error) {
+	salt := make([]byte, credentialSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	dk, err := deriveCredentialKey(credential, salt)
+	if err != nil {
+		return "", err
+	}
+
+	return credentialPrefix + base64.StdEncoding.EncodeToString(salt) + ":" + base64.StdEncoding.EncodeToString(dk), nil
+}
+
+// checkCredential reports whether credential matches hash. Successful checks are cached, so the key
+// derivation runs once per credential rather than on every request.
+func checkCredential(credential, hash string) bool {
+	cacheKey := sha256.Sum256([]byte(hash + "\x00" + credential))
+	if verified.contains(cacheKey) {
+		return true
+	}
+
+	var ok bool
+
+	if rest, found := strings.CutPrefix(hash, credentialPrefix); found {
+		ok = checkScryptCredential(credential, rest)
+	} else {
+		sum := sha256.Sum256([]byte(credential))
+		ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) == 1
+	}
+
+	if ok {
+		verified.add(cacheKey)
+	}
+
+	return ok
+}
+
+// checkScryptCredential reports whether credential matches the "<salt>:<key>" scrypt hash.
+func checkScryptCredential(credential, hash string) bool {
+	encSalt, encKey, ok := strings.Cut(hash, ":")
+	if !ok {
+		return false
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(encSalt)
+	if err != nil {
+		return false
+	}
+
+	want, err := base64.StdEncoding.DecodeString(encKey)
+	if err != nil {
+		return false
+	}
+
+	dk, err := deriveCredentialKey(credential, salt)
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(dk, want) == 1
+}
+
+// deriveCredentialKey derives the key of credential with scrypt, using the parameters of token secrets.
+// It waits for one of the derivation slots to be free.
+func deriveCredentialKey(credential string, salt []byte) ([]byte, error) {
+	derivations <- struct{}{}
+	defer func() { <-derivations }()
+
+	dk, err := scrypt.Key([]byte(credential), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash credential: %w", err)
+	}
+
+	return dk, nil
+}
+
+// verified caches digests of the stored hashes and credentials checked successfully.
+var verified = &credentialCache{entries: make(map[[sha256.Size]byte]struct{})}
+
+// credentialCache is a set of digests bounded by maxVerifiedCredentials, it is emptied when full.
+type credentialCache struct {
+	entries map[[sha256.Size]byte]struct{}
+	mu      sync.Mutex
+}
+
+func (c *credentialCache) contains(key [sha256.Size]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.entries[key]
+
+	return ok
+}
+
+func (c *credentialCache) add(key [sha256.Size]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxVerifiedCredentials {
+		clear(c.entries)
+	}
+
+	c.entries[key] = struct{}{}
+}
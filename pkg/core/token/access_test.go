@@ -0,0 +1,98 @@
+package token
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAccess(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		username string
+		password string
+		header   string
+		key      string
+		wantNil  bool
+	}{
+		{name: "no access", wantNil: true},
+		{name: "basic auth", username: "admin", password: "secret"},
+		{name: "bearer key", key: "secret"},
+		{name: "header key", header: "X-Api-Key", key: "secret"},
+		{name: "missing password", username: "admin", wantErr: ErrInvalidAccess},
+		{name: "colon in username", username: "ad:min", password: "secret", wantErr: ErrInvalidAccess},
+		{name: "basic auth and key", username: "admin", password: "secret", key: "secret", wantErr: ErrInvalidAccess},
+		{name: "header without key", header: "X-Api-Key", wantErr: ErrInvalidAccess},
+		{name: "invalid header", header: "X Api Key", key: "secret", wantErr: ErrInvalidAccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, err := NewAccess(tt.username, tt.password, tt.header, tt.key)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			if tt.wantNil {
+				assert.Nil(t, access)
+				return
+			}
+
+			assert.NotNil(t, access)
+			assert.NotContains(t, access.PasswordHash+access.KeyHash, "secret")
+		})
+	}
+}
+
+func TestAccess_CheckBasic(t *testing.T) {
+	access, err := NewAccess("admin", "secret", "", "")
+	require.NoError(t, err)
+
+	assert.True(t, access.IsBasic())
+	assert.True(t, access.CheckBasic("admin", "secret"))
+	assert.False(t, access.CheckBasic("admin", "wrong"))
+	assert.False(t, access.CheckBasic("other", "secret"))
+	assert.False(t, access.CheckKey("secret"))
+}
+
+func TestAccess_CheckKey(t *testing.T) {
+	access, err := NewAccess("", "", "x-api-key", "secret")
+	require.NoError(t, err)
+
+	assert.False(t, access.IsBasic())
+	assert.Equal(t, "X-Api-Key", access.Header)
+	assert.True(t, access.CheckKey("secret"))
+	assert.False(t, access.CheckKey("wrong"))
+	assert.False(t, access.CheckBasic("", "secret"))
+
+	access, err = NewAccess("", "", "", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAccessHeader, access.Header)
+}
+
+func TestAccess_SaltedHash(t *testing.T) {
+	first, err := NewAccess("admin", "secret", "", "")
+	require.NoError(t, err)
+
+	second, err := NewAccess("admin", "secret", "", "")
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first.PasswordHash, credentialPrefix))
+	assert.NotEqual(t, first.PasswordHash, second.PasswordHash)
+	assert.True(t, second.CheckBasic("admin", "secret"))
+}
+
+func TestAccess_LegacyHash(t *testing.T) {
+	// SHA-256 digest of "secret", as stored by earlier versions.
+	access := &Access{Header: "X-Api-Key", KeyHash: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}
+
+	assert.True(t, access.CheckKey("secret"))
+	assert.False(t, access.CheckKey("wrong"))
+}
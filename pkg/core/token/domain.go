@@ -0,0 +1,35 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDomainLength is the maximum length of a domain name in its text form.
+const maxDomainLength = 253
+
+var ErrInvalidDomain = fmt.Errorf("domain must be a hostname of at least two DNS labels, at most 253 characters long")
+
+// NormalizeDomain returns domain in the form custom domains are stored and looked up in:
+// lower case and without the trailing dot of a fully qualified name.
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
+
+// ValidateDomain checks that domain is a normalized hostname made of at least two valid DNS labels.
+// Returns ErrInvalidDomain if it is not.
+func ValidateDomain(domain string) error {
+	labels := strings.Split(domain, ".")
+
+	if len(domain) > maxDomainLength || len(labels) < 2 {
+		return fmt.Errorf("%q: %w", domain, ErrInvalidDomain)
+	}
+
+	for _, label := range labels {
+		if !subdomainRe.MatchString(label) {
+			return fmt.Errorf("%q: %w", domain, ErrInvalidDomain)
+		}
+	}
+
+	return nil
+}
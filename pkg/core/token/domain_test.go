@@ -0,0 +1,26 @@
+package token
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	assert.Equal(t, "app.example.com", NormalizeDomain("App.Example.COM."))
+	assert.Equal(t, "app.example.com", NormalizeDomain("app.example.com"))
+}
+
+func TestValidateDomain(t *testing.T) {
+	for _, domain := range []string{"example.com", "app.example.com", "x1.a-b.example.co.uk"} {
+		assert.NoError(t, ValidateDomain(domain), domain)
+	}
+
+	long := strings.Repeat(strings.Repeat("a", 63)+".", 4) + "com"
+
+	for _, domain := range []string{"", "localhost", "App.example.com", "app..example.com", "-app.example.com",
+		"app.example.com.", "app_1.example.com", "app.example.com:8080", "*.example.com", long} {
+		assert.ErrorIs(t, ValidateDomain(domain), ErrInvalidDomain, domain)
+	}
+}
@@ -0,0 +1,36 @@
+package token
+
+import "time"
+
+// Meta holds descriptive metadata of a token supplied when it is generated.
+// Owner identifies the person or team the token was issued to.
+type Meta struct {
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+}
+
+// Info is the metadata of a stored token, it never includes the secret.
+// ExpiresAt is zero for tokens that never expire. Type is empty when the stored token does not record its type.
+type Info struct {
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Meta
+	ID   string    `json:"id"`
+	Type TokenType `json:"type,omitempty"`
+}
+
+// NewInfo returns the metadata of t created at now.
+func NewInfo(t *Token, now time.Time) Info {
+	info := Info{
+		ID:        t.ID,
+		Type:      t.Type,
+		Meta:      t.Meta,
+		CreatedAt: now.UTC(),
+	}
+
+	if t.TTL > 0 {
+		info.ExpiresAt = now.Add(t.TTL).UTC()
+	}
+
+	return info
+}
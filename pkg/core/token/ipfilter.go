@@ -0,0 +1,96 @@
+package token
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+var ErrInvalidIPFilter = fmt.Errorf("ip filter entries must be IP addresses or CIDR prefixes")
+
+// IPFilter restricts the end-user IP addresses that may reach a tunnel.
+// Addresses matching Deny are always rejected. When Allow is not empty, only addresses matching Allow are accepted.
+type IPFilter struct {
+	Allow []netip.Prefix `json:"allow,omitempty"`
+	Deny  []netip.Prefix `json:"deny,omitempty"`
+}
+
+// NewIPFilter creates an IP filter from allow and deny lists of CIDR prefixes or single IP addresses.
+// Returns nil and no error if both lists are empty, meaning every address is accepted.
+// Returns ErrInvalidIPFilter if an entry cannot be parsed.
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	allowPrefixes, err := parsePrefixes(allow)
+	if err != nil {
+		return nil, err
+	}
+
+	denyPrefixes, err := parsePrefixes(deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IPFilter{Allow: allowPrefixes, Deny: denyPrefixes}, nil
+}
+
+// Allows reports whether the end-user address ip may reach the tunnel.
+// Addresses that cannot be parsed are rejected.
+func (f *IPFilter) Allows(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	if containsAddr(f.Deny, addr) {
+		return false
+	}
+
+	return len(f.Allow) == 0 || containsAddr(f.Allow, addr)
+}
+
+// parsePrefixes parses CIDR prefixes or single IP addresses, which are turned into prefixes of their full length.
+// Returns ErrInvalidIPFilter if an entry cannot be parsed.
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", entry, ErrInvalidIPFilter)
+			}
+
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, ErrInvalidIPFilter)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// containsAddr reports whether any of prefixes contains addr.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
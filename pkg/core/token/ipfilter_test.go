@@ -0,0 +1,68 @@
+package token
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPFilter(t *testing.T) {
+	filter, err := NewIPFilter(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	_, err = NewIPFilter([]string{"not-an-ip"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidIPFilter)
+
+	_, err = NewIPFilter(nil, []string{"10.0.0.0/33"})
+	assert.ErrorIs(t, err, ErrInvalidIPFilter)
+
+	filter, err = NewIPFilter([]string{"10.0.0.1", "192.168.1.7/24"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1/32", filter.Allow[0].String())
+	assert.Equal(t, "192.168.1.0/24", filter.Allow[1].String())
+}
+
+func TestIPFilter_Allows(t *testing.T) {
+	tests := []struct {
+		name  string
+		ip    string
+		allow []string
+		deny  []string
+		want  bool
+	}{
+		{name: "allowed", allow: []string{"10.0.0.0/8"}, ip: "10.1.2.3", want: true},
+		{name: "not in allow list", allow: []string{"10.0.0.0/8"}, ip: "192.168.1.1", want: false},
+		{name: "denied", deny: []string{"10.0.0.0/8"}, ip: "10.1.2.3", want: false},
+		{name: "not in deny list", deny: []string{"10.0.0.0/8"}, ip: "192.168.1.1", want: true},
+		{name: "deny takes precedence", allow: []string{"10.0.0.0/8"}, deny: []string{"10.0.0.1"}, ip: "10.0.0.1", want: false},
+		{name: "ipv6", allow: []string{"2001:db8::/32"}, ip: "2001:db8::1", want: true},
+		{name: "ipv4 mapped ipv6", allow: []string{"10.0.0.0/8"}, ip: "::ffff:10.0.0.1", want: true},
+		{name: "invalid ip", allow: []string{"10.0.0.0/8"}, ip: "unknown", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewIPFilter(tt.allow, tt.deny)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, filter.Allows(tt.ip))
+		})
+	}
+}
+
+func TestIPFilter_JSON(t *testing.T) {
+	filter, err := NewIPFilter([]string{"10.0.0.0/8"}, []string{"10.0.0.1"})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(filter)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"allow":["10.0.0.0/8"],"deny":["10.0.0.1/32"]}`, string(data))
+
+	var decoded IPFilter
+
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, filter, &decoded)
+}
@@ -0,0 +1,121 @@
+package token
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultQuotaPeriod is the quota window used when a quota is created without an explicit period.
+const DefaultQuotaPeriod = 24 * time.Hour
+
+// AnySubdomain in Policy.Subdomains allows clients to request any subdomain label that is not reserved yet.
+const AnySubdomain = "*"
+
+// subdomainSeparator separates the token ID from the requested subdomain label in the username sent by clients.
+const subdomainSeparator = "@"
+
+var (
+	ErrInvalidQuota     = fmt.Errorf("quota bytes and period must not be negative")
+	ErrInvalidSubdomain = fmt.Errorf("subdomain must be a DNS label of at most 63 lowercase letters, digits and hyphens")
+
+	subdomainRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+// Policy holds per-token restrictions enforced by the server for tunnels opened with the token.
+// The zero value imposes no restrictions.
+// Subdomains lists the custom subdomain labels clients may request for web tunnels, AnySubdomain allows any label.
+// Access restricts who may reach web tunnels opened with the token and IPFilter restricts the end-user
+// addresses that may reach tunnels of any type. Rewrite modifies the headers of the messages of web tunnels and
+// RateLimit overrides the request rate limits of web tunnels configured on the server.
+type Policy struct {
+	Quota      *Quota     `json:"quota,omitempty"`
+	Access     *Access    `json:"access,omitempty"`
+	IPFilter   *IPFilter  `json:"ip_filter,omitempty"`
+	Rewrite    *Rewrite   `json:"rewrite,omitempty"`
+	RateLimit  *RateLimit `json:"rate_limit,omitempty"`
+	Subdomains []string   `json:"subdomains,omitempty"`
+}
+
+// Quota limits the number of bytes a tunnel may proxy, in both directions combined, within a fixed period.
+type Quota struct {
+	Bytes  int64         `json:"bytes"`
+	Period time.Duration `json:"period"`
+}
+
+// NewQuota creates a quota allowing bytes of traffic per period.
+// If period is 0, DefaultQuotaPeriod is used.
+// Returns nil and no error if bytes is 0, meaning the traffic is not limited.
+// Returns ErrInvalidQuota if bytes or period is negative.
+func NewQuota(bytes int64, period time.Duration) (*Quota, error) {
+	if bytes < 0 || period < 0 {
+		return nil, ErrInvalidQuota
+	}
+
+	if bytes == 0 {
+		return nil, nil
+	}
+
+	if period == 0 {
+		period = DefaultQuotaPeriod
+	}
+
+	return &Quota{Bytes: bytes, Period: period}, nil
+}
+
+// IsZero reports whether the policy imposes no restrictions.
+func (p Policy) IsZero() bool {
+	return p.Quota == nil && p.Access == nil && p.IPFilter == nil && p.Rewrite == nil && p.RateLimit == nil &&
+		len(p.Subdomains) == 0
+}
+
+// AllowsSubdomain reports whether clients may request the subdomain label with this policy.
+func (p Policy) AllowsSubdomain(label string) bool {
+	return slices.Contains(p.Subdomains, AnySubdomain) || slices.Contains(p.Subdomains, label)
+}
+
+// ValidateSubdomains checks that every entry of subdomains is a valid DNS label or AnySubdomain.
+// Returns ErrInvalidSubdomain if it is not.
+func ValidateSubdomains(subdomains []string) error {
+	for _, label := range subdomains {
+		if label == AnySubdomain {
+			continue
+		}
+
+		if err := ValidateSubdomain(label); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateSubdomain checks that label is a valid DNS label.
+// Returns ErrInvalidSubdomain if it is not.
+func ValidateSubdomain(label string) error {
+	if !subdomainRe.MatchString(label) {
+		return fmt.Errorf("%q: %w", label, ErrInvalidSubdomain)
+	}
+
+	return nil
+}
+
+// UsernameWithSubdomain returns the username a client sends to request the subdomain label for the token ID
+// with type suffix. Without a label it is the token ID with type suffix.
+func UsernameWithSubdomain(idWithType, label string) string {
+	if label == "" {
+		return idWithType
+	}
+
+	return idWithType + subdomainSeparator + label
+}
+
+// SplitSubdomain splits a username sent by a client into the token ID with type suffix and the requested subdomain label.
+// The label is empty if the client did not request one.
+func SplitSubdomain(username string) (idWithType, label string) {
+	idWithType, label, _ = strings.Cut(username, subdomainSeparator)
+
+	return idWithType, label
+}
@@ -0,0 +1,97 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQuota(t *testing.T) {
+	tests := []struct {
+		want    *Quota
+		wantErr error
+		name    string
+		bytes   int64
+		period  time.Duration
+	}{
+		{
+			name:   "explicit period",
+			bytes:  1024,
+			period: time.Hour,
+			want:   &Quota{Bytes: 1024, Period: time.Hour},
+		},
+		{
+			name:  "default period",
+			bytes: 1024,
+			want:  &Quota{Bytes: 1024, Period: DefaultQuotaPeriod},
+		},
+		{
+			name:   "no quota",
+			period: time.Hour,
+		},
+		{
+			name:    "negative bytes",
+			bytes:   -1,
+			wantErr: ErrInvalidQuota,
+		},
+		{
+			name:    "negative period",
+			bytes:   1,
+			period:  -time.Second,
+			wantErr: ErrInvalidQuota,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewQuota(tt.bytes, tt.period)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolicy_IsZero(t *testing.T) {
+	assert.True(t, Policy{}.IsZero())
+	assert.False(t, Policy{Quota: &Quota{Bytes: 1, Period: time.Hour}}.IsZero())
+	assert.False(t, Policy{Subdomains: []string{"demo"}}.IsZero())
+	assert.False(t, Policy{Access: &Access{Username: "admin"}}.IsZero())
+	assert.False(t, Policy{IPFilter: &IPFilter{}}.IsZero())
+}
+
+func TestPolicy_AllowsSubdomain(t *testing.T) {
+	assert.False(t, Policy{}.AllowsSubdomain("demo"))
+	assert.True(t, Policy{Subdomains: []string{"demo", "other"}}.AllowsSubdomain("demo"))
+	assert.False(t, Policy{Subdomains: []string{"other"}}.AllowsSubdomain("demo"))
+	assert.True(t, Policy{Subdomains: []string{AnySubdomain}}.AllowsSubdomain("demo"))
+}
+
+func TestValidateSubdomains(t *testing.T) {
+	assert.NoError(t, ValidateSubdomains([]string{"payments-demo", "a", "x1", AnySubdomain}))
+
+	for _, label := range []string{"", "-demo", "demo-", "Demo", "a.b", "demo_1", strings.Repeat("a", 64)} {
+		assert.ErrorIs(t, ValidateSubdomains([]string{label}), ErrInvalidSubdomain, label)
+	}
+}
+
+func TestSplitSubdomain(t *testing.T) {
+	username := UsernameWithSubdomain("key-w", "demo")
+	assert.Equal(t, "key-w@demo", username)
+
+	id, label := SplitSubdomain(username)
+	assert.Equal(t, "key-w", id)
+	assert.Equal(t, "demo", label)
+
+	id, label = SplitSubdomain(UsernameWithSubdomain("key-w", ""))
+	assert.Equal(t, "key-w", id)
+	assert.Empty(t, label)
+}
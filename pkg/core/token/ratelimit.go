@@ -0,0 +1,75 @@
+package token
+
+import (
+	"fmt"
+	"math"
+)
+
+var ErrInvalidRateLimit = fmt.Errorf("rate limits must not be negative and a burst requires a rate")
+
+// RateLimit limits the rate of the requests reaching a web tunnel with token buckets.
+// TunnelRate is the number of requests per second the tunnel accepts from all end users together and ClientRate
+// the number of requests per second it accepts from a single end-user IP address. The bursts are the number
+// of requests accepted at once before the rate applies. A zero rate does not limit requests.
+type RateLimit struct {
+	TunnelRate  float64 `json:"tunnel_rate,omitempty"`
+	ClientRate  float64 `json:"client_rate,omitempty"`
+	TunnelBurst int     `json:"tunnel_burst,omitempty"`
+	ClientBurst int     `json:"client_burst,omitempty"`
+}
+
+// NewRateLimit validates limits and returns them with the default burst set for every rate without one,
+// which is the number of requests per second rounded up.
+// Returns nil and no error if no rate is set, meaning requests are not rate limited.
+// Returns ErrInvalidRateLimit if a rate or a burst is negative, or a burst is set without its rate.
+func NewRateLimit(limits RateLimit) (*RateLimit, error) {
+	if limits.TunnelRate < 0 || limits.ClientRate < 0 || limits.TunnelBurst < 0 || limits.ClientBurst < 0 ||
+		math.IsInf(limits.TunnelRate, 0) || math.IsInf(limits.ClientRate, 0) ||
+		math.IsNaN(limits.TunnelRate) || math.IsNaN(limits.ClientRate) {
+		return nil, ErrInvalidRateLimit
+	}
+
+	if (limits.TunnelRate == 0 && limits.TunnelBurst > 0) || (limits.ClientRate == 0 && limits.ClientBurst > 0) {
+		return nil, ErrInvalidRateLimit
+	}
+
+	if limits.TunnelRate == 0 && limits.ClientRate == 0 {
+		return nil, nil
+	}
+
+	limits.TunnelBurst = defaultBurst(limits.TunnelRate, limits.TunnelBurst)
+	limits.ClientBurst = defaultBurst(limits.ClientRate, limits.ClientBurst)
+
+	return &limits, nil
+}
+
+// Override returns the limits of r with the rates set in o replacing them, together with their bursts.
+// Rates not set in o are kept, so a token only overrides the limits it sets. A nil o changes nothing.
+func (r RateLimit) Override(o *RateLimit) RateLimit {
+	if o == nil {
+		return r
+	}
+
+	if o.TunnelRate > 0 {
+		r.TunnelRate, r.TunnelBurst = o.TunnelRate, o.TunnelBurst
+	}
+
+	if o.ClientRate > 0 {
+		r.ClientRate, r.ClientBurst = o.ClientRate, o.ClientBurst
+	}
+
+	return r
+}
+
+// IsZero reports whether the limits do not restrict requests.
+func (r RateLimit) IsZero() bool {
+	return r.TunnelRate == 0 && r.ClientRate == 0
+}
+
+func defaultBurst(rate float64, burst int) int {
+	if rate == 0 || burst > 0 {
+		return burst
+	}
+
+	return int(math.Max(1, math.Ceil(rate)))
+}
@@ -0,0 +1,57 @@
+package token
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRateLimit(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		want    *RateLimit
+		name    string
+		limits  RateLimit
+	}{
+		{name: "no limits"},
+		{name: "tunnel rate", limits: RateLimit{TunnelRate: 10, TunnelBurst: 50}, want: &RateLimit{TunnelRate: 10, TunnelBurst: 50}},
+		{name: "default burst", limits: RateLimit{TunnelRate: 2.5, ClientRate: 0.5}, want: &RateLimit{TunnelRate: 2.5, TunnelBurst: 3, ClientRate: 0.5, ClientBurst: 1}},
+		{name: "negative rate", limits: RateLimit{ClientRate: -1}, wantErr: ErrInvalidRateLimit},
+		{name: "negative burst", limits: RateLimit{ClientRate: 1, ClientBurst: -1}, wantErr: ErrInvalidRateLimit},
+		{name: "burst without rate", limits: RateLimit{TunnelBurst: 10}, wantErr: ErrInvalidRateLimit},
+		{name: "infinite rate", limits: RateLimit{TunnelRate: math.Inf(1)}, wantErr: ErrInvalidRateLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits, err := NewRateLimit(tt.limits)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, limits)
+		})
+	}
+}
+
+func TestRateLimit_Override(t *testing.T) {
+	defaults := RateLimit{TunnelRate: 100, TunnelBurst: 200, ClientRate: 5, ClientBurst: 10}
+
+	assert.Equal(t, defaults, defaults.Override(nil))
+	assert.Equal(t,
+		RateLimit{TunnelRate: 100, TunnelBurst: 200, ClientRate: 1, ClientBurst: 1},
+		defaults.Override(&RateLimit{ClientRate: 1, ClientBurst: 1}),
+	)
+	assert.Equal(t,
+		RateLimit{TunnelRate: 1000, TunnelBurst: 1000, ClientRate: 5, ClientBurst: 10},
+		defaults.Override(&RateLimit{TunnelRate: 1000, TunnelBurst: 1000}),
+	)
+
+	assert.True(t, RateLimit{}.IsZero())
+	assert.False(t, defaults.IsZero())
+}
@@ -0,0 +1,178 @@
+package token
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidRewrite = fmt.Errorf("rewrite rules require valid header names and values and a host[:port] host override")
+
+// protectedHeaders cannot be rewritten as they control the framing of messages or the connection itself.
+// Host is overridden with Rewrite.Host.
+var protectedHeaders = []string{"Connection", "Content-Length", "Host", "Transfer-Encoding", "Upgrade"}
+
+// Rewrite modifies the requests forwarded to a web tunnel and the responses sent back to end users.
+// Host replaces the Host header of requests, which is useful for services relying on virtual hosts or checking
+// the host of requests. Headers in SetRequestHeaders and SetResponseHeaders are set, replacing any value the message
+// already has, and headers in RemoveRequestHeaders and RemoveResponseHeaders are removed.
+type Rewrite struct {
+	SetRequestHeaders     map[string]string `json:"set_request_headers,omitempty"`
+	SetResponseHeaders    map[string]string `json:"set_response_headers,omitempty"`
+	Host                  string            `json:"host,omitempty"`
+	RemoveRequestHeaders  []string          `json:"remove_request_headers,omitempty"`
+	RemoveResponseHeaders []string          `json:"remove_response_headers,omitempty"`
+}
+
+// NewRewrite validates rules and returns them with header names in canonical form.
+// Returns nil and no error if rules are empty, meaning messages are forwarded unchanged.
+// Returns ErrInvalidRewrite if a header name or value is invalid, a header controlling the connection is rewritten,
+// or the host override is not a host name with an optional port.
+func NewRewrite(rules Rewrite) (*Rewrite, error) {
+	if rules.Host == "" && len(rules.SetRequestHeaders) == 0 && len(rules.RemoveRequestHeaders) == 0 &&
+		len(rules.SetResponseHeaders) == 0 && len(rules.RemoveResponseHeaders) == 0 {
+		return nil, nil
+	}
+
+	if rules.Host != "" && !validHost(rules.Host) {
+		return nil, fmt.Errorf("host %q: %w", rules.Host, ErrInvalidRewrite)
+	}
+
+	rw := &Rewrite{Host: rules.Host}
+
+	var err error
+
+	if rw.SetRequestHeaders, err = canonicalHeaderValues(rules.SetRequestHeaders); err != nil {
+		return nil, err
+	}
+
+	if rw.SetResponseHeaders, err = canonicalHeaderValues(rules.SetResponseHeaders); err != nil {
+		return nil, err
+	}
+
+	if rw.RemoveRequestHeaders, err = canonicalHeaderNames(rules.RemoveRequestHeaders); err != nil {
+		return nil, err
+	}
+
+	if rw.RemoveResponseHeaders, err = canonicalHeaderNames(rules.RemoveResponseHeaders); err != nil {
+		return nil, err
+	}
+
+	return rw, nil
+}
+
+// RewriteRequest applies the request rules to r: headers are removed first, then set, and the host is overridden.
+func (rw *Rewrite) RewriteRequest(r *http.Request) {
+	rewriteHeader(r.Header, rw.RemoveRequestHeaders, rw.SetRequestHeaders)
+
+	if rw.Host != "" {
+		r.Host = rw.Host
+	}
+}
+
+// RewriteResponse applies the response rules to the response header h: headers are removed first, then set.
+func (rw *Rewrite) RewriteResponse(h http.Header) {
+	rewriteHeader(h, rw.RemoveResponseHeaders, rw.SetResponseHeaders)
+}
+
+// HasResponseRules reports whether the rules modify responses.
+func (rw *Rewrite) HasResponseRules() bool {
+	return len(rw.SetResponseHeaders) > 0 || len(rw.RemoveResponseHeaders) > 0
+}
+
+func rewriteHeader(h http.Header, remove []string, set map[string]string) {
+	for _, name := range remove {
+		h.Del(name)
+	}
+
+	for name, value := range set {
+		h.Set(name, value)
+	}
+}
+
+// canonicalHeaderValues returns headers with canonical names.
+// Returns ErrInvalidRewrite if a name or a value is invalid, or a header is given twice.
+func canonicalHeaderValues(headers map[string]string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	canonical := make(map[string]string, len(headers))
+
+	for name, value := range headers {
+		name, err := canonicalHeaderName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := canonical[name]; ok {
+			return nil, fmt.Errorf("header %s is set twice: %w", name, ErrInvalidRewrite)
+		}
+
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("value of header %s: %w", name, ErrInvalidRewrite)
+		}
+
+		canonical[name] = value
+	}
+
+	return canonical, nil
+}
+
+// canonicalHeaderNames returns names in canonical form, sorted and without duplicates.
+// Returns ErrInvalidRewrite if a name is invalid.
+func canonicalHeaderNames(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	canonical := make([]string, 0, len(names))
+
+	for _, name := range names {
+		name, err := canonicalHeaderName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		canonical = append(canonical, name)
+	}
+
+	slices.Sort(canonical)
+
+	return slices.Compact(canonical), nil
+}
+
+// canonicalHeaderName returns name in canonical form.
+// Returns ErrInvalidRewrite if name is invalid or the header cannot be rewritten.
+func canonicalHeaderName(name string) (string, error) {
+	if !headerNameRe.MatchString(name) {
+		return "", fmt.Errorf("header %q: %w", name, ErrInvalidRewrite)
+	}
+
+	name = http.CanonicalHeaderKey(name)
+
+	if slices.Contains(protectedHeaders, name) {
+		return "", fmt.Errorf("header %s cannot be rewritten: %w", name, ErrInvalidRewrite)
+	}
+
+	return name, nil
+}
+
+// validHost reports whether host is a host name or IP address with an optional port.
+func validHost(host string) bool {
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if port == "" || strings.Trim(port, "0123456789") != "" {
+			return false
+		}
+
+		host = strings.Trim(h, "[]")
+	}
+
+	if net.ParseIP(host) != nil {
+		return true
+	}
+
+	return ValidateDomain(strings.ToLower(host)) == nil || subdomainRe.MatchString(strings.ToLower(host))
+}
@@ -0,0 +1,102 @@
+package token
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRewrite(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		rules   Rewrite
+		wantNil bool
+	}{
+		{name: "no rules", wantNil: true},
+		{name: "host", rules: Rewrite{Host: "localhost:8080"}},
+		{name: "ip host", rules: Rewrite{Host: "[::1]:8080"}},
+		{name: "headers", rules: Rewrite{SetRequestHeaders: map[string]string{"x-env": "dev"}, RemoveResponseHeaders: []string{"server"}}},
+		{name: "invalid host", rules: Rewrite{Host: "local host"}, wantErr: ErrInvalidRewrite},
+		{name: "invalid host port", rules: Rewrite{Host: "localhost:http"}, wantErr: ErrInvalidRewrite},
+		{name: "invalid header name", rules: Rewrite{SetRequestHeaders: map[string]string{"X Env": "dev"}}, wantErr: ErrInvalidRewrite},
+		{name: "header value with newline", rules: Rewrite{SetResponseHeaders: map[string]string{"X-Env": "dev\r\nX-Other: 1"}}, wantErr: ErrInvalidRewrite},
+		{name: "header set twice", rules: Rewrite{SetRequestHeaders: map[string]string{"x-env": "dev", "X-Env": "prod"}}, wantErr: ErrInvalidRewrite},
+		{name: "protected header", rules: Rewrite{RemoveRequestHeaders: []string{"transfer-encoding"}}, wantErr: ErrInvalidRewrite},
+		{name: "host header", rules: Rewrite{SetRequestHeaders: map[string]string{"Host": "localhost"}}, wantErr: ErrInvalidRewrite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, err := NewRewrite(tt.rules)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			if tt.wantNil {
+				assert.Nil(t, rw)
+				return
+			}
+
+			assert.NotNil(t, rw)
+		})
+	}
+}
+
+func TestNewRewrite_Canonical(t *testing.T) {
+	rw, err := NewRewrite(Rewrite{
+		SetRequestHeaders:    map[string]string{"x-env": "dev"},
+		RemoveRequestHeaders: []string{"x-debug", "cookie", "X-Debug"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"X-Env": "dev"}, rw.SetRequestHeaders)
+	assert.Equal(t, []string{"Cookie", "X-Debug"}, rw.RemoveRequestHeaders)
+	assert.False(t, rw.HasResponseRules())
+}
+
+func TestRewrite_RewriteRequest(t *testing.T) {
+	rw, err := NewRewrite(Rewrite{
+		Host:                 "localhost:3000",
+		SetRequestHeaders:    map[string]string{"X-Env": "dev"},
+		RemoveRequestHeaders: []string{"Cookie"},
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "http://demo.example.com/", http.NoBody)
+	r.Header.Set("Cookie", "session=1")
+	r.Header.Set("X-Env", "prod")
+	r.Header.Set("Accept", "text/html")
+
+	rw.RewriteRequest(r)
+
+	assert.Equal(t, "localhost:3000", r.Host)
+	assert.Equal(t, "dev", r.Header.Get("X-Env"))
+	assert.Empty(t, r.Header.Values("Cookie"))
+	assert.Equal(t, "text/html", r.Header.Get("Accept"))
+}
+
+func TestRewrite_RewriteResponse(t *testing.T) {
+	rw, err := NewRewrite(Rewrite{
+		SetResponseHeaders:    map[string]string{"Strict-Transport-Security": "max-age=31536000"},
+		RemoveResponseHeaders: []string{"Server"},
+	})
+	require.NoError(t, err)
+	assert.True(t, rw.HasResponseRules())
+
+	h := http.Header{"Server": {"nginx"}, "Content-Type": {"text/html"}}
+
+	rw.RewriteResponse(h)
+
+	assert.Equal(t, http.Header{
+		"Content-Type":              {"text/html"},
+		"Strict-Transport-Security": {"max-age=31536000"},
+	}, h)
+}
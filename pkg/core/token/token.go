@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 )
 
@@ -21,7 +22,9 @@ const (
 	defaultTTLSeconds   = 3600 // 1 hour
 )
 
-// TokenType represents the type of token (web or TCP).
+// TokenType represents the type of token (web, TCP or UDP).
+// A multi-purpose token allows several tunnel types, its type is the concatenation of their codes in the order
+// of tunnelTypes, for example "wt" for a token usable for both web and TCP tunnels.
 type TokenType string
 
 const (
@@ -29,25 +32,134 @@ const (
 	TokenTypeWeb TokenType = "w"
 	// TokenTypeTCP represents a token for TCP tunnels.
 	TokenTypeTCP TokenType = "t"
+	// TokenTypeUDP represents a token for UDP tunnels.
+	TokenTypeUDP TokenType = "u"
 )
 
+// tunnelTypes lists the tunnel types in the order their codes appear in the type of a multi-purpose token.
+var tunnelTypes = []TokenType{TokenTypeWeb, TokenTypeTCP, TokenTypeUDP}
+
 // String returns the user-facing string representation of the token type.
-// It maps internal codes to readable names: "w" -> "web", "t" -> "tcp".
+// It maps internal codes to readable names: "w" -> "web", "t" -> "tcp", "u" -> "udp", "wt" -> "web,tcp".
 func (t TokenType) String() string {
 	switch t {
 	case TokenTypeWeb:
 		return "web"
 	case TokenTypeTCP:
 		return "tcp"
-	default:
+	case TokenTypeUDP:
+		return "udp"
+	}
+
+	types := t.Types()
+	if len(types) < 2 {
 		return string(t)
 	}
+
+	names := make([]string, len(types))
+	for i, typ := range types {
+		names[i] = typ.String()
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Types returns the tunnel types a token of type t may be used for.
+// Returns nil if t is not a valid token type.
+func (t TokenType) Types() []TokenType {
+	rest := string(t)
+	types := make([]TokenType, 0, len(tunnelTypes))
+
+	for _, typ := range tunnelTypes {
+		if r, ok := strings.CutPrefix(rest, string(typ)); ok {
+			types = append(types, typ)
+			rest = r
+		}
+	}
+
+	if rest != "" || len(types) == 0 {
+		return nil
+	}
+
+	return types
+}
+
+// Allows reports whether a token of type t may be used for every tunnel type of tunnel.
+func (t TokenType) Allows(tunnel TokenType) bool {
+	allowed := t.Types()
+	requested := tunnel.Types()
+
+	if allowed == nil || requested == nil {
+		return false
+	}
+
+	for _, typ := range requested {
+		if !slices.Contains(allowed, typ) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsMulti reports whether t is the type of a multi-purpose token, allowing more than one tunnel type.
+func (t TokenType) IsMulti() bool {
+	return len(t.Types()) > 1
+}
+
+// CombineTypes returns the type of a token that may be used for all the given tunnel types, in any order.
+// Returns ErrInvalidTokenType if no type is given or a type is unknown.
+func CombineTypes(types ...TokenType) (TokenType, error) {
+	if len(types) == 0 {
+		return "", ErrInvalidTokenType
+	}
+
+	for _, typ := range types {
+		if !slices.Contains(tunnelTypes, typ) {
+			return "", ErrInvalidTokenType
+		}
+	}
+
+	var b strings.Builder
+
+	for _, typ := range tunnelTypes {
+		if slices.Contains(types, typ) {
+			b.WriteString(string(typ))
+		}
+	}
+
+	return TokenType(b.String()), nil
+}
+
+// ParseTokenType maps the user-facing name of a token type to the token type.
+// Names are "web", "tcp" and "udp", or a comma-separated list of them for a multi-purpose token, for example "web,tcp".
+// Returns ErrInvalidTokenType if a name is unknown.
+func ParseTokenType(name string) (TokenType, error) {
+	parts := strings.Split(name, ",")
+	types := make([]TokenType, 0, len(parts))
+
+	for _, part := range parts {
+		switch strings.TrimSpace(part) {
+		case "web":
+			types = append(types, TokenTypeWeb)
+		case "tcp":
+			types = append(types, TokenTypeTCP)
+		case "udp":
+			types = append(types, TokenTypeUDP)
+		default:
+			return "", ErrInvalidTokenType
+		}
+	}
+
+	return CombineTypes(types...)
 }
 
 type Token struct {
+	Meta   Meta
 	ID     string
 	Secret string // #nosec G117 -- This is a field name, not an exposed secret value
 	Type   TokenType
+	Policy Policy
 	TTL    time.Duration
 }
 
@@ -55,14 +167,14 @@ var (
 	ErrTokenTooLong      = fmt.Errorf("token length exceeds maximum limit of %d characters", maxIDLength)
 	ErrTokenInvalid      = fmt.Errorf("token contains invalid characters, only lowercase letters and digits are allowed")
 	ErrInvalidTokenTTL   = fmt.Errorf("ttl must be positive number")
-	ErrInvalidTokenType  = fmt.Errorf("token type must be 'w' (web) or 't' (tcp)")
+	ErrInvalidTokenType  = fmt.Errorf("token type must be 'w' (web), 't' (tcp), 'u' (udp) or a combination of them")
 	ErrInvalidTypeSuffix = fmt.Errorf("invalid or missing type suffix in token ID")
 )
 
 // IsValidTokenType checks if the provided token type is valid.
-// It returns true if the type is either TokenTypeWeb or TokenTypeTCP.
+// It returns true if the type is TokenTypeWeb, TokenTypeTCP, TokenTypeUDP, or the type of a multi-purpose token combining them.
 func IsValidTokenType(t TokenType) bool {
-	return t == TokenTypeWeb || t == TokenTypeTCP
+	return t.Types() != nil
 }
 
 // GenerateToken creates a new token with the specified keyID, time-to-live (TTL), and token type.
@@ -106,7 +218,7 @@ func GenerateToken(keyID string, ttl int, tokenType TokenType) (*Token, error) {
 		keyID = id
 	}
 
-	bufferLen := calculateSecretBuffer(len(keyID))
+	bufferLen := calculateSecretBuffer(len(keyID), len(tokenType))
 
 	secret, err := generateSecret(bufferLen)
 	if err != nil {
@@ -122,7 +234,7 @@ func GenerateToken(keyID string, ttl int, tokenType TokenType) (*Token, error) {
 }
 
 // IDWithType returns the token ID with the type suffix appended.
-// The format is: <ID>-<type> where <type> is 'w' (web) or 't' (tcp).
+// The format is: <ID>-<type> where <type> is 'w' (web), 't' (tcp), 'u' (udp) or a combination such as 'wt' for a multi-purpose token.
 // If the token type is empty, it defaults to TokenTypeWeb.
 func (t *Token) IDWithType() string {
 	tokenType := t.Type
@@ -135,17 +247,19 @@ func (t *Token) IDWithType() string {
 
 // Encode generates a base64-encoded string representation of the token.
 // It combines the token's ID (with type suffix), and Secret, separated by a colon, before encoding.
-// The format is: base64(<ID>-<type>:<Secret>) where <type> is 'w' or 't'.
-// This format is backward compatible with old clients that expect only ID:Secret.
+// The format is: base64(<ID>-<type>:<Secret>) where <type> is 'w', 't', 'u' or a combination such as 'wt'.
+// This format is backward compatible with old clients that expect only ID:Secret,
+// except for multi-purpose tokens that require a client able to select the tunnel type.
 // Returns the encoded token string.
 func (t *Token) Encode() string {
 	return base64.StdEncoding.EncodeToString([]byte(t.IDWithType() + ":" + t.Secret))
 }
 
 // ExtractIDAndType extracts the base ID and token type from an ID with a type suffix.
-// It looks for a pattern like "mykey-w" or "mykey-t" and returns the base ID and type.
+// It looks for a pattern like "mykey-w", "mykey-t" or "mykey-u" and returns the base ID and type.
 // Returns an error if the ID doesn't have a valid type suffix.
-// Valid suffixes are 'w' (web) and 't' (tcp).
+// Valid suffixes are 'w' (web), 't' (tcp) and 'u' (udp), clients always connect for a single tunnel type,
+// so the suffix of a multi-purpose token is not accepted.
 func ExtractIDAndType(idWithSuffix string) (string, TokenType, error) {
 	lastDash := bytes.LastIndexByte([]byte(idWithSuffix), '-')
 	if lastDash == -1 || lastDash == len(idWithSuffix)-1 {
@@ -153,7 +267,7 @@ func ExtractIDAndType(idWithSuffix string) (string, TokenType, error) {
 	}
 
 	suffix := idWithSuffix[lastDash+1:]
-	if suffix != string(TokenTypeWeb) && suffix != string(TokenTypeTCP) {
+	if !slices.Contains(tunnelTypes, TokenType(suffix)) {
 		return "", "", ErrInvalidTypeSuffix
 	}
 
@@ -163,7 +277,7 @@ func ExtractIDAndType(idWithSuffix string) (string, TokenType, error) {
 }
 
 // extractTypeFromIDWithValidation extracts the token type from an ID with a type suffix.
-// It looks for a pattern like "mykey-w" or "mykey-t" and returns the type and whether a valid suffix was found.
+// It looks for a pattern like "mykey-w", "mykey-t" or "mykey-wt" and returns the type and whether a valid suffix was found.
 // If no valid type suffix is found, it returns TokenTypeWeb (default) and false.
 func extractTypeFromIDWithValidation(id string) (TokenType, bool) {
 	lastDash := bytes.LastIndexByte([]byte(id), '-')
@@ -171,18 +285,42 @@ func extractTypeFromIDWithValidation(id string) (TokenType, bool) {
 		return TokenTypeWeb, false
 	}
 
-	suffix := id[lastDash+1:]
-	if suffix == string(TokenTypeWeb) || suffix == string(TokenTypeTCP) {
-		return TokenType(suffix), true
+	suffix := TokenType(id[lastDash+1:])
+	if IsValidTokenType(suffix) {
+		return suffix, true
 	}
 
 	return TokenTypeWeb, false
 }
 
+// SelectType narrows the token to the tunnel type a client opens with it, so that the client connects
+// for that type. An empty tunnelType selects the first type the token allows, web for a multi-purpose token.
+// Returns ErrInvalidTokenType if tunnelType is not a single tunnel type allowed by the token.
+func (t *Token) SelectType(tunnelType TokenType) error {
+	allowed := cmp.Or(t.Type, TokenTypeWeb)
+
+	if tunnelType == "" {
+		types := allowed.Types()
+		if len(types) == 0 {
+			return ErrInvalidTokenType
+		}
+
+		tunnelType = types[0]
+	}
+
+	if tunnelType.IsMulti() || !allowed.Allows(tunnelType) {
+		return fmt.Errorf("token of type %s cannot be used for %s tunnels: %w", allowed, tunnelType, ErrInvalidTokenType)
+	}
+
+	t.Type = tunnelType
+
+	return nil
+}
+
 // Decode parses a base64-encoded string into a Token instance.
 // It validates the encoding and token format, ensuring data integrity.
 // Supports two formats:
-// 1. New format: base64(<ID>-<type>:<Secret>) where <type> is 'w' or 't'
+// 1. New format: base64(<ID>-<type>:<Secret>) where <type> is 'w', 't', 'u' or a combination such as 'wt'
 // 2. Old format: base64(<ID>:<Secret>) defaults to TokenTypeWeb
 // Accepts encoded which is a base64-encoded string containing token ID and Secret.
 // Returns a Token containing the Type, ID and Secret if decoding is successful.
@@ -280,13 +418,13 @@ func randomIntSlice(maxLen, length int) ([]int, error) {
 
 // calculateSecretBuffer calculates the required buffer length for a secret based on the given key ID length.
 // It ensures that the total length of the ID with type suffix, colon separator, and secret is divisible by base64 encoding factor.
-// The format is <ID>-<type>:<secret>, so total length = keyIDLength + 1 (dash) + 1 (type) + 1 (colon) + buffer.
+// The format is <ID>-<type>:<secret>, so total length = keyIDLength + 1 (dash) + typeLength + 1 (colon) + buffer.
 // Returns the calculated buffer length.
-func calculateSecretBuffer(keyIDLength int) int {
+func calculateSecretBuffer(keyIDLength, typeLength int) int {
 	buffer := defaultSecretLength
 
-	// Total length = keyIDLength + 1 (dash) + 1 (type char) + 1 (colon) + buffer
-	for (keyIDLength+3+buffer)%base64Modulo != 0 {
+	// Total length = keyIDLength + 1 (dash) + typeLength + 1 (colon) + buffer
+	for (keyIDLength+typeLength+2+buffer)%base64Modulo != 0 {
 		buffer++
 	}
 
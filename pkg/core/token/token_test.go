@@ -44,6 +44,21 @@ func TestGenerateToken(t *testing.T) {
 		assert.ErrorIs(t, err, ErrInvalidTokenType)
 	})
 
+	t.Run("SaveToken with multi-purpose type", func(t *testing.T) {
+		for _, keyID := range []string{"a", "ab", "abc"} {
+			token, err := GenerateToken(keyID, 100, TokenType("wt"))
+			assert.NoError(t, err, "Token generation should not return an error")
+			assert.Equal(t, TokenType("wt"), token.Type, "Token type should be web and TCP")
+
+			decoded, err := Decode(token.Encode())
+			assert.NoError(t, err, "Decoding should not return an error")
+			assert.Equal(t, token.ID, decoded.ID, "Decoded token ID should match")
+			assert.Equal(t, token.Secret, decoded.Secret, "Decoded token Secret should match")
+			assert.Equal(t, token.Type, decoded.Type, "Decoded token type should match")
+			assert.NotContains(t, token.Encode(), "=", "Encoded token should not be padded")
+		}
+	})
+
 	t.Run("unusually long keyID returns error", func(t *testing.T) {
 		keyID := "testKeyIDtestKeyIDtestKeyIDtestKeyIDtestKeyIDtestKeyIDtestKeyIDtestKeyID"
 		token, err := GenerateToken(keyID, 0, TokenTypeWeb)
@@ -127,6 +142,14 @@ func TestDecode(t *testing.T) {
 		assert.Equal(t, TokenTypeTCP, token.Type, "Decoded token type should be TCP")
 	})
 
+	t.Run("Decode valid multi-purpose token", func(t *testing.T) {
+		encoded := base64.StdEncoding.EncodeToString([]byte("testID-wt:testSecret"))
+		token, err := Decode(encoded)
+		assert.NoError(t, err, "Decoding should not return an error")
+		assert.Equal(t, "testID", token.ID, "Decoded token ID should match")
+		assert.Equal(t, TokenType("wt"), token.Type, "Decoded token type should be web and TCP")
+	})
+
 	t.Run("Decode old token without type prefix defaults to web", func(t *testing.T) {
 		// Old format without type prefix - 2-part format
 		encoded := base64.StdEncoding.EncodeToString([]byte("abc123:testSecret"))
@@ -238,10 +261,116 @@ func TestTokenTypeString(t *testing.T) {
 		assert.Equal(t, "tcp", TokenTypeTCP.String(), "TokenTypeTCP.String() should return 'tcp'")
 	})
 
+	t.Run("TokenTypeUDP String() returns 'udp'", func(t *testing.T) {
+		assert.Equal(t, "udp", TokenTypeUDP.String(), "TokenTypeUDP.String() should return 'udp'")
+	})
+
 	t.Run("Invalid TokenType String() returns raw value", func(t *testing.T) {
 		invalid := TokenType("x")
 		assert.Equal(t, "x", invalid.String(), "Invalid TokenType.String() should return raw value")
 	})
+
+	t.Run("Multi-purpose TokenType String() lists the types", func(t *testing.T) {
+		assert.Equal(t, "web,tcp", TokenType("wt").String())
+	})
+}
+
+func TestTokenType_Types(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  TokenType
+		want []TokenType
+	}{
+		{name: "web", typ: TokenTypeWeb, want: []TokenType{TokenTypeWeb}},
+		{name: "tcp", typ: TokenTypeTCP, want: []TokenType{TokenTypeTCP}},
+		{name: "udp", typ: TokenTypeUDP, want: []TokenType{TokenTypeUDP}},
+		{name: "web and tcp", typ: "wt", want: []TokenType{TokenTypeWeb, TokenTypeTCP}},
+		{name: "tcp and udp", typ: "tu", want: []TokenType{TokenTypeTCP, TokenTypeUDP}},
+		{name: "not canonical", typ: "tw"},
+		{name: "duplicate", typ: "ww"},
+		{name: "unknown", typ: "x"},
+		{name: "empty", typ: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.typ.Types())
+			assert.Equal(t, tt.want != nil, IsValidTokenType(tt.typ))
+			assert.Equal(t, len(tt.want) > 1, tt.typ.IsMulti())
+		})
+	}
+}
+
+func TestTokenType_Allows(t *testing.T) {
+	assert.True(t, TokenTypeWeb.Allows(TokenTypeWeb))
+	assert.False(t, TokenTypeWeb.Allows(TokenTypeTCP))
+	assert.True(t, TokenType("wt").Allows(TokenTypeWeb))
+	assert.True(t, TokenType("wt").Allows(TokenTypeTCP))
+	assert.True(t, TokenType("wt").Allows("wt"))
+	assert.False(t, TokenTypeTCP.Allows("wt"))
+	assert.False(t, TokenType("").Allows(TokenTypeWeb))
+	assert.False(t, TokenTypeWeb.Allows(""))
+}
+
+func TestParseTokenType(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		input   string
+		want    TokenType
+	}{
+		{name: "web", input: "web", want: TokenTypeWeb},
+		{name: "tcp", input: "tcp", want: TokenTypeTCP},
+		{name: "web and tcp", input: "web,tcp", want: "wt"},
+		{name: "any order", input: "tcp, web", want: "wt"},
+		{name: "repeated", input: "web,web", want: TokenTypeWeb},
+		{name: "udp", input: "udp", want: TokenTypeUDP},
+		{name: "all types", input: "udp,tcp,web", want: "wtu"},
+		{name: "unknown", input: "sctp", wantErr: ErrInvalidTokenType},
+		{name: "empty", input: "", wantErr: ErrInvalidTokenType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTokenType(tt.input)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestToken_SelectType(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		typ     TokenType
+		tunnel  TokenType
+		want    TokenType
+	}{
+		{name: "web token default", typ: TokenTypeWeb, want: TokenTypeWeb},
+		{name: "tcp token default", typ: TokenTypeTCP, want: TokenTypeTCP},
+		{name: "multi-purpose default", typ: "wt", want: TokenTypeWeb},
+		{name: "multi-purpose tcp", typ: "wt", tunnel: TokenTypeTCP, want: TokenTypeTCP},
+		{name: "not allowed", typ: TokenTypeWeb, tunnel: TokenTypeTCP, wantErr: ErrInvalidTokenType},
+		{name: "several types", typ: "wt", tunnel: "wt", wantErr: ErrInvalidTokenType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &Token{ID: "mykey", Type: tt.typ}
+
+			err := token.SelectType(tt.tunnel)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.typ, token.Type)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, token.Type)
+		})
+	}
 }
 
 func TestToken_IDWithType(t *testing.T) {
@@ -285,6 +414,13 @@ func TestExtractIDAndType(t *testing.T) {
 		assert.Equal(t, TokenTypeTCP, tokenType)
 	})
 
+	t.Run("Extract UDP token type", func(t *testing.T) {
+		id, tokenType, err := ExtractIDAndType("mykey-u")
+		assert.NoError(t, err)
+		assert.Equal(t, "mykey", id)
+		assert.Equal(t, TokenTypeUDP, tokenType)
+	})
+
 	t.Run("ID without suffix returns error", func(t *testing.T) {
 		id, tokenType, err := ExtractIDAndType("mykey")
 		assert.ErrorIs(t, err, ErrInvalidTypeSuffix)
@@ -299,6 +435,11 @@ func TestExtractIDAndType(t *testing.T) {
 		assert.Equal(t, TokenType(""), tokenType)
 	})
 
+	t.Run("Multi-purpose suffix returns error", func(t *testing.T) {
+		_, _, err := ExtractIDAndType("mykey-wt")
+		assert.ErrorIs(t, err, ErrInvalidTypeSuffix)
+	})
+
 	t.Run("ID ending with dash returns error", func(t *testing.T) {
 		id, tokenType, err := ExtractIDAndType("mykey-")
 		assert.ErrorIs(t, err, ErrInvalidTypeSuffix)
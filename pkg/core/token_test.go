@@ -3,9 +3,11 @@ package core
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/ksysoev/make-it-public/pkg/core/token"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -16,16 +18,19 @@ func TestService_GenerateToken(t *testing.T) {
 	t.Run("successful token generation with empty keyID", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
+
+		meta := token.Meta{Description: "demo", Owner: "team-a"}
 
 		// Mock expectations
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil)
 		mockAuth.EXPECT().SaveToken(context.Background(),
 			mock.MatchedBy(func(t *token.Token) bool {
-				return t.ID != "" && t.Secret != "" && t.TTL == 3600*time.Second
+				return t.ID != "" && t.Secret != "" && t.TTL == 3600*time.Second && t.Meta == meta
 			})).Return(nil)
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb, token.Policy{}, meta)
 
 		// Assert
 		require.NoError(t, err)
@@ -37,18 +42,19 @@ func TestService_GenerateToken(t *testing.T) {
 	t.Run("successful token generation with provided keyID and TTL", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 		keyID := "testkeyid"
 		ttl := 100
 
 		// Mock expectations
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil)
 		mockAuth.EXPECT().SaveToken(context.Background(),
 			mock.MatchedBy(func(t *token.Token) bool {
 				return t.ID == keyID && t.Secret != "" && t.TTL == 100*time.Second
 			})).Return(nil)
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), keyID, ttl, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), keyID, ttl, token.TokenTypeWeb, token.Policy{}, token.Meta{})
 
 		// Assert
 		require.NoError(t, err)
@@ -57,14 +63,62 @@ func TestService_GenerateToken(t *testing.T) {
 		assert.Equal(t, 100*time.Second, tkn.TTL)
 	})
 
+	t.Run("successful token generation with policy", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		svc := New(nil, nil, nil, mockAuth)
+		policy := token.Policy{Quota: &token.Quota{Bytes: 1024, Period: time.Hour}}
+
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil)
+		mockAuth.EXPECT().SaveToken(context.Background(),
+			mock.MatchedBy(func(t *token.Token) bool {
+				return t.Policy.Quota != nil && t.Policy.Quota.Bytes == 1024
+			})).Return(nil)
+
+		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb, policy, token.Meta{})
+
+		require.NoError(t, err)
+		assert.Equal(t, policy, tkn.Policy)
+	})
+
+	t.Run("invalid subdomains", func(t *testing.T) {
+		svc := New(nil, nil, nil, NewMockAuthRepo(t))
+
+		_, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb, token.Policy{Subdomains: []string{"Invalid_Label"}}, token.Meta{})
+		assert.ErrorIs(t, err, token.ErrInvalidSubdomain)
+
+		_, err = svc.GenerateToken(context.Background(), "", 0, token.TokenTypeTCP, token.Policy{Subdomains: []string{"demo"}}, token.Meta{})
+		assert.ErrorIs(t, err, token.ErrInvalidSubdomain)
+	})
+
+	t.Run("access for tcp token", func(t *testing.T) {
+		svc := New(nil, nil, nil, NewMockAuthRepo(t))
+
+		_, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeTCP, token.Policy{Access: &token.Access{Username: "admin"}}, token.Meta{})
+		assert.ErrorIs(t, err, token.ErrInvalidAccess)
+	})
+
+	t.Run("rewrite for tcp token", func(t *testing.T) {
+		svc := New(nil, nil, nil, NewMockAuthRepo(t))
+
+		_, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeTCP, token.Policy{Rewrite: &token.Rewrite{Host: "localhost"}}, token.Meta{})
+		assert.ErrorIs(t, err, token.ErrInvalidRewrite)
+	})
+
+	t.Run("rate limit for tcp token", func(t *testing.T) {
+		svc := New(nil, nil, nil, NewMockAuthRepo(t))
+
+		_, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeTCP, token.Policy{RateLimit: &token.RateLimit{TunnelRate: 10, TunnelBurst: 10}}, token.Meta{})
+		assert.ErrorIs(t, err, token.ErrInvalidRateLimit)
+	})
+
 	t.Run("error from token generation - invalid characters", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 		keyID := "INVALID_KEY!" // Contains invalid characters
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), keyID, 0, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), keyID, 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
 
 		// Assert
 		require.Error(t, err)
@@ -76,11 +130,11 @@ func TestService_GenerateToken(t *testing.T) {
 	t.Run("error from token generation - token too long", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 		keyID := "thisistoolongforatokenid" // Exceeds maxIDLength
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), keyID, 0, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), keyID, 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
 
 		// Assert
 		require.Error(t, err)
@@ -92,10 +146,10 @@ func TestService_GenerateToken(t *testing.T) {
 	t.Run("error from token generation - invalid TTL", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), "validkeyid", -1, token.TokenTypeWeb) // Negative TTL
+		tkn, err := svc.GenerateToken(context.Background(), "validkeyid", -1, token.TokenTypeWeb, token.Policy{}, token.Meta{}) // Negative TTL
 
 		// Assert
 		require.Error(t, err)
@@ -107,17 +161,18 @@ func TestService_GenerateToken(t *testing.T) {
 	t.Run("error from SaveToken", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 		expectedErr := errors.New("database error")
 
 		// Mock expectations
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil)
 		mockAuth.EXPECT().SaveToken(context.Background(),
 			mock.MatchedBy(func(t *token.Token) bool {
 				return t.ID != "" && t.Secret != ""
 			})).Return(expectedErr)
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
 
 		// Assert
 		require.Error(t, err)
@@ -128,17 +183,18 @@ func TestService_GenerateToken(t *testing.T) {
 	t.Run("duplicate token ID with non-empty keyID", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 		keyID := "testkeyid"
 
 		// Mock expectations
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil)
 		mockAuth.EXPECT().SaveToken(context.Background(),
 			mock.MatchedBy(func(t *token.Token) bool {
 				return t.ID == keyID
 			})).Return(ErrDuplicateTokenID)
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), keyID, 0, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), keyID, 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
 
 		// Assert
 		require.Error(t, err)
@@ -150,11 +206,12 @@ func TestService_GenerateToken(t *testing.T) {
 	t.Run("duplicate token ID with empty keyID should retry", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 
 		// Use a counter to simulate different behavior on different calls
 		callCount := 0
 
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil)
 		mockAuth.EXPECT().SaveToken(context.Background(),
 			mock.MatchedBy(func(t *token.Token) bool {
 				return t.ID != "" && t.Secret != ""
@@ -168,7 +225,7 @@ func TestService_GenerateToken(t *testing.T) {
 		})
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
 
 		// Assert
 		require.NoError(t, err)
@@ -177,10 +234,49 @@ func TestService_GenerateToken(t *testing.T) {
 		assert.Equal(t, 2, callCount, "SaveToken should be called exactly twice")
 	})
 
+	t.Run("keyID reserved as a subdomain", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		svc := New(nil, nil, nil, mockAuth)
+
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), "demo").Return("key2", nil)
+
+		tkn, err := svc.GenerateToken(context.Background(), "demo", 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
+
+		assert.Nil(t, tkn)
+		assert.ErrorIs(t, err, ErrDuplicateTokenID)
+	})
+
+	t.Run("generated keyID reserved as a subdomain should retry", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		svc := New(nil, nil, nil, mockAuth)
+
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("key2", nil).Once()
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil).Once()
+		mockAuth.EXPECT().SaveToken(context.Background(), mock.Anything).Return(nil).Once()
+
+		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
+
+		require.NoError(t, err)
+		assert.NotEmpty(t, tkn.ID)
+	})
+
+	t.Run("error resolving subdomain", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		svc := New(nil, nil, nil, mockAuth)
+
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), "demo").Return("", assert.AnError)
+
+		_, err := svc.GenerateToken(context.Background(), "demo", 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
 	t.Run("retry exhaustion", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
+
+		mockAuth.EXPECT().ResolveSubdomain(context.Background(), mock.Anything).Return("", nil)
 
 		// All attempts return duplicate token ID
 		for i := 0; i < attemptsToGenerateToken; i++ {
@@ -191,7 +287,7 @@ func TestService_GenerateToken(t *testing.T) {
 		}
 
 		// Execute
-		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb)
+		tkn, err := svc.GenerateToken(context.Background(), "", 0, token.TokenTypeWeb, token.Policy{}, token.Meta{})
 
 		// Assert
 		require.Error(t, err)
@@ -200,11 +296,137 @@ func TestService_GenerateToken(t *testing.T) {
 	})
 }
 
+func TestService_ListTokens(t *testing.T) {
+	stored := []token.Info{
+		{ID: "d", Type: token.TokenTypeWeb, Meta: token.Meta{Owner: "team-a"}},
+		{ID: "a", Type: token.TokenTypeWeb, Meta: token.Meta{Owner: "team-a"}},
+		{ID: "c", Type: token.TokenTypeTCP, Meta: token.Meta{Owner: "team-b"}},
+		{ID: "b", Type: token.TokenTypeTCP, Meta: token.Meta{Owner: "team-a"}},
+	}
+
+	tests := []struct {
+		name     string
+		wantNext string
+		wantIDs  []string
+		filter   TokenFilter
+	}{
+		{name: "all tokens ordered by ID", wantIDs: []string{"a", "b", "c", "d"}},
+		{name: "filtered by type", filter: TokenFilter{Type: token.TokenTypeTCP}, wantIDs: []string{"b", "c"}},
+		{name: "filtered by owner", filter: TokenFilter{Owner: "team-a"}, wantIDs: []string{"a", "b", "d"}},
+		{name: "first page", filter: TokenFilter{Limit: 2}, wantIDs: []string{"a", "b"}, wantNext: "b"},
+		{name: "last page", filter: TokenFilter{Limit: 2, After: "b"}, wantIDs: []string{"c", "d"}},
+		{name: "filtered page", filter: TokenFilter{Owner: "team-a", Limit: 1, After: "a"}, wantIDs: []string{"b"}, wantNext: "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAuth := NewMockAuthRepo(t)
+			mockAuth.EXPECT().ListTokens(context.Background()).Return(slices.Clone(stored), nil)
+
+			svc := New(nil, nil, nil, mockAuth)
+
+			tokens, next, err := svc.ListTokens(context.Background(), tt.filter)
+			require.NoError(t, err)
+
+			ids := make([]string, len(tokens))
+			for i, tok := range tokens {
+				ids[i] = tok.ID
+			}
+
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantNext, next)
+		})
+	}
+
+	t.Run("multi-purpose tokens match both types", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().ListTokens(context.Background()).Return([]token.Info{
+			{ID: "a", Type: token.TokenTypeWeb},
+			{ID: "b", Type: token.TokenType("wt")},
+			{ID: "c", Type: token.TokenTypeTCP},
+		}, nil).Twice()
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		tokens, _, err := svc.ListTokens(context.Background(), TokenFilter{Type: token.TokenTypeWeb})
+		require.NoError(t, err)
+		require.Len(t, tokens, 2)
+		assert.Equal(t, "a", tokens[0].ID)
+		assert.Equal(t, "b", tokens[1].ID)
+
+		tokens, _, err = svc.ListTokens(context.Background(), TokenFilter{Type: token.TokenTypeTCP})
+		require.NoError(t, err)
+		require.Len(t, tokens, 2)
+		assert.Equal(t, "b", tokens[0].ID)
+		assert.Equal(t, "c", tokens[1].ID)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().ListTokens(context.Background()).Return(nil, assert.AnError)
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		_, _, err := svc.ListTokens(context.Background(), TokenFilter{})
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestService_GetToken(t *testing.T) {
+	mockAuth := NewMockAuthRepo(t)
+	mockAuth.EXPECT().GetTokenInfo(context.Background(), "key1").Return(token.Info{ID: "key1"}, nil)
+	mockAuth.EXPECT().GetTokenInfo(context.Background(), "unknown").Return(token.Info{}, ErrTokenNotFound)
+
+	svc := New(nil, nil, nil, mockAuth)
+
+	info, err := svc.GetToken(context.Background(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, token.Info{ID: "key1"}, info)
+
+	_, err = svc.GetToken(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+}
+
+func TestService_SetTokenTTL(t *testing.T) {
+	tests := []struct {
+		repoErr error
+		wantErr error
+		name    string
+		ttl     int
+		wantTTL time.Duration
+	}{
+		{name: "extended", ttl: 7200, wantTTL: 2 * time.Hour},
+		{name: "never expires", ttl: 0, wantTTL: 0},
+		{name: "negative TTL", ttl: -1, wantErr: token.ErrInvalidTokenTTL},
+		{name: "token not found", ttl: 60, wantTTL: time.Minute, repoErr: ErrTokenNotFound, wantErr: ErrTokenNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAuth := NewMockAuthRepo(t)
+			if tt.ttl >= 0 {
+				mockAuth.EXPECT().SetTokenTTL(context.Background(), "key1", tt.wantTTL).Return(token.Info{ID: "key1"}, tt.repoErr)
+			}
+
+			svc := New(nil, nil, nil, mockAuth)
+
+			info, err := svc.SetTokenTTL(context.Background(), "key1", tt.ttl)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, token.Info{ID: "key1"}, info)
+		})
+	}
+}
+
 func TestService_DeleteToken(t *testing.T) {
 	t.Run("successful token deletion", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 		tokenID := "test-token-id"
 
 		// Mock expectations
@@ -217,13 +439,46 @@ func TestService_DeleteToken(t *testing.T) {
 		require.NoError(t, err)
 	})
 
+	t.Run("disconnects live tunnels", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		svc := New(nil, nil, nil, mockAuth)
+
+		revoked := NewMockControlConn(t)
+		revoked.EXPECT().Close().Return(nil)
+
+		other := NewMockControlConn(t)
+
+		svc.tunnels.add(Tunnel{ID: uuid.New(), KeyID: "test-token-id"}, revoked)
+		svc.tunnels.add(Tunnel{ID: uuid.New(), KeyID: "other-token-id"}, other)
+
+		mockAuth.EXPECT().DeleteToken(context.Background(), "test-token-id").Return(nil)
+
+		require.NoError(t, svc.DeleteToken(context.Background(), "test-token-id"))
+	})
+
+	t.Run("disconnects tunnels on other nodes", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		cluster := NewMockCluster(t)
+		svc := New(nil, nil, nil, mockAuth)
+		svc.SetCluster(cluster)
+
+		mockAuth.EXPECT().DeleteToken(context.Background(), "test-token-id").Return(nil)
+		cluster.EXPECT().Disconnect(context.Background(), "test-token-id").Return(assert.AnError)
+
+		// The token is revoked even if some nodes cannot be reached.
+		require.NoError(t, svc.DeleteToken(context.Background(), "test-token-id"))
+	})
+
 	t.Run("error from DeleteToken", func(t *testing.T) {
 		// Setup
 		mockAuth := NewMockAuthRepo(t)
-		svc := New(nil, nil, mockAuth)
+		svc := New(nil, nil, nil, mockAuth)
 		tokenID := "test-token-id"
 		expectedErr := ErrTokenNotFound
 
+		// A tunnel is left untouched when the token cannot be revoked.
+		svc.tunnels.add(Tunnel{ID: uuid.New(), KeyID: tokenID}, NewMockControlConn(t))
+
 		// Mock expectations
 		mockAuth.EXPECT().DeleteToken(context.Background(), tokenID).Return(expectedErr)
 
@@ -235,3 +490,146 @@ func TestService_DeleteToken(t *testing.T) {
 		assert.ErrorIs(t, err, ErrTokenNotFound)
 	})
 }
+
+func TestService_ResolveKeyID(t *testing.T) {
+	tests := []struct {
+		repoErr error
+		name    string
+		label   string
+		keyID   string
+		want    string
+		wantErr bool
+	}{
+		{name: "reserved subdomain", label: "payments-demo", keyID: "abc123", want: "abc123"},
+		{name: "key ID", label: "abc123", want: "abc123"},
+		{name: "repository error", label: "payments-demo", repoErr: assert.AnError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAuth := NewMockAuthRepo(t)
+			mockAuth.EXPECT().ResolveSubdomain(context.Background(), tt.label).Return(tt.keyID, tt.repoErr)
+
+			svc := New(nil, nil, nil, mockAuth)
+
+			keyID, err := svc.ResolveKeyID(context.Background(), tt.label)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.repoErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, keyID)
+		})
+	}
+}
+
+func TestService_GetPolicy(t *testing.T) {
+	policy := token.Policy{
+		Access:    &token.Access{Username: "admin", PasswordHash: "hash"},
+		Rewrite:   &token.Rewrite{Host: "localhost"},
+		RateLimit: &token.RateLimit{ClientRate: 1, ClientBurst: 5},
+	}
+
+	mockAuth := NewMockAuthRepo(t)
+	mockAuth.EXPECT().GetTokenPolicy(context.Background(), "abc123").Return(policy, nil).Once()
+	mockAuth.EXPECT().GetTokenPolicy(context.Background(), "broken").Return(token.Policy{}, assert.AnError).Once()
+
+	svc := New(nil, nil, nil, mockAuth)
+
+	got, err := svc.GetPolicy(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, policy, got)
+
+	_, err = svc.GetPolicy(context.Background(), "broken")
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestService_GetIPFilter(t *testing.T) {
+	filter := &token.IPFilter{}
+
+	mockAuth := NewMockAuthRepo(t)
+	mockAuth.EXPECT().GetTokenPolicy(context.Background(), "abc123").Return(token.Policy{IPFilter: filter}, nil).Once()
+	mockAuth.EXPECT().GetTokenPolicy(context.Background(), "broken").Return(token.Policy{}, assert.AnError).Once()
+
+	svc := New(nil, nil, nil, mockAuth)
+
+	got, err := svc.GetIPFilter(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Same(t, filter, got)
+
+	_, err = svc.GetIPFilter(context.Background(), "broken")
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestService_reserveSubdomain(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("allowed subdomain", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().GetTokenPolicy(ctx, "abc123").Return(token.Policy{Subdomains: []string{"payments-demo"}}, nil)
+		mockAuth.EXPECT().ReserveSubdomain(ctx, "payments-demo", "abc123").Return(nil)
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		assert.NoError(t, svc.reserveSubdomain(ctx, "abc123", "payments-demo"))
+	})
+
+	t.Run("any subdomain", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().GetTokenPolicy(ctx, "abc123").Return(token.Policy{Subdomains: []string{token.AnySubdomain}}, nil)
+		mockAuth.EXPECT().ReserveSubdomain(ctx, "payments-demo", "abc123").Return(nil)
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		assert.NoError(t, svc.reserveSubdomain(ctx, "abc123", "payments-demo"))
+	})
+
+	t.Run("invalid subdomain", func(t *testing.T) {
+		svc := New(nil, nil, nil, NewMockAuthRepo(t))
+
+		assert.ErrorIs(t, svc.reserveSubdomain(ctx, "abc123", "Payments.Demo"), token.ErrInvalidSubdomain)
+	})
+
+	t.Run("subdomain not allowed", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().GetTokenPolicy(ctx, "abc123").Return(token.Policy{Subdomains: []string{"other"}}, nil)
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		assert.ErrorContains(t, svc.reserveSubdomain(ctx, "abc123", "payments-demo"), "not allowed")
+	})
+
+	t.Run("subdomain taken", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().GetTokenPolicy(ctx, "abc123").Return(token.Policy{Subdomains: []string{token.AnySubdomain}}, nil)
+		mockAuth.EXPECT().ReserveSubdomain(ctx, "payments-demo", "abc123").Return(ErrSubdomainTaken)
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		err := svc.reserveSubdomain(ctx, "abc123", "payments-demo")
+		assert.ErrorIs(t, err, ErrSubdomainTaken)
+		assert.NotErrorIs(t, err, errAuthUnavailable)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().GetTokenPolicy(ctx, "abc123").Return(token.Policy{Subdomains: []string{token.AnySubdomain}}, nil)
+		mockAuth.EXPECT().ReserveSubdomain(ctx, "payments-demo", "abc123").Return(assert.AnError)
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		err := svc.reserveSubdomain(ctx, "abc123", "payments-demo")
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorIs(t, err, errAuthUnavailable)
+	})
+
+	t.Run("policy error", func(t *testing.T) {
+		mockAuth := NewMockAuthRepo(t)
+		mockAuth.EXPECT().GetTokenPolicy(ctx, "abc123").Return(token.Policy{}, assert.AnError)
+
+		svc := New(nil, nil, nil, mockAuth)
+
+		assert.ErrorIs(t, svc.reserveSubdomain(ctx, "abc123", "payments-demo"), errAuthUnavailable)
+	})
+}
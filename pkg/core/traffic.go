@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+var ErrQuotaExceeded = errors.New("traffic quota exceeded")
+
+// TrafficUsage holds the number of bytes proxied through a tunnel.
+// Inbound is traffic from end users to the MIT client, Outbound is traffic from the MIT client to end users.
+type TrafficUsage struct {
+	Inbound  int64
+	Outbound int64
+}
+
+// Total returns the number of bytes proxied in both directions.
+func (u TrafficUsage) Total() int64 {
+	return u.Inbound + u.Outbound
+}
+
+// trafficFlushInterval is how often the traffic accounted by a meter is added to the usage counters of the
+// auth repository, and the usage of the quota window is reloaded to account the traffic of the other servers.
+var trafficFlushInterval = 5 * time.Second
+
+// trafficMeter counts the bytes proxied for keyID by all end-user connections served by this server and
+// enforces the quota of the token against the usage of the current quota window, shared by all connections.
+// The bytes are added to the usage counters of the auth repository every trafficFlushInterval and when the last
+// connection using the meter is closed.
+// period is the quota window the usage is accounted to, zero when the tunnel has no quota.
+type trafficMeter struct {
+	auth     AuthRepo
+	stop     chan struct{}
+	done     chan struct{}
+	keyID    string
+	period   time.Duration
+	limit    atomic.Int64
+	used     atomic.Int64
+	inbound  atomic.Int64
+	outbound atomic.Int64
+	// base is the usage loaded from the auth repository minus the bytes flushed by the meter until then,
+	// used is base plus all the bytes accounted by the meter.
+	base    int64
+	flushed int64
+	refs    int
+	mu      sync.Mutex
+	loaded  bool
+}
+
+// consume accounts n bytes against the quota and the unflushed counter for the given direction.
+// Returns ErrQuotaExceeded without accounting the bytes if the quota would be exceeded.
+func (m *trafficMeter) consume(counter *atomic.Int64, n int) error {
+	if m.used.Add(int64(n)) > m.limit.Load() {
+		m.used.Add(-int64(n))
+		return ErrQuotaExceeded
+	}
+
+	counter.Add(int64(n))
+
+	return nil
+}
+
+// exceeded reports whether the quota is used up.
+func (m *trafficMeter) exceeded() bool {
+	return m.used.Load() >= m.limit.Load()
+}
+
+// load loads the usage of the current quota window from the auth repository, unless it is already loaded
+// or the tunnel has no quota.
+// Returns an error if the usage cannot be loaded.
+func (m *trafficMeter) load(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.loaded || m.period == 0 {
+		return nil
+	}
+
+	return m.reload(ctx)
+}
+
+// reload loads the usage of the current quota window from the auth repository and updates the quota usage with it.
+// The bytes flushed by the meter are part of the loaded usage, so only the bytes not flushed yet are added to it.
+// The caller must hold m.mu. Returns an error if the usage cannot be loaded.
+func (m *trafficMeter) reload(ctx context.Context) error {
+	usage, err := m.auth.GetTrafficUsage(ctx, m.keyID, m.period)
+	if err != nil {
+		return fmt.Errorf("failed to get traffic usage: %w", err)
+	}
+
+	base := usage.Total() - m.flushed
+	m.used.Add(base - m.base)
+	m.base = base
+	m.loaded = true
+
+	return nil
+}
+
+// record adds the bytes accounted since the last call to the usage counters of the auth repository.
+// The caller must hold m.mu. On failure the bytes are kept to be recorded by the next call, and the error is logged.
+func (m *trafficMeter) record(ctx context.Context) {
+	usage := TrafficUsage{Inbound: m.inbound.Swap(0), Outbound: m.outbound.Swap(0)}
+	if usage.Total() == 0 {
+		return
+	}
+
+	if err := m.auth.AddTrafficUsage(ctx, m.keyID, usage, m.period); err != nil {
+		m.inbound.Add(usage.Inbound)
+		m.outbound.Add(usage.Outbound)
+		slog.ErrorContext(ctx, "failed to record traffic usage", slog.String("keyID", m.keyID), slog.Any("error", err))
+
+		return
+	}
+
+	m.flushed += usage.Total()
+}
+
+// flush records the accounted bytes and reloads the usage of the current quota window,
+// so that the traffic proxied for keyID by the other servers counts against the quota.
+// Failures are logged rather than returned.
+func (m *trafficMeter) flush(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.record(ctx)
+
+	if m.period == 0 || !m.loaded {
+		return
+	}
+
+	if err := m.reload(ctx); err != nil {
+		slog.ErrorContext(ctx, "failed to reload traffic usage", slog.String("keyID", m.keyID), slog.Any("error", err))
+	}
+}
+
+// run flushes the meter every trafficFlushInterval until stop is closed.
+func (m *trafficMeter) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(trafficFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.flush(context.Background())
+		}
+	}
+}
+
+// reader wraps r so that bytes read from the end user are accounted as inbound traffic.
+func (m *trafficMeter) reader(r io.Reader) io.Reader {
+	return &meteredReader{Reader: r, meter: m}
+}
+
+// writer wraps w so that bytes written to the end user are accounted as outbound traffic.
+func (m *trafficMeter) writer(w io.Writer) io.Writer {
+	return &meteredWriter{Writer: w, meter: m}
+}
+
+type meteredReader struct {
+	io.Reader
+	meter *trafficMeter
+}
+
+func (r *meteredReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if qErr := r.meter.consume(&r.meter.inbound, n); qErr != nil {
+			return 0, qErr
+		}
+	}
+
+	return n, err
+}
+
+type meteredWriter struct {
+	io.Writer
+	meter *trafficMeter
+}
+
+func (w *meteredWriter) Write(p []byte) (int, error) {
+	if err := w.meter.consume(&w.meter.outbound, len(p)); err != nil {
+		return 0, err
+	}
+
+	return w.Writer.Write(p)
+}
+
+// meteredConn accounts bytes written to the reverse connection as inbound traffic.
+// It is used for the initial request data that is written before the pipes start.
+type meteredConn struct {
+	net.Conn
+	meter *trafficMeter
+}
+
+func (c *meteredConn) Write(p []byte) (int, error) {
+	if err := c.meter.consume(&c.meter.inbound, len(p)); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Write(p)
+}
+
+// trafficMeterKey identifies the meter of a tunnel and the quota window it accounts the usage to.
+type trafficMeterKey struct {
+	keyID  string
+	period time.Duration
+}
+
+// trafficMeters holds the meters of the tunnels with end-user connections in progress.
+type trafficMeters struct {
+	byKey map[trafficMeterKey]*trafficMeter
+	mu    sync.Mutex
+}
+
+// acquire returns the meter of keyID for the quota, creating it if no connection of the tunnel is in progress.
+// The meter must be returned with release once the connection is closed.
+func (t *trafficMeters) acquire(auth AuthRepo, keyID string, quota *token.Quota) *trafficMeter {
+	key := trafficMeterKey{keyID: keyID}
+	limit := int64(math.MaxInt64)
+
+	if quota != nil {
+		key.period = quota.Period
+		limit = quota.Bytes
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	meter, ok := t.byKey[key]
+	if !ok {
+		if t.byKey == nil {
+			t.byKey = make(map[trafficMeterKey]*trafficMeter)
+		}
+
+		meter = &trafficMeter{
+			auth:   auth,
+			keyID:  keyID,
+			period: key.period,
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		t.byKey[key] = meter
+
+		go meter.run()
+	}
+
+	// The quota of the token may have been updated since the meter was created.
+	meter.limit.Store(limit)
+	meter.refs++
+
+	return meter
+}
+
+// release returns a meter obtained with acquire.
+// Returns true if it was the last reference, the meter is then removed and its flush loop stopped.
+func (t *trafficMeters) release(meter *trafficMeter) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	meter.refs--
+	if meter.refs > 0 {
+		return false
+	}
+
+	delete(t.byKey, trafficMeterKey{keyID: meter.keyID, period: meter.period})
+	close(meter.stop)
+
+	return true
+}
+
+// newTrafficMeter loads the traffic policy of keyID and returns the meter shared by the connections of the tunnel
+// for a new end-user connection. The meter must be released with releaseTrafficMeter once the connection is closed.
+// Returns ErrQuotaExceeded if the quota of the tunnel is already used up for the current period,
+// or an error if the policy or usage cannot be loaded from the auth repository.
+func (s *Service) newTrafficMeter(ctx context.Context, keyID string) (*trafficMeter, error) {
+	policy, err := s.auth.GetTokenPolicy(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token policy: %w", err)
+	}
+
+	meter := s.traffic.acquire(s.auth, keyID, policy.Quota)
+
+	if err := meter.load(ctx); err != nil {
+		s.releaseTrafficMeter(ctx, meter)
+		return nil, err
+	}
+
+	if meter.exceeded() {
+		s.releaseTrafficMeter(ctx, meter)
+		return nil, fmt.Errorf("keyID %s used %d of %d bytes: %w", keyID, meter.used.Load(), meter.limit.Load(), ErrQuotaExceeded)
+	}
+
+	return meter, nil
+}
+
+// releaseTrafficMeter releases a meter obtained with newTrafficMeter.
+// When the last connection of the tunnel is closed, the bytes not flushed yet are recorded in the auth repository.
+// Failures are logged rather than returned, as the connection has already been served.
+func (s *Service) releaseTrafficMeter(ctx context.Context, meter *trafficMeter) {
+	if !s.traffic.release(meter) {
+		return
+	}
+
+	<-meter.done
+
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+
+	meter.record(context.WithoutCancel(ctx))
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMeter returns a meter of keyID with a quota of limit bytes per hour that is not flushed in the background.
+func newTestMeter(auth AuthRepo, limit int64) *trafficMeter {
+	meter := &trafficMeter{auth: auth, keyID: "key", period: time.Hour, loaded: true}
+	meter.limit.Store(limit)
+
+	return meter
+}
+
+func TestTrafficMeter_ReaderWriter(t *testing.T) {
+	meter := newTestMeter(nil, 10)
+
+	var out bytes.Buffer
+
+	n, err := meter.writer(&out).Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	buf := make([]byte, 4)
+	n, err = meter.reader(strings.NewReader("abcd")).Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	assert.Equal(t, int64(4), meter.inbound.Load())
+	assert.Equal(t, int64(5), meter.outbound.Load())
+
+	// Only one byte of the quota is left, so a larger write is refused and not accounted.
+	n, err = meter.writer(&out).Write([]byte("world"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Zero(t, n)
+	assert.Equal(t, "hello", out.String())
+	assert.Equal(t, int64(5), meter.outbound.Load())
+	assert.Equal(t, int64(9), meter.used.Load())
+}
+
+func TestTrafficMeter_MeteredConn(t *testing.T) {
+	srv, cli := net.Pipe()
+	defer srv.Close()
+	defer cli.Close()
+
+	meter := newTestMeter(nil, 3)
+	c := &meteredConn{Conn: srv, meter: meter}
+
+	_, err := c.Write([]byte("too long"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Zero(t, meter.used.Load())
+}
+
+func TestTrafficMeter_Flush(t *testing.T) {
+	auth := NewMockAuthRepo(t)
+	meter := newTestMeter(auth, 100)
+	meter.base = 10
+	meter.used.Store(10)
+
+	require.NoError(t, meter.consume(&meter.inbound, 15))
+	require.NoError(t, meter.consume(&meter.outbound, 5))
+
+	// Other servers proxied 30 bytes for the tunnel since the usage was loaded.
+	auth.EXPECT().AddTrafficUsage(mock.Anything, "key", TrafficUsage{Inbound: 15, Outbound: 5}, time.Hour).Return(nil).Once()
+	auth.EXPECT().GetTrafficUsage(mock.Anything, "key", time.Hour).Return(TrafficUsage{Inbound: 45, Outbound: 15}, nil).Once()
+
+	meter.flush(context.Background())
+
+	assert.Equal(t, int64(60), meter.used.Load())
+	assert.Zero(t, meter.inbound.Load())
+	assert.Zero(t, meter.outbound.Load())
+
+	// Bytes that cannot be recorded are kept for the next flush, and the usage stays consistent.
+	require.NoError(t, meter.consume(&meter.outbound, 10))
+
+	auth.EXPECT().AddTrafficUsage(mock.Anything, "key", TrafficUsage{Outbound: 10}, time.Hour).Return(assert.AnError).Once()
+	auth.EXPECT().GetTrafficUsage(mock.Anything, "key", time.Hour).Return(TrafficUsage{Inbound: 45, Outbound: 15}, nil).Once()
+
+	meter.flush(context.Background())
+
+	assert.Equal(t, int64(70), meter.used.Load())
+	assert.Equal(t, int64(10), meter.outbound.Load())
+
+	// A new quota window starts with the bytes that were not recorded yet.
+	auth.EXPECT().AddTrafficUsage(mock.Anything, "key", TrafficUsage{Outbound: 10}, time.Hour).Return(nil).Once()
+	auth.EXPECT().GetTrafficUsage(mock.Anything, "key", time.Hour).Return(TrafficUsage{Outbound: 10}, nil).Once()
+
+	meter.flush(context.Background())
+
+	assert.Equal(t, int64(10), meter.used.Load())
+}
+
+func TestTrafficMeter_PeriodicFlush(t *testing.T) {
+	trafficFlushInterval = 10 * time.Millisecond
+	t.Cleanup(func() { trafficFlushInterval = 5 * time.Second })
+
+	auth := NewMockAuthRepo(t)
+	auth.EXPECT().GetTokenPolicy(mock.Anything, "key").Return(token.Policy{}, nil)
+
+	flushed := make(chan TrafficUsage, 1)
+
+	auth.EXPECT().AddTrafficUsage(mock.Anything, "key", mock.Anything, time.Duration(0)).
+		RunAndReturn(func(_ context.Context, _ string, usage TrafficUsage, _ time.Duration) error {
+			flushed <- usage
+			return nil
+		}).Once()
+
+	svc := New(nil, nil, nil, auth)
+
+	meter, err := svc.newTrafficMeter(context.Background(), "key")
+	require.NoError(t, err)
+
+	require.NoError(t, meter.consume(&meter.inbound, 7))
+
+	// The usage is recorded while the connection is still open.
+	select {
+	case usage := <-flushed:
+		assert.Equal(t, TrafficUsage{Inbound: 7}, usage)
+	case <-time.After(time.Second):
+		t.Fatal("traffic usage was not flushed")
+	}
+
+	svc.releaseTrafficMeter(context.Background(), meter)
+}
+
+func TestService_NewTrafficMeter(t *testing.T) {
+	tests := []struct {
+		setup      func(auth *MockAuthRepo)
+		wantErr    error
+		name       string
+		wantLimit  int64
+		wantUsed   int64
+		wantPeriod time.Duration
+	}{
+		{
+			name: "no quota",
+			setup: func(auth *MockAuthRepo) {
+				auth.EXPECT().GetTokenPolicy(mock.Anything, "key").Return(token.Policy{}, nil)
+			},
+			wantLimit: math.MaxInt64,
+		},
+		{
+			name: "remaining quota",
+			setup: func(auth *MockAuthRepo) {
+				auth.EXPECT().GetTokenPolicy(mock.Anything, "key").Return(token.Policy{Quota: &token.Quota{Bytes: 100, Period: time.Hour}}, nil)
+				auth.EXPECT().GetTrafficUsage(mock.Anything, "key", time.Hour).Return(TrafficUsage{Inbound: 30, Outbound: 20}, nil)
+			},
+			wantLimit:  100,
+			wantUsed:   50,
+			wantPeriod: time.Hour,
+		},
+		{
+			name: "quota used up",
+			setup: func(auth *MockAuthRepo) {
+				auth.EXPECT().GetTokenPolicy(mock.Anything, "key").Return(token.Policy{Quota: &token.Quota{Bytes: 100, Period: time.Hour}}, nil)
+				auth.EXPECT().GetTrafficUsage(mock.Anything, "key", time.Hour).Return(TrafficUsage{Inbound: 100}, nil)
+			},
+			wantErr: ErrQuotaExceeded,
+		},
+		{
+			name: "usage error",
+			setup: func(auth *MockAuthRepo) {
+				auth.EXPECT().GetTokenPolicy(mock.Anything, "key").Return(token.Policy{Quota: &token.Quota{Bytes: 100, Period: time.Hour}}, nil)
+				auth.EXPECT().GetTrafficUsage(mock.Anything, "key", time.Hour).Return(TrafficUsage{}, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewMockAuthRepo(t)
+			tt.setup(auth)
+
+			svc := New(nil, nil, nil, auth)
+
+			meter, err := svc.newTrafficMeter(context.Background(), "key")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, svc.traffic.byKey)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLimit, meter.limit.Load())
+			assert.Equal(t, tt.wantUsed, meter.used.Load())
+			assert.Equal(t, tt.wantPeriod, meter.period)
+
+			svc.releaseTrafficMeter(context.Background(), meter)
+		})
+	}
+}
+
+func TestService_TrafficMeterSharedByConnections(t *testing.T) {
+	auth := NewMockAuthRepo(t)
+	auth.EXPECT().GetTokenPolicy(mock.Anything, "key").Return(token.Policy{Quota: &token.Quota{Bytes: 100, Period: time.Hour}}, nil)
+	auth.EXPECT().GetTrafficUsage(mock.Anything, "key", time.Hour).Return(TrafficUsage{Inbound: 20}, nil).Once()
+
+	svc := New(nil, nil, nil, auth)
+
+	first, err := svc.newTrafficMeter(context.Background(), "key")
+	require.NoError(t, err)
+
+	second, err := svc.newTrafficMeter(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// Concurrent connections spend the same remaining quota.
+	require.NoError(t, first.consume(&first.outbound, 50))
+	assert.ErrorIs(t, second.consume(&second.outbound, 50), ErrQuotaExceeded)
+	require.NoError(t, second.consume(&second.outbound, 30))
+
+	// Once the quota is used up, new connections are rejected without loading the usage again.
+	_, err = svc.newTrafficMeter(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	svc.releaseTrafficMeter(context.Background(), first)
+
+	// The unflushed bytes are recorded when the last connection is closed.
+	auth.EXPECT().AddTrafficUsage(mock.Anything, "key", TrafficUsage{Outbound: 80}, time.Hour).Return(assert.AnError).Once()
+
+	// Errors are logged, not propagated.
+	svc.releaseTrafficMeter(context.Background(), second)
+	assert.Empty(t, svc.traffic.byKey)
+}
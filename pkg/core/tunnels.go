@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+var ErrTunnelNotFound = errors.New("tunnel not found")
+
+// Tunnel describes a control connection of a MIT client that is registered on this server.
+// Protocol is the revdial protocol version of the connection, V1 or V2.
+// Endpoint is the public URL of web tunnels or the host:port of TCP tunnels.
+// InFlight is the number of end-user connections currently proxied through the control connection.
+type Tunnel struct {
+	ConnectedAt time.Time
+	KeyID       string
+	Type        token.TokenType
+	Protocol    string
+	RemoteAddr  string
+	Endpoint    string
+	InFlight    int64
+	ID          uuid.UUID
+}
+
+// liveTunnel is a registered tunnel along with its control connection and in-flight connection counter.
+type liveTunnel struct {
+	conn     io.Closer
+	info     Tunnel
+	inFlight atomic.Int64
+}
+
+// tunnelRegistry keeps track of the control connections added to the connection managers.
+type tunnelRegistry struct {
+	tunnels map[uuid.UUID]*liveTunnel
+	mu      sync.RWMutex
+}
+
+// add registers the tunnel t served by the control connection c.
+// It returns a function that must be called once the control connection is removed.
+func (r *tunnelRegistry) add(t Tunnel, c io.Closer) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tunnels == nil {
+		r.tunnels = make(map[uuid.UUID]*liveTunnel)
+	}
+
+	r.tunnels[t.ID] = &liveTunnel{info: t, conn: c}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.tunnels, t.ID)
+	}
+}
+
+// track counts an end-user connection proxied through the control connection with the given id.
+// It returns a function that must be called once the end-user connection is closed.
+// Connections of unknown control connections are not counted.
+func (r *tunnelRegistry) track(id uuid.UUID) func() {
+	r.mu.RLock()
+	t, ok := r.tunnels[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return func() {}
+	}
+
+	t.inFlight.Add(1)
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { t.inFlight.Add(-1) })
+	}
+}
+
+// disconnect closes the control connections of the tunnels registered for keyID.
+// Closing a control connection ends its serving loop, which removes the tunnel and releases its resources.
+// Returns the number of closed control connections.
+func (r *tunnelRegistry) disconnect(ctx context.Context, keyID string) int {
+	r.mu.RLock()
+
+	conns := make([]io.Closer, 0, 1)
+
+	for _, t := range r.tunnels {
+		if t.info.KeyID == keyID {
+			conns = append(conns, t.conn)
+		}
+	}
+
+	r.mu.RUnlock()
+
+	for _, c := range conns {
+		if err := c.Close(); err != nil {
+			slog.DebugContext(ctx, "failed to close control connection", slog.String("keyID", keyID), slog.Any("error", err))
+		}
+	}
+
+	if len(conns) > 0 {
+		slog.InfoContext(ctx, "tunnel disconnected", slog.String("keyID", keyID), slog.Int("connections", len(conns)))
+	}
+
+	return len(conns)
+}
+
+// list returns the registered tunnels, oldest first.
+func (r *tunnelRegistry) list() []Tunnel {
+	r.mu.RLock()
+
+	tunnels := make([]Tunnel, 0, len(r.tunnels))
+
+	for _, t := range r.tunnels {
+		info := t.info
+		info.InFlight = t.inFlight.Load()
+		tunnels = append(tunnels, info)
+	}
+
+	r.mu.RUnlock()
+
+	slices.SortFunc(tunnels, func(a, b Tunnel) int {
+		if c := a.ConnectedAt.Compare(b.ConnectedAt); c != 0 {
+			return c
+		}
+
+		return slices.Compare(a.ID[:], b.ID[:])
+	})
+
+	return tunnels
+}
+
+// ListTunnels returns the control connections of the MIT clients connected to this server, oldest first.
+func (s *Service) ListTunnels(_ context.Context) []Tunnel {
+	return s.tunnels.list()
+}
+
+// DisconnectTunnel closes the control connections of the MIT client identified by keyID on this server.
+// End-user connections proxied through them are closed as well and TCP ports are released.
+// The token stays valid, so the client may connect again.
+// Returns ErrTunnelNotFound if the client is not connected to this server.
+func (s *Service) DisconnectTunnel(ctx context.Context, keyID string) error {
+	if s.tunnels.disconnect(ctx, keyID) == 0 {
+		return fmt.Errorf("keyID %s is not connected: %w", keyID, ErrTunnelNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ksysoev/make-it-public/pkg/core/conn"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTunnelRegistry(t *testing.T) {
+	var r tunnelRegistry
+
+	assert.Empty(t, r.list())
+
+	now := time.Now()
+	older := Tunnel{ID: uuid.New(), KeyID: "key1", Type: token.TokenTypeWeb, ConnectedAt: now.Add(-time.Minute)}
+	newer := Tunnel{ID: uuid.New(), KeyID: "key2", Type: token.TokenTypeTCP, ConnectedAt: now}
+
+	removeNewer := r.add(newer, NewMockControlConn(t))
+	removeOlder := r.add(older, NewMockControlConn(t))
+
+	done1 := r.track(older.ID)
+	done2 := r.track(older.ID)
+	r.track(uuid.New())()
+
+	tunnels := r.list()
+	require.Len(t, tunnels, 2)
+	assert.Equal(t, "key1", tunnels[0].KeyID)
+	assert.Equal(t, int64(2), tunnels[0].InFlight)
+	assert.Equal(t, "key2", tunnels[1].KeyID)
+	assert.Zero(t, tunnels[1].InFlight)
+
+	done1()
+	done1()
+
+	assert.Equal(t, int64(1), r.list()[0].InFlight)
+
+	done2()
+	removeOlder()
+
+	tunnels = r.list()
+	require.Len(t, tunnels, 1)
+	assert.Equal(t, newer, tunnels[0])
+
+	removeNewer()
+
+	assert.Empty(t, r.list())
+}
+
+func TestService_DisconnectTunnel(t *testing.T) {
+	svc := New(nil, nil, nil, nil)
+
+	err := svc.DisconnectTunnel(t.Context(), "key1")
+	require.ErrorIs(t, err, ErrTunnelNotFound)
+
+	first := NewMockControlConn(t)
+	first.EXPECT().Close().Return(nil)
+
+	second := NewMockControlConn(t)
+	second.EXPECT().Close().Return(assert.AnError)
+
+	svc.tunnels.add(Tunnel{ID: uuid.New(), KeyID: "key1"}, first)
+	svc.tunnels.add(Tunnel{ID: uuid.New(), KeyID: "key1"}, second)
+	svc.tunnels.add(Tunnel{ID: uuid.New(), KeyID: "key2"}, NewMockControlConn(t))
+
+	require.NoError(t, svc.DisconnectTunnel(t.Context(), "key1"))
+}
+
+func TestHandleTCPConnection_TracksInFlight(t *testing.T) {
+	webConnMng := NewMockConnManager(t)
+	tcpConnMng := NewMockConnManager(t)
+	authRepo := NewMockAuthRepo(t)
+
+	authRepo.EXPECT().GetTokenPolicy(mock.Anything, "test-user").Return(token.Policy{}, nil)
+
+	revServer, revClient := net.Pipe()
+	defer revClient.Close()
+
+	cliServer, cliClient := net.Pipe()
+	defer cliClient.Close()
+
+	connID := uuid.New()
+
+	mockReq := conn.NewMockRequest(t)
+	mockReq.EXPECT().WaitConn(mock.Anything).Return(&yamuxStreamWrapper{Conn: revServer}, nil)
+	mockReq.EXPECT().ConnID().Return(connID)
+	mockReq.EXPECT().ParentContext().Return(context.Background())
+
+	tcpConnMng.EXPECT().RequestConnection(mock.Anything, "test-user").Return(mockReq, nil)
+
+	service := New(webConnMng, tcpConnMng, nil, authRepo)
+	defer service.tunnels.add(Tunnel{ID: connID, KeyID: "test-user", Type: token.TokenTypeTCP}, NewMockControlConn(t))()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- service.HandleTCPConnection(t.Context(), "test-user", cliServer, "127.0.0.1")
+	}()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := revClient.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	assert.Eventually(t, func() bool {
+		return service.ListTunnels(t.Context())[0].InFlight == 1
+	}, time.Second, 10*time.Millisecond)
+
+	revClient.Close()
+	cliClient.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("HandleTCPConnection did not return after connections closed")
+	}
+
+	assert.Zero(t, service.ListTunnels(t.Context())[0].InFlight)
+}
@@ -13,28 +13,46 @@ const (
 	bannerWidth = 65
 )
 
+// Tunnel describes a connected tunnel shown in the banner.
+// TokenType should be "t" for TCP or "w" (or empty) for web/HTTP tunnels.
+type Tunnel struct {
+	PublicURL string
+	LocalAddr string
+	TokenType string
+}
+
 // ShowConnected displays a colorful banner with the public URL and forwarding info.
-// tokenType should be "t" for TCP or "w" (or empty) for web/HTTP tunnels.
+// tokenType should be "t" for TCP, "u" for UDP or "w" (or empty) for web/HTTP tunnels.
 // In interactive mode, shows a colorful banner.
 // In non-interactive mode, logs connection details using structured logging.
 func (d *Display) ShowConnected(publicURL, localAddr, tokenType string) {
-	label := "Public URL"
-	logKey := "public_url"
-
-	if tokenType == "t" {
-		label = "TCP Endpoint"
-		logKey = "tcp_endpoint"
-	}
+	d.ShowTunnels([]Tunnel{{PublicURL: publicURL, LocalAddr: localAddr, TokenType: tokenType}})
+}
 
+// ShowTunnels displays a colorful banner listing the public URL and forwarding info of every tunnel.
+// In interactive mode, shows a colorful banner.
+// In non-interactive mode, logs connection details of each tunnel using structured logging.
+func (d *Display) ShowTunnels(tunnels []Tunnel) {
 	if !d.interactive {
-		// In non-interactive mode, log connection info using slog
-		if localAddr != "" {
-			slog.Info("service is now publicly accessible",
-				slog.String(logKey, publicURL),
-				slog.String("forwarding", localAddr))
-		} else {
-			slog.Info("service is now publicly accessible",
-				slog.String(logKey, publicURL))
+		for _, t := range tunnels {
+			logKey := "public_url"
+
+			switch t.TokenType {
+			case "t":
+				logKey = "tcp_endpoint"
+			case "u":
+				logKey = "udp_endpoint"
+			}
+
+			// In non-interactive mode, log connection info using slog
+			if t.LocalAddr != "" {
+				slog.Info("service is now publicly accessible",
+					slog.String(logKey, t.PublicURL),
+					slog.String("forwarding", t.LocalAddr))
+			} else {
+				slog.Info("service is now publicly accessible",
+					slog.String(logKey, t.PublicURL))
+			}
 		}
 
 		return
@@ -49,6 +67,11 @@ func (d *Display) ShowConnected(publicURL, localAddr, tokenType string) {
 	addrColor := color.New(color.FgYellow)
 	hintColor := color.New(color.FgHiBlack)
 
+	message := " Your service is now publicly accessible!"
+	if len(tunnels) > 1 {
+		message = " Your services are now publicly accessible!"
+	}
+
 	// Build the banner
 	fmt.Fprintln(d.out)
 
@@ -65,17 +88,28 @@ func (d *Display) ShowConnected(publicURL, localAddr, tokenType string) {
 	d.printBannerEmptyLine(borderColor)
 
 	// Success message
-	d.printBannerLineWithPrefix(borderColor, successColor, "[OK]", " Your service is now publicly accessible!")
+	d.printBannerLineWithPrefix(borderColor, successColor, "[OK]", message)
 
-	// Empty line
-	d.printBannerEmptyLine(borderColor)
+	for _, t := range tunnels {
+		label := "Public URL"
 
-	// Public URL / TCP Endpoint
-	d.printBannerKeyValue(borderColor, labelColor, urlColor, label, publicURL)
+		switch t.TokenType {
+		case "t":
+			label = "TCP Endpoint"
+		case "u":
+			label = "UDP Endpoint"
+		}
 
-	// Forwarding
-	if localAddr != "" {
-		d.printBannerKeyValue(borderColor, labelColor, addrColor, "Forwarding", localAddr)
+		// Empty line
+		d.printBannerEmptyLine(borderColor)
+
+		// Public URL / TCP Endpoint / UDP Endpoint
+		d.printBannerKeyValue(borderColor, labelColor, urlColor, label, t.PublicURL)
+
+		// Forwarding
+		if t.LocalAddr != "" {
+			d.printBannerKeyValue(borderColor, labelColor, addrColor, "Forwarding", t.LocalAddr)
+		}
 	}
 
 	// Empty line
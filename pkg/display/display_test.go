@@ -129,6 +129,25 @@ func TestDisplay_ShowConnected(t *testing.T) {
 		assert.Contains(t, output, "localhost:5432")
 	})
 
+	t.Run("UDP token shows UDP Endpoint label", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		disp := &Display{
+			out:         &buf,
+			errOut:      &buf,
+			interactive: true,
+			noColor:     true,
+		}
+
+		disp.ShowConnected("udp.example.com:20053", "localhost:53", "u")
+
+		output := buf.String()
+		assert.Contains(t, output, "UDP Endpoint")
+		assert.NotContains(t, output, "Public URL")
+		assert.Contains(t, output, "udp.example.com:20053")
+		assert.Contains(t, output, "localhost:53")
+	})
+
 	t.Run("web token shows Public URL label", func(t *testing.T) {
 		var buf bytes.Buffer
 
@@ -148,6 +167,31 @@ func TestDisplay_ShowConnected(t *testing.T) {
 	})
 }
 
+func TestDisplay_ShowTunnels(t *testing.T) {
+	var buf bytes.Buffer
+
+	disp := &Display{
+		out:         &buf,
+		errOut:      &buf,
+		interactive: true,
+		noColor:     true,
+	}
+
+	disp.ShowTunnels([]Tunnel{
+		{PublicURL: "https://frontend.example.com", LocalAddr: "localhost:3000", TokenType: "w"},
+		{PublicURL: "tcp.example.com:10042", LocalAddr: "localhost:5432", TokenType: "t"},
+	})
+
+	output := buf.String()
+	assert.Contains(t, output, "Your services are now publicly accessible!")
+	assert.Contains(t, output, "https://frontend.example.com")
+	assert.Contains(t, output, "localhost:3000")
+	assert.Contains(t, output, "TCP Endpoint")
+	assert.Contains(t, output, "tcp.example.com:10042")
+	assert.Contains(t, output, "localhost:5432")
+	assert.Equal(t, 2, strings.Count(output, "Forwarding"))
+}
+
 func TestDisplay_ShowError(t *testing.T) {
 	t.Run("shows error with hint", func(t *testing.T) {
 		var buf bytes.Buffer
@@ -262,6 +306,42 @@ func TestDisplay_ShowConnecting(t *testing.T) {
 	})
 }
 
+func TestDisplay_ShowReconnecting(t *testing.T) {
+	t.Run("interactive mode returns spinner", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		disp := &Display{
+			out:         &buf,
+			errOut:      &buf,
+			interactive: true,
+			noColor:     true,
+		}
+
+		spinner := disp.ShowReconnecting("example.com:8080", 3, time.Second, errors.New("connection lost"))
+		require.NotNil(t, spinner)
+
+		time.Sleep(150 * time.Millisecond)
+		spinner.Stop()
+
+		assert.Contains(t, buf.String(), "Reconnecting to example.com:8080 (attempt 3)")
+	})
+
+	t.Run("non-interactive mode returns nil", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		disp := &Display{
+			out:         &buf,
+			errOut:      &buf,
+			interactive: false,
+			noColor:     true,
+		}
+
+		spinner := disp.ShowReconnecting("example.com:8080", 1, time.Second, errors.New("connection lost"))
+		assert.Nil(t, spinner)
+		assert.Empty(t, buf.String())
+	})
+}
+
 func TestSpinner(t *testing.T) {
 	t.Run("start and stop", func(t *testing.T) {
 		var buf bytes.Buffer
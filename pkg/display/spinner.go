@@ -3,6 +3,7 @@ package display
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -176,3 +177,23 @@ func (d *Display) ShowConnecting(server string) *Spinner {
 
 	return spinner
 }
+
+// ShowReconnecting creates and starts a spinner shown while the client reconnects to the server.
+// In non-interactive mode, it logs the reconnection attempt using structured logging and returns nil.
+func (d *Display) ShowReconnecting(server string, attempt int, delay time.Duration, err error) *Spinner {
+	if !d.interactive {
+		slog.Warn("connection to server lost, reconnecting",
+			slog.String("server", server),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+
+		return nil
+	}
+
+	message := fmt.Sprintf("Reconnecting to %s (attempt %d)…", server, attempt)
+	spinner := NewSpinner(message, d.out)
+	spinner.Start()
+
+	return spinner
+}
@@ -0,0 +1,113 @@
+package domainverify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// TXTRecordPrefix is prepended to a custom domain to get the name of the DNS TXT record proving its ownership.
+	TXTRecordPrefix = "_mit-challenge."
+
+	// ChallengePath is the path of the HTTP resource proving the ownership of a custom domain.
+	ChallengePath = "/.well-known/mit-challenge"
+
+	// maxChallengeSize limits the size of the HTTP challenge response that is read.
+	maxChallengeSize = 1024
+
+	defaultTimeout = 10 * time.Second
+)
+
+var errNotProven = errors.New("ownership proof not found")
+
+// Verifier checks the ownership of custom domains. The owner of a domain proves it either with a DNS TXT record
+// named TXTRecordPrefix followed by the domain, or with an HTTP resource served on the domain at ChallengePath,
+// holding the value returned by Challenge for the token the domain is attached to.
+type Verifier struct {
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+	client    *http.Client
+}
+
+// New creates a Verifier that looks up TXT records with the default resolver and fetches HTTP challenges
+// over plain HTTP, following redirects.
+func New() *Verifier {
+	return &Verifier{
+		lookupTXT: net.DefaultResolver.LookupTXT,
+		client:    &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// Challenge returns the value proving the ownership of a custom domain for the token keyID.
+func Challenge(keyID string) string {
+	return "mit-verification=" + keyID
+}
+
+// Verify checks that domain has a TXT record or serves an HTTP challenge holding the challenge of keyID.
+// The TXT record is checked first, the HTTP challenge is only fetched when the record is missing.
+// Returns an error describing both failed checks if neither proves the ownership.
+func (v *Verifier) Verify(ctx context.Context, domain, keyID string) error {
+	want := Challenge(keyID)
+
+	dnsErr := v.verifyTXT(ctx, domain, want)
+	if dnsErr == nil {
+		return nil
+	}
+
+	httpErr := v.verifyHTTP(ctx, domain, want)
+	if httpErr == nil {
+		return nil
+	}
+
+	return fmt.Errorf("TXT record %s%s: %w, HTTP challenge http://%s%s: %w", TXTRecordPrefix, domain, dnsErr, domain, ChallengePath, httpErr)
+}
+
+// verifyTXT checks that one of the TXT records of the challenge name of domain is want.
+func (v *Verifier) verifyTXT(ctx context.Context, domain, want string) error {
+	records, err := v.lookupTXT(ctx, TXTRecordPrefix+domain)
+	if err != nil {
+		return fmt.Errorf("lookup failed: %w", err)
+	}
+
+	if !slices.Contains(records, want) {
+		return errNotProven
+	}
+
+	return nil
+}
+
+// verifyHTTP checks that the challenge resource of domain holds want, surrounding whitespace is ignored.
+func (v *Verifier) verifyHTTP(ctx context.Context, domain, want string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+domain+ChallengePath, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChallengeSize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if strings.TrimSpace(string(body)) != want {
+		return errNotProven
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package domainverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestVerifier returns a Verifier resolving TXT records from records and sending every HTTP request to handler,
+// whatever the requested host.
+func newTestVerifier(t *testing.T, records map[string][]string, handler http.HandlerFunc) *Verifier {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}
+
+	return &Verifier{
+		lookupTXT: func(_ context.Context, name string) ([]string, error) {
+			if r, ok := records[name]; ok {
+				return r, nil
+			}
+
+			return nil, errors.New("no such host")
+		},
+		client: &http.Client{Transport: transport},
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	assert.Equal(t, "mit-verification=key1", Challenge("key1"))
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	notFound := func(w http.ResponseWriter, _ *http.Request) { http.NotFound(w, nil) }
+
+	tests := []struct {
+		records map[string][]string
+		handler http.HandlerFunc
+		name    string
+		wantErr bool
+	}{
+		{
+			name:    "TXT record",
+			records: map[string][]string{"_mit-challenge.app.example.com": {"v=spf1", "mit-verification=key1"}},
+			handler: notFound,
+		},
+		{
+			name: "HTTP challenge",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "app.example.com", r.Host)
+				assert.Equal(t, ChallengePath, r.URL.Path)
+
+				_, _ = w.Write([]byte("mit-verification=key1\n"))
+			},
+		},
+		{
+			name:    "TXT record of another token",
+			records: map[string][]string{"_mit-challenge.app.example.com": {"mit-verification=key2"}},
+			handler: notFound,
+			wantErr: true,
+		},
+		{
+			name:    "HTTP challenge of another token",
+			handler: func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("mit-verification=key2")) },
+			wantErr: true,
+		},
+		{
+			name:    "no proof",
+			handler: notFound,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestVerifier(t, tt.records, tt.handler)
+
+			err := v.Verify(context.Background(), "app.example.com", "key1")
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "_mit-challenge.app.example.com")
+			assert.Contains(t, err.Error(), "http://app.example.com/.well-known/mit-challenge")
+		})
+	}
+}
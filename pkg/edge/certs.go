@@ -0,0 +1,189 @@
+package edge
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ksysoev/make-it-public/pkg/revproxy/watcher"
+)
+
+const (
+	certExt = ".crt"
+	keyExt  = ".key"
+)
+
+// certStore holds the TLS certificates served by the HTTP edge and selects them by the server name
+// requested by clients.
+type certStore struct {
+	byName   map[string]*tls.Certificate
+	fallback *tls.Certificate
+	cfg      TLSConfig
+	mu       sync.RWMutex
+}
+
+func newCertStore(cfg TLSConfig) *certStore {
+	return &certStore{cfg: cfg}
+}
+
+// load loads the default certificate and the certificates of the certificate directory, replacing the ones
+// loaded before. The certificates in use are kept if any of them fails to load.
+// Returns an error if a certificate cannot be loaded, a certificate of the directory has no key or no DNS names,
+// or two certificates of the directory are issued for the same name.
+func (s *certStore) load() error {
+	var fallback *tls.Certificate
+
+	if s.cfg.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(s.cfg.Cert, s.cfg.Key)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate %s: %w", s.cfg.Cert, err)
+		}
+
+		fallback = &cert
+	}
+
+	byName := make(map[string]*tls.Certificate)
+
+	if s.cfg.CertDir != "" {
+		entries, err := os.ReadDir(s.cfg.CertDir)
+		if err != nil {
+			return fmt.Errorf("failed to read certificate directory: %w", err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != certExt {
+				continue
+			}
+
+			if err := loadNamedCert(byName, filepath.Join(s.cfg.CertDir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	if fallback == nil && len(byName) == 0 {
+		return fmt.Errorf("no certificate found in %s", s.cfg.CertDir)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fallback = fallback
+	s.byName = byName
+
+	return nil
+}
+
+// loadNamedCert loads the certificate certPath with the key next to it and adds it to byName under its DNS names.
+func loadNamedCert(byName map[string]*tls.Certificate, certPath string) error {
+	keyPath := strings.TrimSuffix(certPath, certExt) + keyExt
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", certPath, err)
+	}
+
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse certificate %s: %w", certPath, err)
+		}
+	}
+
+	if len(cert.Leaf.DNSNames) == 0 {
+		return fmt.Errorf("certificate %s has no DNS names", certPath)
+	}
+
+	for _, name := range cert.Leaf.DNSNames {
+		name = strings.ToLower(name)
+
+		if _, ok := byName[name]; ok {
+			return fmt.Errorf("certificate %s: another certificate is issued for %s", certPath, name)
+		}
+
+		byName[name] = &cert
+	}
+
+	return nil
+}
+
+// getCertificate returns the certificate for the server name requested by the client: the certificate issued for
+// the name, or else the wildcard certificate covering it, from the certificate directory, or else the default certificate.
+// Returns an error if no certificate matches.
+func (s *certStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if cert, ok := s.byName[name]; ok {
+		return cert, nil
+	}
+
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		if cert, ok := s.byName["*."+parent]; ok {
+			return cert, nil
+		}
+	}
+
+	if s.fallback != nil {
+		return s.fallback, nil
+	}
+
+	return nil, fmt.Errorf("no certificate for %q", hello.ServerName)
+}
+
+// watch reloads the certificates whenever a file changes in the directories of the default certificate and key
+// or in the certificate directory, until ctx is done. Reload failures are logged and the certificates in use are kept.
+// Returns an error if the directories cannot be watched.
+func (s *certStore) watch(ctx context.Context) error {
+	var dirs []string
+
+	for _, path := range []string{s.cfg.Cert, s.cfg.Key} {
+		if path != "" {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+	}
+
+	if s.cfg.CertDir != "" {
+		dirs = append(dirs, s.cfg.CertDir)
+	}
+
+	slices.Sort(dirs)
+
+	w, err := watcher.NewFileWatcher(slices.Compact(dirs)...)
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher for TLS certificates: %w", err)
+	}
+
+	subscriber := w.Subscribe()
+
+	go func() {
+		defer func() { _ = w.Close() }()
+		defer w.Unsubscribe(subscriber)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-subscriber:
+				slog.DebugContext(ctx, "TLS certificate file changed", slog.String("path", notification.Path))
+
+				if err := s.load(); err != nil {
+					slog.ErrorContext(ctx, "failed to reload TLS certificates", slog.Any("error", err))
+					continue
+				}
+
+				slog.InfoContext(ctx, "TLS certificates reloaded")
+			}
+		}
+	}()
+
+	return nil
+}
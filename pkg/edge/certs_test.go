@@ -0,0 +1,166 @@
+package edge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for dnsNames to dir as name.crt with its key in name.key.
+// Returns the paths of the certificate and the key.
+func writeCert(t *testing.T, dir, name string, dnsNames ...string) (certPath, keyPath string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath = filepath.Join(dir, name+certExt)
+	keyPath = filepath.Join(dir, name+keyExt)
+
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+
+	return certPath, keyPath
+}
+
+// certNames returns the DNS names of the certificate the store serves for serverName, or an error.
+func certNames(s *certStore, serverName string) ([]string, error) {
+	cert, err := s.getCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return leaf.DNSNames, nil
+}
+
+func TestCertStore_GetCertificate(t *testing.T) {
+	defaultDir := t.TempDir()
+	certDir := t.TempDir()
+
+	certPath, keyPath := writeCert(t, defaultDir, "default", "*.example.com")
+	writeCert(t, certDir, "app", "app.customer.org", "www.customer.org")
+	writeCert(t, certDir, "wildcard", "*.customer.org")
+
+	s := newCertStore(TLSConfig{Cert: certPath, Key: keyPath, CertDir: certDir})
+	require.NoError(t, s.load())
+
+	tests := []struct {
+		name       string
+		serverName string
+		want       []string
+	}{
+		{name: "exact name", serverName: "app.customer.org", want: []string{"app.customer.org", "www.customer.org"}},
+		{name: "case and trailing dot", serverName: "WWW.Customer.org.", want: []string{"app.customer.org", "www.customer.org"}},
+		{name: "wildcard", serverName: "shop.customer.org", want: []string{"*.customer.org"}},
+		{name: "default", serverName: "demo.example.com", want: []string{"*.example.com"}},
+		{name: "no server name", want: []string{"*.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := certNames(s, tt.serverName)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestCertStore_GetCertificate_NoDefault(t *testing.T) {
+	certDir := t.TempDir()
+	writeCert(t, certDir, "app", "app.customer.org")
+
+	s := newCertStore(TLSConfig{CertDir: certDir})
+	require.NoError(t, s.load())
+
+	_, err := certNames(s, "other.customer.org")
+	assert.ErrorContains(t, err, "no certificate")
+}
+
+func TestCertStore_Load_Errors(t *testing.T) {
+	t.Run("missing key", func(t *testing.T) {
+		certDir := t.TempDir()
+		_, keyPath := writeCert(t, certDir, "app", "app.customer.org")
+		require.NoError(t, os.Remove(keyPath))
+
+		assert.ErrorContains(t, newCertStore(TLSConfig{CertDir: certDir}).load(), "failed to load certificate")
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		certDir := t.TempDir()
+		writeCert(t, certDir, "a", "app.customer.org")
+		writeCert(t, certDir, "b", "app.customer.org")
+
+		assert.ErrorContains(t, newCertStore(TLSConfig{CertDir: certDir}).load(), "another certificate")
+	})
+
+	t.Run("no dns names", func(t *testing.T) {
+		certDir := t.TempDir()
+		writeCert(t, certDir, "app")
+
+		assert.ErrorContains(t, newCertStore(TLSConfig{CertDir: certDir}).load(), "no DNS names")
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		assert.ErrorContains(t, newCertStore(TLSConfig{CertDir: t.TempDir()}).load(), "no certificate found")
+	})
+}
+
+func TestCertStore_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	certDir := t.TempDir()
+	writeCert(t, certDir, "app", "app.customer.org")
+
+	s := newCertStore(TLSConfig{CertDir: certDir})
+	require.NoError(t, s.load())
+	require.NoError(t, s.watch(ctx))
+
+	writeCert(t, certDir, "shop", "shop.customer.org")
+
+	assert.Eventually(t, func() bool {
+		names, err := certNames(s, "shop.customer.org")
+		return err == nil && len(names) == 1 && names[0] == "shop.customer.org"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// A broken certificate is not loaded, the certificates in use are kept.
+	require.NoError(t, os.WriteFile(filepath.Join(certDir, "broken"+certExt), []byte("broken"), 0o600))
+
+	time.Sleep(100 * time.Millisecond)
+
+	names, err := certNames(s, "app.customer.org")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app.customer.org"}, names)
+}
@@ -8,6 +8,7 @@ import (
 	context "context"
 	net "net"
 
+	token "github.com/ksysoev/make-it-public/pkg/core/token"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -24,6 +25,63 @@ func (_m *MockConnService) EXPECT() *MockConnService_Expecter {
 	return &MockConnService_Expecter{mock: &_m.Mock}
 }
 
+// GetPolicy provides a mock function with given fields: ctx, keyID
+func (_m *MockConnService) GetPolicy(ctx context.Context, keyID string) (token.Policy, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPolicy")
+	}
+
+	var r0 token.Policy
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (token.Policy, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) token.Policy); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		r0 = ret.Get(0).(token.Policy)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockConnService_GetPolicy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetPolicy'
+type MockConnService_GetPolicy_Call struct {
+	*mock.Call
+}
+
+// GetPolicy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockConnService_Expecter) GetPolicy(ctx interface{}, keyID interface{}) *MockConnService_GetPolicy_Call {
+	return &MockConnService_GetPolicy_Call{Call: _e.mock.On("GetPolicy", ctx, keyID)}
+}
+
+func (_c *MockConnService_GetPolicy_Call) Run(run func(ctx context.Context, keyID string)) *MockConnService_GetPolicy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_GetPolicy_Call) Return(_a0 token.Policy, _a1 error) *MockConnService_GetPolicy_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnService_GetPolicy_Call) RunAndReturn(run func(context.Context, string) (token.Policy, error)) *MockConnService_GetPolicy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // HandleHTTPConnection provides a mock function with given fields: ctx, keyID, conn, write, clientIP
 func (_m *MockConnService) HandleHTTPConnection(ctx context.Context, keyID string, conn net.Conn, write func(net.Conn) error, clientIP string) error {
 	ret := _m.Called(ctx, keyID, conn, write, clientIP)
@@ -74,6 +132,120 @@ func (_c *MockConnService_HandleHTTPConnection_Call) RunAndReturn(run func(conte
 	return _c
 }
 
+// ResolveDomain provides a mock function with given fields: ctx, host
+func (_m *MockConnService) ResolveDomain(ctx context.Context, host string) (string, error) {
+	ret := _m.Called(ctx, host)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResolveDomain")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, host)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, host)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, host)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockConnService_ResolveDomain_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResolveDomain'
+type MockConnService_ResolveDomain_Call struct {
+	*mock.Call
+}
+
+// ResolveDomain is a helper method to define mock.On call
+//   - ctx context.Context
+//   - host string
+func (_e *MockConnService_Expecter) ResolveDomain(ctx interface{}, host interface{}) *MockConnService_ResolveDomain_Call {
+	return &MockConnService_ResolveDomain_Call{Call: _e.mock.On("ResolveDomain", ctx, host)}
+}
+
+func (_c *MockConnService_ResolveDomain_Call) Run(run func(ctx context.Context, host string)) *MockConnService_ResolveDomain_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_ResolveDomain_Call) Return(_a0 string, _a1 error) *MockConnService_ResolveDomain_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnService_ResolveDomain_Call) RunAndReturn(run func(context.Context, string) (string, error)) *MockConnService_ResolveDomain_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResolveKeyID provides a mock function with given fields: ctx, label
+func (_m *MockConnService) ResolveKeyID(ctx context.Context, label string) (string, error) {
+	ret := _m.Called(ctx, label)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResolveKeyID")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, label)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, label)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, label)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockConnService_ResolveKeyID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResolveKeyID'
+type MockConnService_ResolveKeyID_Call struct {
+	*mock.Call
+}
+
+// ResolveKeyID is a helper method to define mock.On call
+//   - ctx context.Context
+//   - label string
+func (_e *MockConnService_Expecter) ResolveKeyID(ctx interface{}, label interface{}) *MockConnService_ResolveKeyID_Call {
+	return &MockConnService_ResolveKeyID_Call{Call: _e.mock.On("ResolveKeyID", ctx, label)}
+}
+
+func (_c *MockConnService_ResolveKeyID_Call) Run(run func(ctx context.Context, label string)) *MockConnService_ResolveKeyID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_ResolveKeyID_Call) Return(_a0 string, _a1 error) *MockConnService_ResolveKeyID_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnService_ResolveKeyID_Call) RunAndReturn(run func(context.Context, string) (string, error)) *MockConnService_ResolveKeyID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetEndpointGenerator provides a mock function with given fields: generator
 func (_m *MockConnService) SetEndpointGenerator(generator func(string) (string, error)) {
 	_m.Called(generator)
@@ -85,7 +257,7 @@ type MockConnService_SetEndpointGenerator_Call struct {
 }
 
 // SetEndpointGenerator is a helper method to define mock.On call
-//   - generator func(string)(string , error)
+//   - generator func(string) (string, error)
 func (_e *MockConnService_Expecter) SetEndpointGenerator(generator interface{}) *MockConnService_SetEndpointGenerator_Call {
 	return &MockConnService_SetEndpointGenerator_Call{Call: _e.mock.On("SetEndpointGenerator", generator)}
 }
@@ -3,16 +3,19 @@ package edge
 import (
 	"cmp"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
 	"github.com/ksysoev/make-it-public/pkg/core/url"
 	"github.com/ksysoev/make-it-public/pkg/edge/middleware"
 )
@@ -20,20 +23,61 @@ import (
 type ConnService interface {
 	HandleHTTPConnection(ctx context.Context, keyID string, conn net.Conn, write func(net.Conn) error, clientIP string) error
 	SetEndpointGenerator(generator func(string) (string, error))
+	ResolveKeyID(ctx context.Context, label string) (string, error)
+	ResolveDomain(ctx context.Context, host string) (string, error)
+	GetPolicy(ctx context.Context, keyID string) (token.Policy, error)
 }
 
 type HTTPServer struct {
-	connService ConnService
-	config      Config
+	connService    ConnService
+	certs          *certStore
+	trustedProxies []netip.Prefix
+	config         Config
+	rateLimit      token.RateLimit
 }
 
-const defaultConnLimitPerKeyID = 4
+const (
+	defaultConnLimitPerKeyID = 4
+	shutdownTimeout          = 5 * time.Second
+)
 
+// Config holds the settings of the HTTP edge.
+// TrustedProxies lists the IP addresses or CIDR prefixes of the reverse proxies in front of the edge whose
+// forwarding headers, such as X-Forwarded-For, identify the end user. Requests from other addresses are attributed
+// to the peer of the connection, or to the address reported with the PROXY protocol when ProxyProto is enabled.
 type Config struct {
-	Listen     string               `mapstructure:"listen"`
-	Public     PublicEndpointConfig `mapstructure:"public"`
-	ConnLimit  int                  `mapstructure:"conn_limit"`
-	ProxyProto bool                 `mapstructure:"proxy_proto"`
+	TLS            TLSConfig            `mapstructure:"tls"`
+	Listen         string               `mapstructure:"listen"`
+	Public         PublicEndpointConfig `mapstructure:"public"`
+	TrustedProxies []string             `mapstructure:"trusted_proxies"`
+	RateLimit      RateLimitConfig      `mapstructure:"rate_limit"`
+	ConnLimit      int                  `mapstructure:"conn_limit"`
+	ProxyProto     bool                 `mapstructure:"proxy_proto"`
+}
+
+// RateLimitConfig holds the default request rate limits of web tunnels, tokens may override them.
+// Rates are in requests per second, TunnelRate applies to all the requests of a tunnel and ClientRate to the
+// requests each end-user IP address sends to a tunnel. Bursts default to the rates rounded up, a zero rate
+// does not limit requests.
+type RateLimitConfig struct {
+	TunnelRate  float64 `mapstructure:"tunnel_rate"`
+	ClientRate  float64 `mapstructure:"client_rate"`
+	TunnelBurst int     `mapstructure:"tunnel_burst"`
+	ClientBurst int     `mapstructure:"client_burst"`
+}
+
+// TLSConfig enables TLS termination on the HTTP edge when Cert or CertDir is set.
+// Cert and Key are the default certificate, usually a wildcard certificate for the public domain. CertDir holds
+// certificates for specific hostnames as <name>.crt files with their keys in <name>.key files, selected by the
+// server name clients request. Certificates are reloaded when their files change.
+type TLSConfig struct {
+	Cert    string `mapstructure:"cert"`
+	Key     string `mapstructure:"key"`
+	CertDir string `mapstructure:"cert_dir"`
+}
+
+func (c TLSConfig) enabled() bool {
+	return c.Cert != "" || c.CertDir != ""
 }
 
 type PublicEndpointConfig struct {
@@ -46,35 +90,97 @@ type PublicEndpointConfig struct {
 // It validates the configuration by creating a URL endpoint generator and applies it to the connection service.
 // Accepts cfg, a configuration struct defining server and public endpoint parameters, and connService,
 // an interface to manage HTTP connections.
+// When TLS is enabled the public schema defaults to https.
 // Returns a pointer to an HTTPServer if successful or an error if the configuration or endpoint generator fails.
 func New(cfg Config, connService ConnService) (*HTTPServer, error) {
-	generator, err := url.NewEndpointGenerator(cfg.Public.Schema, cfg.Public.Domain, cfg.Public.Port)
+	if (cfg.TLS.Cert != "" && cfg.TLS.Key == "") || (cfg.TLS.Cert == "" && cfg.TLS.Key != "") {
+		return nil, fmt.Errorf("both cert and key are required for TLS")
+	}
+
+	schema := cfg.Public.Schema
+	if schema == "" && cfg.TLS.enabled() {
+		schema = "https"
+	}
+
+	generator, err := url.NewEndpointGenerator(schema, cfg.Public.Domain, cfg.Public.Port)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create endpoint generator: %w", err)
 	}
 
+	rateLimit, err := token.NewRateLimit(token.RateLimit(cfg.RateLimit))
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit: %w", err)
+	}
+
+	trustedProxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	connService.SetEndpointGenerator(generator)
 
-	return &HTTPServer{
-		config:      cfg,
-		connService: connService,
-	}, nil
+	srv := &HTTPServer{
+		config:         cfg,
+		connService:    connService,
+		trustedProxies: trustedProxies,
+	}
+
+	if rateLimit != nil {
+		srv.rateLimit = *rateLimit
+	}
+
+	if cfg.TLS.enabled() {
+		srv.certs = newCertStore(cfg.TLS)
+	}
+
+	return srv, nil
 }
 
-// Run starts the HTTP server and manages its lifecycle using the provided context.
-// It composes middleware, sets up a TCP listener, and creates an HTTP server instance.
-// Accepts ctx to control the server's lifecycle and handle graceful shutdowns.
-// Returns an error if the server fails to start, listen, or encounters unexpected termination issues.
-func (s *HTTPServer) Run(ctx context.Context) error {
-	mw := make([]func(next http.Handler) http.Handler, 0, 6)
+// parseTrustedProxies parses the IP addresses or CIDR prefixes of trusted proxies.
+// Returns an error if an entry is neither.
+func parseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// handler wraps the server with its middleware chain, clientIP identifies the end user of each request.
+// The credentials of protected tunnels are checked last, so that requests from rejected or rate limited
+// end users never reach the costly key derivation of the check.
+func (s *HTTPServer) handler(clientIP func(next http.Handler) http.Handler) http.Handler {
+	mw := make([]func(next http.Handler) http.Handler, 0, 12)
 
 	mw = append(mw,
-		middleware.NewFishingProtection(),
-		middleware.ParseKeyID(s.config.Public.Domain),
 		middleware.Metrics(),
+		middleware.NewFishingProtection(),
+		middleware.ParseKeyID(s.config.Public.Domain, s.connService.ResolveKeyID, s.connService.ResolveDomain),
+		middleware.LoadPolicy(s.connService.GetPolicy),
 		middleware.LimitConnections(cmp.Or(s.config.ConnLimit, defaultConnLimitPerKeyID)),
-		middleware.ClientIP(),
+		clientIP,
+		middleware.FilterIPs(),
+		middleware.LimitRate(s.rateLimit),
+		middleware.CheckAccess(),
 		middleware.ReqID(),
+		middleware.ForwardedProto(),
+		middleware.RewriteHeaders(),
 	)
 
 	var handler http.Handler = s
@@ -83,20 +189,57 @@ func (s *HTTPServer) Run(ctx context.Context) error {
 		handler = mw[i](handler)
 	}
 
+	return handler
+}
+
+// Run starts the HTTP server and manages its lifecycle using the provided context.
+// It composes middleware, sets up a TCP listener, and creates an HTTP server instance.
+// With TLS enabled the certificates are loaded and watched for changes, and connections are served over TLS;
+// the client IP is then taken from the connection only, as there is no proxy in front to set forwarding headers.
+// Accepts ctx to control the server's lifecycle; once it is done the server stops accepting new connections
+// while the connections already proxied through the tunnels are left running.
+// Returns an error if the server fails to start, listen, or encounters unexpected termination issues.
+func (s *HTTPServer) Run(ctx context.Context) error {
+	clientIP := middleware.ClientIP(s.trustedProxies)
+
+	if s.certs != nil {
+		if err := s.certs.load(); err != nil {
+			return fmt.Errorf("failed to load TLS certificates: %w", err)
+		}
+
+		if err := s.certs.watch(ctx); err != nil {
+			return err
+		}
+
+		clientIP = middleware.DirectClientIP()
+	}
+
+	handler := s.handler(clientIP)
+
 	ln, err := listen(s.config.Listen, s.config.ProxyProto)
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", s.config.Listen, err)
 	}
 
+	if s.certs != nil {
+		ln = tls.NewListener(ln, &tls.Config{
+			GetCertificate: s.certs.getCertificate,
+			MinVersion:     tls.VersionTLS12,
+			// Connections are hijacked to be proxied through the tunnels, which HTTP/2 does not support.
+			NextProtos: []string{"http/1.1"},
+		})
+	}
+
 	server := &http.Server{
 		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug),
 	}
 
 	go func() {
 		<-ctx.Done()
 
-		_ = server.Close()
+		shutdown(server)
 	}()
 
 	if err := server.Serve(ln); err != http.ErrServerClosed {
@@ -106,6 +249,18 @@ func (s *HTTPServer) Run(ctx context.Context) error {
 	return nil
 }
 
+// shutdown stops the server from accepting new connections and waits up to shutdownTimeout for requests
+// that have not been handed to the tunnel yet. Hijacked connections are not affected, they are owned by the
+// connection service and drained by it. Connections still open after the timeout are closed.
+func shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		_ = server.Close()
+	}
+}
+
 // ServeHTTP handles incoming HTTP requests by processing the request context and managing hijacked connections.
 // It uses a hijacker to take control of the underlying connection for advanced protocol handling.
 // Returns appropriate HTTP error responses for unsupported hijacking, connection issues, or context errors.
@@ -149,6 +304,8 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		sendResponse(r, clientConn, http.StatusBadGateway, htmlErrorTemplate502)
 	case errors.Is(err, core.ErrKeyIDNotFound):
 		sendResponse(r, clientConn, http.StatusNotFound, htmlErrorTemplate404)
+	case errors.Is(err, core.ErrQuotaExceeded):
+		sendResponse(r, clientConn, http.StatusTooManyRequests, htmlErrorTemplate429)
 	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
 		slog.DebugContext(ctx, "connection timed out", slog.String("host", r.Host))
 	case err != nil:
@@ -164,6 +321,8 @@ func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // body contains the response body content as a string.
 // Returns nothing but logs an error if writing the response fails.
 func sendResponse(r *http.Request, conn net.Conn, status int, body string) {
+	middleware.ObserveStatus(r, status)
+
 	resp := http.Response{
 		StatusCode:    status,
 		Proto:         r.Proto,
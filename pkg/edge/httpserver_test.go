@@ -4,17 +4,21 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/make-it-public/pkg/edge/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -62,6 +66,58 @@ func TestNew(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "invalid rate limit",
+			config: Config{
+				Listen: ":8080",
+				Public: PublicEndpointConfig{
+					Schema: "http",
+					Domain: "example.com",
+					Port:   80,
+				},
+				RateLimit: RateLimitConfig{TunnelRate: -1},
+			},
+			expectError: true,
+		},
+		{
+			name: "trusted proxies",
+			config: Config{
+				Listen: ":8080",
+				Public: PublicEndpointConfig{
+					Schema: "http",
+					Domain: "example.com",
+					Port:   80,
+				},
+				TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"},
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid trusted proxy",
+			config: Config{
+				Listen: ":8080",
+				Public: PublicEndpointConfig{
+					Schema: "http",
+					Domain: "example.com",
+					Port:   80,
+				},
+				TrustedProxies: []string{"not-an-ip"},
+			},
+			expectError: true,
+		},
+		{
+			name: "tls cert without key",
+			config: Config{
+				Listen: ":8080",
+				Public: PublicEndpointConfig{
+					Schema: "https",
+					Domain: "example.com",
+					Port:   443,
+				},
+				TLS: TLSConfig{Cert: "/path/to/cert.crt"},
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -135,6 +191,127 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestHTTPServer_Handler_FiltersIPsBeforeAccess(t *testing.T) {
+	access, err := token.NewAccess("admin", "secret", "", "")
+	require.NoError(t, err)
+
+	filter, err := token.NewIPFilter(nil, []string{"203.0.113.0/24"})
+	require.NoError(t, err)
+
+	mockConnService := NewMockConnService(t)
+	mockConnService.EXPECT().ResolveKeyID(mock.Anything, "key1").Return("key1", nil)
+	mockConnService.EXPECT().GetPolicy(mock.Anything, "key1").Return(token.Policy{Access: access, IPFilter: filter}, nil)
+
+	server := &HTTPServer{
+		connService: mockConnService,
+		config:      Config{Public: PublicEndpointConfig{Domain: "example.com"}},
+	}
+
+	// The end user is rejected by the IP filter before its credentials are checked.
+	req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.SetBasicAuth("admin", "wrong")
+
+	rec := httptest.NewRecorder()
+	server.handler(middleware.DirectClientIP()).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies([]string{"10.0.0.1/8", " 192.0.2.1 ", "::ffff:198.51.100.1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("198.51.100.1/32"),
+	}, prefixes)
+}
+
+func TestNew_TLSDefaultSchema(t *testing.T) {
+	var generator func(string) (string, error)
+
+	mockConnService := NewMockConnService(t)
+	mockConnService.EXPECT().SetEndpointGenerator(mock.Anything).Run(func(g func(string) (string, error)) {
+		generator = g
+	}).Return()
+
+	_, err := New(Config{Public: PublicEndpointConfig{Domain: "example.com"}, TLS: TLSConfig{CertDir: "/certs"}}, mockConnService)
+	require.NoError(t, err)
+
+	endpoint, err := generator("demo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://demo.example.com", endpoint)
+}
+
+func TestRun_TLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	certPath, keyPath := writeCert(t, t.TempDir(), "default", "*.example.com")
+
+	// Reserve a free port for the server.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	mockConnService := NewMockConnService(t)
+	mockConnService.On("SetEndpointGenerator", mock.AnythingOfType("func(string) (string, error)")).Return()
+
+	server, err := New(Config{
+		Listen: addr,
+		Public: PublicEndpointConfig{Domain: "example.com"},
+		TLS:    TLSConfig{Cert: certPath, Key: keyPath},
+	}, mockConnService)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- server.Run(ctx)
+	}()
+
+	var conn *tls.Conn
+
+	require.Eventually(t, func() bool {
+		//nolint:gosec // the test certificate is self-signed
+		conn, err = tls.Dial("tcp", addr, &tls.Config{ServerName: "demo.example.com", NextProtos: []string{"h2", "http/1.1"}, InsecureSkipVerify: true})
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	state := conn.ConnectionState()
+	assert.Equal(t, "http/1.1", state.NegotiatedProtocol)
+	assert.Equal(t, []string{"*.example.com"}, state.PeerCertificates[0].DNSNames)
+	require.NoError(t, conn.Close())
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Server did not stop within timeout")
+	}
+}
+
+func TestRun_TLSLoadError(t *testing.T) {
+	mockConnService := NewMockConnService(t)
+	mockConnService.On("SetEndpointGenerator", mock.AnythingOfType("func(string) (string, error)")).Return()
+
+	server, err := New(Config{
+		Listen: "127.0.0.1:0",
+		Public: PublicEndpointConfig{Domain: "example.com"},
+		TLS:    TLSConfig{CertDir: t.TempDir()},
+	}, mockConnService)
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, server.Run(context.Background()), "failed to load TLS certificates")
+}
+
 func TestServeHTTP(t *testing.T) {
 	tests := []struct {
 		handleConnErr  error
@@ -164,6 +341,13 @@ func TestServeHTTP(t *testing.T) {
 			handleConnErr:  core.ErrKeyIDNotFound,
 			expectedStatus: http.StatusNotFound,
 		},
+		{
+			name:           "traffic quota exceeded",
+			keyID:          "test-key",
+			clientIP:       "192.168.1.1",
+			handleConnErr:  core.ErrQuotaExceeded,
+			expectedStatus: http.StatusTooManyRequests,
+		},
 		{
 			name:           "context canceled",
 			keyID:          "test-key",
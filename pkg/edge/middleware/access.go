@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+const (
+	accessRealm  = `Basic realm="make-it-public", charset="UTF-8"`
+	bearerPrefix = "bearer "
+
+	// accessFailureRate and accessFailureBurst limit the failed credential checks of each end-user IP address
+	// to each tunnel: a burst of 10 failures, then one every 10 seconds.
+	accessFailureRate  = 0.1
+	accessFailureBurst = 10
+)
+
+// CheckAccess enforces the access restriction of the tunnel the request is addressed to.
+// Tunnels protected with basic auth ask for credentials with a 401 response, tunnels protected with a key
+// answer 401 unless the key is sent in the configured header, as a bearer token in the Authorization header.
+// The header carrying the credentials is removed from allowed requests, so they never reach the exposed service.
+// Credentials are hashed with a costly key derivation, so each end-user IP address may only fail the check a few
+// times per tunnel before its requests receive a 429 response without being checked.
+// It must run after LoadPolicy and ClientIP. Returns a middleware handler function.
+func CheckAccess() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		failures := newBuckets(limitRateNow)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access := GetPolicy(r).Access
+			if access == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// A failure is taken up front and given back if the credentials are valid.
+			failureKey := GetKeyID(r) + " " + GetClientIP(r)
+
+			ok, retryAfter := failures.take(failureKey, accessFailureRate, accessFailureBurst)
+			if !ok {
+				tooManyRequests(w, retryAfter)
+				return
+			}
+
+			if !isAllowed(r, access) {
+				if access.IsBasic() {
+					w.Header().Set("WWW-Authenticate", accessRealm)
+				}
+
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+				return
+			}
+
+			failures.refund(failureKey, accessFailureRate)
+			r.Header.Del(credentialHeader(access))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAllowed reports whether the request carries the credentials required by access.
+func isAllowed(r *http.Request, access *token.Access) bool {
+	if access.IsBasic() {
+		username, password, ok := r.BasicAuth()
+
+		return ok && access.CheckBasic(username, password)
+	}
+
+	key := r.Header.Get(access.Header)
+
+	if access.Header == token.DefaultAccessHeader {
+		if len(key) < len(bearerPrefix) || !strings.EqualFold(key[:len(bearerPrefix)], bearerPrefix) {
+			return false
+		}
+
+		key = key[len(bearerPrefix):]
+	}
+
+	return key != "" && access.CheckKey(key)
+}
+
+// credentialHeader returns the header carrying the credentials required by access.
+func credentialHeader(access *token.Access) string {
+	if access.IsBasic() {
+		return "Authorization"
+	}
+
+	return access.Header
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAccess(t *testing.T) {
+	basic, err := token.NewAccess("admin", "secret", "", "")
+	require.NoError(t, err)
+
+	bearer, err := token.NewAccess("", "", "", "secret")
+	require.NoError(t, err)
+
+	header, err := token.NewAccess("", "", "X-Api-Key", "secret")
+	require.NoError(t, err)
+
+	tests := []struct {
+		access      *token.Access
+		setup       func(r *http.Request)
+		name        string
+		wantAuthHdr string
+		wantStatus  int
+	}{
+		{name: "public tunnel", wantStatus: http.StatusOK},
+		{
+			name:       "valid basic auth",
+			access:     basic,
+			setup:      func(r *http.Request) { r.SetBasicAuth("admin", "secret") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "invalid basic auth",
+			access:      basic,
+			setup:       func(r *http.Request) { r.SetBasicAuth("admin", "wrong") },
+			wantStatus:  http.StatusUnauthorized,
+			wantAuthHdr: accessRealm,
+		},
+		{name: "missing basic auth", access: basic, wantStatus: http.StatusUnauthorized, wantAuthHdr: accessRealm},
+		{
+			name:       "valid bearer key",
+			access:     bearer,
+			setup:      func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "key without bearer prefix",
+			access:     bearer,
+			setup:      func(r *http.Request) { r.Header.Set("Authorization", "secret") },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "valid header key",
+			access:     header,
+			setup:      func(r *http.Request) { r.Header.Set("X-Api-Key", "secret") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid header key",
+			access:     header,
+			setup:      func(r *http.Request) { r.Header.Set("X-Api-Key", "wrong") },
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CheckAccess()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The credentials are not forwarded to the exposed service.
+				assert.Empty(t, r.Header.Get("Authorization"))
+				assert.Empty(t, r.Header.Get("X-Api-Key"))
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+			req = req.WithContext(context.WithValue(req.Context(), policyKeyType{}, token.Policy{Access: tt.access}))
+
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantAuthHdr, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestCheckAccess_LimitsFailures(t *testing.T) {
+	basic, err := token.NewAccess("admin", "secret", "", "")
+	require.NoError(t, err)
+
+	handler := CheckAccess()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(clientIP, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+		ctx := context.WithValue(req.Context(), policyKeyType{}, token.Policy{Access: basic})
+		ctx = context.WithValue(ctx, clientIPKeyType{}, clientIP)
+		req = req.WithContext(ctx)
+		req.SetBasicAuth("admin", password)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	for range accessFailureBurst {
+		assert.Equal(t, http.StatusUnauthorized, send("10.0.0.1", "wrong").Code)
+	}
+
+	// Further attempts are rejected without checking the credentials, even valid ones.
+	rec := send("10.0.0.1", "secret")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Other end users are not affected, and valid credentials do not use up the limit.
+	for range accessFailureBurst + 1 {
+		assert.Equal(t, http.StatusOK, send("10.0.0.2", "secret").Code)
+	}
+}
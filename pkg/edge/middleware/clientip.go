@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 )
 
@@ -11,13 +12,18 @@ import (
 type clientIPKeyType struct{}
 
 // ClientIP is a middleware that identifies the client IP address from an HTTP request
-// and stores it in the request context. It checks various headers including forwarded headers,
-// Cloudflare, and CloudFront headers. If no headers are present, it falls back to the remote IP.
+// and stores it in the request context. Forwarding headers, including the Cloudflare and CloudFront ones, are only
+// trusted on requests received from one of trustedProxies, as any client may set them; other requests are attributed
+// to the peer of the connection, which is the end user or, with the PROXY protocol, the address it reports.
 // Returns a middleware function that adds the client IP to the request context.
-func ClientIP() func(next http.Handler) http.Handler {
+func ClientIP(trustedProxies []netip.Prefix) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			clientIP := extractClientIP(r)
+			clientIP := remoteIP(r)
+
+			if isTrusted(trustedProxies, clientIP) {
+				clientIP = extractClientIP(r, trustedProxies)
+			}
 
 			ctx := r.Context()
 			ctx = context.WithValue(ctx, clientIPKeyType{}, clientIP)
@@ -28,6 +34,20 @@ func ClientIP() func(next http.Handler) http.Handler {
 	}
 }
 
+// DirectClientIP is a middleware that stores the IP address of the peer of the connection in the request context,
+// ignoring forwarding headers. It is used when clients connect to the server directly, as they may then set
+// forwarding headers to any address.
+// Returns a middleware function that adds the client IP to the request context.
+func DirectClientIP() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPKeyType{}, remoteIP(r))
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // GetClientIP retrieves the client IP address from the request context.
 // Returns the client IP as a string, or an empty string if not found.
 func GetClientIP(r *http.Request) string {
@@ -38,7 +58,7 @@ func GetClientIP(r *http.Request) string {
 	return ""
 }
 
-// extractClientIP extracts the client IP address from various headers in the request.
+// extractClientIP extracts the client IP address from various headers of a request received from a trusted proxy.
 // It checks headers in the following order:
 // 1. CF-Connecting-IP (Cloudflare)
 // 2. X-Forwarded-For
@@ -47,8 +67,9 @@ func GetClientIP(r *http.Request) string {
 // 5. X-Cluster-Client-IP
 // 6. True-Client-IP
 // 7. X-CloudFront-Forwarded-For (AWS CloudFront)
+// Lists of addresses are read from the right, skipping the trusted proxies that appended to them.
 // If no headers are present, it falls back to the remote IP from the request.
-func extractClientIP(r *http.Request) string {
+func extractClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
 	// Check Cloudflare header
 	if ip := r.Header.Get("CF-Connecting-IP"); ip != "" {
 		return ip
@@ -56,11 +77,7 @@ func extractClientIP(r *http.Request) string {
 
 	// Check X-Forwarded-For header
 	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(forwardedFor, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
+		return lastUntrusted(forwardedFor, trustedProxies)
 	}
 
 	// Check other common headers
@@ -79,13 +96,15 @@ func extractClientIP(r *http.Request) string {
 
 	// Check CloudFront header
 	if cloudFrontIP := r.Header.Get("X-CloudFront-Forwarded-For"); cloudFrontIP != "" {
-		ips := strings.Split(cloudFrontIP, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
+		return lastUntrusted(cloudFrontIP, trustedProxies)
 	}
 
 	// Fall back to remote address
+	return remoteIP(r)
+}
+
+// remoteIP returns the IP address of the peer of the connection r was received on.
+func remoteIP(r *http.Request) string {
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		// If there's an error splitting the address, just return the RemoteAddr as is
@@ -94,3 +113,35 @@ func extractClientIP(r *http.Request) string {
 
 	return ip
 }
+
+// lastUntrusted returns the rightmost address of the comma separated list that is not one of trustedProxies,
+// which is the address the first trusted proxy received the request from, or the leftmost address if all are trusted.
+func lastUntrusted(list string, trustedProxies []netip.Prefix) string {
+	ips := strings.Split(list, ",")
+
+	for i := len(ips) - 1; i > 0; i-- {
+		if ip := strings.TrimSpace(ips[i]); !isTrusted(trustedProxies, ip) {
+			return ip
+		}
+	}
+
+	return strings.TrimSpace(ips[0])
+}
+
+// isTrusted reports whether ip is one of trustedProxies.
+func isTrusted(trustedProxies []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,9 +3,14 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 )
 
+// testTrustedProxies are the proxies trusted in the tests, requests from other addresses have their forwarding
+// headers ignored.
+var testTrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.0.0/16")}
+
 func TestClientIP(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -62,6 +67,25 @@ func TestClientIP(t *testing.T) {
 			},
 			expectedIP: "203.0.113.1", // CF-Connecting-IP should have highest priority
 		},
+		{
+			name:       "Untrusted peer with forwarding headers",
+			remoteAddr: "198.51.100.7:1234",
+			headers: map[string]string{
+				"CF-Connecting-IP": "10.0.0.2",
+				"X-Forwarded-For":  "10.0.0.3",
+				"X-Real-IP":        "10.0.0.4",
+				"True-Client-IP":   "10.0.0.5",
+			},
+			expectedIP: "198.51.100.7", // headers set by the end user itself must be ignored
+		},
+		{
+			name:       "X-Forwarded-For with a spoofed leftmost address",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"X-Forwarded-For": "10.0.0.2, 203.0.113.9",
+			},
+			expectedIP: "203.0.113.9", // the address appended by the trusted proxy
+		},
 		{
 			name:       "Invalid remote address",
 			remoteAddr: "invalid-address",
@@ -82,7 +106,7 @@ func TestClientIP(t *testing.T) {
 			})
 
 			// Apply the middleware
-			middleware := ClientIP()
+			middleware := ClientIP(testTrustedProxies)
 			handler := middleware(testHandler)
 
 			// Create a test request
@@ -173,7 +197,7 @@ func TestExtractClientIP(t *testing.T) {
 			}
 
 			// Call the function directly
-			ip := extractClientIP(req)
+			ip := extractClientIP(req, testTrustedProxies)
 
 			// Check the result
 			if ip != tt.expectedIP {
@@ -182,3 +206,22 @@ func TestExtractClientIP(t *testing.T) {
 		})
 	}
 }
+
+func TestDirectClientIP(t *testing.T) {
+	var got string
+
+	handler := DirectClientIP()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = GetClientIP(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.RemoteAddr = "198.51.100.7:4321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.2")
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "198.51.100.7" {
+		t.Errorf("GetClientIP() = %v, want %v", got, "198.51.100.7")
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import "net/http"
+
+// ForwardedProto sets the X-Forwarded-Proto header of requests received over TLS to https, so that the services
+// behind the tunnels know the scheme end users connected with when TLS is terminated by the server.
+// Requests received without TLS keep the header set by the proxy in front of the server.
+// Returns a middleware handler function.
+func ForwardedProto() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				r.Header.Set("X-Forwarded-Proto", "https")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
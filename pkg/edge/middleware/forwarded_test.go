@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardedProto(t *testing.T) {
+	tests := []struct {
+		tls       *tls.ConnectionState
+		name      string
+		forwarded string
+		want      string
+	}{
+		{name: "tls", tls: &tls.ConnectionState{}, want: "https"},
+		{name: "tls overrides header", tls: &tls.ConnectionState{}, forwarded: "http", want: "https"},
+		{name: "plain keeps header", forwarded: "https", want: "https"},
+		{name: "plain without header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ForwardedProto()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.want, r.Header.Get("X-Forwarded-Proto"))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.TLS = tt.tls
+
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwarded)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// FilterIPs rejects requests from client IP addresses the tunnel the request is addressed to does not accept
+// with a 403 response. It must run after LoadPolicy and ClientIP. Returns a middleware handler function.
+func FilterIPs() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keyID := GetKeyID(r)
+			filter := GetPolicy(r).IPFilter
+
+			if clientIP := GetClientIP(r); filter != nil && !filter.Allows(clientIP) {
+				slog.DebugContext(r.Context(), "client IP rejected", slog.String("key_id", keyID), slog.String("client_ip", clientIP))
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
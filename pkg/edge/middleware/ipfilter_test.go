@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterIPs(t *testing.T) {
+	filter, err := token.NewIPFilter([]string{"10.0.0.0/8"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		filter     *token.IPFilter
+		name       string
+		clientIP   string
+		wantStatus int
+	}{
+		{name: "no filter", clientIP: "192.168.1.1", wantStatus: http.StatusOK},
+		{name: "allowed", filter: filter, clientIP: "10.0.0.1", wantStatus: http.StatusOK},
+		{name: "rejected", filter: filter, clientIP: "192.168.1.1", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := FilterIPs()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+			ctx := context.WithValue(req.Context(), keyIDKeyType{}, "key1")
+			ctx = context.WithValue(ctx, policyKeyType{}, token.Policy{IPFilter: tt.filter})
+			ctx = context.WithValue(ctx, clientIPKeyType{}, tt.clientIP)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(ctx))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
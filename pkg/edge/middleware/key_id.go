@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 	"strings"
 )
@@ -14,36 +15,84 @@ const UpstreamHostHeader = "X-Upstream-Host"
 
 type keyIDKeyType struct{}
 
+// KeyIDResolver returns the key ID of the tunnel served on a subdomain label.
+type KeyIDResolver func(ctx context.Context, label string) (string, error)
+
+// DomainResolver returns the key ID of the tunnel a custom domain is attached to,
+// or an empty string if the domain is not attached to any tunnel.
+type DomainResolver func(ctx context.Context, host string) (string, error)
+
 // ParseKeyID extracts the tunnel key ID from the request by resolving the effective host.
 // It first checks the X-Upstream-Host header (injected by Caddy from TLS SNI) for CNAME proxy support,
 // then falls back to the Host header for direct subdomain access.
-// Requests with no valid host matching the domain postfix or missing subdomains receive a 404 response.
+// The subdomain label is resolved to the key ID with resolve, so that custom subdomains reach their tunnels.
+// A Host header outside of the domain postfix is looked up as a custom domain with resolveDomain.
+// Requests with no valid host matching the domain postfix or a custom domain, or missing subdomains receive
+// a 404 response, requests whose subdomain or custom domain cannot be resolved receive a 502 response.
 // Accepts domainPostfix as a string specifying the desired domain suffix.
-// Returns a middleware handler function that attaches the subdomain to the request context and processes the next handler.
-func ParseKeyID(domainPostfix string) func(next http.Handler) http.Handler {
+// Returns a middleware handler function that attaches the key ID to the request context and processes the next handler.
+func ParseKeyID(domainPostfix string, resolve KeyIDResolver, resolveDomain DomainResolver) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			host := resolveHost(r, domainPostfix)
 			if host == "" {
-				http.NotFound(w, r)
+				serveCustomDomain(w, r, resolveDomain, next)
 				return
 			}
 
-			keyID := extractKeyIDFromHost(host)
-			if keyID == "" {
+			label := extractKeyIDFromHost(host)
+			if label == "" {
 				http.NotFound(w, r)
 				return
 			}
 
-			ctx := r.Context()
-			ctx = context.WithValue(ctx, keyIDKeyType{}, keyID)
-			r = r.WithContext(ctx)
+			keyID, err := resolve(r.Context(), label)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to resolve subdomain", slog.String("subdomain", label), slog.Any("error", err))
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+
+				return
+			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, withKeyID(r, keyID))
 		})
 	}
 }
 
+// serveCustomDomain serves a request whose Host header is outside of the domain postfix with next, when the host
+// is a custom domain attached to a tunnel. Requests for other hosts receive a 404 response.
+func serveCustomDomain(w http.ResponseWriter, r *http.Request, resolveDomain DomainResolver, next http.Handler) {
+	host := strings.Split(r.Host, ":")[0]
+	if host == "" {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	keyID, err := resolveDomain(r.Context(), host)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to resolve custom domain", slog.String("host", host), slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+
+		return
+	}
+
+	if keyID == "" {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	next.ServeHTTP(w, withKeyID(r, keyID))
+}
+
+// withKeyID returns a shallow copy of r whose context carries keyID.
+func withKeyID(r *http.Request, keyID string) *http.Request {
+	slog.InfoContext(r.Context(), "connection to edge server", slog.String("key_id", keyID))
+
+	return r.WithContext(context.WithValue(r.Context(), keyIDKeyType{}, keyID))
+}
+
 // GetKeyID retrieves the key ID from the request's context if available.
 // It returns the key ID as a string, or an empty string if not found or the value is not a string.
 func GetKeyID(r *http.Request) string {
@@ -9,6 +9,11 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// noCustomDomains is a DomainResolver for a server without custom domains.
+func noCustomDomains(context.Context, string) (string, error) {
+	return "", nil
+}
+
 func TestParseKeyID(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -113,7 +118,9 @@ func TestParseKeyID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			middleware := ParseKeyID(tt.domainPostfix)
+			middleware := ParseKeyID(tt.domainPostfix, func(_ context.Context, label string) (string, error) {
+				return label, nil
+			}, noCustomDomains)
 
 			// Create a dummy handler to validate the middleware
 			handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -138,6 +145,105 @@ func TestParseKeyID(t *testing.T) {
 	}
 }
 
+func TestParseKeyID_CustomSubdomain(t *testing.T) {
+	resolve := func(_ context.Context, label string) (string, error) {
+		switch label {
+		case "payments-demo":
+			return "key123", nil
+		case "broken":
+			return "", assert.AnError
+		default:
+			return label, nil
+		}
+	}
+
+	tests := []struct {
+		name          string
+		host          string
+		expectedKeyID string
+		wantStatus    int
+	}{
+		{name: "reserved subdomain", host: "payments-demo.example.com", expectedKeyID: "key123", wantStatus: http.StatusOK},
+		{name: "key ID subdomain", host: "key456.example.com", expectedKeyID: "key456", wantStatus: http.StatusOK},
+		{name: "resolver error", host: "broken.example.com", wantStatus: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ParseKeyID("example.com", resolve, noCustomDomains)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.expectedKeyID, GetKeyID(r))
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Host = tt.host
+
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Result().StatusCode)
+		})
+	}
+}
+
+func TestParseKeyID_CustomDomain(t *testing.T) {
+	resolveDomain := func(_ context.Context, host string) (string, error) {
+		switch host {
+		case "app.custom-domain.com":
+			return "key123", nil
+		case "broken.custom-domain.com":
+			return "", assert.AnError
+		default:
+			return "", nil
+		}
+	}
+
+	tests := []struct {
+		name          string
+		host          string
+		upstreamHost  string
+		expectedKeyID string
+		wantStatus    int
+	}{
+		{name: "attached domain", host: "app.custom-domain.com", expectedKeyID: "key123", wantStatus: http.StatusOK},
+		{name: "attached domain with port", host: "app.custom-domain.com:8080", expectedKeyID: "key123", wantStatus: http.StatusOK},
+		{name: "unknown domain", host: "other.custom-domain.com", wantStatus: http.StatusNotFound},
+		{name: "resolver error", host: "broken.custom-domain.com", wantStatus: http.StatusBadGateway},
+		{
+			name:          "upstream host takes priority over custom domain",
+			host:          "app.custom-domain.com",
+			upstreamHost:  "mykey.example.com",
+			expectedKeyID: "mykey",
+			wantStatus:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolve := func(_ context.Context, label string) (string, error) { return label, nil }
+
+			handler := ParseKeyID("example.com", resolve, resolveDomain)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.expectedKeyID, GetKeyID(r))
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Host = tt.host
+
+			if tt.upstreamHost != "" {
+				req.Header.Set(UpstreamHostHeader, tt.upstreamHost)
+			}
+
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Result().StatusCode)
+		})
+	}
+}
+
 func TestGetKeyID(t *testing.T) {
 	tests := []struct {
 		name          string
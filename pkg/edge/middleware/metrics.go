@@ -1,20 +1,82 @@
 package middleware
 
 import (
-	"log/slog"
+	"bufio"
+	"context"
+	"net"
 	"net/http"
+	"strconv"
+
+	"github.com/ksysoev/make-it-public/pkg/metrics"
 )
 
-// Metrics is an HTTP middleware that logs the "key_id" field from the request context
-// and passes the request to the next handler. It is intended for use in edge server
-// applications to track connections and associated keys.
+// statusKeyType is a custom type used as a key for storing the response status recorder in the request context.
+type statusKeyType struct{}
+
+// statusRecorder records the status code of the response written by the edge.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the first status code written and writes it to the underlying ResponseWriter.
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 status code if no status was written and writes b to the underlying ResponseWriter.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// Hijack takes over the connection of the underlying ResponseWriter.
+// Returns http.ErrNotSupported if the underlying ResponseWriter cannot be hijacked.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	return hj.Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Metrics is an HTTP middleware that counts the requests received by the edge server and the error responses
+// produced by the edge itself, by status code. It must run first, so that the responses of all the other
+// middlewares are counted. Handlers writing a response on a hijacked connection report its status with
+// ObserveStatus. Returns a middleware handler function.
 func Metrics() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			keyID := GetKeyID(r)
-			slog.InfoContext(r.Context(), "connection to edge server", slog.String("key_id", keyID))
+			metrics.EdgeRequests.Inc()
 
-			next.ServeHTTP(w, r)
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), statusKeyType{}, rec)))
+
+			if rec.status >= http.StatusBadRequest {
+				metrics.EdgeErrors.WithLabelValues(strconv.Itoa(rec.status)).Inc()
+			}
 		})
 	}
 }
+
+// ObserveStatus reports the status code of a response the edge wrote on a hijacked connection to the Metrics
+// middleware. It has no effect if the Metrics middleware did not run.
+func ObserveStatus(r *http.Request, status int) {
+	if rec, ok := r.Context().Value(statusKeyType{}).(*statusRecorder); ok {
+		rec.status = status
+	}
+}
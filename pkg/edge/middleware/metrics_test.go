@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksysoev/make-it-public/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		handler   http.HandlerFunc
+		name      string
+		code      string
+		wantError float64
+	}{
+		{
+			name:    "success",
+			code:    "200",
+			handler: func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("ok")) },
+		},
+		{
+			name:      "middleware error",
+			code:      "403",
+			handler:   func(w http.ResponseWriter, _ *http.Request) { http.Error(w, "Forbidden", http.StatusForbidden) },
+			wantError: 1,
+		},
+		{
+			name:      "hijacked connection error",
+			code:      "504",
+			handler:   func(_ http.ResponseWriter, r *http.Request) { ObserveStatus(r, http.StatusGatewayTimeout) },
+			wantError: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := testutil.ToFloat64(metrics.EdgeRequests)
+			errors := testutil.ToFloat64(metrics.EdgeErrors.WithLabelValues(tt.code))
+
+			handler := Metrics()(tt.handler)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody))
+
+			assert.Equal(t, requests+1, testutil.ToFloat64(metrics.EdgeRequests))
+			assert.Equal(t, errors+tt.wantError, testutil.ToFloat64(metrics.EdgeErrors.WithLabelValues(tt.code)))
+		})
+	}
+}
+
+func TestMetrics_Hijack(t *testing.T) {
+	handler := Metrics()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// The recorder does not support hijacking, the error is passed through.
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.ErrorIs(t, err, http.ErrNotSupported)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody))
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+// policyKeyType is a custom type used as a key for storing the token policy in the request context.
+type policyKeyType struct{}
+
+// PolicyResolver returns the policy of the token of the tunnel keyID.
+type PolicyResolver func(ctx context.Context, keyID string) (token.Policy, error)
+
+// LoadPolicy resolves the policy of the tunnel the request is addressed to once and stores it in the request
+// context, for the middlewares enforcing it. Requests whose policy cannot be resolved receive a 502 response.
+// It must run after ParseKeyID. Returns a middleware handler function.
+func LoadPolicy(resolve PolicyResolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keyID := GetKeyID(r)
+
+			policy, err := resolve(r.Context(), keyID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to resolve tunnel policy", slog.String("key_id", keyID), slog.Any("error", err))
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), policyKeyType{}, policy)))
+		})
+	}
+}
+
+// GetPolicy retrieves the token policy from the request context.
+// Returns the zero policy, which imposes no restrictions, if LoadPolicy did not run.
+func GetPolicy(r *http.Request) token.Policy {
+	if policy, ok := r.Context().Value(policyKeyType{}).(token.Policy); ok {
+		return policy
+	}
+
+	return token.Policy{}
+}
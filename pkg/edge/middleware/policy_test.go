@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	filter, err := token.NewIPFilter([]string{"10.0.0.0/8"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		resolveErr error
+		name       string
+		policy     token.Policy
+		wantStatus int
+	}{
+		{name: "policy", policy: token.Policy{IPFilter: filter}, wantStatus: http.StatusOK},
+		{name: "no policy", wantStatus: http.StatusOK},
+		{name: "resolve error", resolveErr: assert.AnError, wantStatus: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			resolve := func(_ context.Context, keyID string) (token.Policy, error) {
+				calls++
+
+				assert.Equal(t, "key1", keyID)
+
+				return tt.policy, tt.resolveErr
+			}
+
+			handler := LoadPolicy(resolve)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.policy, GetPolicy(r))
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+			ctx := context.WithValue(req.Context(), keyIDKeyType{}, "key1")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(ctx))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, 1, calls)
+		})
+	}
+}
+
+func TestGetPolicy_NotLoaded(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+
+	assert.True(t, GetPolicy(req).IsZero())
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+const (
+	// bucketSweepInterval is how often the buckets that are full again are dropped.
+	bucketSweepInterval = time.Minute
+	// maxBuckets bounds the number of buckets of a limit, so that requests from many addresses cannot grow it forever.
+	maxBuckets = 1 << 16
+)
+
+// limitRateNow returns the current time of the rate limits, it is replaced in tests.
+var limitRateNow = time.Now
+
+// LimitRate limits the rate of the requests reaching each tunnel, and of the requests each end-user IP address
+// sends to each tunnel, with token buckets. End users are identified by the address set by ClientIP, which only
+// honours forwarding headers from trusted proxies, so they cannot get a fresh bucket by changing a header. The limits of a tunnel are defaults overridden by the limits of its token.
+// Requests over a limit receive a 429 response with a Retry-After header.
+// Only the first request of a hijacked connection passes through the edge, so requests to rate limited tunnels are
+// forwarded with "Connection: close", unless they upgrade the connection, to have every request counted.
+// It must run after LoadPolicy and ClientIP.
+// Returns a middleware handler function.
+func LimitRate(defaults token.RateLimit) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		tunnels := newBuckets(limitRateNow)
+		clients := newBuckets(limitRateNow)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keyID := GetKeyID(r)
+			override := GetPolicy(r).RateLimit
+
+			limits := defaults.Override(override)
+			if limits.IsZero() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The end user is checked first, so that a single one over its limit does not use up the tunnel limit.
+			// Its token is given back if the tunnel is over its limit, as the request is not forwarded.
+			clientKey := keyID + " " + GetClientIP(r)
+
+			ok, retryAfter := clients.take(clientKey, limits.ClientRate, limits.ClientBurst)
+			if ok {
+				if ok, retryAfter = tunnels.take(keyID, limits.TunnelRate, limits.TunnelBurst); !ok {
+					clients.refund(clientKey, limits.ClientRate)
+				}
+			}
+
+			if !ok {
+				tooManyRequests(w, retryAfter)
+				return
+			}
+
+			closeAfterRequest(r)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tooManyRequests replies with a 429 response telling the client to retry after retryAfter.
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
+
+// bucket is a token bucket holding up to burst tokens and refilled with rate tokens per second.
+type bucket struct {
+	updated time.Time
+	tokens  float64
+	rate    float64
+	burst   int
+}
+
+// refill adds the tokens accumulated since the bucket was last updated.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.burst), b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	b.updated = now
+}
+
+// buckets holds a token bucket per key. Buckets that are full are dropped, as a new bucket starts full.
+// At most limit buckets are kept, when a new one is needed past the limit the fullest bucket is evicted.
+type buckets struct {
+	now       func() time.Time
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	limit     int
+	mu        sync.Mutex
+}
+
+func newBuckets(now func() time.Time) *buckets {
+	return &buckets{
+		now:       now,
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+		limit:     maxBuckets,
+	}
+}
+
+// take takes a token from the bucket of key with the given rate and burst, a zero rate is not limited.
+// Returns true if a token was available, or false and the time until the next one is otherwise. Thread-safe.
+func (bs *buckets) take(key string, rate float64, burst int) (ok bool, retryAfter time.Duration) {
+	if rate == 0 {
+		return true, 0
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	now := bs.now()
+
+	if now.Sub(bs.lastSweep) >= bucketSweepInterval {
+		bs.sweep(now)
+	}
+
+	b, exists := bs.buckets[key]
+	if !exists {
+		if len(bs.buckets) >= bs.limit {
+			bs.evict(now)
+		}
+
+		b = &bucket{tokens: float64(burst), updated: now}
+		bs.buckets[key] = b
+	}
+
+	// The limits of a token may have changed since the bucket was created.
+	b.rate, b.burst = rate, burst
+	b.refill(now)
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return true, 0
+}
+
+// refund gives back the token taken from the bucket of key with take, a zero rate is not limited. Thread-safe.
+func (bs *buckets) refund(key string, rate float64) {
+	if rate == 0 {
+		return
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if b, ok := bs.buckets[key]; ok {
+		b.tokens = math.Min(float64(b.burst), b.tokens+1)
+	}
+}
+
+// sweep drops the buckets that are full at now.
+func (bs *buckets) sweep(now time.Time) {
+	for key, b := range bs.buckets {
+		if b.refill(now); b.tokens >= float64(b.burst) {
+			delete(bs.buckets, key)
+		}
+	}
+
+	bs.lastSweep = now
+}
+
+// evict makes room for a new bucket: the buckets that are full at now are dropped and, if none is,
+// the fullest bucket is, as it is the closest to the state of a new one.
+func (bs *buckets) evict(now time.Time) {
+	if bs.sweep(now); len(bs.buckets) < bs.limit {
+		return
+	}
+
+	var (
+		fullestKey string
+		fullest    float64
+	)
+
+	for key, b := range bs.buckets {
+		if level := b.tokens / float64(max(b.burst, 1)); fullestKey == "" || level > fullest {
+			fullestKey, fullest = key, level
+		}
+	}
+
+	delete(bs.buckets, fullestKey)
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitRate(t *testing.T) {
+	tests := []struct {
+		override       *token.RateLimit
+		name           string
+		wantRetryAfter string
+		clientIPs      []string
+		wantStatus     []int
+		defaults       token.RateLimit
+	}{
+		{
+			name:       "no limits",
+			clientIPs:  []string{"10.0.0.1", "10.0.0.1", "10.0.0.1"},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:           "client limit",
+			defaults:       token.RateLimit{ClientRate: 0.5, ClientBurst: 2},
+			clientIPs:      []string{"10.0.0.1", "10.0.0.1", "10.0.0.1", "10.0.0.2"},
+			wantStatus:     []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+			wantRetryAfter: "2",
+		},
+		{
+			name:           "tunnel limit",
+			defaults:       token.RateLimit{TunnelRate: 1, TunnelBurst: 2},
+			clientIPs:      []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			wantStatus:     []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			wantRetryAfter: "1",
+		},
+		{
+			name:       "token override",
+			defaults:   token.RateLimit{TunnelRate: 1, TunnelBurst: 1},
+			override:   &token.RateLimit{TunnelRate: 100, TunnelBurst: 100},
+			clientIPs:  []string{"10.0.0.1", "10.0.0.1", "10.0.0.1"},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := LimitRate(tt.defaults)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			for i, clientIP := range tt.clientIPs {
+				req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+				ctx := context.WithValue(req.Context(), keyIDKeyType{}, "key1")
+				ctx = context.WithValue(ctx, policyKeyType{}, token.Policy{RateLimit: tt.override})
+				ctx = context.WithValue(ctx, clientIPKeyType{}, clientIP)
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req.WithContext(ctx))
+
+				assert.Equal(t, tt.wantStatus[i], rec.Code, "request %d", i)
+
+				if rec.Code == http.StatusTooManyRequests {
+					assert.Equal(t, tt.wantRetryAfter, rec.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestLimitRate_TunnelRejectionRefundsClient(t *testing.T) {
+	now := time.Unix(0, 0)
+	limits := token.RateLimit{TunnelRate: 1, TunnelBurst: 1, ClientRate: 0.1, ClientBurst: 2}
+
+	limitRateNow = func() time.Time { return now }
+	t.Cleanup(func() { limitRateNow = time.Now })
+
+	handler := LimitRate(limits)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(clientIP string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+		ctx := context.WithValue(req.Context(), keyIDKeyType{}, "key1")
+		ctx = context.WithValue(ctx, clientIPKeyType{}, clientIP)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+
+		return rec.Code
+	}
+
+	// The tunnel accepts a single request, the following ones are rejected by the tunnel limit.
+	assert.Equal(t, http.StatusOK, serve("10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("10.0.0.2"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("10.0.0.2"))
+
+	// Once the tunnel bucket is refilled, the visitor still has its whole burst left.
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve("10.0.0.2"))
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve("10.0.0.2"))
+}
+
+func TestLimitRate_IgnoresSpoofedForwardingHeaders(t *testing.T) {
+	limits := token.RateLimit{ClientRate: 0.1, ClientBurst: 1}
+
+	handler := ClientIP(testTrustedProxies)(LimitRate(limits)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+		req.RemoteAddr = "198.51.100.7:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(context.WithValue(req.Context(), keyIDKeyType{}, "key1")))
+
+		return rec.Code
+	}
+
+	// Rotating the header does not give a visitor that is not a trusted proxy a fresh bucket.
+	assert.Equal(t, http.StatusOK, serve("203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("203.0.113.2"))
+}
+
+func TestLimitRate_ForcesConnectionClose(t *testing.T) {
+	handler := LimitRate(token.RateLimit{TunnelRate: 10, TunnelBurst: 10})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		assert.True(t, r.Close)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+	req.Header.Set("Connection", "keep-alive")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), keyIDKeyType{}, "key1")))
+}
+
+func TestBuckets_Take(t *testing.T) {
+	now := time.Unix(0, 0)
+	bs := newBuckets(func() time.Time { return now })
+
+	ok, _ := bs.take("a", 2, 1)
+	assert.True(t, ok)
+
+	ok, retryAfter := bs.take("a", 2, 1)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+
+	ok, _ = bs.take("a", 2, 1)
+	assert.True(t, ok)
+
+	ok, _ = bs.take("b", 0, 0)
+	assert.True(t, ok)
+	assert.NotContains(t, bs.buckets, "b")
+
+	// Buckets that are full again are dropped once the sweep interval has passed.
+	now = now.Add(bucketSweepInterval)
+
+	ok, _ = bs.take("c", 1, 1)
+	assert.True(t, ok)
+	assert.NotContains(t, bs.buckets, "a")
+	assert.Contains(t, bs.buckets, "c")
+}
+
+func TestBuckets_Limit(t *testing.T) {
+	now := time.Unix(0, 0)
+	bs := newBuckets(func() time.Time { return now })
+	bs.limit = 2
+
+	for _, key := range []string{"a", "a", "b"} {
+		ok, _ := bs.take(key, 1, 2)
+		assert.True(t, ok)
+	}
+
+	// The map stays bounded, the fullest bucket making room for the new one.
+	ok, _ := bs.take("c", 1, 2)
+	assert.True(t, ok)
+	assert.Len(t, bs.buckets, 2)
+	assert.Contains(t, bs.buckets, "a", "the emptiest bucket must be kept")
+	assert.Contains(t, bs.buckets, "c")
+
+	ok, _ = bs.take("a", 1, 2)
+	assert.False(t, ok)
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+// maxResponseHeaderSize is the size of the response headers buffered to rewrite them,
+// responses with larger headers are forwarded unchanged.
+const maxResponseHeaderSize = 64 << 10
+
+var headerEnd = []byte("\r\n\r\n")
+
+// RewriteHeaders applies the header rewrite rules of the tunnel the request is addressed to.
+// Request rules are applied before the request is forwarded, response rules are applied to the response headers
+// written to the connection returned when the response writer is hijacked.
+// Only the first request of a hijacked connection passes through the edge, so requests to tunnels with rules are
+// forwarded with "Connection: close", unless they upgrade the connection, to have every request rewritten.
+// It must run after LoadPolicy and right before the handler hijacking the connection.
+// Returns a middleware handler function.
+func RewriteHeaders() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rewrite := GetPolicy(r).Rewrite
+
+			if rewrite == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			closeAfterRequest(r)
+
+			rewrite.RewriteRequest(r)
+
+			if rewrite.HasResponseRules() {
+				if hj, ok := w.(http.Hijacker); ok {
+					w = &rewriteHijacker{ResponseWriter: w, hijacker: hj, rewrite: rewrite}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// closeAfterRequest has r forwarded with "Connection: close", unless it upgrades the connection, so that the
+// following requests of the end user are sent on a new connection and pass through the edge again.
+func closeAfterRequest(r *http.Request) {
+	if r.Header.Get("Upgrade") != "" {
+		return
+	}
+
+	r.Header.Del("Connection")
+	r.Header.Del("Keep-Alive")
+	r.Close = true
+}
+
+// rewriteHijacker hands out connections rewriting the response headers written to them when hijacked.
+type rewriteHijacker struct {
+	http.ResponseWriter
+	hijacker http.Hijacker
+	rewrite  *token.Rewrite
+}
+
+func (h *rewriteHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := h.hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &rewriteConn{Conn: conn, rewrite: h.rewrite}, brw, nil
+}
+
+// rewriteConn applies the response rules to the header of the response written to it. Interim 1xx responses
+// are rewritten as well, the data following the final response header is written unchanged.
+type rewriteConn struct {
+	net.Conn
+	rewrite *token.Rewrite
+	buf     []byte
+	mu      sync.Mutex
+	done    bool
+}
+
+// Write buffers the response header until it is complete and writes it rewritten. The lock only guards the
+// buffer, writes to the connection are made without it so that Close is never blocked by a pending write.
+func (c *rewriteConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+
+	if c.done {
+		c.mu.Unlock()
+		return c.Conn.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+
+	var out []byte
+
+	for !c.done {
+		end := bytes.Index(c.buf, headerEnd)
+		if end < 0 {
+			if len(c.buf) <= maxResponseHeaderSize {
+				break
+			}
+
+			// The header is too large to be rewritten, it is forwarded unchanged.
+			c.done = true
+
+			break
+		}
+
+		header, final := rewriteResponseHeader(c.buf[:end+len(headerEnd)], c.rewrite)
+
+		out = append(out, header...)
+		c.buf = c.buf[end+len(headerEnd):]
+		c.done = final
+	}
+
+	if c.done {
+		out = append(out, c.buf...)
+		c.buf = nil
+	}
+
+	c.mu.Unlock()
+
+	if len(out) > 0 {
+		if _, err := c.Conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the bytes still buffered unchanged, as the response ended before its header was complete,
+// and closes the connection.
+func (c *rewriteConn) Close() error {
+	c.mu.Lock()
+	rest := c.buf
+	c.buf = nil
+	c.done = true
+	c.mu.Unlock()
+
+	if len(rest) > 0 {
+		_, _ = c.Conn.Write(rest)
+	}
+
+	return c.Conn.Close()
+}
+
+// rewriteResponseHeader applies rewrite to the response header block, status line included.
+// final is false for interim 1xx responses, which are followed by another response header, except for
+// 101 Switching Protocols after which the connection no longer carries HTTP.
+// A header block that cannot be parsed is returned unchanged.
+func rewriteResponseHeader(block []byte, rewrite *token.Rewrite) (header []byte, final bool) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(block)))
+
+	statusLine, err := tp.ReadLine()
+	if err != nil {
+		return block, true
+	}
+
+	mime, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return block, true
+	}
+
+	h := http.Header(mime)
+	rewrite.RewriteResponse(h)
+
+	var buf bytes.Buffer
+
+	buf.WriteString(statusLine)
+	buf.WriteString("\r\n")
+
+	if err := h.Write(&buf); err != nil {
+		return block, true
+	}
+
+	buf.WriteString("\r\n")
+
+	_, status, _ := strings.Cut(statusLine, " ")
+	interim := strings.HasPrefix(status, "1") && !strings.HasPrefix(status, "101")
+
+	return buf.Bytes(), !interim
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteHeaders(t *testing.T) {
+	rewrite, err := token.NewRewrite(token.Rewrite{Host: "localhost:3000", SetRequestHeaders: map[string]string{"X-Env": "dev"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		rewrite    *token.Rewrite
+		name       string
+		wantHost   string
+		wantEnv    string
+		wantStatus int
+		wantClose  bool
+	}{
+		{name: "no rules", wantStatus: http.StatusOK, wantHost: "key1.example.com"},
+		{name: "rules", rewrite: rewrite, wantStatus: http.StatusOK, wantHost: "localhost:3000", wantEnv: "dev", wantClose: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RewriteHeaders()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.wantHost, r.Host)
+				assert.Equal(t, tt.wantEnv, r.Header.Get("X-Env"))
+				assert.Equal(t, tt.wantClose, r.Close)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+			ctx := context.WithValue(req.Context(), policyKeyType{}, token.Policy{Rewrite: tt.rewrite})
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(ctx))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestRewriteHeaders_Upgrade(t *testing.T) {
+	rewrite, err := token.NewRewrite(token.Rewrite{Host: "localhost:3000"})
+	require.NoError(t, err)
+
+	handler := RewriteHeaders()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		assert.False(t, r.Close)
+		assert.Equal(t, "Upgrade", r.Header.Get("Connection"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://key1.example.com/", http.NoBody)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), policyKeyType{}, token.Policy{Rewrite: rewrite})))
+}
+
+func TestRewriteHeaders_Response(t *testing.T) {
+	rewrite, err := token.NewRewrite(token.Rewrite{
+		SetResponseHeaders:    map[string]string{"X-Frame-Options": "DENY"},
+		RemoveResponseHeaders: []string{"Server"},
+	})
+	require.NoError(t, err)
+
+	handler := RewriteHeaders()(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		defer func() { _ = conn.Close() }()
+
+		// The response is written in pieces, as when it is copied from the tunnel.
+		for _, chunk := range []string{
+			"HTTP/1.1 100 Continue\r\nServer: nginx\r\n\r\nHTTP/1.1 200 OK\r\nSer",
+			"ver: nginx\r\nContent-Length: 5\r\n",
+			"\r\nhello",
+		} {
+			_, err := conn.Write([]byte(chunk))
+			assert.NoError(t, err)
+		}
+	}))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), policyKeyType{}, token.Policy{Rewrite: rewrite})))
+	}))
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, http.NoBody)
+	require.NoError(t, err)
+
+	var interim http.Header
+
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(_ int, h textproto.MIMEHeader) error {
+			interim = http.Header(h)
+			return nil
+		},
+	}
+
+	resp, err := srv.Client().Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	require.NoError(t, err)
+
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Server"))
+	assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"))
+	assert.Equal(t, int64(5), resp.ContentLength)
+
+	assert.Empty(t, interim.Get("Server"))
+	assert.Equal(t, "DENY", interim.Get("X-Frame-Options"))
+}
+
+func TestRewriteResponseHeader(t *testing.T) {
+	rewrite, err := token.NewRewrite(token.Rewrite{SetResponseHeaders: map[string]string{"X-Env": "dev"}})
+	require.NoError(t, err)
+
+	header, final := rewriteResponseHeader([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n"), rewrite)
+	assert.True(t, final)
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(header)), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "dev", resp.Header.Get("X-Env"))
+	assert.Equal(t, "websocket", resp.Header.Get("Upgrade"))
+
+	_, final = rewriteResponseHeader([]byte("HTTP/1.1 103 Early Hints\r\nLink: </style.css>\r\n\r\n"), rewrite)
+	assert.False(t, final)
+
+	invalid := []byte("HTTP/1.1 200 OK\r\nbroken header\r\n\r\n")
+	header, final = rewriteResponseHeader(invalid, rewrite)
+	assert.True(t, final)
+	assert.Equal(t, invalid, header)
+}
+
+func TestRewriteConn_CloseFlushesIncompleteHeader(t *testing.T) {
+	rewrite, err := token.NewRewrite(token.Rewrite{RemoveResponseHeaders: []string{"Server"}})
+	require.NoError(t, err)
+
+	server, client := net.Pipe()
+	conn := &rewriteConn{Conn: server, rewrite: rewrite}
+
+	received := make(chan []byte, 1)
+
+	go func() {
+		data, _ := io.ReadAll(client)
+		received <- data
+	}()
+
+	// The upstream closes before the end of the header, what was written is forwarded unchanged.
+	partial := []byte("HTTP/1.1 200 OK\r\nServer: nginx\r\n")
+
+	n, err := conn.Write(partial)
+	require.NoError(t, err)
+	assert.Equal(t, len(partial), n)
+
+	require.NoError(t, conn.Close())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nServer: nginx\r\n", string(<-received))
+}
@@ -23,3 +23,15 @@ const htmlErrorTemplate404 = `<!DOCTYPE html>
 	<p>Please check the URL and try again.</p>
 </body>
 </html>`
+
+const htmlErrorTemplate429 = `<!DOCTYPE html>
+<html>
+<head>
+	<title>429 Too Many Requests</title>
+</head>
+<body>
+	<h1>429 Too Many Requests</h1>
+	<p>This tunnel has used up its traffic quota.</p>
+	<p>Please try again later.</p>
+</body>
+</html>`
@@ -0,0 +1,352 @@
+// Package inspector captures the HTTP traffic proxied by the client to the exposed service and serves
+// a local web UI and JSON API to browse the captured requests and replay them.
+package inspector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListen      = "localhost:4040"
+	DefaultCapacity    = 100
+	DefaultMaxBodySize = 1 << 20
+
+	IndexEndpoint         = "GET /{$}"
+	ListEndpoint          = "GET /api/requests"
+	GetEndpoint           = "GET /api/requests/{id}"
+	ClearEndpoint         = "DELETE /api/requests"
+	ReplayEndpoint        = "POST /api/requests/{id}/replay"
+	replayTimeout         = 30 * time.Second
+	shutdownTimeout       = 5 * time.Second
+	contentTypeJSON       = "application/json"
+	contentTypeHTML       = "text/html; charset=utf-8"
+	errMsgExchangeMissing = "request not found"
+	errMsgForbiddenHost   = "host not allowed"
+	errMsgCrossOrigin     = "cross-origin request not allowed"
+)
+
+var (
+	errBodyTruncated = errors.New("the request body was truncated when captured and cannot be replayed")
+	errNotForwarded  = errors.New("the request was not forwarded to a local service and cannot be replayed")
+)
+
+// Config holds the inspector settings.
+// Listen is the address of the web UI.
+// Capacity is the number of exchanges kept in memory and MaxBodySize is the number of body bytes captured
+// per request or response, zero values fall back to the defaults.
+type Config struct {
+	Listen      string `mapstructure:"listen"`
+	Capacity    int    `mapstructure:"capacity"`
+	MaxBodySize int64  `mapstructure:"max_body_size"`
+}
+
+// Inspector keeps the recently captured exchanges and serves them over HTTP.
+type Inspector struct {
+	store   *store
+	client  *http.Client
+	isReady chan struct{}
+	addr    string
+	cfg     Config
+}
+
+// New creates an Inspector with cfg, applying defaults to the unset fields.
+func New(cfg Config) *Inspector {
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultListen
+	}
+
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = DefaultCapacity
+	}
+
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+
+	return &Inspector{
+		cfg:     cfg,
+		store:   newStore(cfg.Capacity),
+		isReady: make(chan struct{}),
+		client: &http.Client{
+			Timeout: replayTimeout,
+			// Replayed requests go straight to the exposed service, ignoring proxy environment variables,
+			// and redirects are returned as they are so that the replayed exchange matches the original one.
+			Transport: &http.Transport{},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run starts the web UI and serves it until ctx is cancelled.
+// Returns an error if the listener cannot be created or the server fails.
+func (i *Inspector) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", i.cfg.Listen)
+	if err != nil {
+		close(i.isReady)
+		return fmt.Errorf("failed to start inspector: %w", err)
+	}
+
+	i.addr = l.Addr().String()
+
+	router := http.NewServeMux()
+	router.HandleFunc(IndexEndpoint, i.indexHandler)
+	router.HandleFunc(ListEndpoint, i.listHandler)
+	router.HandleFunc(GetEndpoint, i.getHandler)
+	router.HandleFunc(ClearEndpoint, i.clearHandler)
+	router.HandleFunc(ReplayEndpoint, i.replayHandler)
+
+	server := &http.Server{
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      replayTimeout + 5*time.Second,
+		Handler:           i.guard(router),
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdown(server)
+	}()
+
+	close(i.isReady)
+
+	if err := server.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Addr waits for the web UI to be ready and returns its address in "host:port" format.
+// It returns an empty string if the web UI failed to start.
+func (i *Inspector) Addr() string {
+	<-i.isReady
+	return i.addr
+}
+
+// shutdown gracefully stops the server, giving in-flight requests up to shutdownTimeout to complete
+// before the remaining connections are closed.
+func shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		_ = server.Close()
+	}
+}
+
+// guard only lets next serve the requests made by the web UI itself.
+// Requests for a Host other than a loopback name or address, or the host of the listen address, are answered
+// with HTTP 403, so that a web page cannot reach the inspector by rebinding its own domain to this machine.
+// Requests other than GET and HEAD sent by a page of another origin are answered with HTTP 403 as well,
+// so that a web page cannot replay or clear the captured requests.
+func (i *Inspector) guard(next http.Handler) http.Handler {
+	listenHost, _, _ := net.SplitHostPort(i.cfg.Listen)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAllowedHost(r.Host, listenHost) {
+			http.Error(w, errMsgForbiddenHost, http.StatusForbidden)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && isCrossOrigin(r) {
+			http.Error(w, errMsgCrossOrigin, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAllowedHost reports whether host, the Host header of a request with an optional port, is localhost,
+// a loopback address or listenHost. Unspecified listen hosts, like 0.0.0.0, allow loopback hosts only.
+func isAllowedHost(host, listenHost string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && addr.IsLoopback() {
+		return true
+	}
+
+	if addr, err := netip.ParseAddr(listenHost); listenHost == "" || (err == nil && addr.IsUnspecified()) {
+		return false
+	}
+
+	return strings.EqualFold(host, listenHost)
+}
+
+// isCrossOrigin reports whether r was sent by a page of another origin than the web UI.
+// Browsers tell with the Sec-Fetch-Site header, older ones only with the Origin header of non-GET requests.
+// Requests without either header do not come from a web page and are not cross-origin.
+func isCrossOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site != "same-origin" && site != "none"
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+
+	return err != nil || !strings.EqualFold(u.Host, r.Host)
+}
+
+// indexHandler serves the web UI.
+func (i *Inspector) indexHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentTypeHTML)
+
+	if _, err := w.Write([]byte(indexHTML)); err != nil {
+		slog.DebugContext(r.Context(), "failed to write response", slog.Any("error", err))
+	}
+}
+
+// listHandler responds with the captured exchanges, newest first.
+func (i *Inspector) listHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, i.store.list())
+}
+
+// getHandler responds with a single captured exchange.
+// Returns HTTP 404 if the exchange is not stored anymore.
+func (i *Inspector) getHandler(w http.ResponseWriter, r *http.Request) {
+	e, ok := i.store.get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, errMsgExchangeMissing, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, e)
+}
+
+// clearHandler removes all captured exchanges.
+func (i *Inspector) clearHandler(w http.ResponseWriter, _ *http.Request) {
+	i.store.clear()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replayHandler sends a captured request to the local service it was forwarded to again and responds with
+// the new exchange. Returns HTTP 404 if the exchange is not stored anymore and HTTP 422 if its request body was
+// truncated or it was not forwarded to any service. A failure to reach the service is reported in the Error field of the new exchange.
+func (i *Inspector) replayHandler(w http.ResponseWriter, r *http.Request) {
+	orig, ok := i.store.get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, errMsgExchangeMissing, http.StatusNotFound)
+		return
+	}
+
+	e, err := i.replay(r.Context(), orig)
+	if errors.Is(err, errBodyTruncated) || errors.Is(err, errNotForwarded) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, e)
+}
+
+// replay sends the request of orig to the local service it was forwarded to and stores the resulting exchange.
+// Returns errBodyTruncated if the request body of orig was not fully captured and errNotForwarded if orig
+// has no destination.
+func (i *Inspector) replay(ctx context.Context, orig *Exchange) (*Exchange, error) {
+	if orig.Request.BodyTruncated {
+		return nil, errBodyTruncated
+	}
+
+	if orig.Dest == "" {
+		return nil, errNotForwarded
+	}
+
+	e := &Exchange{
+		StartedAt: time.Now(),
+		Request:   orig.Request,
+		ClientIP:  orig.ClientIP,
+		ReplayOf:  orig.ID,
+		Dest:      orig.Dest,
+	}
+
+	defer i.store.add(e)
+
+	req, err := newReplayRequest(ctx, orig.Request, orig.Dest)
+	if err != nil {
+		e.Error = err.Error()
+		return e, nil
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		e.Duration = time.Since(e.StartedAt)
+		e.Error = err.Error()
+
+		return e, nil
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, size, truncated, err := readBody(resp.Body, i.cfg.MaxBodySize)
+
+	e.Duration = time.Since(e.StartedAt)
+	e.Response = &Response{
+		Header:        resp.Header,
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Proto:         resp.Proto,
+		Body:          body,
+		BodySize:      size,
+		BodyTruncated: truncated,
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e, nil
+}
+
+// newReplayRequest builds a request to dest, the URL the captured request r was forwarded to.
+// The original Host header is preserved so that the service sees the same request as before.
+func newReplayRequest(ctx context.Context, r *Request, dest string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, r.Method, dest, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header = r.Header.Clone()
+	req.Host = r.Host
+
+	// The transport manages the framing and the connection itself.
+	req.Header.Del("Content-Length")
+	req.Header.Del("Transfer-Encoding")
+	req.Header.Del("Connection")
+
+	return req, nil
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.DebugContext(r.Context(), "failed to write response", slog.Any("error", err))
+	}
+}
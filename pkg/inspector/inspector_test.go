@@ -0,0 +1,251 @@
+package inspector
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForExchanges polls the store until it holds n exchanges.
+func waitForExchanges(t *testing.T, i *Inspector, n int) []*Exchange {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return len(i.store.list()) == n }, time.Second, 10*time.Millisecond)
+
+	return i.store.list()
+}
+
+// forwardTo returns a destination func forwarding every request to the service at addr.
+func forwardTo(addr string) func(*url.URL) *url.URL {
+	return func(u *url.URL) *url.URL {
+		dest := *u
+		dest.Scheme, dest.Host = "http", addr
+
+		return &dest
+	}
+}
+
+func TestInspector_Tap(t *testing.T) {
+	i := New(Config{MaxBodySize: 4})
+
+	inbound, outbound := i.Tap("1.2.3.4", func(u *url.URL) *url.URL {
+		if u.Path == "/second" {
+			return nil
+		}
+
+		return forwardTo("localhost:8080")(u)
+	})
+
+	go func() {
+		_, _ = io.WriteString(inbound, "POST /hook?x=1 HTTP/1.1\r\nHost: example.com\r\nContent-Length: 5\r\n\r\nhello")
+		_, _ = io.WriteString(inbound, "GET /second HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		_ = inbound.Close()
+	}()
+
+	_, err := io.WriteString(outbound, "HTTP/1.1 201 Created\r\nContent-Length: 2\r\n\r\nok")
+	require.NoError(t, err)
+	require.NoError(t, outbound.Close())
+
+	list := waitForExchanges(t, i, 2)
+
+	first, second := list[1], list[0]
+
+	assert.Equal(t, "1.2.3.4", first.ClientIP)
+	assert.Equal(t, http.MethodPost, first.Request.Method)
+	assert.Equal(t, "/hook?x=1", first.Request.URL)
+	assert.Equal(t, "example.com", first.Request.Host)
+	assert.Equal(t, "http://localhost:8080/hook?x=1", first.Dest)
+	assert.Equal(t, []byte("hell"), first.Request.Body)
+	assert.Equal(t, int64(5), first.Request.BodySize)
+	assert.True(t, first.Request.BodyTruncated)
+	require.NotNil(t, first.Response)
+	assert.Equal(t, http.StatusCreated, first.Response.StatusCode)
+	assert.Equal(t, []byte("ok"), first.Response.Body)
+
+	assert.Equal(t, "/second", second.Request.URL)
+	assert.Empty(t, second.Dest)
+	assert.Nil(t, second.Response)
+	assert.NotEmpty(t, second.Error)
+}
+
+func TestInspector_Tap_NotHTTP(t *testing.T) {
+	i := New(Config{})
+
+	inbound, outbound := i.Tap("1.2.3.4", forwardTo("localhost:8080"))
+
+	_, err := io.WriteString(inbound, "\x16\x03\x01 definitely not http\r\n\r\n")
+	require.NoError(t, err)
+	_, err = io.WriteString(outbound, "garbage")
+	require.NoError(t, err)
+
+	require.NoError(t, inbound.Close())
+	require.NoError(t, outbound.Close())
+
+	assert.Empty(t, i.store.list())
+}
+
+func TestInspector_API(t *testing.T) {
+	var gotHost, gotURL, gotBody, gotHeader string
+
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotHost, gotURL, gotBody, gotHeader = r.Host, r.RequestURI, string(body), r.Header.Get("X-Test")
+
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("replayed"))
+	}))
+	t.Cleanup(service.Close)
+
+	i := New(Config{Listen: "127.0.0.1:0"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go func() { _ = i.Run(ctx) }()
+
+	base := "http://" + i.Addr()
+
+	captured := &Exchange{
+		ClientIP: "1.2.3.4",
+		Dest:     service.URL + "/users?x=1",
+		Request: &Request{
+			Method:   http.MethodPost,
+			URL:      "/hook?x=1",
+			Host:     "example.com",
+			Header:   http.Header{"X-Test": {"yes"}, "Content-Length": {"4"}},
+			Body:     []byte("data"),
+			BodySize: 4,
+		},
+	}
+	truncated := &Exchange{Dest: service.URL + "/", Request: &Request{Method: http.MethodPost, URL: "/", BodyTruncated: true}}
+	notForwarded := &Exchange{Request: &Request{Method: http.MethodGet, URL: "/"}}
+
+	i.store.add(captured)
+	i.store.add(truncated)
+	i.store.add(notForwarded)
+
+	resp, err := http.Get(base + "/api/requests")
+	require.NoError(t, err)
+
+	var list []*Exchange
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	_ = resp.Body.Close()
+	require.Len(t, list, 3)
+
+	resp, err = http.Post(base+"/api/requests/"+captured.ID+"/replay", "", nil)
+	require.NoError(t, err)
+
+	var replayed Exchange
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&replayed))
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, replayed.ID)
+	assert.Equal(t, captured.ID, replayed.ReplayOf)
+	assert.Equal(t, captured.Dest, replayed.Dest)
+	assert.Empty(t, replayed.Error)
+	require.NotNil(t, replayed.Response)
+	assert.Equal(t, http.StatusAccepted, replayed.Response.StatusCode)
+	assert.Equal(t, []byte("replayed"), replayed.Response.Body)
+	assert.Equal(t, "example.com", gotHost)
+	assert.Equal(t, "/users?x=1", gotURL)
+	assert.Equal(t, "data", gotBody)
+	assert.Equal(t, "yes", gotHeader)
+
+	for _, e := range []*Exchange{truncated, notForwarded} {
+		resp, err = http.Post(base+"/api/requests/"+e.ID+"/replay", "", nil)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+
+	resp, err = http.Get(base + "/api/requests/42")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodDelete, base+"/api/requests", http.NoBody)
+	require.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, i.store.list())
+}
+
+func TestInspector_Guard(t *testing.T) {
+	tests := []struct {
+		header     http.Header
+		name       string
+		method     string
+		host       string
+		listen     string
+		wantStatus int
+	}{
+		{name: "localhost", method: http.MethodGet, host: "localhost:4040", wantStatus: http.StatusOK},
+		{name: "loopback address", method: http.MethodGet, host: "127.0.0.1:4040", wantStatus: http.StatusOK},
+		{name: "loopback IPv6 address", method: http.MethodGet, host: "[::1]:4040", wantStatus: http.StatusOK},
+		{name: "listen host", method: http.MethodGet, host: "192.168.1.5:4040", listen: "192.168.1.5:4040", wantStatus: http.StatusOK},
+		{name: "rebound domain", method: http.MethodGet, host: "attacker.example:4040", wantStatus: http.StatusForbidden},
+		{name: "unspecified listen host", method: http.MethodGet, host: "192.168.1.5:4040", listen: "0.0.0.0:4040", wantStatus: http.StatusForbidden},
+		{name: "request without origin", method: http.MethodPost, host: "localhost:4040", wantStatus: http.StatusOK},
+		{
+			name:       "same origin",
+			method:     http.MethodPost,
+			host:       "localhost:4040",
+			header:     http.Header{"Origin": {"http://localhost:4040"}, "Sec-Fetch-Site": {"same-origin"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "cross-site fetch",
+			method:     http.MethodPost,
+			host:       "localhost:4040",
+			header:     http.Header{"Sec-Fetch-Site": {"cross-site"}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "foreign origin",
+			method:     http.MethodDelete,
+			host:       "localhost:4040",
+			header:     http.Header{"Origin": {"https://attacker.example"}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "cross-site read",
+			method:     http.MethodGet,
+			host:       "localhost:4040",
+			header:     http.Header{"Sec-Fetch-Site": {"cross-site"}},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := New(Config{Listen: tt.listen})
+			handler := i.guard(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/requests", http.NoBody)
+			req.Host = tt.host
+
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package inspector
+
+import (
+	"crypto/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Exchange is a request proxied to the exposed service together with its response.
+// Error is set when the response could not be captured, for example because the connection was closed.
+// Dest is the URL of the local service the request was forwarded to, empty if no service matched it.
+// ReplayOf is the ID of the exchange that was replayed to produce this one.
+type Exchange struct {
+	StartedAt time.Time     `json:"started_at"`
+	Request   *Request      `json:"request"`
+	Response  *Response     `json:"response,omitempty"`
+	ID        string        `json:"id"`
+	ClientIP  string        `json:"client_ip"`
+	Dest      string        `json:"dest,omitempty"`
+	ReplayOf  string        `json:"replay_of,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Request is a captured HTTP request. URL is the request target as sent by the client.
+// Body holds at most the configured maximum body size, BodySize is the full size of the body.
+type Request struct {
+	Header        http.Header `json:"header"`
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Host          string      `json:"host"`
+	Proto         string      `json:"proto"`
+	Body          []byte      `json:"body,omitempty"`
+	BodySize      int64       `json:"body_size"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// Response is a captured HTTP response.
+// Body holds at most the configured maximum body size, BodySize is the full size of the body.
+type Response struct {
+	Header        http.Header `json:"header"`
+	Status        string      `json:"status"`
+	Proto         string      `json:"proto"`
+	Body          []byte      `json:"body,omitempty"`
+	BodySize      int64       `json:"body_size"`
+	StatusCode    int         `json:"status_code"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// store is a ring buffer keeping the most recent exchanges.
+type store struct {
+	items []*Exchange
+	next  int
+	mu    sync.RWMutex
+}
+
+// newStore creates a store keeping up to capacity exchanges.
+func newStore(capacity int) *store {
+	return &store{items: make([]*Exchange, capacity)}
+}
+
+// add assigns a random ID to e and stores it, evicting the oldest exchange if the store is full.
+// IDs are random so that the captured requests cannot be addressed without listing them first.
+func (s *store) add(e *Exchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = rand.Text()
+
+	s.items[s.next] = e
+	s.next = (s.next + 1) % len(s.items)
+}
+
+// list returns the stored exchanges, newest first.
+func (s *store) list() []*Exchange {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exchanges := make([]*Exchange, 0, len(s.items))
+
+	for i := 1; i <= len(s.items); i++ {
+		e := s.items[(s.next-i+len(s.items))%len(s.items)]
+		if e == nil {
+			break
+		}
+
+		exchanges = append(exchanges, e)
+	}
+
+	return exchanges
+}
+
+// get returns the exchange with the given ID if it is still stored.
+func (s *store) get(id string) (*Exchange, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.items {
+		if e != nil && e.ID == id {
+			return e, true
+		}
+	}
+
+	return nil, false
+}
+
+// clear removes all stored exchanges.
+func (s *store) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.items)
+	s.next = 0
+}
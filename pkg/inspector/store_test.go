@@ -0,0 +1,38 @@
+package inspector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s := newStore(2)
+
+	assert.Empty(t, s.list())
+
+	added := make([]*Exchange, 3)
+
+	for n := range added {
+		added[n] = &Exchange{}
+		s.add(added[n])
+	}
+
+	assert.NotEqual(t, added[0].ID, added[1].ID)
+
+	list := s.list()
+	require.Len(t, list, 2)
+	assert.Same(t, added[2], list[0])
+	assert.Same(t, added[1], list[1])
+
+	_, ok := s.get(added[0].ID)
+	assert.False(t, ok, "oldest exchange should be evicted")
+
+	e, ok := s.get(added[1].ID)
+	require.True(t, ok)
+	assert.Same(t, added[1], e)
+
+	s.clear()
+	assert.Empty(t, s.list())
+}
@@ -0,0 +1,186 @@
+package inspector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxPipelined bounds the number of requests waiting for their responses on a single connection.
+const maxPipelined = 16
+
+// pending is a request whose response has not been captured yet.
+// bodyRead is closed once the request body has been captured.
+type pending struct {
+	req      *http.Request
+	exchange *Exchange
+	bodyRead chan struct{}
+}
+
+// Tap starts capturing the HTTP exchanges of a connection from the end user clientIP.
+// dest returns the URL of the local service a request for the given URL is forwarded to, or nil if it is not
+// forwarded, it is stored with each exchange so that the request can be replayed to the same service.
+// The returned writers must receive a copy of the bytes sent to the exposed service (inbound) and of the bytes
+// sent back to the end user (outbound), and be closed when the connection ends.
+// The writers never block for long: traffic that cannot be parsed as HTTP, such as upgraded connections,
+// is discarded.
+func (i *Inspector) Tap(clientIP string, dest func(*url.URL) *url.URL) (inbound, outbound io.WriteCloser) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	queue := make(chan *pending, maxPipelined)
+
+	go i.readRequests(reqR, queue, clientIP, dest)
+	go i.readResponses(respR, queue)
+
+	return reqW, respW
+}
+
+// readRequests parses the requests sent by the end user and queues them for readResponses.
+// It stops parsing at the first error and discards the rest of the traffic.
+func (i *Inspector) readRequests(r io.Reader, queue chan<- *pending, clientIP string, dest func(*url.URL) *url.URL) {
+	defer drain(r)
+	defer close(queue)
+
+	br := bufio.NewReader(r)
+
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Debug("stopped inspecting requests", slog.Any("error", err))
+			}
+
+			return
+		}
+
+		p := &pending{
+			req:      req,
+			bodyRead: make(chan struct{}),
+			exchange: &Exchange{
+				StartedAt: time.Now(),
+				ClientIP:  clientIP,
+				Request: &Request{
+					Header: req.Header,
+					Method: req.Method,
+					URL:    req.RequestURI,
+					Host:   req.Host,
+					Proto:  req.Proto,
+				},
+			},
+		}
+
+		if u := dest(req.URL); u != nil {
+			p.exchange.Dest = u.String()
+		}
+
+		// The request is queued before its body is read, so that interim responses like
+		// 100 Continue can be parsed while the end user waits for them to send the body.
+		queue <- p
+
+		body, size, truncated, err := readBody(req.Body, i.cfg.MaxBodySize)
+		p.exchange.Request.Body, p.exchange.Request.BodySize, p.exchange.Request.BodyTruncated = body, size, truncated
+
+		close(p.bodyRead)
+
+		if err != nil {
+			slog.Debug("stopped inspecting requests", slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// readResponses parses the responses of the exposed service, pairs them with the queued requests and stores
+// the exchanges. It stops parsing at the first error or protocol switch and discards the rest of the traffic.
+// Requests left without a response are stored with an error.
+func (i *Inspector) readResponses(r io.Reader, queue <-chan *pending) {
+	defer drain(r)
+
+	br := bufio.NewReader(r)
+
+	for p := range queue {
+		resp, err := readFinalResponse(br, p.req)
+		if err != nil {
+			i.fail(p, queue, err)
+			return
+		}
+
+		body, size, truncated, err := readBody(resp.Body, i.cfg.MaxBodySize)
+		if err != nil {
+			i.fail(p, queue, err)
+			return
+		}
+
+		<-p.bodyRead
+
+		p.exchange.Duration = time.Since(p.exchange.StartedAt)
+		p.exchange.Response = &Response{
+			Header:        resp.Header,
+			Status:        resp.Status,
+			StatusCode:    resp.StatusCode,
+			Proto:         resp.Proto,
+			Body:          body,
+			BodySize:      size,
+			BodyTruncated: truncated,
+		}
+
+		i.store.add(p.exchange)
+
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			return
+		}
+	}
+}
+
+// fail stores p and the remaining queued requests with err as they will not get a captured response.
+func (i *Inspector) fail(p *pending, queue <-chan *pending, err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = errors.New("connection closed before the response was received")
+	}
+
+	for ; p != nil; p = <-queue {
+		<-p.bodyRead
+
+		p.exchange.Duration = time.Since(p.exchange.StartedAt)
+		p.exchange.Error = err.Error()
+
+		i.store.add(p.exchange)
+	}
+}
+
+// readFinalResponse reads the response to req from br, skipping interim 1xx responses other than 101 Switching Protocols.
+func readFinalResponse(br *bufio.Reader, req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+			return resp, nil
+		}
+	}
+}
+
+// readBody reads body to the end and returns up to limit bytes of it, its full size and whether it was truncated.
+func readBody(body io.Reader, limit int64) (data []byte, size int64, truncated bool, err error) {
+	var buf bytes.Buffer
+
+	n, err := io.Copy(&buf, io.LimitReader(body, limit))
+	if err != nil {
+		return buf.Bytes(), n, false, err
+	}
+
+	rest, err := io.Copy(io.Discard, body)
+
+	return buf.Bytes(), n + rest, rest > 0, err
+}
+
+// drain discards everything left in r so that the writer on the other side of the pipe never blocks.
+func drain(r io.Reader) {
+	_, _ = io.Copy(io.Discard, r)
+}
@@ -0,0 +1,116 @@
+package inspector
+
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>mit inspector</title>
+	<style>
+		body { font-family: sans-serif; margin: 0; display: flex; height: 100vh; }
+		#list { width: 40%; overflow-y: auto; border-right: 1px solid #ccc; }
+		#details { flex: 1; overflow-y: auto; padding: 0 1em; }
+		header { padding: 0.5em; border-bottom: 1px solid #ccc; }
+		.item { padding: 0.5em; border-bottom: 1px solid #eee; cursor: pointer; font-family: monospace; }
+		.item:hover, .item.selected { background: #eef; }
+		.error { color: #b00; }
+		pre { background: #f6f6f6; padding: 0.5em; white-space: pre-wrap; word-break: break-all; }
+	</style>
+</head>
+<body>
+	<div id="list">
+		<header>
+			<button onclick="load()">Refresh</button>
+			<button onclick="clearAll()">Clear</button>
+		</header>
+		<div id="items"></div>
+	</div>
+	<div id="details"><p>Select a request to see its details.</p></div>
+	<script>
+		let selected = null;
+
+		function text(tag, value, cls) {
+			const el = document.createElement(tag);
+			el.textContent = value;
+			if (cls) el.className = cls;
+			return el;
+		}
+
+		function decode(body) {
+			if (!body) return "";
+			try { return atob(body); } catch (e) { return body; }
+		}
+
+		function headers(h) {
+			return Object.entries(h || {}).map(([k, vs]) => vs.map(v => k + ": " + v).join("\n")).join("\n");
+		}
+
+		function summary(e) {
+			const status = e.response ? e.response.status_code : (e.error ? "ERR" : "...");
+			return status + " " + e.request.method + " " + e.request.url + (e.replay_of ? " (replay of #" + e.replay_of + ")" : "");
+		}
+
+		async function load() {
+			const resp = await fetch("/api/requests");
+			const exchanges = await resp.json();
+			const items = document.getElementById("items");
+			items.replaceChildren();
+			for (const e of exchanges) {
+				const el = text("div", "#" + e.id + " " + summary(e), "item" + (e.id === selected ? " selected" : ""));
+				el.onclick = () => show(e.id);
+				items.appendChild(el);
+			}
+		}
+
+		async function show(id) {
+			selected = id;
+			const resp = await fetch("/api/requests/" + id);
+			const details = document.getElementById("details");
+			details.replaceChildren();
+			if (!resp.ok) {
+				details.appendChild(text("p", await resp.text(), "error"));
+				return;
+			}
+			const e = await resp.json();
+			const replay = text("button", "Replay");
+			replay.onclick = () => replayRequest(id);
+			details.append(
+				text("h3", "#" + e.id + " " + summary(e)),
+				text("p", "From " + e.client_ip + " at " + e.started_at + ", took " + (e.duration / 1e6).toFixed(1) + "ms"),
+				replay,
+				text("h4", "Request"),
+				text("pre", e.request.method + " " + e.request.url + " " + e.request.proto + "\nHost: " + e.request.host + "\n" + headers(e.request.header)),
+				text("pre", decode(e.request.body) + (e.request.body_truncated ? "\n... (" + e.request.body_size + " bytes in total)" : "")),
+				text("h4", "Response"),
+			);
+			if (e.error) details.appendChild(text("p", e.error, "error"));
+			if (e.response) {
+				details.append(
+					text("pre", e.response.proto + " " + e.response.status + "\n" + headers(e.response.header)),
+					text("pre", decode(e.response.body) + (e.response.body_truncated ? "\n... (" + e.response.body_size + " bytes in total)" : "")),
+				);
+			}
+			load();
+		}
+
+		async function replayRequest(id) {
+			const resp = await fetch("/api/requests/" + id + "/replay", { method: "POST" });
+			if (!resp.ok) {
+				alert(await resp.text());
+				return;
+			}
+			const e = await resp.json();
+			show(e.id);
+		}
+
+		async function clearAll() {
+			await fetch("/api/requests", { method: "DELETE" });
+			selected = null;
+			document.getElementById("details").replaceChildren(text("p", "Select a request to see its details."));
+			load();
+		}
+
+		load();
+		setInterval(load, 2000);
+	</script>
+</body>
+</html>`
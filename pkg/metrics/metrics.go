@@ -0,0 +1,101 @@
+// Package metrics collects runtime metrics of the MIT server and exposes them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds all metrics exposed by the MIT server, together with the Go runtime and process metrics.
+var registry = newRegistry()
+
+var (
+	// ControlConnections tracks active control connections per tunnel type.
+	ControlConnections = promauto.With(registry).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mit_control_connections",
+		Help: "Number of active control connections by tunnel type.",
+	}, []string{"type"})
+
+	// PendingRequests tracks connection requests sent to clients that are waiting for a reverse connection.
+	PendingRequests = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Name: "mit_pending_connection_requests",
+		Help: "Number of connection requests waiting for a reverse connection from the client.",
+	})
+
+	// EdgeRequests counts HTTP requests received by the HTTP edge.
+	EdgeRequests = promauto.With(registry).NewCounter(prometheus.CounterOpts{
+		Name: "mit_edge_http_requests_total",
+		Help: "Total number of HTTP requests received by the HTTP edge.",
+	})
+
+	// EdgeErrors counts error responses generated by the HTTP edge itself, partitioned by status code.
+	EdgeErrors = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "mit_edge_http_errors_total",
+		Help: "Total number of error responses produced by the HTTP edge by status code.",
+	}, []string{"code"})
+
+	// TCPPortsTotal is the size of the TCP edge port pool.
+	TCPPortsTotal = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Name: "mit_tcp_port_pool_size",
+		Help: "Total number of ports in the TCP edge port pool.",
+	})
+
+	// TCPPortsAvailable is the number of unallocated ports in the TCP edge port pool.
+	TCPPortsAvailable = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Name: "mit_tcp_ports_available",
+		Help: "Number of unallocated ports in the TCP edge port pool.",
+	})
+
+	// UDPPortsTotal is the size of the UDP edge port pool.
+	UDPPortsTotal = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Name: "mit_udp_port_pool_size",
+		Help: "Total number of ports in the UDP edge port pool.",
+	})
+
+	// UDPPortsAvailable is the number of unallocated ports in the UDP edge port pool.
+	UDPPortsAvailable = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Name: "mit_udp_ports_available",
+		Help: "Number of unallocated ports in the UDP edge port pool.",
+	})
+
+	// UDPSessions tracks the UDP sessions of end users, one per source address, served by the UDP edge.
+	UDPSessions = promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Name: "mit_udp_sessions",
+		Help: "Number of active UDP sessions served by the UDP edge.",
+	})
+
+	// AuthVerifyDuration measures the latency of token verification against the auth repository.
+	AuthVerifyDuration = promauto.With(registry).NewHistogram(prometheus.HistogramOpts{
+		Name:    "mit_auth_verify_duration_seconds",
+		Help:    "Latency of token verification against the auth repository.",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	})
+
+	// PipedBytes counts bytes proxied between end users and tunnel clients by direction.
+	// "inbound" is traffic from end users to clients, "outbound" is traffic from clients to end users.
+	PipedBytes = promauto.With(registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "mit_piped_bytes_total",
+		Help: "Total number of bytes proxied between end users and tunnel clients by direction.",
+	}, []string{"direction"})
+)
+
+// newRegistry creates the registry of the server metrics with the Go runtime and process collectors registered.
+func newRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return r
+}
+
+// Handler returns an HTTP handler that serves all server metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
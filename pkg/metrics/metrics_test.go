@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	EdgeErrors.WithLabelValues("502").Inc()
+	ControlConnections.WithLabelValues("web").Set(2)
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rr.Body.String(), `mit_control_connections{type="web"} 2`)
+	assert.Contains(t, rr.Body.String(), "# TYPE mit_edge_http_errors_total counter")
+	assert.Contains(t, rr.Body.String(), "# TYPE mit_auth_verify_duration_seconds histogram")
+	assert.Contains(t, rr.Body.String(), "go_goroutines")
+}
+
+func TestRegistry_Lint(t *testing.T) {
+	problems, err := testutil.GatherAndLint(registry)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
@@ -3,7 +3,11 @@ package auth
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/core"
@@ -13,22 +17,60 @@ import (
 )
 
 const (
-	scryptPrefix = "sc:"
-	apiKeyPrefix = "API_KEY::"
+	scryptPrefix     = "sc:"
+	apiKeyPrefix     = "API_KEY::"
+	policyPrefix     = "TOKEN_POLICY::"
+	usagePrefix      = "USAGE::"
+	subdomainPrefix  = "SUBDOMAIN::"
+	domainPrefix     = "DOMAIN::"
+	domainsPrefix    = "TOKEN_DOMAINS::"
+	subdomainsPrefix = "TOKEN_SUBDOMAINS::"
+	metaPrefix       = "TOKEN_META::"
+	tokenIndexKey    = "TOKENS"
+	inboundField     = "inbound"
+	outboundField    = "outbound"
+
+	// missingKeyTTL is the TTL reported by Redis for keys that do not exist.
+	missingKeyTTL = -2
 )
 
+// Supported values of Config.Backend.
+const (
+	BackendRedis  = "redis"
+	BackendFile   = "file"
+	BackendStatic = "static"
+)
+
+// Config selects and configures the token storage.
+// Backend is one of BackendRedis (default), BackendFile or BackendStatic.
+// Path is the location of the token file used by the file backend.
+// Tokens lists the tokens served by the static backend.
 type Config struct {
-	RedisAddr string `mapstructure:"redis_addr"`
-	Password  string `mapstructure:"redis_password"` // #nosec G117 -- This is a config field name, not an exposed password
-	KeyPrefix string `mapstructure:"key_prefix"`
-	Salt      string `mapstructure:"salt"`
+	Backend   string        `mapstructure:"backend"`
+	RedisAddr string        `mapstructure:"redis_addr"`
+	Password  string        `mapstructure:"redis_password"` // #nosec G117 -- This is a config field name, not an exposed password
+	KeyPrefix string        `mapstructure:"key_prefix"`
+	Salt      string        `mapstructure:"salt"`
+	Path      string        `mapstructure:"path"`
+	Tokens    []StaticToken `mapstructure:"tokens"`
 }
 
 type Redis interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
 	Exists(ctx context.Context, keys ...string) *redis.IntCmd
 	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	Persist(ctx context.Context, key string) *redis.BoolCmd
+	PTTL(ctx context.Context, key string) *redis.DurationCmd
+	HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	ZAdd(ctx context.Context, key string, members ...redis.Z) *redis.IntCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	ZRemRangeByScore(ctx context.Context, key, lower, upper string) *redis.IntCmd
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
 	Ping(ctx context.Context) *redis.StatusCmd
 	Close() error
 }
@@ -39,6 +81,21 @@ type Repo struct {
 	salt      []byte
 }
 
+// NewBackend creates the authentication repository selected by cfg.Backend.
+// Returns an error if the backend is unknown or its configuration is invalid.
+func NewBackend(cfg *Config) (core.AuthRepo, error) {
+	switch cfg.Backend {
+	case "", BackendRedis:
+		return New(cfg), nil
+	case BackendFile:
+		return NewFileRepo(cfg)
+	case BackendStatic:
+		return NewStaticRepo(cfg)
+	default:
+		return nil, fmt.Errorf("unknown auth backend: %s", cfg.Backend)
+	}
+}
+
 // New creates and initializes a new Repo instance with the provided configuration.
 // It sets up a Redis client using the given Redis address, password, and key prefix from the Config struct.
 // Returns a pointer to the initialized Repo. Assumes valid Config is provided and may panic on misconfiguration.
@@ -116,7 +173,8 @@ func (r *Repo) Verify(ctx context.Context, keyIDWithSuffix, secret string) (*tok
 // SaveToken saves a token to the database with a hashed secret and specified TTL.
 // It generates a hashed secret using the token's Secret and the Repo's salt.
 // The stored value format is: sc:<hash>
-// The token is stored using its base ID (without type suffix).
+// The token is stored using its base ID (without type suffix), together with its metadata and policy,
+// and is added to the index of tokens used for listing.
 // Returns an error if hashing fails, or if the database operation encounters an issue.
 // Returns core.ErrDuplicateTokenID if a token with the same ID already exists.
 func (r *Repo) SaveToken(ctx context.Context, t *token.Token) error {
@@ -135,13 +193,574 @@ func (r *Repo) SaveToken(ctx context.Context, t *token.Token) error {
 		return core.ErrDuplicateTokenID
 	}
 
+	err = r.saveInfo(ctx, t)
+	if err == nil && !t.Policy.IsZero() {
+		err = r.savePolicy(ctx, t)
+	}
+
+	if err != nil {
+		// Do not leave a token behind that would be served without its restrictions.
+		if delErr := r.db.Del(ctx, r.keyPrefix+apiKeyPrefix+t.ID).Err(); delErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to roll back token: %w", delErr))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// saveInfo stores the metadata of the token as JSON with the same TTL as the token itself and adds the token
+// to the index, scored by its expiration time so that expired tokens can be dropped from it.
+// Returns an error if the metadata cannot be encoded or the database operation fails.
+func (r *Repo) saveInfo(ctx context.Context, t *token.Token) error {
+	return r.storeInfo(ctx, token.NewInfo(t, time.Now()), t.TTL)
+}
+
+// storeInfo stores the token metadata info with the given TTL, 0 means it never expires, and indexes the token
+// by its expiration time.
+// Returns an error if the metadata cannot be encoded or the database operation fails.
+func (r *Repo) storeInfo(ctx context.Context, info token.Info, ttl time.Duration) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode token metadata: %w", err)
+	}
+
+	if err := r.db.Set(ctx, r.keyPrefix+metaPrefix+info.ID, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save token metadata: %w", err)
+	}
+
+	score := math.Inf(1)
+	if !info.ExpiresAt.IsZero() {
+		score = float64(info.ExpiresAt.Unix())
+	}
+
+	if err := r.db.ZAdd(ctx, r.keyPrefix+tokenIndexKey, redis.Z{Score: score, Member: info.ID}).Err(); err != nil {
+		return fmt.Errorf("failed to index token: %w", err)
+	}
+
+	return nil
+}
+
+// SetTokenTTL changes the expiration of the token identified by keyID to ttl from now, keeping its secret.
+// A ttl of 0 makes the token never expire. The policy, metadata and subdomain reservations of the token follow
+// the new expiration.
+// Tokens saved before metadata was recorded get metadata with their ID and expiration only.
+// Returns the updated metadata, core.ErrTokenNotFound if the token does not exist, or an error if the database
+// operation fails.
+func (r *Repo) SetTokenTTL(ctx context.Context, keyID string, ttl time.Duration) (token.Info, error) {
+	info, err := r.GetTokenInfo(ctx, keyID)
+	if err != nil {
+		return token.Info{}, err
+	}
+
+	ok, err := r.setKeyTTL(ctx, r.keyPrefix+apiKeyPrefix+keyID, ttl)
+
+	switch {
+	case err != nil:
+		return token.Info{}, fmt.Errorf("failed to update token expiration: %w", err)
+	case !ok:
+		return token.Info{}, core.ErrTokenNotFound
+	}
+
+	if _, err := r.setKeyTTL(ctx, r.keyPrefix+policyPrefix+keyID, ttl); err != nil {
+		return token.Info{}, fmt.Errorf("failed to update token policy expiration: %w", err)
+	}
+
+	if err := r.setSubdomainsTTL(ctx, keyID, ttl); err != nil {
+		return token.Info{}, err
+	}
+
+	info.ExpiresAt = time.Time{}
+	if ttl > 0 {
+		info.ExpiresAt = time.Now().Add(ttl).UTC()
+	}
+
+	if err := r.storeInfo(ctx, info, ttl); err != nil {
+		return token.Info{}, err
+	}
+
+	return info, nil
+}
+
+// setSubdomainsTTL sets the TTL of the subdomain reservations of the token keyID, 0 removes the expiration.
+// Returns an error if the database operation fails.
+func (r *Repo) setSubdomainsTTL(ctx context.Context, keyID string, ttl time.Duration) error {
+	index := r.keyPrefix + subdomainsPrefix + keyID
+
+	labels, err := r.db.ZRange(ctx, index, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list subdomains: %w", err)
+	}
+
+	keys := []string{index}
+	for _, label := range labels {
+		keys = append(keys, r.keyPrefix+subdomainPrefix+label)
+	}
+
+	for _, key := range keys {
+		if _, err := r.setKeyTTL(ctx, key, ttl); err != nil {
+			return fmt.Errorf("failed to update subdomain expiration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// setKeyTTL sets the TTL of key, 0 removes the expiration.
+// Returns false if the key does not exist.
+func (r *Repo) setKeyTTL(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl > 0 {
+		return r.db.Expire(ctx, key, ttl).Result()
+	}
+
+	// PERSIST reports false for keys without expiration too, so existence is checked separately.
+	if err := r.db.Persist(ctx, key).Err(); err != nil {
+		return false, err
+	}
+
+	n, err := r.db.Exists(ctx, key).Result()
+
+	return n > 0, err
+}
+
+// ListTokens returns the metadata of all stored tokens.
+// Expired tokens are dropped from the index first. Tokens saved before metadata was recorded are not listed.
+// Returns an error if the database operation fails or stored metadata cannot be decoded.
+func (r *Repo) ListTokens(ctx context.Context) ([]token.Info, error) {
+	index := r.keyPrefix + tokenIndexKey
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	if err := r.db.ZRemRangeByScore(ctx, index, "-inf", "("+now).Err(); err != nil {
+		return nil, fmt.Errorf("failed to drop expired tokens: %w", err)
+	}
+
+	ids, err := r.db.ZRange(ctx, index, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return []token.Info{}, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.keyPrefix + metaPrefix + id
+	}
+
+	values, err := r.db.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token metadata: %w", err)
+	}
+
+	tokens := make([]token.Info, 0, len(values))
+
+	for _, v := range values {
+		// The metadata expires together with the token, so it may be gone before the index is cleaned up.
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		var info token.Info
+		if err := json.Unmarshal([]byte(data), &info); err != nil {
+			return nil, fmt.Errorf("failed to decode token metadata: %w", err)
+		}
+
+		tokens = append(tokens, info)
+	}
+
+	return tokens, nil
+}
+
+// GetTokenInfo returns the metadata of the token identified by keyID.
+// Tokens saved before metadata was recorded are returned with their ID only.
+// Returns core.ErrTokenNotFound if the token does not exist, or an error if the database operation fails
+// or the stored metadata cannot be decoded.
+func (r *Repo) GetTokenInfo(ctx context.Context, keyID string) (token.Info, error) {
+	data, err := r.db.Get(ctx, r.keyPrefix+metaPrefix+keyID).Result()
+
+	switch {
+	case errors.Is(err, redis.Nil):
+		exists, err := r.tokenExists(ctx, keyID)
+		if err != nil {
+			return token.Info{}, err
+		}
+
+		if !exists {
+			return token.Info{}, core.ErrTokenNotFound
+		}
+
+		return token.Info{ID: keyID}, nil
+	case err != nil:
+		return token.Info{}, fmt.Errorf("failed to get token metadata: %w", err)
+	}
+
+	var info token.Info
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		return token.Info{}, fmt.Errorf("failed to decode token metadata: %w", err)
+	}
+
+	return info, nil
+}
+
+// savePolicy stores the policy of the token as JSON with the same TTL as the token itself.
+// Returns an error if the policy cannot be encoded or the database operation fails.
+func (r *Repo) savePolicy(ctx context.Context, t *token.Token) error {
+	data, err := json.Marshal(t.Policy)
+	if err != nil {
+		return fmt.Errorf("failed to encode token policy: %w", err)
+	}
+
+	if err := r.db.Set(ctx, r.keyPrefix+policyPrefix+t.ID, data, t.TTL).Err(); err != nil {
+		return fmt.Errorf("failed to save token policy: %w", err)
+	}
+
 	return nil
 }
 
-// DeleteToken removes a token identified by tokenID from the database using the configured key prefix.
+// GetTokenPolicy retrieves the policy stored for the token identified by keyID.
+// Returns an empty policy if the token has no restrictions or does not exist.
+// Returns an error if the database operation fails or the stored policy cannot be decoded.
+func (r *Repo) GetTokenPolicy(ctx context.Context, keyID string) (token.Policy, error) {
+	var policy token.Policy
+
+	res := r.db.Get(ctx, r.keyPrefix+policyPrefix+keyID)
+
+	switch {
+	case errors.Is(res.Err(), redis.Nil):
+		return policy, nil
+	case res.Err() != nil:
+		return policy, fmt.Errorf("failed to get token policy: %w", res.Err())
+	}
+
+	if err := json.Unmarshal([]byte(res.Val()), &policy); err != nil {
+		return policy, fmt.Errorf("failed to decode token policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+// AddTrafficUsage adds usage to the lifetime traffic counters of keyID.
+// If period is positive, usage is also added to the counters of the current quota window,
+// which expire together with the window.
+// Returns an error if the database operation fails.
+func (r *Repo) AddTrafficUsage(ctx context.Context, keyID string, usage core.TrafficUsage, period time.Duration) error {
+	_, err := r.db.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		keys := []string{r.keyPrefix + usagePrefix + keyID}
+
+		if period > 0 {
+			keys = append(keys, r.usageWindowKey(keyID, period))
+		}
+
+		for _, key := range keys {
+			pipe.HIncrBy(ctx, key, inboundField, usage.Inbound)
+			pipe.HIncrBy(ctx, key, outboundField, usage.Outbound)
+		}
+
+		if period > 0 {
+			pipe.Expire(ctx, keys[1], period)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add traffic usage: %w", err)
+	}
+
+	return nil
+}
+
+// GetTrafficUsage returns the traffic proxied for keyID within the current quota window of the given period.
+// If period is 0, the lifetime traffic counters are returned.
+// Returns an error if the database operation fails or the stored counters are malformed.
+func (r *Repo) GetTrafficUsage(ctx context.Context, keyID string, period time.Duration) (core.TrafficUsage, error) {
+	key := r.keyPrefix + usagePrefix + keyID
+	if period > 0 {
+		key = r.usageWindowKey(keyID, period)
+	}
+
+	res := r.db.HGetAll(ctx, key)
+	if res.Err() != nil {
+		return core.TrafficUsage{}, fmt.Errorf("failed to get traffic usage: %w", res.Err())
+	}
+
+	var (
+		usage core.TrafficUsage
+		err   error
+	)
+
+	if v, ok := res.Val()[inboundField]; ok {
+		if usage.Inbound, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return core.TrafficUsage{}, fmt.Errorf("invalid inbound traffic counter: %w", err)
+		}
+	}
+
+	if v, ok := res.Val()[outboundField]; ok {
+		if usage.Outbound, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return core.TrafficUsage{}, fmt.Errorf("invalid outbound traffic counter: %w", err)
+		}
+	}
+
+	return usage, nil
+}
+
+// usageWindowKey returns the key of the traffic counters for the quota window of the given period
+// that contains the current time.
+func (r *Repo) usageWindowKey(keyID string, period time.Duration) string {
+	start := windowStart(time.Now(), period).Unix()
+
+	return r.keyPrefix + usagePrefix + keyID + "::" + strconv.FormatInt(start, 10)
+}
+
+// windowStart returns the start of the quota window of the given period that contains t.
+// Windows are aligned to multiples of period since the zero time, so daily windows start at midnight UTC.
+func windowStart(t time.Time, period time.Duration) time.Time {
+	return t.UTC().Truncate(period)
+}
+
+// ReserveSubdomain reserves the subdomain label for the token keyID.
+// The reservation expires together with the token and is dropped when the token is deleted, so the label keeps
+// resolving to the same tunnel across reconnects while the token exists.
+// Returns core.ErrSubdomainTaken if the label is reserved by another existing token or is the ID of another token,
+// core.ErrTokenNotFound if the token keyID does not exist, or an error if the database operation fails.
+func (r *Repo) ReserveSubdomain(ctx context.Context, label, keyID string) error {
+	if label != keyID {
+		taken, err := r.tokenExists(ctx, label)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return core.ErrSubdomainTaken
+		}
+	}
+
+	ttl, err := r.tokenTTL(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	key := r.keyPrefix + subdomainPrefix + label
+
+	reserved, err := r.db.SetNX(ctx, key, keyID, ttl).Result()
+	if err != nil {
+		return fmt.Errorf("failed to reserve subdomain: %w", err)
+	}
+
+	if !reserved {
+		owner, err := r.db.Get(ctx, key).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to get subdomain owner: %w", err)
+		}
+
+		if err := r.takeOverSubdomain(ctx, key, label, owner, keyID, ttl); err != nil {
+			return err
+		}
+	}
+
+	index := r.keyPrefix + subdomainsPrefix + keyID
+
+	if err := r.db.ZAdd(ctx, index, redis.Z{Member: label}).Err(); err != nil {
+		return fmt.Errorf("failed to add subdomain to token: %w", err)
+	}
+
+	if _, err := r.setKeyTTL(ctx, index, ttl); err != nil {
+		return fmt.Errorf("failed to update subdomains expiration: %w", err)
+	}
+
+	return nil
+}
+
+// takeOverSubdomain stores keyID as the owner of the subdomain label stored at key with the given ttl, provided
+// the label is already reserved by keyID or the token of the previous owner is gone.
+// Returns core.ErrSubdomainTaken if the previous owner still exists.
+func (r *Repo) takeOverSubdomain(ctx context.Context, key, label, owner, keyID string, ttl time.Duration) error {
+	if owner != "" && owner != keyID {
+		taken, err := r.tokenExists(ctx, owner)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return core.ErrSubdomainTaken
+		}
+
+		if err := r.db.ZRem(ctx, r.keyPrefix+subdomainsPrefix+owner, label).Err(); err != nil {
+			return fmt.Errorf("failed to drop subdomain from previous owner: %w", err)
+		}
+	}
+
+	// Reservations of the same token are refreshed, so that they follow the expiration of the token.
+	if err := r.db.Set(ctx, key, keyID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to reserve subdomain: %w", err)
+	}
+
+	return nil
+}
+
+// tokenTTL returns the time left before the token keyID expires, 0 if it never expires.
+// Returns core.ErrTokenNotFound if the token does not exist, or an error if the database operation fails.
+func (r *Repo) tokenTTL(ctx context.Context, keyID string) (time.Duration, error) {
+	ttl, err := r.db.PTTL(ctx, r.keyPrefix+apiKeyPrefix+keyID).Result()
+
+	switch {
+	case err != nil:
+		return 0, fmt.Errorf("failed to get token expiration: %w", err)
+	case ttl == missingKeyTTL:
+		return 0, core.ErrTokenNotFound
+	case ttl < 0:
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// ResolveSubdomain returns the keyID of the token that reserved the subdomain label,
+// or an empty string if the label is not reserved.
+// Returns an error if the database operation fails.
+func (r *Repo) ResolveSubdomain(ctx context.Context, label string) (string, error) {
+	keyID, err := r.db.Get(ctx, r.keyPrefix+subdomainPrefix+label).Result()
+
+	switch {
+	case errors.Is(err, redis.Nil):
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("failed to resolve subdomain: %w", err)
+	}
+
+	return keyID, nil
+}
+
+// AttachDomain attaches the custom domain to the token keyID.
+// Like subdomain reservations, the domain stays attached until it is detached or the token is gone.
+// Returns core.ErrDomainTaken if the domain is attached to another existing token,
+// or an error if the database operation fails.
+func (r *Repo) AttachDomain(ctx context.Context, domain, keyID string) error {
+	key := r.keyPrefix + domainPrefix + domain
+
+	attached, err := r.db.SetNX(ctx, key, keyID, 0).Result()
+	if err != nil {
+		return fmt.Errorf("failed to attach domain: %w", err)
+	}
+
+	if !attached {
+		owner, err := r.db.Get(ctx, key).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to get domain owner: %w", err)
+		}
+
+		if owner != keyID {
+			if err := r.takeOverDomain(ctx, key, domain, owner, keyID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := r.db.ZAdd(ctx, r.keyPrefix+domainsPrefix+keyID, redis.Z{Member: domain}).Err(); err != nil {
+		return fmt.Errorf("failed to add domain to token: %w", err)
+	}
+
+	return nil
+}
+
+// takeOverDomain moves the domain stored at key from owner to keyID, provided the token of owner is gone.
+// Returns core.ErrDomainTaken if the owner still exists.
+func (r *Repo) takeOverDomain(ctx context.Context, key, domain, owner, keyID string) error {
+	if owner != "" {
+		taken, err := r.tokenExists(ctx, owner)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return core.ErrDomainTaken
+		}
+
+		if err := r.db.ZRem(ctx, r.keyPrefix+domainsPrefix+owner, domain).Err(); err != nil {
+			return fmt.Errorf("failed to drop domain from previous owner: %w", err)
+		}
+	}
+
+	if err := r.db.Set(ctx, key, keyID, 0).Err(); err != nil {
+		return fmt.Errorf("failed to attach domain: %w", err)
+	}
+
+	return nil
+}
+
+// DetachDomain detaches the custom domain from the token keyID.
+// Returns core.ErrDomainNotFound if the domain is not attached to the token, or an error if the database operation fails.
+func (r *Repo) DetachDomain(ctx context.Context, domain, keyID string) error {
+	key := r.keyPrefix + domainPrefix + domain
+
+	owner, err := r.db.Get(ctx, key).Result()
+
+	switch {
+	case errors.Is(err, redis.Nil):
+		return core.ErrDomainNotFound
+	case err != nil:
+		return fmt.Errorf("failed to get domain owner: %w", err)
+	case owner != keyID:
+		return core.ErrDomainNotFound
+	}
+
+	if err := r.db.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to detach domain: %w", err)
+	}
+
+	if err := r.db.ZRem(ctx, r.keyPrefix+domainsPrefix+keyID, domain).Err(); err != nil {
+		return fmt.Errorf("failed to drop domain from token: %w", err)
+	}
+
+	return nil
+}
+
+// ResolveDomain returns the keyID of the token the custom domain is attached to,
+// or an empty string if the domain is not attached.
+// Returns an error if the database operation fails.
+func (r *Repo) ResolveDomain(ctx context.Context, domain string) (string, error) {
+	keyID, err := r.db.Get(ctx, r.keyPrefix+domainPrefix+domain).Result()
+
+	switch {
+	case errors.Is(err, redis.Nil):
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("failed to resolve domain: %w", err)
+	}
+
+	return keyID, nil
+}
+
+// ListDomains returns the custom domains attached to the token keyID, sorted by name.
+// Returns an error if the database operation fails.
+func (r *Repo) ListDomains(ctx context.Context, keyID string) ([]string, error) {
+	domains, err := r.db.ZRange(ctx, r.keyPrefix+domainsPrefix+keyID, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// tokenExists checks if a token with keyID is stored.
+func (r *Repo) tokenExists(ctx context.Context, keyID string) (bool, error) {
+	n, err := r.db.Exists(ctx, r.keyPrefix+apiKeyPrefix+keyID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token existence: %w", err)
+	}
+
+	return n > 0, nil
+}
+
+// DeleteToken removes a token identified by tokenID, its policy and metadata from the database using the configured
+// key prefix, and drops it from the index of tokens.
+// The subdomains reserved by the token and the custom domains attached to it are released.
+// Traffic counters are kept for accounting purposes.
 // It returns an error if the deletion operation fails.
 func (r *Repo) DeleteToken(ctx context.Context, tokenID string) error {
-	res := r.db.Del(ctx, r.keyPrefix+apiKeyPrefix+tokenID)
+	res := r.db.Del(ctx, r.keyPrefix+apiKeyPrefix+tokenID, r.keyPrefix+policyPrefix+tokenID, r.keyPrefix+metaPrefix+tokenID)
 
 	if res.Err() != nil {
 		return fmt.Errorf("failed to delete token: %w", res.Err())
@@ -151,6 +770,43 @@ func (r *Repo) DeleteToken(ctx context.Context, tokenID string) error {
 		return core.ErrTokenNotFound
 	}
 
+	if err := r.db.ZRem(ctx, r.keyPrefix+tokenIndexKey, tokenID).Err(); err != nil {
+		return fmt.Errorf("failed to drop token from index: %w", err)
+	}
+
+	return r.releaseNames(ctx, tokenID)
+}
+
+// releaseNames deletes the subdomain reservations and custom domain attachments of the token keyID.
+// Returns an error if the database operation fails.
+func (r *Repo) releaseNames(ctx context.Context, keyID string) error {
+	subdomains := r.keyPrefix + subdomainsPrefix + keyID
+	domains := r.keyPrefix + domainsPrefix + keyID
+
+	labels, err := r.db.ZRange(ctx, subdomains, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list subdomains: %w", err)
+	}
+
+	attached, err := r.db.ZRange(ctx, domains, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list domains: %w", err)
+	}
+
+	keys := []string{subdomains, domains}
+
+	for _, label := range labels {
+		keys = append(keys, r.keyPrefix+subdomainPrefix+label)
+	}
+
+	for _, domain := range attached {
+		keys = append(keys, r.keyPrefix+domainPrefix+domain)
+	}
+
+	if err := r.db.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to release subdomains and domains: %w", err)
+	}
+
 	return nil
 }
 
@@ -2,12 +2,16 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"math"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redismock/v9"
 	"github.com/ksysoev/make-it-public/pkg/core"
 	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -141,9 +145,20 @@ func TestRepo_SaveToken(t *testing.T) {
 			name: "successful token save",
 			mockSetup: func(m redismock.ClientMock) {
 				m.CustomMatch(matcher).ExpectSetNX(mock.Anything, mock.Anything, time.Minute).SetVal(true)
+				m.CustomMatch(matcher).ExpectSet(mock.Anything, mock.Anything, time.Minute).SetVal("OK")
+				m.CustomMatch(matcher).ExpectZAdd(mock.Anything, redis.Z{}).SetVal(1)
 			},
 			wantErr: nil,
 		},
+		{
+			name: "token rolled back when metadata cannot be saved",
+			mockSetup: func(m redismock.ClientMock) {
+				m.CustomMatch(matcher).ExpectSetNX(mock.Anything, mock.Anything, time.Minute).SetVal(true)
+				m.CustomMatch(matcher).ExpectSet(mock.Anything, mock.Anything, time.Minute).SetErr(assert.AnError)
+				m.ExpectDel("prefix::API_KEY::test-id").SetVal(1)
+			},
+			wantErr: assert.AnError,
+		},
 		{
 			name: "duplicate token ID",
 			mockSetup: func(m redismock.ClientMock) {
@@ -190,6 +205,530 @@ func TestRepo_SaveToken(t *testing.T) {
 	}
 }
 
+func TestRepo_SaveToken_WithPolicy(t *testing.T) {
+	policy := token.Policy{Quota: &token.Quota{Bytes: 1024, Period: time.Hour}}
+	policyJSON := `{"quota":{"bytes":1024,"period":3600000000000}}`
+
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+	}{
+		{
+			name: "policy saved with token TTL",
+			mockSetup: func(m redismock.ClientMock) {
+				m.CustomMatch(func(_, _ []interface{}) error { return nil }).
+					ExpectSetNX("prefix::API_KEY::test-id", mock.Anything, time.Minute).SetVal(true)
+				m.CustomMatch(func(_, _ []interface{}) error { return nil }).
+					ExpectSet("prefix::TOKEN_META::test-id", mock.Anything, time.Minute).SetVal("OK")
+				m.CustomMatch(func(_, _ []interface{}) error { return nil }).
+					ExpectZAdd("prefix::TOKENS", redis.Z{}).SetVal(1)
+				m.ExpectSet("prefix::TOKEN_POLICY::test-id", []byte(policyJSON), time.Minute).SetVal("OK")
+			},
+		},
+		{
+			name: "token rolled back when policy cannot be saved",
+			mockSetup: func(m redismock.ClientMock) {
+				m.CustomMatch(func(_, _ []interface{}) error { return nil }).
+					ExpectSetNX("prefix::API_KEY::test-id", mock.Anything, time.Minute).SetVal(true)
+				m.CustomMatch(func(_, _ []interface{}) error { return nil }).
+					ExpectSet("prefix::TOKEN_META::test-id", mock.Anything, time.Minute).SetVal("OK")
+				m.CustomMatch(func(_, _ []interface{}) error { return nil }).
+					ExpectZAdd("prefix::TOKENS", redis.Z{}).SetVal(1)
+				m.ExpectSet("prefix::TOKEN_POLICY::test-id", []byte(policyJSON), time.Minute).SetErr(assert.AnError)
+				m.ExpectDel("prefix::API_KEY::test-id").SetVal(1)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+				salt:      []byte("test-salt"),
+			}
+
+			err := r.SaveToken(context.Background(), &token.Token{
+				ID:     "test-id",
+				Secret: "test-secret",
+				TTL:    time.Minute,
+				Type:   token.TokenTypeWeb,
+				Policy: policy,
+			})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.NoError(t, mockRDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_GetTokenPolicy(t *testing.T) {
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+		want      token.Policy
+	}{
+		{
+			name: "stored policy",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_POLICY::key1").SetVal(`{"quota":{"bytes":10,"period":60000000000}}`)
+			},
+			want: token.Policy{Quota: &token.Quota{Bytes: 10, Period: time.Minute}},
+		},
+		{
+			name: "no policy",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_POLICY::key1").RedisNil()
+			},
+		},
+		{
+			name: "malformed policy",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_POLICY::key1").SetVal(`{`)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "redis error",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_POLICY::key1").SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			got, err := r.GetTokenPolicy(context.Background(), "key1")
+
+			if tt.wantErr != nil {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepo_AddTrafficUsage(t *testing.T) {
+	rdb, mockRDB := redismock.NewClientMock()
+	usage := core.TrafficUsage{Inbound: 10, Outbound: 20}
+
+	mockRDB.ExpectHIncrBy("prefix::USAGE::key1", "inbound", 10).SetVal(10)
+	mockRDB.ExpectHIncrBy("prefix::USAGE::key1", "outbound", 20).SetVal(20)
+
+	r := &Repo{
+		db:        rdb,
+		keyPrefix: "prefix::",
+	}
+
+	require.NoError(t, r.AddTrafficUsage(context.Background(), "key1", usage, 0))
+	assert.NoError(t, mockRDB.ExpectationsWereMet())
+
+	windowKey := r.usageWindowKey("key1", time.Hour)
+
+	mockRDB.ExpectHIncrBy("prefix::USAGE::key1", "inbound", 10).SetVal(20)
+	mockRDB.ExpectHIncrBy("prefix::USAGE::key1", "outbound", 20).SetVal(40)
+	mockRDB.ExpectHIncrBy(windowKey, "inbound", 10).SetVal(10)
+	mockRDB.ExpectHIncrBy(windowKey, "outbound", 20).SetVal(20)
+	mockRDB.ExpectExpire(windowKey, time.Hour).SetVal(true)
+
+	require.NoError(t, r.AddTrafficUsage(context.Background(), "key1", usage, time.Hour))
+	assert.NoError(t, mockRDB.ExpectationsWereMet())
+
+	mockRDB.ExpectHIncrBy("prefix::USAGE::key1", "inbound", 10).SetErr(assert.AnError)
+
+	assert.ErrorIs(t, r.AddTrafficUsage(context.Background(), "key1", usage, 0), assert.AnError)
+}
+
+func TestRepo_GetTrafficUsage(t *testing.T) {
+	rdb, mockRDB := redismock.NewClientMock()
+
+	r := &Repo{
+		db:        rdb,
+		keyPrefix: "prefix::",
+	}
+
+	mockRDB.ExpectHGetAll("prefix::USAGE::key1").SetVal(map[string]string{"inbound": "10", "outbound": "20"})
+
+	usage, err := r.GetTrafficUsage(context.Background(), "key1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, core.TrafficUsage{Inbound: 10, Outbound: 20}, usage)
+
+	mockRDB.ExpectHGetAll(r.usageWindowKey("key1", time.Hour)).SetVal(map[string]string{})
+
+	usage, err = r.GetTrafficUsage(context.Background(), "key1", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, usage)
+
+	mockRDB.ExpectHGetAll("prefix::USAGE::key1").SetVal(map[string]string{"inbound": "x"})
+
+	_, err = r.GetTrafficUsage(context.Background(), "key1", 0)
+	assert.ErrorContains(t, err, "invalid inbound traffic counter")
+
+	mockRDB.ExpectHGetAll("prefix::USAGE::key1").SetErr(assert.AnError)
+
+	_, err = r.GetTrafficUsage(context.Background(), "key1", 0)
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestRepo_ReserveSubdomain(t *testing.T) {
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+	}{
+		{
+			name: "free subdomain",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(0)
+				m.ExpectPTTL("prefix::API_KEY::key1").SetVal(time.Hour)
+				m.ExpectSetNX("prefix::SUBDOMAIN::demo", "key1", time.Hour).SetVal(true)
+				m.ExpectZAdd("prefix::TOKEN_SUBDOMAINS::key1", redis.Z{Member: "demo"}).SetVal(1)
+				m.ExpectExpire("prefix::TOKEN_SUBDOMAINS::key1", time.Hour).SetVal(true)
+			},
+		},
+		{
+			name: "token without expiration",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(0)
+				m.ExpectPTTL("prefix::API_KEY::key1").SetVal(-1)
+				m.ExpectSetNX("prefix::SUBDOMAIN::demo", "key1", 0).SetVal(true)
+				m.ExpectZAdd("prefix::TOKEN_SUBDOMAINS::key1", redis.Z{Member: "demo"}).SetVal(1)
+				m.ExpectPersist("prefix::TOKEN_SUBDOMAINS::key1").SetVal(false)
+				m.ExpectExists("prefix::TOKEN_SUBDOMAINS::key1").SetVal(1)
+			},
+		},
+		{
+			name: "reserved by the same token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(0)
+				m.ExpectPTTL("prefix::API_KEY::key1").SetVal(time.Hour)
+				m.ExpectSetNX("prefix::SUBDOMAIN::demo", "key1", time.Hour).SetVal(false)
+				m.ExpectGet("prefix::SUBDOMAIN::demo").SetVal("key1")
+				m.ExpectSet("prefix::SUBDOMAIN::demo", "key1", time.Hour).SetVal("OK")
+				m.ExpectZAdd("prefix::TOKEN_SUBDOMAINS::key1", redis.Z{Member: "demo"}).SetVal(0)
+				m.ExpectExpire("prefix::TOKEN_SUBDOMAINS::key1", time.Hour).SetVal(true)
+			},
+		},
+		{
+			name: "reserved by another token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(0)
+				m.ExpectPTTL("prefix::API_KEY::key1").SetVal(time.Hour)
+				m.ExpectSetNX("prefix::SUBDOMAIN::demo", "key1", time.Hour).SetVal(false)
+				m.ExpectGet("prefix::SUBDOMAIN::demo").SetVal("key2")
+				m.ExpectExists("prefix::API_KEY::key2").SetVal(1)
+			},
+			wantErr: core.ErrSubdomainTaken,
+		},
+		{
+			name: "reserved by a deleted token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(0)
+				m.ExpectPTTL("prefix::API_KEY::key1").SetVal(time.Hour)
+				m.ExpectSetNX("prefix::SUBDOMAIN::demo", "key1", time.Hour).SetVal(false)
+				m.ExpectGet("prefix::SUBDOMAIN::demo").SetVal("key2")
+				m.ExpectExists("prefix::API_KEY::key2").SetVal(0)
+				m.ExpectZRem("prefix::TOKEN_SUBDOMAINS::key2", "demo").SetVal(1)
+				m.ExpectSet("prefix::SUBDOMAIN::demo", "key1", time.Hour).SetVal("OK")
+				m.ExpectZAdd("prefix::TOKEN_SUBDOMAINS::key1", redis.Z{Member: "demo"}).SetVal(1)
+				m.ExpectExpire("prefix::TOKEN_SUBDOMAINS::key1", time.Hour).SetVal(true)
+			},
+		},
+		{
+			name: "ID of another token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(1)
+			},
+			wantErr: core.ErrSubdomainTaken,
+		},
+		{
+			name: "token does not exist",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(0)
+				m.ExpectPTTL("prefix::API_KEY::key1").SetVal(-2)
+			},
+			wantErr: core.ErrTokenNotFound,
+		},
+		{
+			name: "redis error",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectExists("prefix::API_KEY::demo").SetVal(0)
+				m.ExpectPTTL("prefix::API_KEY::key1").SetVal(time.Hour)
+				m.ExpectSetNX("prefix::SUBDOMAIN::demo", "key1", time.Hour).SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			err := r.ReserveSubdomain(context.Background(), "demo", "key1")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mockRDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_ResolveSubdomain(t *testing.T) {
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+		want      string
+	}{
+		{
+			name: "reserved subdomain",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::SUBDOMAIN::demo").SetVal("key1")
+			},
+			want: "key1",
+		},
+		{
+			name: "not reserved",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::SUBDOMAIN::demo").RedisNil()
+			},
+		},
+		{
+			name: "redis error",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::SUBDOMAIN::demo").SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			got, err := r.ResolveSubdomain(context.Background(), "demo")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepo_AttachDomain(t *testing.T) {
+	const (
+		domainKey = "prefix::DOMAIN::app.example.com"
+		domain    = "app.example.com"
+	)
+
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+	}{
+		{
+			name: "free domain",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectSetNX(domainKey, "key1", 0).SetVal(true)
+				m.ExpectZAdd("prefix::TOKEN_DOMAINS::key1", redis.Z{Member: domain}).SetVal(1)
+			},
+		},
+		{
+			name: "attached to the same token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectSetNX(domainKey, "key1", 0).SetVal(false)
+				m.ExpectGet(domainKey).SetVal("key1")
+				m.ExpectZAdd("prefix::TOKEN_DOMAINS::key1", redis.Z{Member: domain}).SetVal(0)
+			},
+		},
+		{
+			name: "attached to another token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectSetNX(domainKey, "key1", 0).SetVal(false)
+				m.ExpectGet(domainKey).SetVal("key2")
+				m.ExpectExists("prefix::API_KEY::key2").SetVal(1)
+			},
+			wantErr: core.ErrDomainTaken,
+		},
+		{
+			name: "attached to a deleted token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectSetNX(domainKey, "key1", 0).SetVal(false)
+				m.ExpectGet(domainKey).SetVal("key2")
+				m.ExpectExists("prefix::API_KEY::key2").SetVal(0)
+				m.ExpectZRem("prefix::TOKEN_DOMAINS::key2", domain).SetVal(1)
+				m.ExpectSet(domainKey, "key1", 0).SetVal("OK")
+				m.ExpectZAdd("prefix::TOKEN_DOMAINS::key1", redis.Z{Member: domain}).SetVal(1)
+			},
+		},
+		{
+			name: "redis error",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectSetNX(domainKey, "key1", 0).SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			err := r.AttachDomain(context.Background(), domain, "key1")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mockRDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_DetachDomain(t *testing.T) {
+	const domainKey = "prefix::DOMAIN::app.example.com"
+
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+	}{
+		{
+			name: "attached domain",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet(domainKey).SetVal("key1")
+				m.ExpectDel(domainKey).SetVal(1)
+				m.ExpectZRem("prefix::TOKEN_DOMAINS::key1", "app.example.com").SetVal(1)
+			},
+		},
+		{
+			name: "not attached",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet(domainKey).RedisNil()
+			},
+			wantErr: core.ErrDomainNotFound,
+		},
+		{
+			name: "attached to another token",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet(domainKey).SetVal("key2")
+			},
+			wantErr: core.ErrDomainNotFound,
+		},
+		{
+			name: "redis error",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet(domainKey).SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			err := r.DetachDomain(context.Background(), "app.example.com", "key1")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, mockRDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_ResolveAndListDomains(t *testing.T) {
+	rdb, mockRDB := redismock.NewClientMock()
+
+	mockRDB.ExpectGet("prefix::DOMAIN::app.example.com").SetVal("key1")
+	mockRDB.ExpectGet("prefix::DOMAIN::other.example.com").RedisNil()
+	mockRDB.ExpectGet("prefix::DOMAIN::broken.example.com").SetErr(assert.AnError)
+	mockRDB.ExpectZRange("prefix::TOKEN_DOMAINS::key1", 0, -1).SetVal([]string{"a.example.com", "app.example.com"})
+
+	r := &Repo{
+		db:        rdb,
+		keyPrefix: "prefix::",
+	}
+
+	keyID, err := r.ResolveDomain(context.Background(), "app.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "key1", keyID)
+
+	keyID, err = r.ResolveDomain(context.Background(), "other.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, keyID)
+
+	_, err = r.ResolveDomain(context.Background(), "broken.example.com")
+	assert.ErrorIs(t, err, assert.AnError)
+
+	domains, err := r.ListDomains(context.Background(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com", "app.example.com"}, domains)
+
+	assert.NoError(t, mockRDB.ExpectationsWereMet())
+}
+
 func TestRepo_Close(t *testing.T) {
 	rdb, _ := redismock.NewClientMock()
 	r := &Repo{
@@ -261,6 +800,247 @@ func TestHashSecret(t *testing.T) {
 	}
 }
 
+func TestRepo_ListTokens(t *testing.T) {
+	matcher := func(_, _ []interface{}) error {
+		return nil
+	}
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	metaJSON := `{"created_at":"2025-01-02T03:04:05Z","id":"key1","type":"w","owner":"team-a"}`
+
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+		want      []token.Info
+	}{
+		{
+			name: "tokens listed",
+			mockSetup: func(m redismock.ClientMock) {
+				m.CustomMatch(matcher).ExpectZRemRangeByScore("prefix::TOKENS", "-inf", "").SetVal(0)
+				m.ExpectZRange("prefix::TOKENS", 0, -1).SetVal([]string{"key1", "key2"})
+				m.ExpectMGet("prefix::TOKEN_META::key1", "prefix::TOKEN_META::key2").SetVal([]interface{}{metaJSON, nil})
+			},
+			want: []token.Info{{ID: "key1", Type: token.TokenTypeWeb, CreatedAt: createdAt, Meta: token.Meta{Owner: "team-a"}}},
+		},
+		{
+			name: "no tokens",
+			mockSetup: func(m redismock.ClientMock) {
+				m.CustomMatch(matcher).ExpectZRemRangeByScore("prefix::TOKENS", "-inf", "").SetVal(0)
+				m.ExpectZRange("prefix::TOKENS", 0, -1).SetVal([]string{})
+			},
+			want: []token.Info{},
+		},
+		{
+			name: "invalid metadata",
+			mockSetup: func(m redismock.ClientMock) {
+				m.CustomMatch(matcher).ExpectZRemRangeByScore("prefix::TOKENS", "-inf", "").SetVal(0)
+				m.ExpectZRange("prefix::TOKENS", 0, -1).SetVal([]string{"key1"})
+				m.ExpectMGet("prefix::TOKEN_META::key1").SetVal([]interface{}{"not json"})
+			},
+			wantErr: errors.New("failed to decode token metadata"),
+		},
+		{
+			name: "redis error",
+			mockSetup: func(m redismock.ClientMock) {
+				m.CustomMatch(matcher).ExpectZRemRangeByScore("prefix::TOKENS", "-inf", "").SetVal(0)
+				m.ExpectZRange("prefix::TOKENS", 0, -1).SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			got, err := r.ListTokens(context.Background())
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.wantErr.Error())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockRDB.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepo_GetTokenInfo(t *testing.T) {
+	tests := []struct {
+		wantErr   error
+		mockSetup func(m redismock.ClientMock)
+		name      string
+		want      token.Info
+	}{
+		{
+			name: "metadata found",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").SetVal(`{"id":"key1","type":"t","description":"db access"}`)
+			},
+			want: token.Info{ID: "key1", Type: token.TokenTypeTCP, Meta: token.Meta{Description: "db access"}},
+		},
+		{
+			name: "token saved without metadata",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").RedisNil()
+				m.ExpectExists("prefix::API_KEY::key1").SetVal(1)
+			},
+			want: token.Info{ID: "key1"},
+		},
+		{
+			name: "token does not exist",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").RedisNil()
+				m.ExpectExists("prefix::API_KEY::key1").SetVal(0)
+			},
+			wantErr: core.ErrTokenNotFound,
+		},
+		{
+			name: "redis error",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			got, err := r.GetTokenInfo(context.Background(), "key1")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepo_SetTokenTTL(t *testing.T) {
+	matcher := func(_, _ []interface{}) error {
+		return nil
+	}
+
+	metaJSON := `{"created_at":"2025-01-02T03:04:05Z","expires_at":"2025-01-02T04:04:05Z","id":"key1","type":"w"}`
+
+	tests := []struct {
+		wantErr    error
+		mockSetup  func(m redismock.ClientMock)
+		name       string
+		ttl        time.Duration
+		wantExpiry bool
+	}{
+		{
+			name: "expiration extended",
+			ttl:  time.Hour,
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").SetVal(metaJSON)
+				m.ExpectExpire("prefix::API_KEY::key1", time.Hour).SetVal(true)
+				m.ExpectExpire("prefix::TOKEN_POLICY::key1", time.Hour).SetVal(false)
+				m.ExpectZRange("prefix::TOKEN_SUBDOMAINS::key1", 0, -1).SetVal([]string{"demo"})
+				m.ExpectExpire("prefix::TOKEN_SUBDOMAINS::key1", time.Hour).SetVal(true)
+				m.ExpectExpire("prefix::SUBDOMAIN::demo", time.Hour).SetVal(true)
+				m.CustomMatch(matcher).ExpectSet("prefix::TOKEN_META::key1", mock.Anything, time.Hour).SetVal("OK")
+				m.CustomMatch(matcher).ExpectZAdd("prefix::TOKENS", redis.Z{}).SetVal(0)
+			},
+			wantExpiry: true,
+		},
+		{
+			name: "expiration removed",
+			ttl:  0,
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").SetVal(metaJSON)
+				m.ExpectPersist("prefix::API_KEY::key1").SetVal(true)
+				m.ExpectExists("prefix::API_KEY::key1").SetVal(1)
+				m.ExpectPersist("prefix::TOKEN_POLICY::key1").SetVal(false)
+				m.ExpectExists("prefix::TOKEN_POLICY::key1").SetVal(0)
+				m.ExpectZRange("prefix::TOKEN_SUBDOMAINS::key1", 0, -1).SetVal(nil)
+				m.ExpectPersist("prefix::TOKEN_SUBDOMAINS::key1").SetVal(false)
+				m.ExpectExists("prefix::TOKEN_SUBDOMAINS::key1").SetVal(0)
+				m.CustomMatch(matcher).ExpectSet("prefix::TOKEN_META::key1", mock.Anything, 0).SetVal("OK")
+				m.ExpectZAdd("prefix::TOKENS", redis.Z{Score: math.Inf(1), Member: "key1"}).SetVal(0)
+			},
+		},
+		{
+			name: "token does not exist",
+			ttl:  time.Hour,
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").RedisNil()
+				m.ExpectExists("prefix::API_KEY::key1").SetVal(0)
+			},
+			wantErr: core.ErrTokenNotFound,
+		},
+		{
+			name: "token expired meanwhile",
+			ttl:  time.Hour,
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").SetVal(metaJSON)
+				m.ExpectExpire("prefix::API_KEY::key1", time.Hour).SetVal(false)
+			},
+			wantErr: core.ErrTokenNotFound,
+		},
+		{
+			name: "redis error",
+			ttl:  time.Hour,
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectGet("prefix::TOKEN_META::key1").SetVal(metaJSON)
+				m.ExpectExpire("prefix::API_KEY::key1", time.Hour).SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mockRDB := redismock.NewClientMock()
+			tt.mockSetup(mockRDB)
+
+			r := &Repo{
+				db:        rdb,
+				keyPrefix: "prefix::",
+			}
+
+			info, err := r.SetTokenTTL(context.Background(), "key1", tt.ttl)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "key1", info.ID)
+			assert.Equal(t, token.TokenTypeWeb, info.Type)
+
+			if tt.wantExpiry {
+				assert.WithinDuration(t, time.Now().Add(tt.ttl), info.ExpiresAt, time.Minute)
+			} else {
+				assert.True(t, info.ExpiresAt.IsZero())
+			}
+
+			assert.NoError(t, mockRDB.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestRepo_DeleteToken(t *testing.T) {
 	tests := []struct {
 		wantErr   error
@@ -272,15 +1052,30 @@ func TestRepo_DeleteToken(t *testing.T) {
 			name:    "successfully delete token",
 			tokenID: "token123",
 			mockSetup: func(m redismock.ClientMock) {
-				m.ExpectDel("prefix::API_KEY::token123").SetVal(1)
+				m.ExpectDel("prefix::API_KEY::token123", "prefix::TOKEN_POLICY::token123", "prefix::TOKEN_META::token123").SetVal(1)
+				m.ExpectZRem("prefix::TOKENS", "token123").SetVal(1)
+				m.ExpectZRange("prefix::TOKEN_SUBDOMAINS::token123", 0, -1).SetVal([]string{"demo"})
+				m.ExpectZRange("prefix::TOKEN_DOMAINS::token123", 0, -1).SetVal([]string{"app.example.com"})
+				m.ExpectDel("prefix::TOKEN_SUBDOMAINS::token123", "prefix::TOKEN_DOMAINS::token123",
+					"prefix::SUBDOMAIN::demo", "prefix::DOMAIN::app.example.com").SetVal(4)
 			},
 			wantErr: nil,
 		},
+		{
+			name:    "redis error while releasing subdomains",
+			tokenID: "token123",
+			mockSetup: func(m redismock.ClientMock) {
+				m.ExpectDel("prefix::API_KEY::token123", "prefix::TOKEN_POLICY::token123", "prefix::TOKEN_META::token123").SetVal(1)
+				m.ExpectZRem("prefix::TOKENS", "token123").SetVal(1)
+				m.ExpectZRange("prefix::TOKEN_SUBDOMAINS::token123", 0, -1).SetErr(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
 		{
 			name:    "token does not exist",
 			tokenID: "nonexistentToken",
 			mockSetup: func(m redismock.ClientMock) {
-				m.ExpectDel("prefix::API_KEY::nonexistentToken").SetVal(0)
+				m.ExpectDel("prefix::API_KEY::nonexistentToken", "prefix::TOKEN_POLICY::nonexistentToken", "prefix::TOKEN_META::nonexistentToken").SetVal(0)
 			},
 			wantErr: core.ErrTokenNotFound,
 		},
@@ -288,7 +1083,7 @@ func TestRepo_DeleteToken(t *testing.T) {
 			name:    "redis error during deletion",
 			tokenID: "tokenWithError",
 			mockSetup: func(m redismock.ClientMock) {
-				m.ExpectDel("prefix::API_KEY::tokenWithError").SetErr(assert.AnError)
+				m.ExpectDel("prefix::API_KEY::tokenWithError", "prefix::TOKEN_POLICY::tokenWithError", "prefix::TOKEN_META::tokenWithError").SetErr(assert.AnError)
 			},
 			wantErr: assert.AnError,
 		},
@@ -394,3 +1189,33 @@ func TestHealthCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestNewBackend(t *testing.T) {
+	tests := []struct {
+		want    any
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "default is redis", cfg: Config{}, want: &Repo{}},
+		{name: "redis", cfg: Config{Backend: BackendRedis}, want: &Repo{}},
+		{name: "file", cfg: Config{Backend: BackendFile, Path: filepath.Join(t.TempDir(), "tokens.json")}, want: &FileRepo{}},
+		{name: "file without path", cfg: Config{Backend: BackendFile}, wantErr: true},
+		{name: "static", cfg: Config{Backend: BackendStatic}, want: &StaticRepo{}},
+		{name: "unknown", cfg: Config{Backend: "etcd"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := NewBackend(&tt.cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, repo)
+		})
+	}
+}
@@ -0,0 +1,630 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+// fileData is the content of the token file.
+// Subdomains maps reserved subdomain labels to the IDs of the tokens that reserved them,
+// Domains maps custom domains to the IDs of the tokens they are attached to.
+type fileData struct {
+	Tokens     map[string]fileToken `json:"tokens"`
+	Usage      map[string]fileUsage `json:"usage"`
+	Subdomains map[string]string    `json:"subdomains,omitempty"`
+	Domains    map[string]string    `json:"domains,omitempty"`
+}
+
+// fileToken is a token stored in the token file, ExpiresAt is zero for tokens that never expire.
+type fileToken struct {
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	token.Meta
+	Hash   string          `json:"hash"`
+	Type   token.TokenType `json:"type,omitempty"`
+	Policy token.Policy    `json:"policy,omitzero"`
+}
+
+// fileUsage holds traffic counters, ExpiresAt is zero for lifetime counters.
+type fileUsage struct {
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Inbound   int64     `json:"inbound"`
+	Outbound  int64     `json:"outbound"`
+}
+
+// FileRepo is an authentication repository that keeps tokens, their policies and traffic counters
+// in a JSON file on the local disk. It is meant for single-node deployments that do not run Redis.
+// The whole file is kept in memory and rewritten atomically on every change. Changes made by other
+// processes, such as `mit server token generate`, are picked up when the file's modification time changes.
+type FileRepo struct {
+	modTime time.Time
+	data    fileData
+	path    string
+	salt    []byte
+	mu      sync.Mutex
+}
+
+// NewFileRepo creates a FileRepo backed by the file at cfg.Path, loading existing tokens from it.
+// The file is created on the first change if it does not exist yet.
+// Returns an error if the path is not set or the existing file cannot be read or decoded.
+func NewFileRepo(cfg *Config) (*FileRepo, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("path is required for the file auth backend")
+	}
+
+	r := &FileRepo{
+		path: cfg.Path,
+		salt: []byte(cfg.Salt),
+		data: fileData{
+			Tokens:     make(map[string]fileToken),
+			Usage:      make(map[string]fileUsage),
+			Subdomains: make(map[string]string),
+			Domains:    make(map[string]string),
+		},
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// load reads the token file if it has been modified since it was last read.
+// A missing file is treated as empty. The caller must hold the lock or own the repository exclusively.
+// Returns an error if the file cannot be read or decoded.
+func (r *FileRepo) load() error {
+	info, err := os.Stat(r.path)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read token file: %w", err)
+	case info.ModTime().Equal(r.modTime):
+		return nil
+	}
+
+	raw, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var data fileData
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("failed to decode token file: %w", err)
+	}
+
+	if data.Tokens == nil {
+		data.Tokens = make(map[string]fileToken)
+	}
+
+	if data.Usage == nil {
+		data.Usage = make(map[string]fileUsage)
+	}
+
+	if data.Subdomains == nil {
+		data.Subdomains = make(map[string]string)
+	}
+
+	if data.Domains == nil {
+		data.Domains = make(map[string]string)
+	}
+
+	r.data = data
+	r.modTime = info.ModTime()
+
+	return nil
+}
+
+// CheckHealth verifies that the directory of the token file is accessible.
+// Returns an error if it is not.
+func (r *FileRepo) CheckHealth(_ context.Context) error {
+	if _, err := os.Stat(filepath.Dir(r.path)); err != nil {
+		return fmt.Errorf("failed to access token file directory: %w", err)
+	}
+
+	return nil
+}
+
+// IsKeyExists checks if a token that has not expired yet is stored for keyID.
+func (r *FileRepo) IsKeyExists(_ context.Context, keyID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return false, err
+	}
+
+	_, ok := r.token(keyID)
+
+	return ok, nil
+}
+
+// Verify checks if the provided secret matches the stored hash for the given keyID.
+// The keyID must contain a valid type suffix (e.g., "mykey-w" or "mykey-t").
+// Returns a *token.Token populated with the base key ID and token type on success.
+// Returns nil, nil if the credentials are invalid (key not found, expired or secret mismatch).
+// Returns nil, error if the keyID suffix is malformed or the secret cannot be hashed.
+func (r *FileRepo) Verify(_ context.Context, keyIDWithSuffix, secret string) (*token.Token, error) {
+	secretHash, err := hashSecret(secret, r.salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash secret: %w", err)
+	}
+
+	baseKeyID, tokenType, err := token.ExtractIDAndType(keyIDWithSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract token type from key ID: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	t, ok := r.token(baseKeyID)
+	if !ok || t.Hash != secretHash {
+		return nil, nil
+	}
+
+	return &token.Token{ID: baseKeyID, Type: tokenType}, nil
+}
+
+// SaveToken stores the token with its hashed secret, policy and metadata, the token expires after its TTL if it is set.
+// Returns core.ErrDuplicateTokenID if a token with the same ID already exists,
+// or an error if hashing fails or the file cannot be written.
+func (r *FileRepo) SaveToken(_ context.Context, t *token.Token) error {
+	secretHash, err := hashSecret(t.Secret, r.salt)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	if _, ok := r.token(t.ID); ok {
+		return core.ErrDuplicateTokenID
+	}
+
+	info := token.NewInfo(t, time.Now())
+	stored := fileToken{
+		CreatedAt: info.CreatedAt,
+		ExpiresAt: info.ExpiresAt,
+		Meta:      info.Meta,
+		Hash:      secretHash,
+		Type:      info.Type,
+		Policy:    t.Policy,
+	}
+
+	return r.update(func(data *fileData) {
+		data.Tokens[t.ID] = stored
+	})
+}
+
+// GetTokenPolicy retrieves the policy stored for the token identified by keyID.
+// Returns an empty policy if the token has no restrictions or does not exist.
+func (r *FileRepo) GetTokenPolicy(_ context.Context, keyID string) (token.Policy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return token.Policy{}, err
+	}
+
+	t, _ := r.token(keyID)
+
+	return t.Policy, nil
+}
+
+// ListTokens returns the metadata of all tokens that have not expired yet.
+func (r *FileRepo) ListTokens(_ context.Context) ([]token.Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]token.Info, 0, len(r.data.Tokens))
+
+	for id := range r.data.Tokens {
+		if t, ok := r.token(id); ok {
+			tokens = append(tokens, t.info(id))
+		}
+	}
+
+	return tokens, nil
+}
+
+// GetTokenInfo returns the metadata of the token identified by keyID.
+// Returns core.ErrTokenNotFound if the token does not exist or has expired.
+func (r *FileRepo) GetTokenInfo(_ context.Context, keyID string) (token.Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return token.Info{}, err
+	}
+
+	t, ok := r.token(keyID)
+	if !ok {
+		return token.Info{}, core.ErrTokenNotFound
+	}
+
+	return t.info(keyID), nil
+}
+
+// SetTokenTTL changes the expiration of the token identified by keyID to ttl from now, keeping its secret.
+// A ttl of 0 makes the token never expire.
+// Returns the updated metadata, core.ErrTokenNotFound if the token does not exist, or an error if the file cannot
+// be written.
+func (r *FileRepo) SetTokenTTL(_ context.Context, keyID string, ttl time.Duration) (token.Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return token.Info{}, err
+	}
+
+	t, ok := r.token(keyID)
+	if !ok {
+		return token.Info{}, core.ErrTokenNotFound
+	}
+
+	t.ExpiresAt = time.Time{}
+	if ttl > 0 {
+		t.ExpiresAt = time.Now().Add(ttl).UTC()
+	}
+
+	err := r.update(func(data *fileData) {
+		data.Tokens[keyID] = t
+	})
+	if err != nil {
+		return token.Info{}, fmt.Errorf("failed to update token: %w", err)
+	}
+
+	return t.info(keyID), nil
+}
+
+// AddTrafficUsage adds usage to the lifetime traffic counters of keyID.
+// If period is positive, usage is also added to the counters of the current quota window.
+// Returns an error if the file cannot be written.
+func (r *FileRepo) AddTrafficUsage(_ context.Context, keyID string, usage core.TrafficUsage, period time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	err := r.update(func(data *fileData) {
+		lifetime := data.Usage[keyID]
+		lifetime.Inbound += usage.Inbound
+		lifetime.Outbound += usage.Outbound
+		data.Usage[keyID] = lifetime
+
+		if period <= 0 {
+			return
+		}
+
+		start := windowStart(now, period)
+		key := keyID + "::" + strconv.FormatInt(start.Unix(), 10)
+
+		window := data.Usage[key]
+		window.Inbound += usage.Inbound
+		window.Outbound += usage.Outbound
+		window.ExpiresAt = start.Add(period)
+		data.Usage[key] = window
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add traffic usage: %w", err)
+	}
+
+	return nil
+}
+
+// GetTrafficUsage returns the traffic proxied for keyID within the current quota window of the given period.
+// If period is 0, the lifetime traffic counters are returned.
+func (r *FileRepo) GetTrafficUsage(_ context.Context, keyID string, period time.Duration) (core.TrafficUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return core.TrafficUsage{}, err
+	}
+
+	key := keyID
+	if period > 0 {
+		key += "::" + strconv.FormatInt(windowStart(time.Now(), period).Unix(), 10)
+	}
+
+	u, ok := r.data.Usage[key]
+	if !ok || isExpired(u.ExpiresAt) {
+		return core.TrafficUsage{}, nil
+	}
+
+	return core.TrafficUsage{Inbound: u.Inbound, Outbound: u.Outbound}, nil
+}
+
+// DeleteToken removes the token identified by tokenID and its policy, and releases the subdomains it reserved
+// and the custom domains attached to it. Traffic counters are kept for accounting purposes.
+// Returns core.ErrTokenNotFound if the token does not exist, or an error if the file cannot be written.
+func (r *FileRepo) DeleteToken(_ context.Context, tokenID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	if _, ok := r.token(tokenID); !ok {
+		return core.ErrTokenNotFound
+	}
+
+	err := r.update(func(data *fileData) {
+		delete(data.Tokens, tokenID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return nil
+}
+
+// ReserveSubdomain reserves the subdomain label for the token keyID until the token expires or is deleted.
+// Returns core.ErrSubdomainTaken if the label is reserved by another existing token or is the ID of another token,
+// or an error if the file cannot be written.
+func (r *FileRepo) ReserveSubdomain(_ context.Context, label, keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	if _, ok := r.token(label); ok && label != keyID {
+		return core.ErrSubdomainTaken
+	}
+
+	if owner, ok := r.data.Subdomains[label]; ok {
+		if owner == keyID {
+			return nil
+		}
+
+		if _, ok := r.token(owner); ok {
+			return core.ErrSubdomainTaken
+		}
+	}
+
+	err := r.update(func(data *fileData) {
+		data.Subdomains[label] = keyID
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reserve subdomain: %w", err)
+	}
+
+	return nil
+}
+
+// ResolveSubdomain returns the keyID of the token that reserved the subdomain label,
+// or an empty string if the label is not reserved or its token has expired.
+func (r *FileRepo) ResolveSubdomain(_ context.Context, label string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return "", err
+	}
+
+	owner := r.data.Subdomains[label]
+	if _, ok := r.token(owner); !ok {
+		return "", nil
+	}
+
+	return owner, nil
+}
+
+// AttachDomain attaches the custom domain to the token keyID until it is detached or the token is gone.
+// Returns core.ErrDomainTaken if the domain is attached to another existing token, or an error if the file cannot be written.
+func (r *FileRepo) AttachDomain(_ context.Context, domain, keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	if owner, ok := r.data.Domains[domain]; ok {
+		if owner == keyID {
+			return nil
+		}
+
+		if _, ok := r.token(owner); ok {
+			return core.ErrDomainTaken
+		}
+	}
+
+	err := r.update(func(data *fileData) {
+		data.Domains[domain] = keyID
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach domain: %w", err)
+	}
+
+	return nil
+}
+
+// DetachDomain detaches the custom domain from the token keyID.
+// Returns core.ErrDomainNotFound if the domain is not attached to the token, or an error if the file cannot be written.
+func (r *FileRepo) DetachDomain(_ context.Context, domain, keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return err
+	}
+
+	if owner, ok := r.data.Domains[domain]; !ok || owner != keyID {
+		return core.ErrDomainNotFound
+	}
+
+	err := r.update(func(data *fileData) {
+		delete(data.Domains, domain)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to detach domain: %w", err)
+	}
+
+	return nil
+}
+
+// ResolveDomain returns the keyID of the token the custom domain is attached to,
+// or an empty string if the domain is not attached.
+func (r *FileRepo) ResolveDomain(_ context.Context, domain string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return "", err
+	}
+
+	return r.data.Domains[domain], nil
+}
+
+// ListDomains returns the custom domains attached to the token keyID, sorted by name.
+func (r *FileRepo) ListDomains(_ context.Context, keyID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return domainsOf(r.data.Domains, keyID), nil
+}
+
+// Close releases resources held by the repository. The file store holds none.
+func (r *FileRepo) Close() error {
+	return nil
+}
+
+// token returns the token stored for keyID if it exists and has not expired.
+// The caller must hold the lock.
+func (r *FileRepo) token(keyID string) (fileToken, bool) {
+	t, ok := r.data.Tokens[keyID]
+	if !ok || isExpired(t.ExpiresAt) {
+		return fileToken{}, false
+	}
+
+	return t, true
+}
+
+// info returns the metadata of the stored token with the given ID.
+func (t fileToken) info(id string) token.Info {
+	return token.Info{
+		CreatedAt: t.CreatedAt,
+		ExpiresAt: t.ExpiresAt,
+		Meta:      t.Meta,
+		ID:        id,
+		Type:      t.Type,
+	}
+}
+
+// update applies fn to a copy of the stored data, drops expired entries and persists the result.
+// The in-memory state is only replaced once the file has been written successfully.
+// The caller must hold the lock.
+func (r *FileRepo) update(fn func(data *fileData)) error {
+	data := fileData{
+		Tokens:     maps.Clone(r.data.Tokens),
+		Usage:      maps.Clone(r.data.Usage),
+		Subdomains: maps.Clone(r.data.Subdomains),
+		Domains:    maps.Clone(r.data.Domains),
+	}
+
+	fn(&data)
+
+	maps.DeleteFunc(data.Tokens, func(_ string, t fileToken) bool { return isExpired(t.ExpiresAt) })
+	maps.DeleteFunc(data.Usage, func(_ string, u fileUsage) bool { return isExpired(u.ExpiresAt) })
+	maps.DeleteFunc(data.Subdomains, func(_, keyID string) bool {
+		_, ok := data.Tokens[keyID]
+		return !ok
+	})
+	maps.DeleteFunc(data.Domains, func(_, keyID string) bool {
+		_, ok := data.Tokens[keyID]
+		return !ok
+	})
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode token file: %w", err)
+	}
+
+	if err := writeFileAtomic(r.path, raw); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	r.data = data
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path,
+// so that readers never observe a partially written file.
+// Returns an error if any of the file operations fail.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary token file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync token file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close token file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace token file: %w", err)
+	}
+
+	return nil
+}
+
+// isExpired reports whether the expiration time is set and has passed.
+func isExpired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !time.Now().Before(expiresAt)
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileRepo(t *testing.T) (*FileRepo, *Config) {
+	t.Helper()
+
+	cfg := &Config{Backend: BackendFile, Path: filepath.Join(t.TempDir(), "tokens.json"), Salt: "salt"}
+
+	r, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	return r, cfg
+}
+
+func TestNewFileRepo(t *testing.T) {
+	t.Run("missing path", func(t *testing.T) {
+		_, err := NewFileRepo(&Config{})
+		assert.ErrorContains(t, err, "path is required")
+	})
+
+	t.Run("malformed file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tokens.json")
+		require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+		_, err := NewFileRepo(&Config{Path: path})
+		assert.ErrorContains(t, err, "failed to decode token file")
+	})
+}
+
+func TestFileRepo_TokenLifecycle(t *testing.T) {
+	ctx := context.Background()
+	r, cfg := newTestFileRepo(t)
+
+	quota := &token.Quota{Bytes: 1024, Period: time.Hour}
+	tkn := &token.Token{ID: "key123", Secret: "secret123", TTL: time.Hour, Policy: token.Policy{Quota: quota}}
+
+	require.NoError(t, r.SaveToken(ctx, tkn))
+	assert.ErrorIs(t, r.SaveToken(ctx, tkn), core.ErrDuplicateTokenID)
+
+	verified, err := r.Verify(ctx, "key123-t", "secret123")
+	require.NoError(t, err)
+	assert.Equal(t, &token.Token{ID: "key123", Type: token.TokenTypeTCP}, verified)
+
+	verified, err = r.Verify(ctx, "key123-w", "wrong")
+	require.NoError(t, err)
+	assert.Nil(t, verified)
+
+	_, err = r.Verify(ctx, "key123", "secret123")
+	assert.Error(t, err)
+
+	exists, err := r.IsKeyExists(ctx, "key123")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Tokens survive a restart.
+	reopened, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	verified, err = reopened.Verify(ctx, "key123-w", "secret123")
+	require.NoError(t, err)
+	assert.NotNil(t, verified)
+
+	policy, err := reopened.GetTokenPolicy(ctx, "key123")
+	require.NoError(t, err)
+	assert.Equal(t, token.Policy{Quota: quota}, policy)
+
+	require.NoError(t, reopened.DeleteToken(ctx, "key123"))
+	assert.ErrorIs(t, reopened.DeleteToken(ctx, "key123"), core.ErrTokenNotFound)
+
+	exists, err = reopened.IsKeyExists(ctx, "key123")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	policy, err = reopened.GetTokenPolicy(ctx, "key123")
+	require.NoError(t, err)
+	assert.True(t, policy.IsZero())
+
+	info, err := os.Stat(cfg.Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestFileRepo_ExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestFileRepo(t)
+
+	r.data.Tokens["expired"] = fileToken{Hash: "sc:hash", ExpiresAt: time.Now().Add(-time.Minute)}
+	r.data.Subdomains["demo"] = "expired"
+
+	exists, err := r.IsKeyExists(ctx, "expired")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Subdomains reserved by the token expire with it.
+	keyID, err := r.ResolveSubdomain(ctx, "demo")
+	require.NoError(t, err)
+	assert.Empty(t, keyID)
+
+	// An expired token can be replaced and is dropped from the file.
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "other", Secret: "secret"}))
+	assert.NotContains(t, r.data.Tokens, "expired")
+	assert.True(t, r.data.Tokens["other"].ExpiresAt.IsZero())
+}
+
+func TestFileRepo_TokenInfo(t *testing.T) {
+	ctx := context.Background()
+	r, cfg := newTestFileRepo(t)
+
+	meta := token.Meta{Description: "staging", Owner: "team-a"}
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "key1", Secret: "secret", TTL: time.Hour, Type: token.TokenTypeTCP, Meta: meta}))
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "key2", Secret: "secret", Type: token.TokenTypeWeb}))
+
+	r.data.Tokens["expired"] = fileToken{Hash: "sc:hash", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	reopened, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	info, err := reopened.GetTokenInfo(ctx, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "key1", info.ID)
+	assert.Equal(t, token.TokenTypeTCP, info.Type)
+	assert.Equal(t, meta, info.Meta)
+	assert.False(t, info.CreatedAt.IsZero())
+	assert.WithinDuration(t, info.CreatedAt.Add(time.Hour), info.ExpiresAt, time.Second)
+
+	_, err = reopened.GetTokenInfo(ctx, "unknown")
+	assert.ErrorIs(t, err, core.ErrTokenNotFound)
+
+	tokens, err := r.ListTokens(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.ElementsMatch(t, []string{"key1", "key2"}, []string{tokens[0].ID, tokens[1].ID})
+}
+
+func TestFileRepo_SetTokenTTL(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestFileRepo(t)
+
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "key1", Secret: "secret", TTL: time.Minute}))
+
+	info, err := r.SetTokenTTL(ctx, "key1", 0)
+	require.NoError(t, err)
+	assert.True(t, info.ExpiresAt.IsZero())
+	assert.True(t, r.data.Tokens["key1"].ExpiresAt.IsZero())
+
+	info, err = r.SetTokenTTL(ctx, "key1", 48*time.Hour)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), info.ExpiresAt, time.Minute)
+
+	// The secret is kept.
+	verified, err := r.Verify(ctx, "key1-w", "secret")
+	require.NoError(t, err)
+	assert.NotNil(t, verified)
+
+	_, err = r.SetTokenTTL(ctx, "unknown", time.Hour)
+	assert.ErrorIs(t, err, core.ErrTokenNotFound)
+}
+
+func TestFileRepo_TrafficUsage(t *testing.T) {
+	ctx := context.Background()
+	r, cfg := newTestFileRepo(t)
+
+	require.NoError(t, r.AddTrafficUsage(ctx, "key123", core.TrafficUsage{Inbound: 10, Outbound: 20}, time.Hour))
+	require.NoError(t, r.AddTrafficUsage(ctx, "key123", core.TrafficUsage{Inbound: 1, Outbound: 2}, 0))
+
+	reopened, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	usage, err := reopened.GetTrafficUsage(ctx, "key123", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, core.TrafficUsage{Inbound: 10, Outbound: 20}, usage)
+
+	usage, err = reopened.GetTrafficUsage(ctx, "key123", 0)
+	require.NoError(t, err)
+	assert.Equal(t, core.TrafficUsage{Inbound: 11, Outbound: 22}, usage)
+
+	usage, err = reopened.GetTrafficUsage(ctx, "unknown", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, usage)
+}
+
+func TestFileRepo_WriteFailureKeepsState(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestFileRepo(t)
+
+	r.path = filepath.Join(t.TempDir(), "missing", "tokens.json")
+
+	err := r.SaveToken(ctx, &token.Token{ID: "key123", Secret: "secret"})
+	require.Error(t, err)
+
+	assert.Empty(t, r.data.Tokens)
+	assert.Error(t, r.CheckHealth(ctx))
+}
+
+func TestFileRepo_PicksUpExternalChanges(t *testing.T) {
+	ctx := context.Background()
+	server, cfg := newTestFileRepo(t)
+
+	// Another process, e.g. the token generation command, adds a token to the same file.
+	cli, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, cli.SaveToken(ctx, &token.Token{ID: "key123", Secret: "secret123"}))
+
+	exists, err := server.IsKeyExists(ctx, "key123")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Writes of the server keep the externally added token.
+	require.NoError(t, server.AddTrafficUsage(ctx, "key123", core.TrafficUsage{Inbound: 1}, 0))
+
+	reopened, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	exists, err = reopened.IsKeyExists(ctx, "key123")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestFileRepo_Subdomains(t *testing.T) {
+	ctx := context.Background()
+	r, cfg := newTestFileRepo(t)
+
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "key1", Secret: "s", TTL: time.Hour}))
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "key2", Secret: "s", TTL: time.Hour}))
+
+	require.NoError(t, r.ReserveSubdomain(ctx, "demo", "key1"))
+	require.NoError(t, r.ReserveSubdomain(ctx, "demo", "key1"))
+	assert.ErrorIs(t, r.ReserveSubdomain(ctx, "demo", "key2"), core.ErrSubdomainTaken)
+	assert.ErrorIs(t, r.ReserveSubdomain(ctx, "key1", "key2"), core.ErrSubdomainTaken)
+
+	// Reservations are persisted in the token file.
+	reopened, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	keyID, err := reopened.ResolveSubdomain(ctx, "demo")
+	require.NoError(t, err)
+	assert.Equal(t, "key1", keyID)
+
+	// The reservation is released once the token is gone.
+	require.NoError(t, r.DeleteToken(ctx, "key1"))
+
+	keyID, err = r.ResolveSubdomain(ctx, "demo")
+	require.NoError(t, err)
+	assert.Empty(t, keyID)
+
+	require.NoError(t, r.ReserveSubdomain(ctx, "demo", "key2"))
+}
+
+func TestFileRepo_Domains(t *testing.T) {
+	ctx := context.Background()
+	r, cfg := newTestFileRepo(t)
+
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "key1", Secret: "s", TTL: time.Hour}))
+	require.NoError(t, r.SaveToken(ctx, &token.Token{ID: "key2", Secret: "s", TTL: time.Hour}))
+
+	require.NoError(t, r.AttachDomain(ctx, "app.example.com", "key1"))
+	require.NoError(t, r.AttachDomain(ctx, "app.example.com", "key1"))
+	require.NoError(t, r.AttachDomain(ctx, "api.example.com", "key1"))
+	assert.ErrorIs(t, r.AttachDomain(ctx, "app.example.com", "key2"), core.ErrDomainTaken)
+
+	// Domains are persisted in the token file.
+	reopened, err := NewFileRepo(cfg)
+	require.NoError(t, err)
+
+	domains, err := reopened.ListDomains(ctx, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api.example.com", "app.example.com"}, domains)
+
+	assert.ErrorIs(t, r.DetachDomain(ctx, "api.example.com", "key2"), core.ErrDomainNotFound)
+	require.NoError(t, r.DetachDomain(ctx, "api.example.com", "key1"))
+
+	// Domains are detached once the token is gone.
+	require.NoError(t, r.DeleteToken(ctx, "key1"))
+
+	keyID, err := r.ResolveDomain(ctx, "app.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, keyID)
+
+	require.NoError(t, r.AttachDomain(ctx, "app.example.com", "key2"))
+}
@@ -0,0 +1,388 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+// ErrReadOnly is returned when tokens are created or deleted in a read-only repository.
+var ErrReadOnly = errors.New("token repository is read-only")
+
+// StaticToken is a token defined in the server configuration for the static auth backend.
+// QuotaBytes optionally limits the traffic of the tunnel per QuotaPeriod, which defaults to token.DefaultQuotaPeriod.
+// Subdomains lists the custom subdomain labels clients may request with the token.
+// Domains lists the custom domains attached to the web tunnel of the token, their ownership is not verified.
+// Access optionally restricts who may reach the web tunnel of the token, AllowIPs and DenyIPs optionally restrict
+// the end-user IP addresses or CIDR prefixes that may reach the tunnel, Rewrite optionally modifies the headers
+// of the requests and responses of the web tunnel, and RateLimit optionally overrides its request rate limits.
+// Description and Owner are informational and returned when tokens are listed.
+type StaticToken struct {
+	Access      StaticAccess    `mapstructure:"access"`
+	ID          string          `mapstructure:"id"`
+	Secret      string          `mapstructure:"secret"` // #nosec G117 -- This is a config field name, not an exposed password
+	Description string          `mapstructure:"description"`
+	Owner       string          `mapstructure:"owner"`
+	Rewrite     StaticRewrite   `mapstructure:"rewrite"`
+	Subdomains  []string        `mapstructure:"subdomains"`
+	Domains     []string        `mapstructure:"domains"`
+	AllowIPs    []string        `mapstructure:"allow_ips"`
+	DenyIPs     []string        `mapstructure:"deny_ips"`
+	RateLimit   StaticRateLimit `mapstructure:"rate_limit"`
+	QuotaBytes  int64           `mapstructure:"quota_bytes"`
+	QuotaPeriod time.Duration   `mapstructure:"quota_period"`
+}
+
+// StaticAccess restricts who may reach the web tunnel of a static token: either HTTP basic auth with Username
+// and Password, or a Key sent in Header, which defaults to the Authorization header with the key as a bearer token.
+type StaticAccess struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"` // #nosec G117 -- This is a config field name, not an exposed password
+	Header   string `mapstructure:"header"`
+	Key      string `mapstructure:"key"`
+}
+
+// StaticRewrite holds the header rewrite rules of the web tunnel of a static token, see token.Rewrite.
+type StaticRewrite struct {
+	SetRequestHeaders     map[string]string `mapstructure:"set_request_headers"`
+	SetResponseHeaders    map[string]string `mapstructure:"set_response_headers"`
+	Host                  string            `mapstructure:"host"`
+	RemoveRequestHeaders  []string          `mapstructure:"remove_request_headers"`
+	RemoveResponseHeaders []string          `mapstructure:"remove_response_headers"`
+}
+
+// StaticRateLimit holds the request rate limits of the web tunnel of a static token, see token.RateLimit.
+type StaticRateLimit struct {
+	TunnelRate  float64 `mapstructure:"tunnel_rate"`
+	ClientRate  float64 `mapstructure:"client_rate"`
+	TunnelBurst int     `mapstructure:"tunnel_burst"`
+	ClientBurst int     `mapstructure:"client_burst"`
+}
+
+// staticEntry is a validated static token.
+type staticEntry struct {
+	meta   token.Meta
+	secret string
+	policy token.Policy
+}
+
+// StaticRepo is a read-only authentication repository serving the tokens listed in the server configuration.
+// Tokens never expire and cannot be created or revoked at runtime. Traffic counters, subdomain reservations
+// and custom domains attached at runtime are kept in memory and are lost when the server restarts.
+type StaticRepo struct {
+	tokens     map[string]staticEntry
+	usage      map[string]core.TrafficUsage
+	subdomains map[string]string
+	domains    map[string]string
+	mu         sync.Mutex
+}
+
+// NewStaticRepo creates a StaticRepo serving the tokens from cfg.Tokens.
+// Returns an error if a token has no ID or secret, an ID or a domain is used more than once, or a quota, subdomain,
+// domain, access restriction, IP filter, header rewrite rule or rate limit is invalid.
+func NewStaticRepo(cfg *Config) (*StaticRepo, error) {
+	r := &StaticRepo{
+		tokens:     make(map[string]staticEntry, len(cfg.Tokens)),
+		usage:      make(map[string]core.TrafficUsage),
+		subdomains: make(map[string]string),
+		domains:    make(map[string]string),
+	}
+
+	for i, t := range cfg.Tokens {
+		if t.ID == "" || t.Secret == "" {
+			return nil, fmt.Errorf("static token #%d: id and secret are required", i+1)
+		}
+
+		if _, ok := r.tokens[t.ID]; ok {
+			return nil, fmt.Errorf("static token %s: %w", t.ID, core.ErrDuplicateTokenID)
+		}
+
+		quota, err := token.NewQuota(t.QuotaBytes, t.QuotaPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("static token %s: %w", t.ID, err)
+		}
+
+		if err := token.ValidateSubdomains(t.Subdomains); err != nil {
+			return nil, fmt.Errorf("static token %s: %w", t.ID, err)
+		}
+
+		access, err := token.NewAccess(t.Access.Username, t.Access.Password, t.Access.Header, t.Access.Key)
+		if err != nil {
+			return nil, fmt.Errorf("static token %s: %w", t.ID, err)
+		}
+
+		filter, err := token.NewIPFilter(t.AllowIPs, t.DenyIPs)
+		if err != nil {
+			return nil, fmt.Errorf("static token %s: %w", t.ID, err)
+		}
+
+		rewrite, err := token.NewRewrite(token.Rewrite(t.Rewrite))
+		if err != nil {
+			return nil, fmt.Errorf("static token %s: %w", t.ID, err)
+		}
+
+		rateLimit, err := token.NewRateLimit(token.RateLimit(t.RateLimit))
+		if err != nil {
+			return nil, fmt.Errorf("static token %s: %w", t.ID, err)
+		}
+
+		for _, domain := range t.Domains {
+			domain = token.NormalizeDomain(domain)
+
+			if err := token.ValidateDomain(domain); err != nil {
+				return nil, fmt.Errorf("static token %s: %w", t.ID, err)
+			}
+
+			if _, ok := r.domains[domain]; ok {
+				return nil, fmt.Errorf("static token %s: domain %s: %w", t.ID, domain, core.ErrDomainTaken)
+			}
+
+			r.domains[domain] = t.ID
+		}
+
+		r.tokens[t.ID] = staticEntry{
+			meta:   token.Meta{Description: t.Description, Owner: t.Owner},
+			secret: t.Secret,
+			policy: token.Policy{
+				Quota:      quota,
+				Access:     access,
+				IPFilter:   filter,
+				Rewrite:    rewrite,
+				RateLimit:  rateLimit,
+				Subdomains: t.Subdomains,
+			},
+		}
+	}
+
+	return r, nil
+}
+
+// CheckHealth always succeeds, the static repository has no external dependencies.
+func (r *StaticRepo) CheckHealth(_ context.Context) error {
+	return nil
+}
+
+// IsKeyExists checks if a token with keyID is configured.
+func (r *StaticRepo) IsKeyExists(_ context.Context, keyID string) (bool, error) {
+	_, ok := r.tokens[keyID]
+
+	return ok, nil
+}
+
+// Verify checks if the provided secret matches the configured secret for the given keyID.
+// The keyID must contain a valid type suffix (e.g., "mykey-w" or "mykey-t").
+// Returns a *token.Token populated with the base key ID and token type on success.
+// Returns nil, nil if the credentials are invalid and nil, error if the keyID suffix is malformed.
+func (r *StaticRepo) Verify(_ context.Context, keyIDWithSuffix, secret string) (*token.Token, error) {
+	baseKeyID, tokenType, err := token.ExtractIDAndType(keyIDWithSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract token type from key ID: %w", err)
+	}
+
+	t, ok := r.tokens[baseKeyID]
+	if !ok || subtle.ConstantTimeCompare([]byte(t.secret), []byte(secret)) != 1 {
+		return nil, nil
+	}
+
+	return &token.Token{ID: baseKeyID, Type: tokenType}, nil
+}
+
+// SaveToken always fails with ErrReadOnly, static tokens are managed in the configuration file.
+func (r *StaticRepo) SaveToken(_ context.Context, _ *token.Token) error {
+	return ErrReadOnly
+}
+
+// DeleteToken always fails with ErrReadOnly, static tokens are managed in the configuration file.
+func (r *StaticRepo) DeleteToken(_ context.Context, _ string) error {
+	return ErrReadOnly
+}
+
+// SetTokenTTL always fails with ErrReadOnly, static tokens are managed in the configuration file.
+func (r *StaticRepo) SetTokenTTL(_ context.Context, _ string, _ time.Duration) (token.Info, error) {
+	return token.Info{}, ErrReadOnly
+}
+
+// GetTokenPolicy returns the policy configured for the token identified by keyID.
+// Returns an empty policy if the token does not exist.
+func (r *StaticRepo) GetTokenPolicy(_ context.Context, keyID string) (token.Policy, error) {
+	return r.tokens[keyID].policy, nil
+}
+
+// ListTokens returns the metadata of the configured tokens.
+// Static tokens never expire and can be used for tunnels of any type, so ExpiresAt and Type are not set.
+func (r *StaticRepo) ListTokens(_ context.Context) ([]token.Info, error) {
+	tokens := make([]token.Info, 0, len(r.tokens))
+
+	for id, t := range r.tokens {
+		tokens = append(tokens, token.Info{ID: id, Meta: t.meta})
+	}
+
+	return tokens, nil
+}
+
+// GetTokenInfo returns the metadata of the configured token identified by keyID.
+// Returns core.ErrTokenNotFound if the token is not configured.
+func (r *StaticRepo) GetTokenInfo(_ context.Context, keyID string) (token.Info, error) {
+	t, ok := r.tokens[keyID]
+	if !ok {
+		return token.Info{}, core.ErrTokenNotFound
+	}
+
+	return token.Info{ID: keyID, Meta: t.meta}, nil
+}
+
+// AddTrafficUsage adds usage to the in-memory lifetime traffic counters of keyID and,
+// if period is positive, to the counters of the current quota window.
+func (r *StaticRepo) AddTrafficUsage(_ context.Context, keyID string, usage core.TrafficUsage, period time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []string{keyID}
+
+	if period > 0 {
+		keys = append(keys, r.windowKey(keyID, period))
+	}
+
+	for _, key := range keys {
+		u := r.usage[key]
+		u.Inbound += usage.Inbound
+		u.Outbound += usage.Outbound
+		r.usage[key] = u
+	}
+
+	return nil
+}
+
+// GetTrafficUsage returns the traffic proxied for keyID within the current quota window of the given period.
+// If period is 0, the lifetime traffic counters are returned.
+func (r *StaticRepo) GetTrafficUsage(_ context.Context, keyID string, period time.Duration) (core.TrafficUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := keyID
+	if period > 0 {
+		key = r.windowKey(keyID, period)
+	}
+
+	return r.usage[key], nil
+}
+
+// ReserveSubdomain reserves the subdomain label for the token keyID until the server restarts.
+// Returns core.ErrSubdomainTaken if the label is reserved by another token or is the ID of another token.
+func (r *StaticRepo) ReserveSubdomain(_ context.Context, label, keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tokens[label]; ok && label != keyID {
+		return core.ErrSubdomainTaken
+	}
+
+	if owner, ok := r.subdomains[label]; ok && owner != keyID {
+		return core.ErrSubdomainTaken
+	}
+
+	r.subdomains[label] = keyID
+
+	return nil
+}
+
+// ResolveSubdomain returns the keyID of the token that reserved the subdomain label,
+// or an empty string if the label is not reserved.
+func (r *StaticRepo) ResolveSubdomain(_ context.Context, label string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.subdomains[label], nil
+}
+
+// AttachDomain attaches the custom domain to the token keyID until the server restarts.
+// Returns core.ErrDomainTaken if the domain is attached to another token.
+func (r *StaticRepo) AttachDomain(_ context.Context, domain, keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if owner, ok := r.domains[domain]; ok && owner != keyID {
+		return core.ErrDomainTaken
+	}
+
+	r.domains[domain] = keyID
+
+	return nil
+}
+
+// DetachDomain detaches the custom domain from the token keyID until the server restarts.
+// Returns core.ErrDomainNotFound if the domain is not attached to the token.
+func (r *StaticRepo) DetachDomain(_ context.Context, domain, keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if owner, ok := r.domains[domain]; !ok || owner != keyID {
+		return core.ErrDomainNotFound
+	}
+
+	delete(r.domains, domain)
+
+	return nil
+}
+
+// ResolveDomain returns the keyID of the token the custom domain is attached to,
+// or an empty string if the domain is not attached.
+func (r *StaticRepo) ResolveDomain(_ context.Context, domain string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.domains[domain], nil
+}
+
+// ListDomains returns the custom domains attached to the token keyID, sorted by name.
+func (r *StaticRepo) ListDomains(_ context.Context, keyID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return domainsOf(r.domains, keyID), nil
+}
+
+// domainsOf returns the entries of the domain to keyID map domains that are attached to keyID, sorted by name.
+func domainsOf(domains map[string]string, keyID string) []string {
+	attached := make([]string, 0)
+
+	for domain, owner := range domains {
+		if owner == keyID {
+			attached = append(attached, domain)
+		}
+	}
+
+	slices.Sort(attached)
+
+	return attached
+}
+
+// Close releases resources held by the repository. The static repository holds none.
+func (r *StaticRepo) Close() error {
+	return nil
+}
+
+// windowKey returns the key of the counters for the current quota window of keyID.
+// Counters of the previous windows of the same key are dropped as they are no longer needed.
+// The caller must hold the lock.
+func (r *StaticRepo) windowKey(keyID string, period time.Duration) string {
+	prefix := keyID + "::"
+	key := prefix + strconv.FormatInt(windowStart(time.Now(), period).Unix(), 10)
+
+	for k := range r.usage {
+		if k != key && strings.HasPrefix(k, prefix) {
+			delete(r.usage, k)
+		}
+	}
+
+	return key
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStaticRepo(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr string
+		tokens  []StaticToken
+	}{
+		{name: "valid tokens", tokens: []StaticToken{{ID: "a", Secret: "s"}, {ID: "b", Secret: "s", QuotaBytes: 10}}},
+		{name: "missing secret", tokens: []StaticToken{{ID: "a"}}, wantErr: "id and secret are required"},
+		{name: "duplicate id", tokens: []StaticToken{{ID: "a", Secret: "s"}, {ID: "a", Secret: "x"}}, wantErr: "duplicate token ID"},
+		{name: "invalid quota", tokens: []StaticToken{{ID: "a", Secret: "s", QuotaBytes: -1}}, wantErr: "must not be negative"},
+		{name: "invalid access", tokens: []StaticToken{{ID: "a", Secret: "s", Access: StaticAccess{Username: "admin"}}}, wantErr: "requires either"},
+		{name: "invalid ip filter", tokens: []StaticToken{{ID: "a", Secret: "s", AllowIPs: []string{"bad"}}}, wantErr: "ip filter entries"},
+		{name: "invalid rewrite", tokens: []StaticToken{{ID: "a", Secret: "s", Rewrite: StaticRewrite{Host: "bad host"}}}, wantErr: "rewrite rules require"},
+		{name: "invalid rate limit", tokens: []StaticToken{{ID: "a", Secret: "s", RateLimit: StaticRateLimit{ClientBurst: 5}}}, wantErr: "rate limits must not be negative"},
+		{name: "invalid subdomain", tokens: []StaticToken{{ID: "a", Secret: "s", Subdomains: []string{"Not_A_Label"}}}, wantErr: "subdomain must be a DNS label"},
+		{name: "invalid domain", tokens: []StaticToken{{ID: "a", Secret: "s", Domains: []string{"localhost"}}}, wantErr: "domain must be a hostname"},
+		{name: "duplicate domain", tokens: []StaticToken{
+			{ID: "a", Secret: "s", Domains: []string{"app.example.com"}},
+			{ID: "b", Secret: "s", Domains: []string{"App.Example.com."}},
+		}, wantErr: "domain is attached to another token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewStaticRepo(&Config{Tokens: tt.tokens})
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestStaticRepo(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := NewStaticRepo(&Config{Tokens: []StaticToken{
+		{ID: "key123", Secret: "secret123", QuotaBytes: 1024, QuotaPeriod: time.Hour},
+	}})
+	require.NoError(t, err)
+
+	verified, err := r.Verify(ctx, "key123-w", "secret123")
+	require.NoError(t, err)
+	assert.Equal(t, &token.Token{ID: "key123", Type: token.TokenTypeWeb}, verified)
+
+	verified, err = r.Verify(ctx, "key123-w", "wrong")
+	require.NoError(t, err)
+	assert.Nil(t, verified)
+
+	verified, err = r.Verify(ctx, "unknown-w", "secret123")
+	require.NoError(t, err)
+	assert.Nil(t, verified)
+
+	exists, err := r.IsKeyExists(ctx, "key123")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	policy, err := r.GetTokenPolicy(ctx, "key123")
+	require.NoError(t, err)
+	assert.Equal(t, token.Policy{Quota: &token.Quota{Bytes: 1024, Period: time.Hour}}, policy)
+
+	assert.ErrorIs(t, r.SaveToken(ctx, &token.Token{ID: "new"}), ErrReadOnly)
+	assert.ErrorIs(t, r.DeleteToken(ctx, "key123"), ErrReadOnly)
+
+	_, err = r.SetTokenTTL(ctx, "key123", time.Hour)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.NoError(t, r.CheckHealth(ctx))
+
+	require.NoError(t, r.AddTrafficUsage(ctx, "key123", core.TrafficUsage{Inbound: 5, Outbound: 7}, time.Hour))
+
+	usage, err := r.GetTrafficUsage(ctx, "key123", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, core.TrafficUsage{Inbound: 5, Outbound: 7}, usage)
+
+	usage, err = r.GetTrafficUsage(ctx, "key123", 0)
+	require.NoError(t, err)
+	assert.Equal(t, core.TrafficUsage{Inbound: 5, Outbound: 7}, usage)
+}
+
+func TestStaticRepo_TokenInfo(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := NewStaticRepo(&Config{Tokens: []StaticToken{
+		{ID: "key1", Secret: "secret1", Description: "staging", Owner: "team-a"},
+		{ID: "key2", Secret: "secret2"},
+	}})
+	require.NoError(t, err)
+
+	info, err := r.GetTokenInfo(ctx, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, token.Info{ID: "key1", Meta: token.Meta{Description: "staging", Owner: "team-a"}}, info)
+
+	_, err = r.GetTokenInfo(ctx, "unknown")
+	assert.ErrorIs(t, err, core.ErrTokenNotFound)
+
+	tokens, err := r.ListTokens(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []token.Info{info, {ID: "key2"}}, tokens)
+}
+
+func TestStaticRepo_Subdomains(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := NewStaticRepo(&Config{Tokens: []StaticToken{
+		{ID: "key1", Secret: "s", Subdomains: []string{"demo"}},
+		{ID: "key2", Secret: "s", Subdomains: []string{token.AnySubdomain}},
+	}})
+	require.NoError(t, err)
+
+	policy, err := r.GetTokenPolicy(ctx, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"demo"}, policy.Subdomains)
+
+	keyID, err := r.ResolveSubdomain(ctx, "demo")
+	require.NoError(t, err)
+	assert.Empty(t, keyID)
+
+	require.NoError(t, r.ReserveSubdomain(ctx, "demo", "key1"))
+	require.NoError(t, r.ReserveSubdomain(ctx, "demo", "key1"))
+	assert.ErrorIs(t, r.ReserveSubdomain(ctx, "demo", "key2"), core.ErrSubdomainTaken)
+	assert.ErrorIs(t, r.ReserveSubdomain(ctx, "key1", "key2"), core.ErrSubdomainTaken)
+
+	keyID, err = r.ResolveSubdomain(ctx, "demo")
+	require.NoError(t, err)
+	assert.Equal(t, "key1", keyID)
+}
+
+func TestStaticRepo_Domains(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := NewStaticRepo(&Config{Tokens: []StaticToken{
+		{ID: "key1", Secret: "s", Domains: []string{"App.Example.com"}},
+		{ID: "key2", Secret: "s"},
+	}})
+	require.NoError(t, err)
+
+	keyID, err := r.ResolveDomain(ctx, "app.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "key1", keyID)
+
+	require.NoError(t, r.AttachDomain(ctx, "api.example.com", "key1"))
+	assert.ErrorIs(t, r.AttachDomain(ctx, "app.example.com", "key2"), core.ErrDomainTaken)
+
+	domains, err := r.ListDomains(ctx, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api.example.com", "app.example.com"}, domains)
+
+	domains, err = r.ListDomains(ctx, "key2")
+	require.NoError(t, err)
+	assert.Empty(t, domains)
+
+	assert.ErrorIs(t, r.DetachDomain(ctx, "app.example.com", "key2"), core.ErrDomainNotFound)
+	require.NoError(t, r.DetachDomain(ctx, "app.example.com", "key1"))
+
+	keyID, err = r.ResolveDomain(ctx, "app.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, keyID)
+}
+
+func TestStaticRepo_Access(t *testing.T) {
+	r, err := NewStaticRepo(&Config{Tokens: []StaticToken{
+		{ID: "key1", Secret: "s", Access: StaticAccess{Username: "admin", Password: "secret"}},
+	}})
+	require.NoError(t, err)
+
+	policy, err := r.GetTokenPolicy(context.Background(), "key1")
+	require.NoError(t, err)
+	require.NotNil(t, policy.Access)
+	assert.True(t, policy.Access.CheckBasic("admin", "secret"))
+}
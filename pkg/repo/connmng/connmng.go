@@ -4,21 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/google/uuid"
 	"github.com/ksysoev/make-it-public/pkg/core"
 	"github.com/ksysoev/make-it-public/pkg/core/conn"
+	"github.com/ksysoev/make-it-public/pkg/metrics"
 )
 
 type connRequest struct {
-	ctx context.Context
-	req conn.Request
+	ctx    context.Context
+	req    conn.Request
+	connID uuid.UUID
 }
 
 type ConnManager struct {
-	conns    map[string]core.ControlConn
+	conns    map[string][]core.ControlConn
 	requests map[uuid.UUID]*connRequest
+	pending  map[uuid.UUID]int
+	cursor   map[string]int
 	mu       sync.RWMutex
 }
 
@@ -27,80 +32,124 @@ type ConnManager struct {
 // It returns a pointer to a ConnManager with initialized internal maps for conn and requests.
 func New() *ConnManager {
 	return &ConnManager{
-		conns:    make(map[string]core.ControlConn),
+		conns:    make(map[string][]core.ControlConn),
 		requests: make(map[uuid.UUID]*connRequest),
+		pending:  make(map[uuid.UUID]int),
+		cursor:   make(map[string]int),
 	}
 }
 
 // AddConnection adds a server connection to the user's connection pool.
-// It takes a user parameter of type string and a controlConn parameter of type core.ControlConn.
+// Several control connections may be registered for the same keyID, in which case
+// connection requests are balanced between them.
 // It does not return any value and ensures thread-safe access.
 func (cm *ConnManager) AddConnection(keyID string, controlConn core.ControlConn) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	if oldConn, ok := cm.conns[keyID]; ok {
-		_ = oldConn.Close()
-	}
-
-	cm.conns[keyID] = controlConn
+	cm.conns[keyID] = append(cm.conns[keyID], controlConn)
 }
 
 // RemoveConnection removes a connection associated with a specific user by its unique ID.
-// It takes user of type string and id of type uuid.UUID.
+// Other control connections registered for the same keyID are left untouched.
 // It does not return any value but safely does nothing if the user or connection ID does not exist.
 func (cm *ConnManager) RemoveConnection(keyID string, id uuid.UUID) {
 	cm.mu.Lock()
 
-	if revConn, ok := cm.conns[keyID]; ok && revConn.ID() == id {
-		delete(cm.conns, keyID)
+	conns := cm.conns[keyID]
+	idx := slices.IndexFunc(conns, func(c core.ControlConn) bool { return c.ID() == id })
 
-		defer func() {
-			_ = revConn.Close()
-		}()
+	if idx == -1 {
+		cm.mu.Unlock()
+		return
 	}
 
+	revConn := conns[idx]
+	conns = slices.Delete(conns, idx, idx+1)
+
+	if len(conns) == 0 {
+		delete(cm.conns, keyID)
+		delete(cm.cursor, keyID)
+	} else {
+		cm.conns[keyID] = conns
+	}
+
+	delete(cm.pending, id)
+
 	cm.mu.Unlock()
+
+	_ = revConn.Close()
 }
 
 // RequestConnection attempts to establish a new connection for the specified user.
-// It takes ctx of type context.Context and userID of type string.
-// It returns a channel of type net.Conn to receive the connection or an error if the operation fails.
+// When several control connections are registered for the keyID, the one with the fewest pending
+// requests is chosen, ties are resolved in round-robin order. If sending the connect command fails,
+// the remaining control connections are tried before giving up.
+// The request is registered before the command is sent, as the client may connect back before the command returns,
+// and the lock is not held while the command is sent, so a slow control connection does not delay the requests of others.
 // It returns an error if no connections are available for the user, the user does not exist, or a command fails to send.
 func (cm *ConnManager) RequestConnection(ctx context.Context, keyID string) (conn.Request, error) {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
+	cm.mu.Lock()
+	candidates := cm.candidates(keyID)
+	cm.mu.Unlock()
 
-	revConn, ok := cm.conns[keyID]
-	if !ok {
+	if len(candidates) == 0 {
 		return nil, core.ErrKeyIDNotFound
 	}
 
-	req, err := revConn.RequestConnection()
-	if err != nil {
-		return nil, fmt.Errorf("failed to send connect command: %w", err)
-	}
+	errs := make([]error, 0, len(candidates))
 
-	r := &connRequest{
-		ctx: ctx,
-		req: req,
-	}
+	for _, revConn := range candidates {
+		req := revConn.NewRequest()
+		r := &connRequest{
+			ctx:    ctx,
+			req:    req,
+			connID: revConn.ID(),
+		}
+
+		// The request is counted as pending while it is sent, so that concurrent requests are balanced.
+		cm.mu.Lock()
+		cm.requests[req.ID()] = r
+		cm.pending[r.connID]++
+		cm.mu.Unlock()
+
+		metrics.PendingRequests.Inc()
+
+		err := revConn.SendConnectCommand(req.ID())
+		if err == nil {
+			return req, nil
+		}
+
+		cm.mu.Lock()
+
+		if _, ok := cm.requests[req.ID()]; ok {
+			cm.removeRequest(req.ID(), r)
+		}
+
+		cm.mu.Unlock()
 
-	cm.requests[req.ID()] = r
+		errs = append(errs, err)
+	}
 
-	return req, nil
+	return nil, fmt.Errorf("failed to send connect command: %w", errors.Join(errs...))
 }
 
 // ResolveRequest resolves a pending connection request by sending the provided connection to the request's channel.
 // It takes an id parameter of type uuid.UUID and a netConn parameter of type net.Conn.
-// If the request is not found or its context is canceled, the connection is closed and no further actions are taken.
+// If the request is not found, as with late or duplicate connections, the connection is closed and no further
+// actions are taken.
 func (cm *ConnManager) ResolveRequest(id uuid.UUID, netConn conn.WithWriteCloser) {
 	cm.mu.Lock()
 	r, ok := cm.requests[id]
-	delete(cm.requests, id)
+
+	if ok {
+		cm.removeRequest(id, r)
+	}
+
 	cm.mu.Unlock()
 
 	if !ok {
+		_ = netConn.Close()
 		return
 	}
 
@@ -121,7 +170,7 @@ func (cm *ConnManager) CancelRequest(id uuid.UUID) {
 	}
 
 	r.req.Cancel()
-	delete(cm.requests, id)
+	cm.removeRequest(id, r)
 }
 
 // Close releases all resources managed by ConnManager and terminates active connections gracefully.
@@ -136,12 +185,17 @@ func (cm *ConnManager) Close() error {
 	for id, r := range cm.requests {
 		r.req.Cancel()
 		delete(cm.requests, id)
+		metrics.PendingRequests.Dec()
 	}
 
-	for _, userConn := range cm.conns {
-		err := userConn.Close()
-		if err != nil {
-			errs = append(errs, err)
+	clear(cm.pending)
+
+	for _, userConns := range cm.conns {
+		for _, userConn := range userConns {
+			err := userConn.Close()
+			if err != nil {
+				errs = append(errs, err)
+			}
 		}
 	}
 
@@ -151,3 +205,50 @@ func (cm *ConnManager) Close() error {
 
 	return nil
 }
+
+// candidates returns the control connections registered for keyID ordered by preference.
+// Connections with fewer pending requests come first; connections with equal load keep
+// a round-robin order that advances on every call. The caller must hold the write lock.
+func (cm *ConnManager) candidates(keyID string) []core.ControlConn {
+	conns := cm.conns[keyID]
+	if len(conns) == 0 {
+		return nil
+	}
+
+	start := cm.cursor[keyID] % len(conns)
+	cm.cursor[keyID] = start + 1
+
+	ordered := make([]core.ControlConn, 0, len(conns))
+	ordered = append(ordered, conns[start:]...)
+	ordered = append(ordered, conns[:start]...)
+
+	if len(ordered) == 1 {
+		return ordered
+	}
+
+	slices.SortStableFunc(ordered, func(a, b core.ControlConn) int {
+		return cm.pending[a.ID()] - cm.pending[b.ID()]
+	})
+
+	return ordered
+}
+
+// removeRequest deletes the request from the pending set and updates the load counter of
+// the control connection it was issued on. The caller must hold the write lock.
+func (cm *ConnManager) removeRequest(id uuid.UUID, r *connRequest) {
+	delete(cm.requests, id)
+	metrics.PendingRequests.Dec()
+
+	cm.releasePending(r.connID)
+}
+
+// releasePending decrements the pending requests counter of the control connection connID.
+// The caller must hold the write lock.
+func (cm *ConnManager) releasePending(connID uuid.UUID) {
+	if cm.pending[connID] <= 1 {
+		delete(cm.pending, connID)
+		return
+	}
+
+	cm.pending[connID]--
+}
@@ -18,19 +18,16 @@ func TestConnManager_AddConnection(t *testing.T) {
 	cm := New()
 	mockConn := core.NewMockControlConn(t)
 
-	mockConn.EXPECT().Close().Return(nil)
-
 	cm.AddConnection("key1", mockConn)
 
-	assert.NotNil(t, cm.conns["key1"])
-	assert.Equal(t, mockConn, cm.conns["key1"])
+	assert.Equal(t, []core.ControlConn{mockConn}, cm.conns["key1"])
 
-	// Overwrite connection
+	// Second connection for the same key is kept alongside the first one
 	newConn := core.NewMockControlConn(t)
 
 	cm.AddConnection("key1", newConn)
 
-	assert.Equal(t, newConn, cm.conns["key1"])
+	assert.Equal(t, []core.ControlConn{mockConn, newConn}, cm.conns["key1"])
 }
 
 func TestConnManager_RemoveConnection(t *testing.T) {
@@ -47,14 +44,49 @@ func TestConnManager_RemoveConnection(t *testing.T) {
 	assert.Nil(t, cm.conns["key1"])
 }
 
+func TestConnManager_RemoveConnection_KeepsOtherConnections(t *testing.T) {
+	cm := New()
+	firstConn := core.NewMockControlConn(t)
+	secondConn := core.NewMockControlConn(t)
+
+	firstID := uuid.New()
+	secondID := uuid.New()
+
+	firstConn.EXPECT().ID().Return(firstID)
+	firstConn.EXPECT().Close().Return(nil)
+	secondConn.EXPECT().ID().Return(secondID).Maybe()
+
+	cm.AddConnection("key1", firstConn)
+	cm.AddConnection("key1", secondConn)
+
+	cm.RemoveConnection("key1", firstID)
+
+	assert.Equal(t, []core.ControlConn{secondConn}, cm.conns["key1"])
+}
+
+func TestConnManager_RemoveConnection_UnknownID(t *testing.T) {
+	cm := New()
+	mockConn := core.NewMockControlConn(t)
+
+	mockConn.EXPECT().ID().Return(uuid.New())
+
+	cm.AddConnection("key1", mockConn)
+	cm.RemoveConnection("key1", uuid.New())
+
+	assert.Equal(t, []core.ControlConn{mockConn}, cm.conns["key1"])
+}
+
 func TestConnManager_RequestConnection(t *testing.T) {
 	mockConn := core.NewMockControlConn(t)
 	mockReq := conn.NewMockRequest(t)
 	cm := New()
 
 	reqID := uuid.New()
+	connID := uuid.New()
 
-	mockConn.EXPECT().RequestConnection().Return(mockReq, nil)
+	mockConn.EXPECT().NewRequest().Return(mockReq)
+	mockConn.EXPECT().SendConnectCommand(mock.Anything).Return(nil)
+	mockConn.EXPECT().ID().Return(connID)
 	mockReq.EXPECT().ID().Return(reqID)
 
 	cm.AddConnection("key1", mockConn)
@@ -64,6 +96,93 @@ func TestConnManager_RequestConnection(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, mockReq, req)
 	assert.NotNil(t, cm.requests[reqID])
+	assert.Equal(t, 1, cm.pending[connID])
+}
+
+func TestConnManager_RequestConnection_RoundRobin(t *testing.T) {
+	cm := New()
+	firstConn := core.NewMockControlConn(t)
+	secondConn := core.NewMockControlConn(t)
+	firstReq := conn.NewMockRequest(t)
+	secondReq := conn.NewMockRequest(t)
+
+	firstConn.EXPECT().ID().Return(uuid.New())
+	secondConn.EXPECT().ID().Return(uuid.New())
+	firstConn.EXPECT().NewRequest().Return(firstReq).Once()
+	firstConn.EXPECT().SendConnectCommand(mock.Anything).Return(nil).Once()
+	secondConn.EXPECT().NewRequest().Return(secondReq).Once()
+	secondConn.EXPECT().SendConnectCommand(mock.Anything).Return(nil).Once()
+
+	firstReqID := uuid.New()
+	secondReqID := uuid.New()
+
+	firstReq.EXPECT().ID().Return(firstReqID)
+	secondReq.EXPECT().ID().Return(secondReqID)
+
+	cm.AddConnection("key1", firstConn)
+	cm.AddConnection("key1", secondConn)
+
+	req, err := cm.RequestConnection(context.Background(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, firstReq, req)
+
+	req, err = cm.RequestConnection(context.Background(), "key1")
+	require.NoError(t, err)
+	assert.Equal(t, secondReq, req)
+}
+
+func TestConnManager_RequestConnection_LeastPending(t *testing.T) {
+	cm := New()
+	busyConn := core.NewMockControlConn(t)
+	idleConn := core.NewMockControlConn(t)
+	mockReq := conn.NewMockRequest(t)
+
+	busyID := uuid.New()
+	idleID := uuid.New()
+
+	busyConn.EXPECT().ID().Return(busyID)
+	idleConn.EXPECT().ID().Return(idleID)
+	idleConn.EXPECT().NewRequest().Return(mockReq).Once()
+	idleConn.EXPECT().SendConnectCommand(mock.Anything).Return(nil).Once()
+	mockReq.EXPECT().ID().Return(uuid.New())
+
+	cm.AddConnection("key1", busyConn)
+	cm.AddConnection("key1", idleConn)
+
+	cm.pending[busyID] = 3
+
+	req, err := cm.RequestConnection(context.Background(), "key1")
+
+	require.NoError(t, err)
+	assert.Equal(t, mockReq, req)
+	assert.Equal(t, 1, cm.pending[idleID])
+}
+
+func TestConnManager_RequestConnection_Failover(t *testing.T) {
+	cm := New()
+	brokenConn := core.NewMockControlConn(t)
+	healthyConn := core.NewMockControlConn(t)
+	brokenReq := conn.NewMockRequest(t)
+	mockReq := conn.NewMockRequest(t)
+	brokenReqID := uuid.New()
+
+	brokenConn.EXPECT().ID().Return(uuid.New())
+	healthyConn.EXPECT().ID().Return(uuid.New())
+	brokenReq.EXPECT().ID().Return(brokenReqID)
+	brokenConn.EXPECT().NewRequest().Return(brokenReq).Once()
+	brokenConn.EXPECT().SendConnectCommand(mock.Anything).Return(errors.New("connection error")).Once()
+	healthyConn.EXPECT().NewRequest().Return(mockReq).Once()
+	healthyConn.EXPECT().SendConnectCommand(mock.Anything).Return(nil).Once()
+	mockReq.EXPECT().ID().Return(uuid.New())
+
+	cm.AddConnection("key1", brokenConn)
+	cm.AddConnection("key1", healthyConn)
+
+	req, err := cm.RequestConnection(context.Background(), "key1")
+
+	require.NoError(t, err)
+	assert.Equal(t, mockReq, req)
+	assert.NotContains(t, cm.requests, brokenReqID, "the request of the failed command must be dropped")
 }
 
 func TestConnManager_RequestConnection_NoConnection(t *testing.T) {
@@ -75,14 +194,94 @@ func TestConnManager_RequestConnection_NoConnection(t *testing.T) {
 
 func TestConnManager_RequestConnection_Error(t *testing.T) {
 	mockConn := core.NewMockControlConn(t)
+	mockReq := conn.NewMockRequest(t)
 	cm := New()
 
-	mockConn.EXPECT().RequestConnection().Return(nil, errors.New("connection error"))
+	mockConn.EXPECT().ID().Return(uuid.New())
+	mockReq.EXPECT().ID().Return(uuid.New())
+	mockConn.EXPECT().NewRequest().Return(mockReq)
+	mockConn.EXPECT().SendConnectCommand(mock.Anything).Return(errors.New("connection error"))
 	cm.AddConnection("key1", mockConn)
 
 	_, err := cm.RequestConnection(context.Background(), "key1")
 
 	assert.ErrorContains(t, err, "failed to send connect command")
+	assert.Empty(t, cm.pending)
+	assert.Empty(t, cm.requests)
+}
+
+func TestConnManager_RequestConnection_ResolvedWhileSending(t *testing.T) {
+	cm := New()
+	mockConn := core.NewMockControlConn(t)
+	mockReq := conn.NewMockRequest(t)
+	reqID := uuid.New()
+	netConn := new(net.TCPConn)
+
+	mockConn.EXPECT().ID().Return(uuid.New())
+	mockReq.EXPECT().ID().Return(reqID)
+	mockConn.EXPECT().NewRequest().Return(mockReq).Once()
+
+	// The client connects back before the connect command returns.
+	mockConn.EXPECT().SendConnectCommand(reqID).RunAndReturn(func(id uuid.UUID) error {
+		cm.ResolveRequest(id, netConn)
+		return nil
+	}).Once()
+	mockReq.EXPECT().SendConn(mock.Anything, netConn).Return().Once()
+
+	cm.AddConnection("key1", mockConn)
+
+	req, err := cm.RequestConnection(context.Background(), "key1")
+
+	require.NoError(t, err)
+	assert.Equal(t, mockReq, req)
+	assert.Empty(t, cm.requests)
+	assert.Empty(t, cm.pending)
+}
+
+func TestConnManager_RequestConnection_SlowSendDoesNotBlock(t *testing.T) {
+	cm := New()
+	slowConn := core.NewMockControlConn(t)
+	fastConn := core.NewMockControlConn(t)
+	slowReq := conn.NewMockRequest(t)
+	fastReq := conn.NewMockRequest(t)
+
+	sending := make(chan struct{})
+	release := make(chan struct{})
+
+	slowConn.EXPECT().ID().Return(uuid.New())
+	slowConn.EXPECT().NewRequest().Return(slowReq).Once()
+	slowConn.EXPECT().SendConnectCommand(mock.Anything).RunAndReturn(func(uuid.UUID) error {
+		close(sending)
+		<-release
+
+		return nil
+	}).Once()
+	slowReq.EXPECT().ID().Return(uuid.New())
+
+	fastConn.EXPECT().ID().Return(uuid.New())
+	fastConn.EXPECT().NewRequest().Return(fastReq).Once()
+	fastConn.EXPECT().SendConnectCommand(mock.Anything).Return(nil).Once()
+	fastReq.EXPECT().ID().Return(uuid.New())
+
+	cm.AddConnection("slow", slowConn)
+	cm.AddConnection("fast", fastConn)
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := cm.RequestConnection(context.Background(), "slow")
+		done <- err
+	}()
+
+	<-sending
+
+	// The request of another keyID goes through while the slow control connection is still sending.
+	req, err := cm.RequestConnection(context.Background(), "fast")
+	require.NoError(t, err)
+	assert.Equal(t, fastReq, req)
+
+	close(release)
+	require.NoError(t, <-done)
 }
 
 func TestConnManager_ResolveRequest(t *testing.T) {
@@ -90,10 +289,13 @@ func TestConnManager_ResolveRequest(t *testing.T) {
 	cm := New()
 
 	reqID := uuid.New()
+	connID := uuid.New()
 	cm.requests[reqID] = &connRequest{
-		ctx: context.Background(),
-		req: mockReq,
+		ctx:    context.Background(),
+		req:    mockReq,
+		connID: connID,
 	}
+	cm.pending[connID] = 1
 
 	mockReq.EXPECT().SendConn(mock.Anything, mock.Anything).Return()
 
@@ -101,6 +303,17 @@ func TestConnManager_ResolveRequest(t *testing.T) {
 	cm.ResolveRequest(reqID, revConn)
 
 	assert.Nil(t, cm.requests[reqID])
+	assert.NotContains(t, cm.pending, connID)
+}
+
+func TestConnManager_ResolveRequest_Unknown(t *testing.T) {
+	cm := New()
+	netConn := conn.NewMockWithWriteCloser(t)
+
+	netConn.EXPECT().Close().Return(nil).Once()
+
+	// Late or duplicate connections are closed rather than leaked.
+	cm.ResolveRequest(uuid.New(), netConn)
 }
 
 func TestConnManager_CancelRequest(t *testing.T) {
@@ -130,7 +343,7 @@ func TestConnManager_Close(t *testing.T) {
 	mockConn.EXPECT().Close().Return(nil)
 	mockReq.EXPECT().Cancel().Return()
 
-	cm.conns["key1"] = mockConn
+	cm.conns["key1"] = []core.ControlConn{mockConn}
 	cm.requests[reqID] = &connRequest{
 		ctx: context.Background(),
 		req: mockReq,
@@ -0,0 +1,51 @@
+package revclient
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = time.Minute
+)
+
+// backoff returns the delay before the given reconnection attempt, counting from 1.
+// The delay grows exponentially from reconnectBaseDelay up to reconnectMaxDelay, and a random
+// jitter of up to half of it is subtracted so that clients do not reconnect in lockstep after
+// a server restart.
+func backoff(attempt int) time.Duration {
+	delay := reconnectMaxDelay
+
+	if shift := attempt - 1; shift < 16 {
+		delay = min(reconnectBaseDelay<<max(shift, 0), reconnectMaxDelay)
+	}
+
+	jitter := rand.N(delay/2 + 1) //nolint:gosec // non-cryptographic jitter is intentional
+
+	return delay - jitter
+}
+
+// isAuthError reports whether err is caused by the server rejecting the credentials.
+// revdial v0.5.0 exports no sentinel or type for a rejected token, only the status code in the error of its
+// handshake, so the innermost error of the chain is matched against that exact format; a message that merely
+// contains the same words, like a wrapped network error, does not count as an authentication failure.
+func isAuthError(err error) bool {
+	for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(err) {
+		err = inner
+	}
+
+	if err == nil {
+		return false
+	}
+
+	var status int
+
+	if _, scanErr := fmt.Sscanf(err.Error(), "failed to authenticate %d", &status); scanErr != nil {
+		return false
+	}
+
+	return err.Error() == fmt.Sprintf("failed to authenticate %d", status)
+}
@@ -0,0 +1,56 @@
+package revclient
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int
+		max     time.Duration
+	}{
+		{name: "first attempt", attempt: 1, max: time.Second},
+		{name: "second attempt", attempt: 2, max: 2 * time.Second},
+		{name: "fourth attempt", attempt: 4, max: 8 * time.Second},
+		{name: "capped", attempt: 10, max: time.Minute},
+		{name: "large attempt does not overflow", attempt: 1000, max: time.Minute},
+		{name: "zero attempt", attempt: 0, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				delay := backoff(tt.attempt)
+
+				assert.GreaterOrEqual(t, delay, tt.max/2)
+				assert.LessOrEqual(t, delay, tt.max)
+			}
+		})
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	tests := []struct {
+		err  error
+		name string
+		want bool
+	}{
+		{name: "nil error", err: nil, want: false},
+		{name: "auth failure", err: fmt.Errorf("failed to register client: %w", errors.New("failed to authenticate 1")), want: true},
+		{name: "connection failure", err: errors.New("failed to connect to dialler server: connection refused"), want: false},
+		{name: "wrapped message with the same words", err: fmt.Errorf("failed to authenticate 1: %w", errors.New("connection reset")), want: false},
+		{name: "not a status code", err: errors.New("failed to authenticate user"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isAuthError(tt.err))
+		})
+	}
+}
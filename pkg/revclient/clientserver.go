@@ -3,33 +3,71 @@ package revclient
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"net/url"
 	"sync"
 	"time"
 
+	"github.com/ksysoev/make-it-public/pkg/core/conn/dgram"
 	"github.com/ksysoev/make-it-public/pkg/core/conn/meta"
 	"github.com/ksysoev/make-it-public/pkg/core/token"
 	"github.com/ksysoev/revdial"
 	"golang.org/x/sync/errgroup"
 )
 
+// udpIdleTimeout is the time after which a UDP session without traffic in either direction is closed,
+// it is longer than the idle timeout of the server so that sessions normally end on the server side.
+const udpIdleTimeout = 2 * time.Minute
+
+// minStableSession is how long a session has to last before the client considers the connection
+// healthy again and resets the reconnection backoff, so that a server that accepts the client and
+// then immediately drops it or announces it is going away is not retried in a tight loop.
+const minStableSession = 30 * time.Second
+
+var (
+	// ErrAuthFailed is returned when the server rejects the token, reconnecting is pointless in this case.
+	ErrAuthFailed = errors.New("authentication failed")
+
+	errConnectionLost  = errors.New("connection to server lost")
+	errServerGoingAway = errors.New("server is going away")
+)
+
+// Config holds the client settings.
+// MaxRetries limits the number of consecutive reconnection attempts, 0 means the client reconnects forever.
+// Routes dispatch the requests of a web tunnel to several local services by path, DestAddr then serves the
+// requests matching no route.
 type Config struct {
 	ServerAddr string
 	DestAddr   string
+	Subdomain  string
+	Routes     []Route
+	MaxRetries int
 	NoTLS      bool
 	Insecure   bool
 	EnableV2   bool
 }
 
+// Tapper receives a copy of the traffic of every connection.
+// Tap is called for each connection with the IP of the end user and dest, which returns the URL of the local service
+// a request for the given URL is forwarded to, or nil if no route matches it. Tap returns writers for the bytes sent
+// to the exposed service (inbound) and back to the end user (outbound), which are closed when the connection ends.
+type Tapper interface {
+	Tap(clientIP string, dest func(*url.URL) *url.URL) (inbound, outbound io.WriteCloser)
+}
+
 type ClientServer struct {
-	onConnected func(url string)
-	onRequest   func(clientIP string)
-	token       *token.Token
-	cfg         Config
-	wg          sync.WaitGroup
+	tapper         Tapper
+	onConnected    func(url string)
+	onRequest      func(clientIP string)
+	onReconnecting func(attempt int, delay time.Duration, err error)
+	token          *token.Token
+	router         *router
+	cfg            Config
+	wg             sync.WaitGroup
 }
 
 // Option is a functional option for configuring ClientServer.
@@ -52,6 +90,23 @@ func WithOnRequest(fn func(clientIP string)) Option {
 	}
 }
 
+// WithOnReconnecting sets a callback function that is called when the connection to the server
+// is lost and the client is about to reconnect. The callback receives the number of the attempt,
+// the delay before it and the reason the previous connection ended. When set, it replaces the
+// default slog message.
+func WithOnReconnecting(fn func(attempt int, delay time.Duration, err error)) Option {
+	return func(c *ClientServer) {
+		c.onReconnecting = fn
+	}
+}
+
+// WithTapper sets a Tapper that receives a copy of the traffic of every connection, for example to inspect it.
+func WithTapper(t Tapper) Option {
+	return func(c *ClientServer) {
+		c.tapper = t
+	}
+}
+
 type Conn interface {
 	net.Conn
 	CloseWrite() error
@@ -93,7 +148,137 @@ func NewClientServer(cfg Config, tkn *token.Token, opts ...Option) *ClientServer
 	return cs
 }
 
+// Run connects to the server and serves incoming connections until ctx is cancelled.
+// When the connection to the server is lost or the server announces it is going away, the client reconnects
+// using exponential backoff with jitter, giving up after MaxRetries consecutive failed attempts if it is set.
+// A session counts as successful and resets the backoff only if it lasted at least minStableSession.
+// Returns ErrAuthFailed if the server rejects the token, an error if a route is invalid,
+// or an error if the client gives up reconnecting.
 func (s *ClientServer) Run(ctx context.Context) error {
+	if len(s.cfg.Routes) > 0 {
+		rt, err := newRouter(s.cfg.Routes, s.cfg.DestAddr)
+		if err != nil {
+			return fmt.Errorf("invalid routes: %w", err)
+		}
+
+		s.router = rt
+		defer rt.transport.CloseIdleConnections()
+	}
+
+	defer s.wg.Wait()
+
+	failures := 0
+
+	for {
+		goingAway := make(chan struct{}, 1)
+
+		opts, err := s.listenerOptions(ctx, goingAway)
+		if err != nil {
+			return err
+		}
+
+		started := time.Now()
+		connected, err := s.runSession(ctx, opts, goingAway)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, ErrAuthFailed):
+			return err
+		case connected && time.Since(started) >= minStableSession:
+			failures = 0
+		}
+
+		failures++
+
+		if s.cfg.MaxRetries > 0 && failures > s.cfg.MaxRetries {
+			return fmt.Errorf("giving up after %d reconnection attempts: %w", s.cfg.MaxRetries, err)
+		}
+
+		delay := backoff(failures)
+
+		if s.onReconnecting != nil {
+			s.onReconnecting(failures, delay, err)
+		} else {
+			slog.WarnContext(ctx, "connection to server lost, reconnecting",
+				slog.Int("attempt", failures),
+				slog.Duration("delay", delay),
+				slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runSession establishes a single connection to the server using opts and serves it until the connection is lost,
+// the server announces it is going away through goingAway or ctx is cancelled.
+// It returns connected set to true if the client managed to register with the server, and the reason the session ended.
+// Returns an error wrapping ErrAuthFailed if the server rejects the token.
+func (s *ClientServer) runSession(ctx context.Context, opts []revdial.ListenerOption, goingAway <-chan struct{}) (connected bool, err error) {
+	slog.DebugContext(ctx, "connecting to server", slog.String("server", s.cfg.ServerAddr))
+
+	listener, err := revdial.Listen(ctx, s.cfg.ServerAddr, opts...)
+	if err != nil {
+		if isAuthError(err) {
+			return false, fmt.Errorf("%w: %w", ErrAuthFailed, err)
+		}
+
+		slog.ErrorContext(ctx, "failed to connect to server",
+			slog.Any("error", err),
+			slog.String("server", s.cfg.ServerAddr),
+			slog.Bool("v2_enabled", s.cfg.EnableV2),
+			slog.String("hint", "If connection fails, try using --disable-v2 flag for V1 fallback"))
+
+		return false, err
+	}
+
+	served := make(chan error, 1)
+
+	go func() { served <- s.listenAndServe(ctx, listener) }()
+
+	select {
+	case <-ctx.Done():
+		_ = listener.Close()
+		<-served
+
+		return true, nil
+	case err := <-served:
+		_ = listener.Close()
+
+		if errors.Is(err, revdial.ErrListenerClosed) {
+			err = errConnectionLost
+		}
+
+		return true, err
+	case <-goingAway:
+		// The old session keeps serving in-flight connections until the server closes it,
+		// while a new session is established in parallel.
+		s.wg.Add(1)
+
+		go func() {
+			defer s.wg.Done()
+
+			select {
+			case <-ctx.Done():
+				_ = listener.Close()
+				<-served
+			case <-served:
+				_ = listener.Close()
+			}
+		}()
+
+		return true, errServerGoingAway
+	}
+}
+
+// listenerOptions builds the revdial listener options for a new session.
+// goingAway receives a value when the server announces that it is shutting down.
+// Returns an error if any of the options cannot be created.
+func (s *ClientServer) listenerOptions(ctx context.Context, goingAway chan<- struct{}) ([]revdial.ListenerOption, error) {
 	opts := []revdial.ListenerOption{}
 
 	slog.DebugContext(ctx, "initializing revdial client",
@@ -102,9 +287,9 @@ func (s *ClientServer) Run(ctx context.Context) error {
 		slog.Bool("no_tls", s.cfg.NoTLS),
 		slog.Bool("insecure", s.cfg.Insecure))
 
-	authOpt, err := revdial.WithUserPass(s.token.IDWithType(), s.token.Secret)
+	authOpt, err := revdial.WithUserPass(token.UsernameWithSubdomain(s.token.IDWithType(), s.cfg.Subdomain), s.token.Secret)
 	if err != nil {
-		return fmt.Errorf("failed to create auth option: %w", err)
+		return nil, fmt.Errorf("failed to create auth option: %w", err)
 	}
 
 	opts = append(opts, authOpt)
@@ -125,15 +310,29 @@ func (s *ClientServer) Run(ctx context.Context) error {
 		}
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create event handler: %w", err)
+		return nil, fmt.Errorf("failed to create event handler: %w", err)
 	}
 
 	opts = append(opts, onConnect)
 
+	onGoingAway, err := revdial.WithEventHandler("serverGoingAway", func(_ revdial.Event) {
+		slog.InfoContext(ctx, "server is going away, reconnecting")
+
+		select {
+		case goingAway <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event handler: %w", err)
+	}
+
+	opts = append(opts, onGoingAway)
+
 	if !s.cfg.NoTLS {
 		host, _, err := net.SplitHostPort(s.cfg.ServerAddr)
 		if err != nil {
-			return fmt.Errorf("failed to split host and port: %w", err)
+			return nil, fmt.Errorf("failed to split host and port: %w", err)
 		}
 
 		tlsConf := revdial.WithListenerTLSConfig(&tls.Config{
@@ -154,33 +353,7 @@ func (s *ClientServer) Run(ctx context.Context) error {
 		slog.DebugContext(ctx, "V2 disabled, using V1 protocol (use without --disable-v2 to enable V2)")
 	}
 
-	slog.DebugContext(ctx, "connecting to server", slog.String("server", s.cfg.ServerAddr))
-
-	listener, err := revdial.Listen(ctx, s.cfg.ServerAddr, opts...)
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to connect to server",
-			slog.Any("error", err),
-			slog.String("server", s.cfg.ServerAddr),
-			slog.Bool("v2_enabled", s.cfg.EnableV2),
-			slog.String("hint", "If connection fails, try using --disable-v2 flag for V1 fallback"))
-
-		return err
-	}
-
-	go func() {
-		<-ctx.Done()
-
-		_ = listener.Close()
-	}()
-
-	defer s.wg.Wait()
-
-	err = s.listenAndServe(ctx, listener)
-	if err != nil && err != revdial.ErrListenerClosed {
-		return err
-	}
-
-	return nil
+	return opts, nil
 }
 
 func (s *ClientServer) listenAndServe(ctx context.Context, listener net.Listener) error {
@@ -218,6 +391,16 @@ func (s *ClientServer) handleConn(ctx context.Context, conn net.Conn) {
 
 	defer slog.DebugContext(ctx, "closing connection", "clientIP", connMeta.IP)
 
+	if s.token.Type == token.TokenTypeUDP {
+		s.serveUDP(ctx, conn)
+		return
+	}
+
+	if s.router != nil {
+		s.serveRoutes(ctx, conn, connMeta.IP)
+		return
+	}
+
 	d := net.Dialer{
 		Timeout: 5 * time.Second,
 	}
@@ -235,10 +418,24 @@ func (s *ClientServer) handleConn(ctx context.Context, conn net.Conn) {
 	// Ensure destConn is fully closed after piping completes
 	defer func() { _ = destConn.Close() }()
 
+	var src, dst Conn = revConn, destConn
+
+	if s.tapper != nil {
+		inbound, outbound := s.tapper.Tap(connMeta.IP, s.destination)
+
+		defer func() {
+			_ = inbound.Close()
+			_ = outbound.Close()
+		}()
+
+		src = &tappedConn{Conn: revConn, tap: inbound}
+		dst = &tappedConn{Conn: destConn, tap: outbound}
+	}
+
 	eg, ctx := errgroup.WithContext(ctx)
 
-	eg.Go(pipeConn(ctx, revConn, destConn))
-	eg.Go(pipeConn(ctx, destConn, revConn))
+	eg.Go(pipeConn(ctx, src, destConn))
+	eg.Go(pipeConn(ctx, dst, revConn))
 
 	go func() {
 		<-ctx.Done()
@@ -252,6 +449,138 @@ func (s *ClientServer) handleConn(ctx context.Context, conn net.Conn) {
 	}
 }
 
+// serveUDP relays the datagrams of a single end-user session between conn, which carries them as dgram frames,
+// and the exposed UDP service. The session ends when either side fails or no datagram is exchanged for udpIdleTimeout.
+// The traffic of UDP sessions is not tapped.
+func (s *ClientServer) serveUDP(ctx context.Context, conn net.Conn) {
+	var d net.Dialer
+
+	dConn, err := d.DialContext(ctx, "udp", s.cfg.DestAddr)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to dial", "err", err)
+		return
+	}
+
+	defer func() { _ = dConn.Close() }()
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	idle := time.AfterFunc(udpIdleTimeout, func() {
+		_ = dConn.Close()
+		_ = conn.Close()
+	})
+	defer idle.Stop()
+
+	eg.Go(func() error {
+		buf := make([]byte, dgram.MaxSize)
+
+		for {
+			n, err := dgram.ReadFrame(conn, buf)
+			if err != nil {
+				return fmt.Errorf("error reading datagram from server: %w", err)
+			}
+
+			idle.Reset(udpIdleTimeout)
+
+			if _, err := dConn.Write(buf[:n]); err != nil {
+				return fmt.Errorf("error sending datagram: %w", err)
+			}
+		}
+	})
+
+	eg.Go(func() error {
+		buf := make([]byte, dgram.MaxSize)
+
+		for {
+			n, err := dConn.Read(buf)
+			if err != nil {
+				return fmt.Errorf("error receiving datagram: %w", err)
+			}
+
+			idle.Reset(udpIdleTimeout)
+
+			if err := dgram.WriteFrame(conn, buf[:n]); err != nil {
+				return fmt.Errorf("error writing datagram to server: %w", err)
+			}
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+
+		_ = dConn.Close()
+		_ = conn.Close()
+	}()
+
+	if err := eg.Wait(); err != nil {
+		slog.DebugContext(ctx, "UDP session closed", slog.Any("error", err))
+	}
+}
+
+// serveRoutes serves the HTTP requests carried by conn, forwarding each of them to the local service of the route
+// matching its path. The tapper, if any, receives the requests and responses as they pass through conn.
+func (s *ClientServer) serveRoutes(ctx context.Context, conn net.Conn, clientIP string) {
+	if s.tapper != nil {
+		inbound, outbound := s.tapper.Tap(clientIP, s.destination)
+
+		defer func() {
+			_ = inbound.Close()
+			_ = outbound.Close()
+		}()
+
+		conn = &tappedConn{Conn: wrapConn(conn), tap: inbound, writeTap: outbound}
+	}
+
+	s.router.serve(ctx, conn)
+}
+
+// destination returns the URL of the local service a request for u is forwarded to, or nil if no route matches it.
+func (s *ClientServer) destination(u *url.URL) *url.URL {
+	if s.router != nil {
+		return s.router.destination(u)
+	}
+
+	dest := *u
+	dest.Scheme = "http"
+	dest.Host = s.cfg.DestAddr
+
+	return &dest
+}
+
+// tappedConn copies everything read from the connection to tap, and everything written to it to writeTap if set.
+// Errors writing to tap are ignored, the tap stops receiving data after the first one.
+type tappedConn struct {
+	Conn
+	tap         io.Writer
+	writeTap    io.Writer
+	failed      bool
+	writeFailed bool
+}
+
+func (c *tappedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+
+	if n > 0 && !c.failed {
+		if _, werr := c.tap.Write(p[:n]); werr != nil {
+			c.failed = true
+		}
+	}
+
+	return n, err
+}
+
+func (c *tappedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+
+	if n > 0 && c.writeTap != nil && !c.writeFailed {
+		if _, werr := c.writeTap.Write(p[:n]); werr != nil {
+			c.writeFailed = true
+		}
+	}
+
+	return n, err
+}
+
 // pipeConn facilitates data transfer from the source connection to the destination connection in a single direction.
 // It utilizes io.Copy for copying data and closes the writing end of the destination connection afterward.
 // Accepts src as the source Conn interface and dst as the destination Conn interface, both supporting a CloseWrite method.
@@ -0,0 +1,241 @@
+package revclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/conn/dgram"
+	"github.com/ksysoev/make-it-public/pkg/core/conn/meta"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/revdial/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closedAddr returns the address of a TCP port that nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := l.Addr().String()
+
+	require.NoError(t, l.Close())
+
+	return addr
+}
+
+func TestClientServer_Run_GivesUpAfterMaxRetries(t *testing.T) {
+	var attempts []int
+
+	cli := NewClientServer(Config{
+		ServerAddr: closedAddr(t),
+		NoTLS:      true,
+		MaxRetries: 1,
+	}, &token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeWeb},
+		WithOnReconnecting(func(attempt int, _ time.Duration, err error) {
+			assert.Error(t, err)
+
+			attempts = append(attempts, attempt)
+		}),
+	)
+
+	err := cli.Run(context.Background())
+
+	assert.ErrorContains(t, err, "giving up after 1 reconnection attempts")
+	assert.Equal(t, []int{1}, attempts)
+}
+
+func TestClientServer_Run_StopsOnContextCancel(t *testing.T) {
+	cli := NewClientServer(Config{
+		ServerAddr: closedAddr(t),
+		NoTLS:      true,
+	}, &token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeWeb})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() { done <- cli.Run(ctx) }()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after context cancel")
+	}
+}
+
+func TestClientServer_Run_AuthFailure(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			srv := proto.NewServerV2(conn, []proto.ServerOption{
+				proto.WithUserPassAuth(func(_, _ string) bool { return false }),
+			})
+
+			_ = srv.Process()
+			_ = conn.Close()
+		}
+	}()
+
+	reconnected := false
+
+	cli := NewClientServer(Config{
+		ServerAddr: l.Addr().String(),
+		NoTLS:      true,
+	}, &token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeWeb},
+		WithOnReconnecting(func(int, time.Duration, error) { reconnected = true }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = cli.Run(ctx)
+
+	assert.ErrorIs(t, err, ErrAuthFailed)
+	assert.False(t, reconnected, "client should not reconnect when authentication fails")
+}
+
+func TestClientServer_Run_BacksOffOnRepeatedGoingAway(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			srv := proto.NewServerV2(conn, []proto.ServerOption{
+				proto.WithUserPassAuth(func(_, _ string) bool { return true }),
+			})
+
+			if err := srv.Process(); err == nil {
+				_ = srv.SendCustomEvent("serverGoingAway", nil)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var attempts []int
+
+	cli := NewClientServer(Config{
+		ServerAddr: l.Addr().String(),
+		NoTLS:      true,
+	}, &token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeWeb},
+		WithOnReconnecting(func(attempt int, _ time.Duration, err error) {
+			assert.ErrorIs(t, err, errServerGoingAway)
+
+			if attempts = append(attempts, attempt); attempt == 2 {
+				cancel()
+			}
+		}),
+	)
+
+	err = cli.Run(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, attempts, "short sessions ended by the server should not reset the backoff")
+}
+
+func TestTappedConn_Read(t *testing.T) {
+	client, server := net.Pipe()
+
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	var tap bytes.Buffer
+
+	conn := &tappedConn{Conn: wrapConn(server), tap: &tap}
+
+	go func() { _, _ = client.Write([]byte("hello")) }()
+
+	buf := make([]byte, 5)
+
+	_, err := io.ReadFull(conn, buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", string(buf))
+	assert.Equal(t, "hello", tap.String())
+}
+
+func TestClientServer_HandleConn_UDP(t *testing.T) {
+	// The exposed service echoes every datagram back in upper case.
+	svc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = svc.Close() })
+
+	go func() {
+		buf := make([]byte, 64)
+
+		for {
+			n, addr, err := svc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			_, _ = svc.WriteTo(bytes.ToUpper(buf[:n]), addr)
+		}
+	}()
+
+	cli := NewClientServer(Config{DestAddr: svc.LocalAddr().String()},
+		&token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeUDP})
+
+	server, conn := net.Pipe()
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		cli.handleConn(context.Background(), conn)
+	}()
+
+	require.NoError(t, server.SetDeadline(time.Now().Add(5*time.Second)))
+	require.NoError(t, meta.WriteData(server, &meta.ClientConnMeta{IP: "203.0.113.7"}))
+
+	buf := make([]byte, dgram.MaxSize)
+
+	for _, msg := range []string{"ping", "pong"} {
+		require.NoError(t, dgram.WriteFrame(server, []byte(msg)))
+
+		n, err := dgram.ReadFrame(server, buf)
+		require.NoError(t, err)
+		assert.Equal(t, strings.ToUpper(msg), string(buf[:n]))
+	}
+
+	require.NoError(t, server.Close())
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handleConn did not return after the server closed the session")
+	}
+}
@@ -0,0 +1,276 @@
+package revclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route forwards the HTTP requests whose path starts with Prefix to the local service at Target.
+// Prefix matches whole path segments, so "/api" matches "/api" and "/api/users" but not "/apix".
+// With StripPrefix the prefix is removed from the path before the request is forwarded.
+type Route struct {
+	Prefix      string
+	Target      string
+	StripPrefix bool
+}
+
+// ParseRoute parses a route given as <prefix>=<host:port>, optionally followed by ",strip" to strip the prefix.
+// Returns an error if the prefix does not start with a slash or the target is not a host:port address.
+func ParseRoute(spec string) (Route, error) {
+	spec, opt, hasOpt := strings.Cut(spec, ",")
+
+	prefix, target, ok := strings.Cut(spec, "=")
+	if !ok || prefix == "" || target == "" {
+		return Route{}, fmt.Errorf("route %q must be <prefix>=<host:port>", spec)
+	}
+
+	if hasOpt && opt != "strip" {
+		return Route{}, fmt.Errorf("route %q has unknown option %q", spec, opt)
+	}
+
+	r := Route{Prefix: prefix, Target: target, StripPrefix: hasOpt}
+
+	if err := r.validate(); err != nil {
+		return Route{}, err
+	}
+
+	return r, nil
+}
+
+// String returns a human-readable description of the route.
+func (r Route) String() string {
+	if r.StripPrefix {
+		return r.Prefix + " -> " + r.Target + " (strip)"
+	}
+
+	return r.Prefix + " -> " + r.Target
+}
+
+func (r Route) validate() error {
+	if !strings.HasPrefix(r.Prefix, "/") {
+		return fmt.Errorf("route prefix %q must start with /", r.Prefix)
+	}
+
+	if _, _, err := net.SplitHostPort(r.Target); err != nil {
+		return fmt.Errorf("route target %q must be host:port: %w", r.Target, err)
+	}
+
+	return nil
+}
+
+// matches reports whether path is the prefix of the route or one of the paths below it.
+func (r Route) matches(path string) bool {
+	prefix := strings.TrimSuffix(r.Prefix, "/")
+
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	rest := path[len(prefix):]
+
+	return rest == "" || rest[0] == '/'
+}
+
+// strip removes the prefix of the route from path, the result always starts with a slash.
+func (r Route) strip(path string) string {
+	path = strings.TrimPrefix(path, strings.TrimSuffix(r.Prefix, "/"))
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return path
+}
+
+// destination returns the URL a request for u is forwarded to by the route.
+func (r Route) destination(u *url.URL) *url.URL {
+	dest := *u
+	dest.Scheme = "http"
+	dest.Host = r.Target
+
+	if r.StripPrefix {
+		dest.Path = r.strip(u.Path)
+		dest.RawPath = ""
+	}
+
+	return &dest
+}
+
+// router dispatches HTTP requests to the local services of the routes, the route with the longest prefix wins.
+type router struct {
+	transport *http.Transport
+	routes    []Route
+	proxies   []*httputil.ReverseProxy
+}
+
+// newRouter creates a router for routes, with defaultTarget serving the requests that match no route if it is set.
+// Requests are forwarded with the Host header and the forwarding headers they came with, as when the tunnel
+// forwards connections to a single service.
+// Returns an error if any of the routes is invalid.
+func newRouter(routes []Route, defaultTarget string) (*router, error) {
+	routes = slices.Clone(routes)
+
+	if defaultTarget != "" && !slices.ContainsFunc(routes, func(r Route) bool { return r.Prefix == "/" }) {
+		routes = append(routes, Route{Prefix: "/", Target: defaultTarget})
+	}
+
+	for _, r := range routes {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortStableFunc(routes, func(a, b Route) int { return len(b.Prefix) - len(a.Prefix) })
+
+	rt := &router{
+		routes: routes,
+		transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	for _, r := range routes {
+		rt.proxies = append(rt.proxies, &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.Out.URL = r.destination(pr.In.URL)
+				pr.Out.Host = pr.In.Host
+
+				for _, h := range []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+					if v, ok := pr.In.Header[h]; ok {
+						pr.Out.Header[h] = v
+					}
+				}
+			},
+			Transport:    rt.transport,
+			ErrorHandler: proxyErrorHandler(r.Target),
+		})
+	}
+
+	return rt, nil
+}
+
+// ServeHTTP forwards r to the local service of the route matching its path, or responds with 404 if none does.
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if i := rt.match(r.URL.Path); i >= 0 {
+		rt.proxies[i].ServeHTTP(w, r)
+		return
+	}
+
+	http.Error(w, "no route for "+r.URL.Path, http.StatusNotFound)
+}
+
+// destination returns the URL a request for u is forwarded to, or nil if no route matches it.
+func (rt *router) destination(u *url.URL) *url.URL {
+	if i := rt.match(u.Path); i >= 0 {
+		return rt.routes[i].destination(u)
+	}
+
+	return nil
+}
+
+// match returns the index of the route matching path, or -1 if none does.
+func (rt *router) match(path string) int {
+	return slices.IndexFunc(rt.routes, func(r Route) bool { return r.matches(path) })
+}
+
+// proxyErrorHandler returns the handler responding with 502 when the local service at target cannot be reached.
+func proxyErrorHandler(target string) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(r.Context(), "failed to forward request", slog.String("target", target), slog.Any("error", err))
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+// serve serves the HTTP requests carried by conn until conn is closed or ctx is cancelled.
+func (rt *router) serve(ctx context.Context, conn net.Conn) {
+	l := newConnListener(conn)
+
+	srv := &http.Server{
+		Handler:           rt,
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug),
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = srv.Close()
+		case <-l.done:
+		}
+	}()
+
+	if err := srv.Serve(l); err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, http.ErrServerClosed) {
+		slog.DebugContext(ctx, "failed to serve routed connection", slog.Any("error", err))
+	}
+}
+
+// connListener is a net.Listener accepting a single connection.
+// Once the connection is accepted, Accept blocks until the connection or the listener is closed.
+type connListener struct {
+	conn net.Conn
+	done chan struct{}
+	once sync.Once
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	l := &connListener{done: make(chan struct{})}
+	l.conn = &notifyConn{Conn: conn, close: l.closeDone}
+
+	return l
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	if conn := l.conn; conn != nil {
+		l.conn = nil
+		return conn, nil
+	}
+
+	<-l.done
+
+	return nil, net.ErrClosed
+}
+
+func (l *connListener) Close() error {
+	l.closeDone()
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return dummyAddr{}
+}
+
+func (l *connListener) closeDone() {
+	l.once.Do(func() { close(l.done) })
+}
+
+// notifyConn calls close once the connection is closed.
+type notifyConn struct {
+	net.Conn
+	close func()
+}
+
+func (c *notifyConn) Close() error {
+	defer c.close()
+
+	return c.Conn.Close()
+}
+
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string { return "tunnel" }
+func (dummyAddr) String() string  { return "tunnel" }
@@ -0,0 +1,204 @@
+package revclient
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/conn/meta"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+		want    Route
+	}{
+		{name: "route", spec: "/api=localhost:8080", want: Route{Prefix: "/api", Target: "localhost:8080"}},
+		{name: "strip prefix", spec: "/api=localhost:8080,strip", want: Route{Prefix: "/api", Target: "localhost:8080", StripPrefix: true}},
+		{name: "root", spec: "/=127.0.0.1:3000", want: Route{Prefix: "/", Target: "127.0.0.1:3000"}},
+		{name: "missing target", spec: "/api", wantErr: "must be <prefix>=<host:port>"},
+		{name: "relative prefix", spec: "api=localhost:8080", wantErr: "must start with /"},
+		{name: "target without port", spec: "/api=localhost", wantErr: "must be host:port"},
+		{name: "unknown option", spec: "/api=localhost:8080,rewrite", wantErr: "unknown option"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRoute(tt.spec)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, r)
+		})
+	}
+}
+
+func TestRoute_Matches(t *testing.T) {
+	api := Route{Prefix: "/api"}
+
+	assert.True(t, api.matches("/api"))
+	assert.True(t, api.matches("/api/users"))
+	assert.False(t, api.matches("/apix"))
+	assert.False(t, api.matches("/"))
+
+	assert.True(t, Route{Prefix: "/api/"}.matches("/api/users"))
+	assert.True(t, Route{Prefix: "/"}.matches("/anything"))
+}
+
+func TestRoute_Strip(t *testing.T) {
+	api := Route{Prefix: "/api"}
+
+	assert.Equal(t, "/users", api.strip("/api/users"))
+	assert.Equal(t, "/", api.strip("/api"))
+	assert.Equal(t, "/users", Route{Prefix: "/api/"}.strip("/api/users"))
+	assert.Equal(t, "/users", Route{Prefix: "/"}.strip("/users"))
+}
+
+// echoPathServer returns the address of a local service responding with its name, the request path and the Host header.
+func echoPathServer(t *testing.T, name string) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, name+" "+r.URL.Path+" "+r.Host)
+	}))
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestClientServer_HandleConn_Routes(t *testing.T) {
+	api := echoPathServer(t, "api")
+	web := echoPathServer(t, "web")
+
+	cli := NewClientServer(Config{DestAddr: web}, &token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeWeb})
+
+	rt, err := newRouter([]Route{
+		{Prefix: "/api", Target: api, StripPrefix: true},
+		{Prefix: "/api/v1", Target: api},
+	}, cli.cfg.DestAddr)
+	require.NoError(t, err)
+
+	cli.router = rt
+
+	server, conn := net.Pipe()
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		cli.handleConn(context.Background(), conn)
+	}()
+
+	require.NoError(t, server.SetDeadline(time.Now().Add(5*time.Second)))
+	require.NoError(t, meta.WriteData(server, &meta.ClientConnMeta{IP: "203.0.113.7"}))
+
+	br := bufio.NewReader(server)
+
+	// Requests of the same connection are dispatched independently.
+	for path, want := range map[string]string{
+		"/api/users":    "api /users demo.example.com",
+		"/api/v1/users": "api /api/v1/users demo.example.com",
+		"/index.html":   "web /index.html demo.example.com",
+	} {
+		req, err := http.NewRequest(http.MethodGet, "http://demo.example.com"+path, http.NoBody)
+		require.NoError(t, err)
+		require.NoError(t, req.Write(server))
+
+		resp, err := http.ReadResponse(br, req)
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, want, string(body))
+	}
+
+	require.NoError(t, server.Close())
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handleConn did not return after the server closed the connection")
+	}
+}
+
+func TestRouter_ServeHTTP(t *testing.T) {
+	rt, err := newRouter([]Route{{Prefix: "/api", Target: closedAddr(t)}}, "")
+	require.NoError(t, err)
+
+	t.Run("no route", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/index.html", http.NoBody))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("unreachable service", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", http.NoBody))
+
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+	})
+}
+
+func TestClientServer_Destination(t *testing.T) {
+	rt, err := newRouter([]Route{{Prefix: "/api", Target: "localhost:8080", StripPrefix: true}}, "")
+	require.NoError(t, err)
+
+	routed := NewClientServer(Config{}, &token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeWeb})
+	routed.router = rt
+
+	direct := NewClientServer(Config{DestAddr: "localhost:3000"}, &token.Token{ID: "test", Secret: "secret", Type: token.TokenTypeWeb})
+
+	tests := []struct {
+		cli    *ClientServer
+		name   string
+		target string
+		want   string
+	}{
+		{name: "route", cli: routed, target: "/api/users?page=2", want: "http://localhost:8080/users?page=2"},
+		{name: "no route", cli: routed, target: "/index.html", want: ""},
+		{name: "direct", cli: direct, target: "/index.html?v=1", want: "http://localhost:3000/index.html?v=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.ParseRequestURI(tt.target)
+			require.NoError(t, err)
+
+			dest := tt.cli.destination(u)
+			if tt.want == "" {
+				assert.Nil(t, dest)
+				return
+			}
+
+			require.NotNil(t, dest)
+			assert.Equal(t, tt.want, dest.String())
+		})
+	}
+}
+
+func TestNewRouter_InvalidRoute(t *testing.T) {
+	_, err := newRouter([]Route{{Prefix: "api", Target: "localhost:8080"}}, "")
+	assert.ErrorContains(t, err, "must start with /")
+}
@@ -6,11 +6,11 @@ package tcpedge
 
 import (
 	context "context"
+	net "net"
 
 	core "github.com/ksysoev/make-it-public/pkg/core"
+	token "github.com/ksysoev/make-it-public/pkg/core/token"
 	mock "github.com/stretchr/testify/mock"
-
-	net "net"
 )
 
 // MockConnService is an autogenerated mock type for the ConnService type
@@ -26,6 +26,65 @@ func (_m *MockConnService) EXPECT() *MockConnService_Expecter {
 	return &MockConnService_Expecter{mock: &_m.Mock}
 }
 
+// GetIPFilter provides a mock function with given fields: ctx, keyID
+func (_m *MockConnService) GetIPFilter(ctx context.Context, keyID string) (*token.IPFilter, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIPFilter")
+	}
+
+	var r0 *token.IPFilter
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*token.IPFilter, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *token.IPFilter); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*token.IPFilter)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockConnService_GetIPFilter_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetIPFilter'
+type MockConnService_GetIPFilter_Call struct {
+	*mock.Call
+}
+
+// GetIPFilter is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockConnService_Expecter) GetIPFilter(ctx interface{}, keyID interface{}) *MockConnService_GetIPFilter_Call {
+	return &MockConnService_GetIPFilter_Call{Call: _e.mock.On("GetIPFilter", ctx, keyID)}
+}
+
+func (_c *MockConnService_GetIPFilter_Call) Run(run func(ctx context.Context, keyID string)) *MockConnService_GetIPFilter_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_GetIPFilter_Call) Return(_a0 *token.IPFilter, _a1 error) *MockConnService_GetIPFilter_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnService_GetIPFilter_Call) RunAndReturn(run func(context.Context, string) (*token.IPFilter, error)) *MockConnService_GetIPFilter_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // HandleTCPConnection provides a mock function with given fields: ctx, keyID, conn, clientIP
 func (_m *MockConnService) HandleTCPConnection(ctx context.Context, keyID string, conn net.Conn, clientIP string) error {
 	ret := _m.Called(ctx, keyID, conn, clientIP)
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"math/rand/v2"
 	"sync"
+
+	"github.com/ksysoev/make-it-public/pkg/metrics"
 )
 
 // ErrPortPoolExhausted is returned when no ports are available in the configured range.
@@ -20,11 +22,16 @@ type portPool struct {
 
 // newPortPool creates a portPool for the inclusive range [minPort, maxPort].
 func newPortPool(minPort, maxPort int) *portPool {
-	return &portPool{
+	p := &portPool{
 		min:  minPort,
 		max:  maxPort,
 		used: make(map[int]struct{}),
 	}
+
+	metrics.TCPPortsTotal.Set(float64(maxPort - minPort + 1))
+	p.reportUsage()
+
+	return p
 }
 
 // Allocate picks a random available port from the pool.
@@ -45,6 +52,8 @@ func (p *portPool) Allocate() (int, error) {
 		port := p.min + rand.IntN(size) //nolint:gosec // non-cryptographic port selection is intentional
 		if _, inUse := p.used[port]; !inUse {
 			p.used[port] = struct{}{}
+			p.reportUsage()
+
 			return port, nil
 		}
 	}
@@ -53,6 +62,8 @@ func (p *portPool) Allocate() (int, error) {
 	for port := p.min; port <= p.max; port++ {
 		if _, inUse := p.used[port]; !inUse {
 			p.used[port] = struct{}{}
+			p.reportUsage()
+
 			return port, nil
 		}
 	}
@@ -67,6 +78,7 @@ func (p *portPool) Release(port int) {
 	defer p.mu.Unlock()
 
 	delete(p.used, port)
+	p.reportUsage()
 }
 
 // Available returns the number of unallocated ports remaining in the pool.
@@ -76,3 +88,9 @@ func (p *portPool) Available() int {
 
 	return (p.max - p.min + 1) - len(p.used)
 }
+
+// reportUsage publishes the number of unallocated ports to the metrics registry.
+// The caller must hold the lock or own the pool exclusively.
+func (p *portPool) reportUsage() {
+	metrics.TCPPortsAvailable.Set(float64((p.max - p.min + 1) - len(p.used)))
+}
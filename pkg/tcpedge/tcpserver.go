@@ -11,20 +11,22 @@ import (
 	"time"
 
 	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
 )
 
-// ErrKeyIDAlreadyAllocated is returned when Allocate is called for a keyID that
-// already has an active listener.
-var ErrKeyIDAlreadyAllocated = errors.New("keyID already has an active TCP listener")
-
 // ConnService is the subset of core.Service required by the TCP edge server.
 type ConnService interface {
 	HandleTCPConnection(ctx context.Context, keyID string, conn net.Conn, clientIP string) error
+	GetIPFilter(ctx context.Context, keyID string) (*token.IPFilter, error)
 	SetTCPEndpointAllocator(allocator core.TCPEndpointAllocator)
 }
 
 // activeListener tracks a running per-keyID TCP listener and its goroutines.
 //
+// refs counts the MIT clients sharing the listener: several clients may
+// authenticate with the same TCP token, in which case they are all served by
+// a single public port and the listener is torn down when the last one leaves.
+//
 // acceptWG tracks the single acceptLoop goroutine; handlerWG tracks the
 // per-connection handler goroutines.  They are kept separate so that
 // Release() can join acceptLoop first (via acceptWG.Wait()), which
@@ -32,11 +34,13 @@ type ConnService interface {
 // handlerWG.Wait() begins — avoiding the "sync: WaitGroup misuse"
 // panic that would result from Add racing with Wait.
 type activeListener struct {
-	cancel    context.CancelFunc
 	listener  net.Listener
+	cancel    context.CancelFunc
+	endpoint  string
 	acceptWG  sync.WaitGroup // tracks the single acceptLoop goroutine
 	handlerWG sync.WaitGroup // tracks per-connection handler goroutines
 	port      int
+	refs      int
 }
 
 // TCPServer dynamically allocates TCP listeners for each connected MIT client
@@ -76,18 +80,39 @@ func (s *TCPServer) Run(ctx context.Context) error {
 	return nil
 }
 
+// StopAccepting closes the public listeners of all allocated endpoints so that no new end-user
+// connections are accepted, while the connections already in progress keep being served.
+// The endpoints stay allocated until they are released or the server stops.
+func (s *TCPServer) StopAccepting() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, al := range s.listeners {
+		_ = al.listener.Close()
+	}
+}
+
 // Allocate creates a TCP listener for keyID and returns the public endpoint
 // string in the form "host:port".  The listener will accept end-user connections
 // and route them through the tunnel.
 //
 // Allocate is called by core.Service when a TCP MIT client completes
 // authentication (StateRegistered).  It must be balanced by a call to Release.
+// If keyID already has an active listener, its endpoint is shared with the new
+// client instead of allocating another port.
 func (s *TCPServer) Allocate(ctx context.Context, keyID string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.listeners[keyID]; exists {
-		return "", fmt.Errorf("allocate keyID=%s: %w", keyID, ErrKeyIDAlreadyAllocated)
+	if al, exists := s.listeners[keyID]; exists {
+		al.refs++
+
+		slog.DebugContext(ctx, "TCP listener shared",
+			slog.String("keyID", keyID),
+			slog.String("endpoint", al.endpoint),
+			slog.Int("clients", al.refs))
+
+		return al.endpoint, nil
 	}
 
 	port, err := s.portPool.Allocate()
@@ -103,12 +128,17 @@ func (s *TCPServer) Allocate(ctx context.Context, keyID string) (string, error)
 		return "", fmt.Errorf("listen on %s for keyID=%s: %w", addr, keyID, err)
 	}
 
-	listenerCtx, cancel := context.WithCancel(ctx)
+	// The listener may outlive the client that triggered the allocation when
+	// other clients share it, so its lifetime is bound to Release only.
+	listenerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	endpoint := net.JoinHostPort(s.config.Public.Host, strconv.Itoa(port))
 
 	al := &activeListener{
 		listener: ln,
 		port:     port,
+		endpoint: endpoint,
 		cancel:   cancel,
+		refs:     1,
 	}
 
 	s.listeners[keyID] = al
@@ -121,8 +151,6 @@ func (s *TCPServer) Allocate(ctx context.Context, keyID string) (string, error)
 		s.acceptLoop(listenerCtx, al, keyID)
 	}()
 
-	endpoint := net.JoinHostPort(s.config.Public.Host, strconv.Itoa(port))
-
 	slog.InfoContext(ctx, "TCP listener allocated",
 		slog.String("keyID", keyID),
 		slog.String("endpoint", endpoint),
@@ -131,8 +159,10 @@ func (s *TCPServer) Allocate(ctx context.Context, keyID string) (string, error)
 	return endpoint, nil
 }
 
-// Release stops the listener associated with keyID and returns its port to the
-// pool.  It is safe to call Release on a keyID that has already been released.
+// Release drops one client reference from the listener associated with keyID.
+// When the last reference is gone, the listener is stopped and its port is
+// returned to the pool.  It is safe to call Release on a keyID that has already
+// been released.
 //
 // Release is called by core.Service via a deferred call in HandleReverseConn so
 // it executes when the MIT client disconnects.
@@ -145,9 +175,21 @@ func (s *TCPServer) Release(keyID string) {
 		return
 	}
 
+	al.refs--
+	if al.refs > 0 {
+		s.mu.Unlock()
+		return
+	}
+
 	delete(s.listeners, keyID)
 	s.mu.Unlock()
 
+	s.stopListener(keyID, al)
+}
+
+// stopListener closes al, waits for its goroutines to finish and returns its
+// port to the pool.  The listener must already be removed from s.listeners.
+func (s *TCPServer) stopListener(keyID string, al *activeListener) {
 	al.cancel()
 
 	_ = al.listener.Close()
@@ -167,8 +209,8 @@ func (s *TCPServer) acceptLoop(ctx context.Context, al *activeListener, keyID st
 	for {
 		conn, err := al.listener.Accept()
 		if err != nil {
-			if ctx.Err() != nil {
-				return // normal shutdown via Release or server stop
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return // normal shutdown via Release, StopAccepting or server stop
 			}
 
 			slog.ErrorContext(ctx, "TCP accept error",
@@ -196,6 +238,7 @@ func (s *TCPServer) acceptLoop(ctx context.Context, al *activeListener, keyID st
 }
 
 // handleConn routes a single end-user TCP connection through the tunnel.
+// Connections from addresses rejected by the IP filter of the tunnel are closed without being routed.
 func (s *TCPServer) handleConn(ctx context.Context, keyID string, conn net.Conn) {
 	defer func() { _ = conn.Close() }()
 
@@ -208,6 +251,23 @@ func (s *TCPServer) handleConn(ctx context.Context, keyID string, conn net.Conn)
 		slog.String("keyID", keyID),
 		slog.String("clientIP", clientIP))
 
+	filter, err := s.connService.GetIPFilter(ctx, keyID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get TCP tunnel IP filter",
+			slog.String("keyID", keyID),
+			slog.Any("error", err))
+
+		return
+	}
+
+	if filter != nil && !filter.Allows(clientIP) {
+		slog.DebugContext(ctx, "TCP end-user connection rejected by IP filter",
+			slog.String("keyID", keyID),
+			slog.String("clientIP", clientIP))
+
+		return
+	}
+
 	if err := s.connService.HandleTCPConnection(ctx, keyID, conn, clientIP); err != nil {
 		slog.DebugContext(ctx, "TCP connection closed",
 			slog.String("keyID", keyID),
@@ -216,18 +276,15 @@ func (s *TCPServer) handleConn(ctx context.Context, keyID string, conn net.Conn)
 	}
 }
 
-// closeAllListeners shuts down every active listener.  Called on server stop.
+// closeAllListeners shuts down every active listener regardless of how many
+// clients share it.  Called on server stop.
 func (s *TCPServer) closeAllListeners() {
 	s.mu.Lock()
-	keys := make([]string, 0, len(s.listeners))
-
-	for k := range s.listeners {
-		keys = append(keys, k)
-	}
-
+	listeners := s.listeners
+	s.listeners = make(map[string]*activeListener)
 	s.mu.Unlock()
 
-	for _, keyID := range keys {
-		s.Release(keyID)
+	for keyID, al := range listeners {
+		s.stopListener(keyID, al)
 	}
 }
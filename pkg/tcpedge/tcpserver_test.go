@@ -3,10 +3,12 @@ package tcpedge
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/ksysoev/make-it-public/pkg/core/token"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -103,7 +105,7 @@ func TestTCPServer_Allocate_ReturnsEndpoint(t *testing.T) {
 	assert.NotEmpty(t, portStr)
 }
 
-func TestTCPServer_Allocate_DuplicateKeyID(t *testing.T) {
+func TestTCPServer_Allocate_SharedKeyID(t *testing.T) {
 	svc := NewMockConnService(t)
 	svc.EXPECT().SetTCPEndpointAllocator(mock.Anything)
 
@@ -112,11 +114,23 @@ func TestTCPServer_Allocate_DuplicateKeyID(t *testing.T) {
 
 	defer srv.closeAllListeners()
 
-	_, err = srv.Allocate(context.Background(), "dup")
+	initial := srv.portPool.Available()
+
+	first, err := srv.Allocate(context.Background(), "dup")
+	require.NoError(t, err)
+
+	second, err := srv.Allocate(context.Background(), "dup")
 	require.NoError(t, err)
 
-	_, err = srv.Allocate(context.Background(), "dup")
-	assert.ErrorIs(t, err, ErrKeyIDAlreadyAllocated)
+	assert.Equal(t, first, second)
+	assert.Equal(t, initial-1, srv.portPool.Available())
+
+	// The listener stays up until the last client releases it.
+	srv.Release("dup")
+	assert.Equal(t, initial-1, srv.portPool.Available())
+
+	srv.Release("dup")
+	assert.Equal(t, initial, srv.portPool.Available())
 }
 
 func TestTCPServer_Release_FreesPort(t *testing.T) {
@@ -162,6 +176,7 @@ func TestTCPServer_AcceptsAndRoutesConnection(t *testing.T) {
 
 	connReceived := make(chan struct{})
 
+	svc.EXPECT().GetIPFilter(mock.Anything, "routekey").Return(nil, nil)
 	svc.EXPECT().
 		HandleTCPConnection(mock.Anything, "routekey", mock.Anything, mock.MatchedBy(func(ip string) bool {
 			return ip == "127.0.0.1"
@@ -199,6 +214,38 @@ func TestTCPServer_AcceptsAndRoutesConnection(t *testing.T) {
 	}
 }
 
+func TestTCPServer_RejectsFilteredIP(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetTCPEndpointAllocator(mock.Anything)
+
+	filter, err := token.NewIPFilter(nil, []string{"127.0.0.0/8"})
+	require.NoError(t, err)
+
+	svc.EXPECT().GetIPFilter(mock.Anything, "filtered").Return(filter, nil)
+
+	srv, err := New(validConfig(t), svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	endpoint, err := srv.Allocate(context.Background(), "filtered")
+	require.NoError(t, err)
+
+	_, portStr, err := net.SplitHostPort(endpoint)
+	require.NoError(t, err)
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", portStr))
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	// The connection is closed without being routed, HandleTCPConnection is not expected.
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(2*time.Second)))
+
+	_, err = c.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
 func TestTCPServer_PortExhausted(t *testing.T) {
 	// Use a range of exactly 1 port so exhaustion happens on the second Allocate.
 	// min == max is a valid single-port range.
@@ -251,3 +298,31 @@ func TestTCPServer_CloseAllListeners(t *testing.T) {
 	expected := cfg.PortRange.Max - cfg.PortRange.Min + 1
 	assert.Equal(t, expected, srv.portPool.Available())
 }
+
+func TestTCPServer_StopAccepting(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetTCPEndpointAllocator(mock.Anything)
+
+	srv, err := New(validConfig(t), svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	endpoint, err := srv.Allocate(context.Background(), "drainkey")
+	require.NoError(t, err)
+
+	_, portStr, err := net.SplitHostPort(endpoint)
+	require.NoError(t, err)
+
+	srv.StopAccepting()
+
+	_, err = net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", portStr), time.Second)
+	assert.Error(t, err, "listener should not accept connections after StopAccepting")
+
+	// The endpoint stays allocated until it is released.
+	srv.mu.RLock()
+	_, exists := srv.listeners["drainkey"]
+	srv.mu.RUnlock()
+
+	assert.True(t, exists)
+}
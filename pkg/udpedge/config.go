@@ -0,0 +1,69 @@
+package udpedge
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultIdleTimeout is the time after which a UDP session without traffic in either direction is closed.
+	DefaultIdleTimeout = time.Minute
+	// DefaultMaxSessions is the number of concurrent sessions served on the port of a tunnel.
+	DefaultMaxSessions = 1024
+)
+
+// Config holds configuration for the UDP edge server.
+// IdleTimeout defaults to DefaultIdleTimeout, MaxSessions defaults to DefaultMaxSessions.
+type Config struct {
+	ListenHost  string        `mapstructure:"listen_host"`
+	Public      PublicConfig  `mapstructure:"public"`
+	PortRange   PortRange     `mapstructure:"port_range"`
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	MaxSessions int           `mapstructure:"max_sessions"`
+}
+
+// PublicConfig defines the publicly advertised hostname for UDP endpoints.
+type PublicConfig struct {
+	Host string `mapstructure:"host"`
+}
+
+// PortRange defines the inclusive range of UDP ports available for allocation.
+type PortRange struct {
+	Min int `mapstructure:"min"`
+	Max int `mapstructure:"max"`
+}
+
+// Validate checks that the Config is valid. It returns an error describing the
+// first violation found.
+func (c *Config) Validate() error {
+	if c.ListenHost == "" {
+		return errors.New("listen_host must not be empty")
+	}
+
+	if c.Public.Host == "" {
+		return errors.New("public.host must not be empty")
+	}
+
+	if c.PortRange.Min < 1024 {
+		return fmt.Errorf("port_range.min must be >= 1024, got %d", c.PortRange.Min)
+	}
+
+	if c.PortRange.Max > 65535 {
+		return fmt.Errorf("port_range.max must be <= 65535, got %d", c.PortRange.Max)
+	}
+
+	if c.PortRange.Min > c.PortRange.Max {
+		return fmt.Errorf("port_range.min (%d) must be <= port_range.max (%d)", c.PortRange.Min, c.PortRange.Max)
+	}
+
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("idle_timeout must not be negative, got %s", c.IdleTimeout)
+	}
+
+	if c.MaxSessions < 0 {
+		return fmt.Errorf("max_sessions must not be negative, got %d", c.MaxSessions)
+	}
+
+	return nil
+}
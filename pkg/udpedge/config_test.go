@@ -0,0 +1,47 @@
+package udpedge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{
+		ListenHost: "0.0.0.0",
+		Public:     PublicConfig{Host: "example.com"},
+		PortRange:  PortRange{Min: 10000, Max: 20000},
+	}
+
+	tests := []struct {
+		modify  func(c *Config)
+		name    string
+		wantErr string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{name: "single port", modify: func(c *Config) { c.PortRange.Max = c.PortRange.Min }},
+		{name: "empty listen host", modify: func(c *Config) { c.ListenHost = "" }, wantErr: "listen_host"},
+		{name: "empty public host", modify: func(c *Config) { c.Public.Host = "" }, wantErr: "public.host"},
+		{name: "privileged min port", modify: func(c *Config) { c.PortRange.Min = 53 }, wantErr: "port_range.min must be >= 1024"},
+		{name: "max above 65535", modify: func(c *Config) { c.PortRange.Max = 70000 }, wantErr: "port_range.max"},
+		{name: "min greater than max", modify: func(c *Config) { c.PortRange.Min = 30000 }, wantErr: "must be <= port_range.max"},
+		{name: "negative idle timeout", modify: func(c *Config) { c.IdleTimeout = -time.Second }, wantErr: "idle_timeout"},
+		{name: "negative max sessions", modify: func(c *Config) { c.MaxSessions = -1 }, wantErr: "max_sessions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Code generated by mockery. DO NOT EDIT.
+
+//go:build !compile
+
+package udpedge
+
+import (
+	context "context"
+
+	core "github.com/ksysoev/make-it-public/pkg/core"
+	mock "github.com/stretchr/testify/mock"
+
+	net "net"
+
+	token "github.com/ksysoev/make-it-public/pkg/core/token"
+)
+
+// MockConnService is an autogenerated mock type for the ConnService type
+type MockConnService struct {
+	mock.Mock
+}
+
+type MockConnService_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockConnService) EXPECT() *MockConnService_Expecter {
+	return &MockConnService_Expecter{mock: &_m.Mock}
+}
+
+// GetIPFilter provides a mock function with given fields: ctx, keyID
+func (_m *MockConnService) GetIPFilter(ctx context.Context, keyID string) (*token.IPFilter, error) {
+	ret := _m.Called(ctx, keyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIPFilter")
+	}
+
+	var r0 *token.IPFilter
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*token.IPFilter, error)); ok {
+		return rf(ctx, keyID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *token.IPFilter); ok {
+		r0 = rf(ctx, keyID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*token.IPFilter)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, keyID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockConnService_GetIPFilter_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetIPFilter'
+type MockConnService_GetIPFilter_Call struct {
+	*mock.Call
+}
+
+// GetIPFilter is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+func (_e *MockConnService_Expecter) GetIPFilter(ctx interface{}, keyID interface{}) *MockConnService_GetIPFilter_Call {
+	return &MockConnService_GetIPFilter_Call{Call: _e.mock.On("GetIPFilter", ctx, keyID)}
+}
+
+func (_c *MockConnService_GetIPFilter_Call) Run(run func(ctx context.Context, keyID string)) *MockConnService_GetIPFilter_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_GetIPFilter_Call) Return(_a0 *token.IPFilter, _a1 error) *MockConnService_GetIPFilter_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnService_GetIPFilter_Call) RunAndReturn(run func(context.Context, string) (*token.IPFilter, error)) *MockConnService_GetIPFilter_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// HandleUDPConnection provides a mock function with given fields: ctx, keyID, conn, clientIP
+func (_m *MockConnService) HandleUDPConnection(ctx context.Context, keyID string, conn net.Conn, clientIP string) error {
+	ret := _m.Called(ctx, keyID, conn, clientIP)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HandleUDPConnection")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, net.Conn, string) error); ok {
+		r0 = rf(ctx, keyID, conn, clientIP)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockConnService_HandleUDPConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'HandleUDPConnection'
+type MockConnService_HandleUDPConnection_Call struct {
+	*mock.Call
+}
+
+// HandleUDPConnection is a helper method to define mock.On call
+//   - ctx context.Context
+//   - keyID string
+//   - conn net.Conn
+//   - clientIP string
+func (_e *MockConnService_Expecter) HandleUDPConnection(ctx interface{}, keyID interface{}, conn interface{}, clientIP interface{}) *MockConnService_HandleUDPConnection_Call {
+	return &MockConnService_HandleUDPConnection_Call{Call: _e.mock.On("HandleUDPConnection", ctx, keyID, conn, clientIP)}
+}
+
+func (_c *MockConnService_HandleUDPConnection_Call) Run(run func(ctx context.Context, keyID string, conn net.Conn, clientIP string)) *MockConnService_HandleUDPConnection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(net.Conn), args[3].(string))
+	})
+	return _c
+}
+
+func (_c *MockConnService_HandleUDPConnection_Call) Return(_a0 error) *MockConnService_HandleUDPConnection_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockConnService_HandleUDPConnection_Call) RunAndReturn(run func(context.Context, string, net.Conn, string) error) *MockConnService_HandleUDPConnection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetUDPEndpointAllocator provides a mock function with given fields: allocator
+func (_m *MockConnService) SetUDPEndpointAllocator(allocator core.UDPEndpointAllocator) {
+	_m.Called(allocator)
+}
+
+// MockConnService_SetUDPEndpointAllocator_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetUDPEndpointAllocator'
+type MockConnService_SetUDPEndpointAllocator_Call struct {
+	*mock.Call
+}
+
+// SetUDPEndpointAllocator is a helper method to define mock.On call
+//   - allocator core.UDPEndpointAllocator
+func (_e *MockConnService_Expecter) SetUDPEndpointAllocator(allocator interface{}) *MockConnService_SetUDPEndpointAllocator_Call {
+	return &MockConnService_SetUDPEndpointAllocator_Call{Call: _e.mock.On("SetUDPEndpointAllocator", allocator)}
+}
+
+func (_c *MockConnService_SetUDPEndpointAllocator_Call) Run(run func(allocator core.UDPEndpointAllocator)) *MockConnService_SetUDPEndpointAllocator_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(core.UDPEndpointAllocator))
+	})
+	return _c
+}
+
+func (_c *MockConnService_SetUDPEndpointAllocator_Call) Return() *MockConnService_SetUDPEndpointAllocator_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockConnService_SetUDPEndpointAllocator_Call) RunAndReturn(run func(core.UDPEndpointAllocator)) *MockConnService_SetUDPEndpointAllocator_Call {
+	_c.Run(run)
+	return _c
+}
+
+// NewMockConnService creates a new instance of MockConnService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockConnService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockConnService {
+	mock := &MockConnService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
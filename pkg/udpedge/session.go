@@ -0,0 +1,127 @@
+package udpedge
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/conn/dgram"
+)
+
+// sessionQueueSize is the number of datagrams from the peer buffered while the tunnel is busy.
+// Further datagrams are dropped, like on a congested network.
+const sessionQueueSize = 64
+
+// session relays the datagrams exchanged with a single end-user peer, identified by its source address.
+// The datagrams are exposed as a stream of dgram frames through one end of a pipe, which is served by
+// core.Service like a TCP connection. The session is closed once it has seen no traffic for idle.
+type session struct {
+	udpConn   *net.UDPConn
+	local     net.Conn
+	remote    net.Conn
+	timer     *time.Timer
+	in        chan []byte
+	done      chan struct{}
+	peer      netip.AddrPort
+	idle      time.Duration
+	closeOnce sync.Once
+}
+
+// newSession creates a session for peer that replies through udpConn.
+func newSession(udpConn *net.UDPConn, peer netip.AddrPort, idle time.Duration) *session {
+	local, remote := net.Pipe()
+
+	s := &session{
+		udpConn: udpConn,
+		local:   local,
+		remote:  &sessionConn{Conn: remote, peer: net.UDPAddrFromAddrPort(peer)},
+		in:      make(chan []byte, sessionQueueSize),
+		done:    make(chan struct{}),
+		peer:    peer,
+		idle:    idle,
+	}
+
+	s.timer = time.AfterFunc(idle, s.close)
+
+	return s
+}
+
+// conn returns the connection carrying the framed datagrams of the session.
+func (s *session) conn() net.Conn {
+	return s.remote
+}
+
+// clientIP returns the IP address of the peer.
+func (s *session) clientIP() string {
+	return s.peer.Addr().Unmap().String()
+}
+
+// deliver queues the datagram p received from the peer. It is dropped if the queue is full.
+func (s *session) deliver(p []byte) {
+	s.touch()
+
+	select {
+	case s.in <- p:
+	default:
+	}
+}
+
+// touch postpones the idle expiry of the session.
+func (s *session) touch() {
+	s.timer.Reset(s.idle)
+}
+
+// relay frames the queued datagrams onto the pipe and sends the frames written to the pipe back to the peer,
+// until the session is closed.
+func (s *session) relay() {
+	go func() {
+		for {
+			select {
+			case p := <-s.in:
+				if err := dgram.WriteFrame(s.local, p); err != nil {
+					s.close()
+					return
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, dgram.MaxSize)
+
+	for {
+		n, err := dgram.ReadFrame(s.local, buf)
+		if err != nil {
+			s.close()
+			return
+		}
+
+		s.touch()
+
+		if _, err := s.udpConn.WriteToUDPAddrPort(buf[:n], s.peer); err != nil {
+			s.close()
+			return
+		}
+	}
+}
+
+// close ends the session, the connection returned by conn reaches EOF. It is safe to call close more than once.
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		_ = s.local.Close()
+	})
+}
+
+// sessionConn is the pipe end handed to core.Service, it reports the address of the peer as its remote address.
+type sessionConn struct {
+	net.Conn
+	peer *net.UDPAddr
+}
+
+func (c *sessionConn) RemoteAddr() net.Addr {
+	return c.peer
+}
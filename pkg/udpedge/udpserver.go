@@ -0,0 +1,378 @@
+package udpedge
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core"
+	"github.com/ksysoev/make-it-public/pkg/core/conn/dgram"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/ksysoev/make-it-public/pkg/metrics"
+)
+
+// ConnService is the subset of core.Service required by the UDP edge server.
+type ConnService interface {
+	HandleUDPConnection(ctx context.Context, keyID string, conn net.Conn, clientIP string) error
+	GetIPFilter(ctx context.Context, keyID string) (*token.IPFilter, error)
+	SetUDPEndpointAllocator(allocator core.UDPEndpointAllocator)
+}
+
+// filterRefreshInterval is how long the IP filter of a tunnel is used to admit new peers before it is reloaded.
+const filterRefreshInterval = 10 * time.Second
+
+// activeListener tracks a bound per-keyID UDP port and the sessions of its peers.
+//
+// filter is the IP filter of the tunnel loaded at filterLoaded, it is only
+// used by readLoop to drop the datagrams of rejected peers.
+//
+// refs counts the MIT clients sharing the port, like for TCP listeners the
+// port is released when the last one leaves.
+//
+// readWG tracks the single readLoop goroutine; handlerWG tracks the
+// per-session goroutines.  readLoop is joined first so that no handlerWG.Add(1)
+// call can race with handlerWG.Wait().
+type activeListener struct {
+	filterLoaded time.Time
+	cancel       context.CancelFunc
+	conn         *net.UDPConn
+	sessions     map[netip.AddrPort]*session
+	filter       *token.IPFilter
+	endpoint     string
+	readWG       sync.WaitGroup
+	handlerWG    sync.WaitGroup
+	port         int
+	refs         int
+	mu           sync.Mutex // guards sessions
+	stopped      atomic.Bool
+}
+
+// UDPServer dynamically binds UDP ports for each connected MIT client that
+// authenticated with a UDP token.  It implements core.UDPEndpointAllocator.
+// Each source address sending datagrams to a port is served as a session with
+// its own reverse connection to the MIT client.
+type UDPServer struct {
+	connService ConnService
+	portPool    *portPool
+	listeners   map[string]*activeListener
+	config      Config
+	mu          sync.RWMutex
+}
+
+// New validates cfg, creates a UDPServer, and injects it as the
+// UDPEndpointAllocator into connService.
+func New(cfg Config, connService ConnService) (*UDPServer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid UDP edge config: %w", err)
+	}
+
+	cfg.IdleTimeout = cmp.Or(cfg.IdleTimeout, DefaultIdleTimeout)
+	cfg.MaxSessions = cmp.Or(cfg.MaxSessions, DefaultMaxSessions)
+
+	s := &UDPServer{
+		connService: connService,
+		portPool:    newPortPool(cfg.PortRange.Min, cfg.PortRange.Max),
+		listeners:   make(map[string]*activeListener),
+		config:      cfg,
+	}
+
+	connService.SetUDPEndpointAllocator(s)
+
+	return s, nil
+}
+
+// Run blocks until ctx is cancelled, then unbinds all ports.
+func (s *UDPServer) Run(ctx context.Context) error {
+	<-ctx.Done()
+	s.closeAllListeners()
+
+	return nil
+}
+
+// StopAccepting stops creating sessions for new peers on all allocated ports,
+// while the sessions already in progress keep being served.
+// The ports stay allocated until they are released or the server stops.
+func (s *UDPServer) StopAccepting() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, al := range s.listeners {
+		al.stopped.Store(true)
+	}
+}
+
+// Allocate binds a UDP port for keyID and returns the public endpoint string
+// in the form "host:port".
+//
+// Allocate is called by core.Service when a UDP MIT client completes
+// authentication (StateRegistered).  It must be balanced by a call to Release.
+// If keyID already has a bound port, it is shared with the new client.
+func (s *UDPServer) Allocate(ctx context.Context, keyID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if al, exists := s.listeners[keyID]; exists {
+		al.refs++
+
+		slog.DebugContext(ctx, "UDP port shared",
+			slog.String("keyID", keyID),
+			slog.String("endpoint", al.endpoint),
+			slog.Int("clients", al.refs))
+
+		return al.endpoint, nil
+	}
+
+	port, err := s.portPool.Allocate()
+	if err != nil {
+		return "", fmt.Errorf("allocate port for keyID=%s: %w", keyID, err)
+	}
+
+	addr := net.JoinHostPort(s.config.ListenHost, strconv.Itoa(port))
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		s.portPool.Release(port)
+		return "", fmt.Errorf("resolve %s for keyID=%s: %w", addr, keyID, err)
+	}
+
+	udpConn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		s.portPool.Release(port)
+		return "", fmt.Errorf("listen on %s for keyID=%s: %w", addr, keyID, err)
+	}
+
+	// The port may outlive the client that triggered the allocation when
+	// other clients share it, so its lifetime is bound to Release only.
+	listenerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	endpoint := net.JoinHostPort(s.config.Public.Host, strconv.Itoa(port))
+
+	al := &activeListener{
+		conn:     udpConn,
+		sessions: make(map[netip.AddrPort]*session),
+		port:     port,
+		endpoint: endpoint,
+		cancel:   cancel,
+		refs:     1,
+	}
+
+	s.listeners[keyID] = al
+
+	al.readWG.Add(1)
+
+	go func() {
+		defer al.readWG.Done()
+
+		s.readLoop(listenerCtx, al, keyID)
+	}()
+
+	slog.InfoContext(ctx, "UDP port allocated",
+		slog.String("keyID", keyID),
+		slog.String("endpoint", endpoint),
+		slog.String("listen", addr))
+
+	return endpoint, nil
+}
+
+// Release drops one client reference from the port bound for keyID.
+// When the last reference is gone, the sessions are closed, the port is
+// unbound and returned to the pool.  It is safe to call Release on a keyID
+// that has already been released.
+func (s *UDPServer) Release(keyID string) {
+	s.mu.Lock()
+
+	al, exists := s.listeners[keyID]
+	if !exists {
+		s.mu.Unlock()
+		return
+	}
+
+	al.refs--
+	if al.refs > 0 {
+		s.mu.Unlock()
+		return
+	}
+
+	delete(s.listeners, keyID)
+	s.mu.Unlock()
+
+	s.stopListener(keyID, al)
+}
+
+// stopListener closes al and its sessions, waits for its goroutines to finish
+// and returns its port to the pool.  The listener must already be removed from s.listeners.
+func (s *UDPServer) stopListener(keyID string, al *activeListener) {
+	al.cancel()
+
+	_ = al.conn.Close()
+
+	// Join readLoop first: once it exits, no further handlerWG.Add(1) calls
+	// can occur, so handlerWG.Wait() below cannot race with Add.
+	al.readWG.Wait()
+
+	al.mu.Lock()
+	for _, sess := range al.sessions {
+		sess.close()
+	}
+	al.mu.Unlock()
+
+	al.handlerWG.Wait()
+
+	s.portPool.Release(al.port)
+
+	slog.Info("UDP port released", slog.String("keyID", keyID), slog.Int("port", al.port))
+}
+
+// readLoop reads the datagrams sent to a single per-keyID port and dispatches them to the sessions of their peers.
+func (s *UDPServer) readLoop(ctx context.Context, al *activeListener, keyID string) {
+	buf := make([]byte, dgram.MaxSize)
+
+	for {
+		n, peer, err := al.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return // normal shutdown via Release or server stop
+			}
+
+			slog.ErrorContext(ctx, "UDP read error",
+				slog.String("keyID", keyID),
+				slog.Any("error", err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+				// retry on transient error
+			}
+
+			continue
+		}
+
+		sess := s.session(ctx, al, keyID, peer)
+		if sess == nil {
+			continue
+		}
+
+		sess.deliver(bytes.Clone(buf[:n]))
+	}
+}
+
+// session returns the session of peer, starting a new one if there is none.
+// Returns nil if the port no longer accepts new peers, the port already serves the maximum number of sessions,
+// or the IP filter of the tunnel rejects peer, the datagram is then dropped.
+func (s *UDPServer) session(ctx context.Context, al *activeListener, keyID string, peer netip.AddrPort) *session {
+	al.mu.Lock()
+	sess, ok := al.sessions[peer]
+	al.mu.Unlock()
+
+	if ok {
+		return sess
+	}
+
+	if al.stopped.Load() || !s.allows(ctx, al, keyID, peer) {
+		return nil
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if len(al.sessions) >= s.config.MaxSessions {
+		slog.DebugContext(ctx, "UDP datagram dropped, too many sessions",
+			slog.String("keyID", keyID),
+			slog.Int("sessions", len(al.sessions)))
+
+		return nil
+	}
+
+	sess = newSession(al.conn, peer, s.config.IdleTimeout)
+	al.sessions[peer] = sess
+
+	al.handlerWG.Add(1)
+
+	go func() {
+		defer al.handlerWG.Done()
+
+		s.serveSession(ctx, al, keyID, sess)
+	}()
+
+	return sess
+}
+
+// allows reports whether the IP filter of the tunnel accepts the datagrams of peer.
+// The filter is reloaded every filterRefreshInterval, peers are rejected while it cannot be loaded.
+// It must only be called by the readLoop of al.
+func (s *UDPServer) allows(ctx context.Context, al *activeListener, keyID string, peer netip.AddrPort) bool {
+	if time.Since(al.filterLoaded) >= filterRefreshInterval {
+		filter, err := s.connService.GetIPFilter(ctx, keyID)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to get UDP tunnel IP filter",
+				slog.String("keyID", keyID),
+				slog.Any("error", err))
+
+			return false
+		}
+
+		al.filter = filter
+		al.filterLoaded = time.Now()
+	}
+
+	if al.filter != nil && !al.filter.Allows(peer.Addr().Unmap().String()) {
+		slog.DebugContext(ctx, "UDP datagram rejected by IP filter",
+			slog.String("keyID", keyID),
+			slog.String("clientIP", peer.Addr().Unmap().String()))
+
+		return false
+	}
+
+	return true
+}
+
+// serveSession routes a single end-user session through the tunnel.
+func (s *UDPServer) serveSession(ctx context.Context, al *activeListener, keyID string, sess *session) {
+	metrics.UDPSessions.Inc()
+
+	defer func() {
+		al.mu.Lock()
+		delete(al.sessions, sess.peer)
+		al.mu.Unlock()
+
+		sess.close()
+		metrics.UDPSessions.Dec()
+	}()
+
+	clientIP := sess.clientIP()
+
+	slog.DebugContext(ctx, "UDP end-user session",
+		slog.String("keyID", keyID),
+		slog.String("clientIP", clientIP))
+
+	go sess.relay()
+
+	if err := s.connService.HandleUDPConnection(ctx, keyID, sess.conn(), clientIP); err != nil {
+		slog.DebugContext(ctx, "UDP session closed",
+			slog.String("keyID", keyID),
+			slog.String("clientIP", clientIP),
+			slog.Any("error", err))
+	}
+}
+
+// closeAllListeners unbinds every port regardless of how many clients share
+// it.  Called on server stop.
+func (s *UDPServer) closeAllListeners() {
+	s.mu.Lock()
+	listeners := s.listeners
+	s.listeners = make(map[string]*activeListener)
+	s.mu.Unlock()
+
+	for keyID, al := range listeners {
+		s.stopListener(keyID, al)
+	}
+}
@@ -0,0 +1,421 @@
+package udpedge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/make-it-public/pkg/core/conn/dgram"
+	"github.com/ksysoev/make-it-public/pkg/core/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// findFreePort asks the OS for an available UDP port and returns it.
+// The port is briefly bound then released; there is a small TOCTOU window,
+// but it is far safer than hard-coding a port that may be in use on CI.
+func findFreePort(t *testing.T) int {
+	t.Helper()
+
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr, ok := c.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok, "expected UDP address")
+
+	port := addr.Port
+
+	require.NoError(t, c.Close())
+
+	return port
+}
+
+// validConfig returns a Config that passes Validate().
+func validConfig(t *testing.T) Config {
+	t.Helper()
+
+	base := findFreePort(t)
+
+	return Config{
+		ListenHost: "127.0.0.1",
+		Public:     PublicConfig{Host: "example.com"},
+		PortRange:  PortRange{Min: base, Max: base + 10},
+	}
+}
+
+// dialEndpoint connects an end-user UDP socket to the local port of endpoint.
+func dialEndpoint(t *testing.T, endpoint string) *net.UDPConn {
+	t.Helper()
+
+	_, portStr, err := net.SplitHostPort(endpoint)
+	require.NoError(t, err)
+
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort("127.0.0.1", portStr))
+	require.NoError(t, err)
+
+	c, err := net.DialUDP("udp", nil, addr)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+// sessionCount returns the number of sessions served on the port of keyID.
+func sessionCount(srv *UDPServer, keyID string) int {
+	srv.mu.RLock()
+	al, ok := srv.listeners[keyID]
+	srv.mu.RUnlock()
+
+	if !ok {
+		return 0
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	return len(al.sessions)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	svc := NewMockConnService(t)
+
+	cfg := Config{} // missing required fields
+
+	_, err := New(cfg, svc)
+	assert.Error(t, err)
+}
+
+func TestNew_InjectsAllocator(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+
+	srv, err := New(validConfig(t), svc)
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultIdleTimeout, srv.config.IdleTimeout)
+}
+
+func TestUDPServer_Run_Shutdown(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+
+	srv, err := New(validConfig(t), svc)
+	require.NoError(t, err)
+
+	_, err = srv.Allocate(context.Background(), "runkey")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+
+	go func() { done <- srv.Run(ctx) }()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after context cancel")
+	}
+
+	assert.Equal(t, 11, srv.portPool.Available())
+}
+
+func TestUDPServer_Allocate_SharedKeyID(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+
+	srv, err := New(validConfig(t), svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	initial := srv.portPool.Available()
+
+	first, err := srv.Allocate(context.Background(), "dup")
+	require.NoError(t, err)
+
+	host, _, err := net.SplitHostPort(first)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", host)
+
+	second, err := srv.Allocate(context.Background(), "dup")
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, initial-1, srv.portPool.Available())
+
+	// The port stays bound until the last client releases it.
+	srv.Release("dup")
+	assert.Equal(t, initial-1, srv.portPool.Available())
+
+	srv.Release("dup")
+	assert.Equal(t, initial, srv.portPool.Available())
+
+	// Releasing a key that is no longer allocated is a no-op.
+	assert.NotPanics(t, func() { srv.Release("dup") })
+}
+
+func TestUDPServer_PortExhausted(t *testing.T) {
+	port := findFreePort(t)
+	cfg := Config{
+		ListenHost: "127.0.0.1",
+		Public:     PublicConfig{Host: "example.com"},
+		PortRange:  PortRange{Min: port, Max: port},
+	}
+
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+
+	srv, err := New(cfg, svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	_, err = srv.Allocate(context.Background(), "key1")
+	require.NoError(t, err)
+
+	_, err = srv.Allocate(context.Background(), "key2")
+	assert.ErrorIs(t, err, ErrPortPoolExhausted)
+}
+
+func TestUDPServer_RoutesSession(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+	svc.EXPECT().GetIPFilter(mock.Anything, "echokey").Return(nil, nil)
+
+	sessionDone := make(chan struct{})
+
+	// The tunnel echoes every datagram back to the end-user.
+	svc.EXPECT().
+		HandleUDPConnection(mock.Anything, "echokey", mock.Anything, "127.0.0.1").
+		RunAndReturn(func(_ context.Context, _ string, conn net.Conn, _ string) error {
+			defer close(sessionDone)
+
+			buf := make([]byte, dgram.MaxSize)
+
+			for {
+				n, err := dgram.ReadFrame(conn, buf)
+				if err != nil {
+					return err
+				}
+
+				if err := dgram.WriteFrame(conn, buf[:n]); err != nil {
+					return err
+				}
+			}
+		}).Once()
+
+	cfg := validConfig(t)
+	cfg.IdleTimeout = 200 * time.Millisecond
+
+	srv, err := New(cfg, svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	endpoint, err := srv.Allocate(context.Background(), "echokey")
+	require.NoError(t, err)
+
+	c := dialEndpoint(t, endpoint)
+	buf := make([]byte, 64)
+
+	for _, msg := range []string{"ping", "pong"} {
+		_, err = c.Write([]byte(msg))
+		require.NoError(t, err)
+
+		require.NoError(t, c.SetReadDeadline(time.Now().Add(2*time.Second)))
+
+		n, err := c.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(buf[:n]))
+	}
+
+	// The session is closed once it has been idle for the configured timeout.
+	select {
+	case <-sessionDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle session was not closed")
+	}
+
+	assert.Eventually(t, func() bool { return sessionCount(srv, "echokey") == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestUDPServer_RejectsFilteredIP(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+
+	filter, err := token.NewIPFilter(nil, []string{"127.0.0.0/8"})
+	require.NoError(t, err)
+
+	svc.EXPECT().GetIPFilter(mock.Anything, "filtered").Return(filter, nil).Once()
+
+	cfg := validConfig(t)
+	cfg.IdleTimeout = 200 * time.Millisecond
+
+	srv, err := New(cfg, svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	endpoint, err := srv.Allocate(context.Background(), "filtered")
+	require.NoError(t, err)
+
+	c := dialEndpoint(t, endpoint)
+
+	// The datagrams of a rejected peer are dropped without starting a session, HandleUDPConnection is not expected
+	// and the filter is loaded once.
+	for range 3 {
+		_, err = c.Write([]byte("ping"))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(300*time.Millisecond)))
+
+	_, err = c.Read(make([]byte, 64))
+
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr) && netErr.Timeout(), "expected read timeout, got %v", err)
+
+	assert.Zero(t, sessionCount(srv, "filtered"))
+}
+
+func TestUDPServer_MaxSessions(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+	svc.EXPECT().GetIPFilter(mock.Anything, "busy").Return(nil, nil).Once()
+
+	started := make(chan struct{})
+
+	svc.EXPECT().
+		HandleUDPConnection(mock.Anything, "busy", mock.Anything, "127.0.0.1").
+		RunAndReturn(func(_ context.Context, _ string, conn net.Conn, _ string) error {
+			close(started)
+
+			_, err := io.Copy(io.Discard, conn)
+
+			return err
+		}).Once()
+
+	cfg := validConfig(t)
+	cfg.MaxSessions = 1
+
+	srv, err := New(cfg, svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	endpoint, err := srv.Allocate(context.Background(), "busy")
+	require.NoError(t, err)
+
+	_, err = dialEndpoint(t, endpoint).Write([]byte("ping"))
+	require.NoError(t, err)
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("HandleUDPConnection was not called within timeout")
+	}
+
+	// The port serves a single session, the datagrams of another peer are dropped.
+	_, err = dialEndpoint(t, endpoint).Write([]byte("ping"))
+	require.NoError(t, err)
+
+	assert.Never(t, func() bool { return sessionCount(srv, "busy") > 1 }, 200*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestUDPServer_ReleaseClosesSessions(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+	svc.EXPECT().GetIPFilter(mock.Anything, "closekey").Return(nil, nil)
+
+	started := make(chan struct{})
+
+	svc.EXPECT().
+		HandleUDPConnection(mock.Anything, "closekey", mock.Anything, "127.0.0.1").
+		RunAndReturn(func(_ context.Context, _ string, conn net.Conn, _ string) error {
+			close(started)
+
+			_, err := io.Copy(io.Discard, conn)
+
+			return err
+		}).Once()
+
+	srv, err := New(validConfig(t), svc)
+	require.NoError(t, err)
+
+	endpoint, err := srv.Allocate(context.Background(), "closekey")
+	require.NoError(t, err)
+
+	c := dialEndpoint(t, endpoint)
+
+	_, err = c.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("HandleUDPConnection was not called within timeout")
+	}
+
+	released := make(chan struct{})
+
+	go func() {
+		srv.Release("closekey")
+		close(released)
+	}()
+
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Release did not wait for the session to be closed")
+	}
+
+	assert.Equal(t, 11, srv.portPool.Available())
+}
+
+func TestUDPServer_StopAccepting(t *testing.T) {
+	svc := NewMockConnService(t)
+	svc.EXPECT().SetUDPEndpointAllocator(mock.Anything)
+
+	srv, err := New(validConfig(t), svc)
+	require.NoError(t, err)
+
+	defer srv.closeAllListeners()
+
+	keys := []string{"drain1", "drain2"}
+
+	for _, keyID := range keys {
+		_, err = srv.Allocate(context.Background(), keyID)
+		require.NoError(t, err)
+	}
+
+	srv.StopAccepting()
+
+	for _, keyID := range keys {
+		srv.mu.RLock()
+		al := srv.listeners[keyID]
+		srv.mu.RUnlock()
+
+		// The endpoint stays allocated, but no session is started for new peers.
+		require.NotNil(t, al, fmt.Sprintf("listener of %s should stay allocated", keyID))
+		assert.True(t, al.stopped.Load())
+
+		c := dialEndpoint(t, al.endpoint)
+
+		_, err = c.Write([]byte("ping"))
+		require.NoError(t, err)
+	}
+
+	assert.Never(t, func() bool {
+		return sessionCount(srv, "drain1")+sessionCount(srv, "drain2") > 0
+	}, 200*time.Millisecond, 10*time.Millisecond)
+}